@@ -0,0 +1,159 @@
+//go:build js && wasm
+
+// Command mbdvr-wasm is the WebAssembly build of the loader, cleaner and stats, for running analyses of
+// small files in the browser without a server. It registers a global mbdvr object:
+//
+//	await mbdvr.load(text, options)  -> {columns, points, participants, conditions, warnings}
+//	await mbdvr.clean(text, options) -> {output, stats}
+//	await mbdvr.stats(text, options) -> {report, tables}
+//	mbdvr.version()                  -> string
+//
+// text is delimited text (CSV, TSV or semicolon-separated). options is an object or JSON string with the
+// fields of request; each section takes the Go field names of its config, e.g.
+// {Name: "P1_task.csv", Clean: {RequiredColumns: ["gaze_x", "gaze_y"], RemoveOutliers: true}}.
+// The calls return promises, which reject with an Error when the call fails.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall/js"
+
+	"mbdvr/internal/cleaner"
+	"mbdvr/internal/loader"
+	"mbdvr/internal/stats"
+	"mbdvr/internal/types"
+	"mbdvr/internal/version"
+)
+
+// request holds the options of every call
+type request struct {
+	Name   string // Stands in for the file name, e.g. "P1_task.csv" for participant P1
+	Loader loader.Loader
+	Clean  cleaner.CleanConfig
+	Stats  stats.StatsConfig
+	Format stats.FormatOptions // Tables returned by stats
+}
+
+func main() {
+	api := js.Global().Get("Object").New()
+	api.Set("load", handler(load))
+	api.Set("clean", handler(clean))
+	api.Set("stats", handler(computeStats))
+	api.Set("version", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return version.Current()
+	}))
+	js.Global().Set("mbdvr", api)
+
+	// Keep the functions registered
+	select {}
+}
+
+// handler adapts a call to JavaScript: it parses the arguments, loads the text and resolves the returned
+// promise with the result as a plain object. Calls run in their own goroutine, as printing the progress
+// messages may wait on JavaScript.
+func handler(call func(dataset *types.Dataset, req request) (map[string]interface{}, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return promise(func() (map[string]interface{}, error) {
+			if len(args) < 1 || args[0].Type() != js.TypeString {
+				return nil, fmt.Errorf("the first argument must be the file contents as a string")
+			}
+			req := request{Name: "data.csv"}
+			if len(args) > 1 && args[1].Truthy() {
+				options := args[1]
+				if options.Type() != js.TypeString {
+					options = js.Global().Get("JSON").Call("stringify", options)
+				}
+				if err := json.Unmarshal([]byte(options.String()), &req); err != nil {
+					return nil, fmt.Errorf("invalid options: %v", err)
+				}
+			}
+			dataset, err := req.Loader.LoadReader(strings.NewReader(args[0].String()), req.Name)
+			if err != nil {
+				return nil, err
+			}
+			return call(dataset, req)
+		})
+	})
+}
+
+// promise runs fn in a goroutine and returns a promise of its result
+func promise(fn func() (map[string]interface{}, error)) js.Value {
+	executor := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			result, err := fn()
+			var data []byte
+			if err == nil {
+				// Through JSON, so nested Go values become plain objects
+				if data, err = json.Marshal(result); err != nil {
+					err = fmt.Errorf("failed to encode the result: %v", err)
+				}
+			}
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(js.Global().Get("JSON").Call("parse", string(data)))
+		}()
+		return nil
+	})
+	defer executor.Release()
+	return js.Global().Get("Promise").New(executor)
+}
+
+func load(dataset *types.Dataset, req request) (map[string]interface{}, error) {
+	participants := make(map[string]bool)
+	conditions := make(map[string]bool)
+	for _, p := range dataset.Points {
+		participants[p.ParticipantID] = true
+		if p.Condition != "" {
+			conditions[p.Condition] = true
+		}
+	}
+	return map[string]interface{}{
+		"columns":      dataset.Columns,
+		"points":       len(dataset.Points),
+		"participants": sortedKeys(participants),
+		"conditions":   sortedKeys(conditions),
+		"warnings":     dataset.Metadata["unit_warnings"],
+	}, nil
+}
+
+func clean(dataset *types.Dataset, req request) (map[string]interface{}, error) {
+	if req.Clean.CacheDir != "" {
+		return nil, fmt.Errorf("stage caches need a file system and are not supported in the browser")
+	}
+	cleaned, cleanStats, err := cleaner.CleanDataset(dataset, req.Clean)
+	if err != nil {
+		return nil, err
+	}
+	var output strings.Builder
+	if err := loader.WriteCSV(cleaned, &output); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"output": output.String(), "stats": cleanStats}, nil
+}
+
+func computeStats(dataset *types.Dataset, req request) (map[string]interface{}, error) {
+	report, err := stats.ComputeStats(dataset, req.Stats)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := report.Format(req.Format)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"report": report.String(), "tables": tables}, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
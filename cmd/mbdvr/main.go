@@ -1,32 +1,58 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"mbdvr/internal/cleaner"
 	"mbdvr/internal/clipper"
+	"mbdvr/internal/diag"
+	"mbdvr/internal/doctor"
+	"mbdvr/internal/events"
+	"mbdvr/internal/export"
+	"mbdvr/internal/expr"
+	"mbdvr/internal/gaze"
 	"mbdvr/internal/loader"
+	"mbdvr/internal/repl"
 	"mbdvr/internal/replay"
+	"mbdvr/internal/roles"
 	"mbdvr/internal/stats"
+	"mbdvr/internal/transform"
 	"mbdvr/internal/types"
+	"mbdvr/internal/usage"
+	"mbdvr/internal/version"
 )
 
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("Usage: mbdvr <command> [options]")
-		fmt.Println("Commands: load | stats | replay | clean | clip")
+		fmt.Println("Commands: load | info | stats | replay | clean | clip | transform | resample | classify | microsaccades | calibration | detect | derive | export | repl | doctor | version | usage")
 		os.Exit(1)
 	}
 
 	command := os.Args[1]
 
+	switch command {
+	case "load", "stats", "clean", "clip", "transform", "resample", "classify", "microsaccades", "calibration", "detect", "derive", "export":
+		warnPinnedVersion()
+	}
+
+	started := time.Now()
+
 	switch command {
 	case "load":
 		loadCommand()
+	case "info":
+		infoCommand()
 	case "stats":
 		statsCommand()
 	case "replay":
@@ -35,10 +61,36 @@ func main() {
 		cleanCommand()
 	case "clip":
 		clipCommand()
+	case "transform":
+		transformCommand()
+	case "resample":
+		resampleCommand()
+	case "classify":
+		classifyCommand()
+	case "microsaccades":
+		microsaccadesCommand()
+	case "calibration":
+		calibrationCommand()
+	case "detect":
+		detectCommand()
+	case "derive":
+		deriveCommand()
+	case "export":
+		exportCommand()
+	case "repl":
+		replCommand()
+	case "doctor":
+		doctorCommand()
+	case "version":
+		versionCommand()
+	case "usage":
+		usageCommand()
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		os.Exit(1)
 	}
+
+	recordUsage(command, started)
 }
 
 func loadCommand() {
@@ -46,6 +98,19 @@ func loadCommand() {
 	pattern := fs.String("pattern", "", "File pattern to load (e.g. 'Boring*.csv' for 'Boring', '*.csv' for all CSVs) (required)")
 	output := fs.String("output", "", "Name your output CSV file (required)")
 	condition := fs.String("condition", "", "Condition name for the dataset (default: null)")
+	columnNames := fs.String("columns", "", "Comma-separated column names for files without a header row (first column is the timestamp)")
+	noHeader := fs.Bool("no-header", false, "Treat the first row as data and name columns col_0, col_1, ...")
+	skipRows := fs.Int("skip-rows", 0, "Number of metadata rows before the header row (default: auto-detect)")
+	selectColumns := fs.String("select-columns", "", "Comma-separated data columns to keep; all other columns are skipped while parsing (timestamp is always kept)")
+	maxRows := fs.Int("max-rows", 0, "Stop after loading this many rows in total (0 = no limit)")
+	sampleEvery := fs.Int("sample-every", 0, "Keep only every Nth row of each file for a decimated view")
+	dedupe := fs.Bool("dedupe", false, "Sort points by participant and timestamp and drop exact duplicates (e.g. from overlapping exports)")
+	resume := fs.Bool("resume", false, "Write each file to the output as it loads, record progress in <output>.progress and skip files completed by a previous run")
+	preview := fs.Int("preview", 0, "Save a random preview of up to N points per participant instead of the full data, for fast iteration on analysis code")
+	previewSeed := fs.Int64("seed", 1, "Random seed for --preview; the same seed and files give the same preview")
+	previewByCondition := fs.Bool("preview-by-condition", false, "Draw the --preview sample per participant and condition")
+	diagnosticsOutput := fs.String("diagnostics", "", "Save the warnings about the data (units, missing values) as CSV, or JSON with a .json extension")
+	splitRows, splitMB := addSplitFlags(fs)
 
 	fs.Parse(os.Args[2:])
 
@@ -60,31 +125,284 @@ func loadCommand() {
 	fmt.Printf("Output: %s\n", *output)
 	fmt.Printf("Condition: %s\n", *condition)
 
+	previewConfig := loader.PreviewConfig{Size: *preview, Seed: *previewSeed, ByCondition: *previewByCondition}
 	loader := &loader.Loader{
 		Condition: *condition,
+		NoHeader:  *noHeader,
+		Dedupe:    *dedupe,
+		SkipRows:  *skipRows,
+
+		MaxRows:     *maxRows,
+		SampleEvery: *sampleEvery,
+		SplitRows:   *splitRows,
+		SplitMB:     *splitMB,
+
+		Diagnostics: &diag.Collector{},
+	}
+	if *columnNames != "" {
+		loader.ColumnNames = strings.Split(*columnNames, ",")
+		for i := range loader.ColumnNames {
+			loader.ColumnNames[i] = strings.TrimSpace(loader.ColumnNames[i])
+		}
+	}
+	if *selectColumns != "" {
+		loader.SelectColumns = strings.Split(*selectColumns, ",")
+		for i := range loader.SelectColumns {
+			loader.SelectColumns[i] = strings.TrimSpace(loader.SelectColumns[i])
+		}
+	}
+
+	if *preview > 0 && (*resume || *maxRows > 0) {
+		fmt.Println("Error: --preview cannot be combined with --resume or --max-rows")
+		os.Exit(1)
+	}
+
+	if *resume {
+		if *dedupe || *splitRows > 0 || *splitMB > 0 {
+			fmt.Println("Error: --resume cannot be combined with --dedupe, --split-rows or --split-mb")
+			os.Exit(1)
+		}
+		written, err := loader.LoadFilesResumable(*pattern, *output, true)
+		if err != nil {
+			fmt.Printf("Error loading files: %v\n", err)
+			os.Exit(1)
+		}
+		trackInput(*pattern, written)
+		fmt.Printf("Loaded %d data points\n", written)
+		fmt.Printf("Dataset saved to %s\n", *output)
+		return
 	}
 
-	dataset, err := loader.LoadFiles(*pattern)
+	var dataset *types.Dataset
+	var err error
+	if *preview > 0 {
+		dataset, err = loader.LoadPreview(*pattern, previewConfig)
+	} else {
+		dataset, err = loader.LoadFiles(*pattern)
+	}
 	if err != nil {
 		fmt.Printf("Error loading files: %v\n", err)
 		os.Exit(1)
 	}
+	trackInput(*pattern, len(dataset.Points))
 
+	if info, ok := dataset.Metadata["preview"].(map[string]interface{}); ok {
+		fmt.Printf("Preview: sampled %d of %d data points from %d strata (seed %d)\n",
+			len(dataset.Points), info["source_points"], info["strata"], *previewSeed)
+	}
 	fmt.Printf("Loaded %d data points with %d columns\n",
 		len(dataset.Points), len(dataset.Columns))
+	if len(dataset.Events) > 0 {
+		fmt.Printf("Loaded %d events\n", len(dataset.Events))
+	}
+	if removed, ok := dataset.Metadata["duplicates_removed"].(int); ok {
+		fmt.Printf("Removed %d duplicate points\n", removed)
+	}
 
-	err = loader.SaveDatasetAsCSV(dataset, *output)
+	err = loader.SaveDataset(dataset, *output)
 	if err != nil {
 		fmt.Printf("Error saving dataset: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Dataset saved to %s\n", *output)
+	printDiagnostics(loader.Diagnostics)
+	saveDiagnostics(loader.Diagnostics, *diagnosticsOutput)
+}
+
+// addSplitFlags registers the output partitioning options shared by all saving commands
+func addSplitFlags(fs *flag.FlagSet) (*int, *float64) {
+	splitRows := fs.Int("split-rows", 0, "Split CSV output into numbered files of at most this many rows (e.g. for Excel)")
+	splitMB := fs.Float64("split-mb", 0, "Split CSV output into numbered files of at most this many MB")
+	return splitRows, splitMB
+}
+
+// addRolesFlag registers the column role overrides shared by the commands that infer their input columns
+func addRolesFlag(fs *flag.FlagSet) *string {
+	return fs.String("roles", "", "Comma-separated column role overrides as column=role, e.g. 'ET_PupilL=left_pupil,aux_x=none' (on top of the study manifest's column_roles)")
+}
+
+// columnRoles infers the roles of the dataset's columns, with the study manifest's column_roles and then
+// the --roles overrides replacing the inferred ones
+func columnRoles(dataset *types.Dataset, spec string) *roles.Roles {
+	inferred, err := roles.Infer(dataset, roleOverrides(dataset, spec))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return inferred
+}
+
+// roleOverrides combines the study manifest's column_roles with the --roles overrides, which must name
+// columns of the dataset when one is given
+func roleOverrides(dataset *types.Dataset, spec string) map[string]string {
+	overrides := make(map[string]string)
+	if path, err := version.FindManifest("."); err == nil && path != "" {
+		manifest, err := version.LoadManifest(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for col, role := range manifest.ColumnRoles {
+			overrides[col] = role
+		}
+	}
+	if spec != "" {
+		for _, item := range strings.Split(spec, ",") {
+			col, role, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok || col == "" {
+				fmt.Printf("Error: invalid role override %q (use column=role)\n", item)
+				os.Exit(1)
+			}
+			if dataset != nil && !hasColumn(dataset, col) {
+				fmt.Printf("Error: role override for unknown column %s\n", col)
+				os.Exit(1)
+			}
+			overrides[col] = role
+		}
+	}
+	return overrides
+}
+
+func hasColumn(dataset *types.Dataset, col string) bool {
+	for _, c := range dataset.Columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+func infoCommand() {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	input := fs.String("input", "", "Input CSV file to inspect (required)")
+	rolesSpec := addRolesFlag(fs)
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{Diagnostics: &diag.Collector{}}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+
+	participants := make(map[string]int)
+	conditions := make(map[string]int)
+	minTime, maxTime := math.Inf(1), math.Inf(-1)
+	for _, point := range dataset.Points {
+		participants[point.ParticipantID]++
+		conditions[point.Condition]++
+		minTime = math.Min(minTime, point.Timestamp)
+		maxTime = math.Max(maxTime, point.Timestamp)
+	}
+
+	fmt.Printf("Points: %d\n", len(dataset.Points))
+	fmt.Printf("Columns: %s\n", strings.Join(dataset.Columns, ", "))
+	fmt.Printf("Participants: %d\n", len(participants))
+	fmt.Printf("Conditions: %d\n", len(conditions))
+	if len(dataset.Points) > 0 {
+		fmt.Printf("Time range: %.3f to %.3f\n", minTime, maxTime)
+	}
+	if inferred := columnRoles(dataset, *rolesSpec); len(inferred.Columns) > 0 {
+		fmt.Println("Column roles:")
+		for _, c := range inferred.Columns {
+			fmt.Printf("  %s: %s (from %s)\n", c.Name, c.Spec(), c.Source)
+		}
+	} else {
+		fmt.Println("Column roles: none recognized")
+	}
+
+	if !printDiagnostics(loader.Diagnostics) {
+		fmt.Println("No data problems detected")
+	}
+}
+
+// printDiagnostics prints the collected warnings as one section at the end of a command's output
+func printDiagnostics(diagnostics *diag.Collector) bool {
+	if diagnostics.Len() == 0 {
+		return false
+	}
+	fmt.Printf("\n%s", diag.Format(diagnostics.Diagnostics()))
+	return true
+}
+
+// saveDiagnostics writes the collected warnings to filename, when one is given
+func saveDiagnostics(diagnostics *diag.Collector, filename string) {
+	if filename == "" {
+		return
+	}
+	if err := diag.Save(diagnostics.Diagnostics(), filename); err != nil {
+		fmt.Printf("Error saving diagnostics: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Diagnostics saved to %s\n", filename)
+}
+
+func doctorCommand() {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	input := fs.String("input", "", "Data file to examine (required; may also be given as the first argument)")
+	rolesSpec := addRolesFlag(fs)
+
+	args := os.Args[2:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		*input, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+
+	if *input == "" {
+		fs.Usage()
+		fmt.Printf("Input is a required field.\n")
+		fmt.Printf("Sample usage: mbdvr doctor P01_task.csv\n")
+		os.Exit(1)
+	}
+
+	report := doctor.Examine(*input, roleOverrides(nil, *rolesSpec))
+	trackInput(*input, report.Points)
+
+	fmt.Println()
+	if report.Points > 0 {
+		fmt.Printf("%s: %d points, %d data columns, participants: %d", report.File, report.Points, len(report.Columns), report.Participants)
+		if report.SampleRate > 0 {
+			fmt.Printf(", sample rate: ~%.3g Hz", report.SampleRate)
+		}
+		fmt.Println()
+	}
+	if len(report.Findings) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+
+	fmt.Printf("Findings (%d, most important first):\n", len(report.Findings))
+	for i, f := range report.Findings {
+		fmt.Printf("%2d. [%s %s] %s\n", i+1, f.Severity, f.Check, f.Message)
+		if f.Fix != "" {
+			fmt.Printf("    Fix: %s\n", f.Fix)
+		}
+	}
+	if len(report.LoadOptions) > 0 {
+		fmt.Printf("\nLoad this file with: %s\n", strings.Join(report.LoadOptions, " "))
+	}
+	if n := report.Problems(); n > 0 {
+		fmt.Printf("\n%d problems must be fixed before analyzing this file\n", n)
+		os.Exit(1)
+	}
 }
 
 func replayCommand() {
 	fs := flag.NewFlagSet("replay", flag.ExitOnError)
 	input := fs.String("input", "", "Input CSV file to replay (required)")
+	maxRows := fs.Int("max-rows", 0, "Stop after loading this many rows (0 = no limit)")
+	sampleEvery := fs.Int("sample-every", 0, "Replay only every Nth row")
+	bookmarkFile := fs.String("bookmarks", "", "Bookmark sidecar file (default: <input>.bookmarks.json)")
+	author := fs.String("author", os.Getenv("USER"), "Name recorded with new bookmarks")
+	listBookmarks := fs.Bool("list-bookmarks", false, "Print the saved bookmarks and exit without opening the replay window")
 
 	fs.Parse(os.Args[2:])
 
@@ -93,14 +411,78 @@ func replayCommand() {
 		os.Exit(1)
 	}
 
-	loader := &loader.Loader{}
+	if *bookmarkFile == "" {
+		*bookmarkFile = replay.BookmarkPath(*input)
+	}
+	if *listBookmarks {
+		bookmarks, err := replay.LoadBookmarks(*bookmarkFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(bookmarks) == 0 {
+			fmt.Printf("No bookmarks in %s\n", *bookmarkFile)
+		}
+		for _, b := range bookmarks {
+			fmt.Printf("%s  %s", replay.FormatTime(b.Time), b.Label)
+			if b.Author != "" {
+				fmt.Printf(" (%s, %s)", b.Author, b.Created.Format("2006-01-02"))
+			}
+			fmt.Println()
+			if b.Note != "" {
+				fmt.Printf("    %s\n", strings.ReplaceAll(b.Note, "\n", "\n    "))
+			}
+		}
+		return
+	}
+	if !replay.UIAvailable {
+		fmt.Println("Error: this build of mbdvr has no replay window (built with -tags headless); --list-bookmarks still works")
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{MaxRows: *maxRows, SampleEvery: *sampleEvery}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+
+	replay.StartUI(dataset, 1.0, *bookmarkFile, *author)
+}
+
+func replCommand() {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	input := fs.String("input", "", "Dataset to explore (required; may also be given as the first argument)")
+	maxRows := fs.Int("max-rows", 0, "Stop after loading this many rows (0 = no limit)")
+	sampleEvery := fs.Int("sample-every", 0, "Load only every Nth row")
+
+	args := os.Args[2:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		*input, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+
+	if *input == "" {
+		fs.Usage()
+		fmt.Printf("Input is a required field.\n")
+		fmt.Printf("Sample usage: mbdvr repl data.csv\n")
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{MaxRows: *maxRows, SampleEvery: *sampleEvery}
 	dataset, err := loader.LoadFiles(*input)
 	if err != nil {
 		fmt.Printf("Error loading input file: %v\n", err)
 		os.Exit(1)
 	}
+	trackInput(*input, len(dataset.Points))
 
-	replay.StartUI(dataset, 1.0)
+	fmt.Printf("Loaded %d points. Type help for the commands, quit to leave.\n", len(dataset.Points))
+	if err := repl.New(dataset).Run(os.Stdin, os.Stdout); err != nil {
+		fmt.Printf("Error reading input: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func cleanCommand() {
@@ -112,24 +494,89 @@ func cleanCommand() {
 	outlierMethod := fs.String("outlier-method", "iqr", "Outlier detection method: 'iqr' or 'zscore'")
 	maxMissing := fs.Float64("max-missing", 0.0, "Max % of missing data per row (0-100)")
 	zThreshold := fs.Float64("z-threshold", 3.0, "Z-score threshold for outlier detection")
+	outlierGrouping := fs.String("outlier-grouping", "", "Compute outlier bounds per 'participant' or 'participant-condition' instead of over the whole dataset")
+	outlierWindow := fs.Float64("outlier-window", 0, "Rolling window in seconds for zscore outliers, judged against local context (0 = whole-signal bounds)")
+	outlierAction := fs.String("outlier-action", "remove", "What to do with outliers: 'remove' the rows, 'winsorize' (clamp to the bounds) or 'nan' (mark the values missing)")
+	duplicates := fs.String("duplicates", "", "Remove 'exact' duplicate rows, or also 'near' duplicates within --duplicate-tolerance (default: off)")
+	duplicateTolerance := fs.Float64("duplicate-tolerance", 0.001, "Seconds within which samples of a participant are near-duplicates")
+	duplicateKeep := fs.String("duplicate-keep", "first", "Which near-duplicate to keep: 'first', 'last' or 'average'")
+	fixTimestamps := fs.Bool("fix-timestamps", false, "Re-time repeated and slightly backwards timestamps before cleaning")
+	maxInversion := fs.Float64("max-inversion", 0.5, "Largest backwards timestamp step in seconds repaired by --fix-timestamps; larger ones are reported as clock jumps")
+	driftAnchors := fs.String("drift-anchors", "", "Linear clock drift correction from 'recorded=reference' anchor times in seconds, e.g. '10=10,1800=1800.9'")
+	sentinelSpec := fs.String("sentinels", "", "Comma-separated tracker codes for invalid samples, e.g. 'gaze_x:-1,pupil:0|-1,*:9999' ('*' for every column); marked missing before any other stage")
+	validRanges := fs.String("valid-range", "", "Comma-separated valid ranges, e.g. 'gaze_x:0..1,pupil:1.5..9'; values outside are marked missing")
+	disparity := fs.String("disparity", "", "Left and right eye gaze columns 'left_x:left_y:right_x:right_y' for a binocular disparity column")
+	maxDisparity := fs.Float64("max-disparity", 0, "Mark samples whose binocular disparity exceeds this as missing (0 = only add the disparity column)")
+	minDisparity := fs.Float64("min-disparity-duration", 0.02, "Seconds the disparity must stay above --max-disparity to be marked")
+	confidenceColumn := fs.String("confidence-column", "", "Per-sample confidence/validity column (e.g. Pupil Labs 'confidence')")
+	minConfidence := fs.Float64("min-confidence", 0.6, "Samples below this confidence are dropped or cleared")
+	confidenceAction := fs.String("confidence-action", "drop", "What to do with low-confidence samples: 'drop' or 'nan' (clear their values)")
+	interpolate := fs.String("interpolate", "", "Fill missing values before filtering: 'linear' or 'cubic' (default: off)")
+	maxGap := fs.Float64("max-gap", 0.1, "Longest gap in seconds to interpolate (0 = no limit)")
+	velocityColumns := fs.String("velocity-columns", "", "Gaze columns for --max-velocity: 'x:y' angles in degrees or 'x:y:z' gaze direction")
+	maxVelocity := fs.Float64("max-velocity", 0, "Samples with gaze speed at or above this many deg/s are implausible tracker glitches (0 = off), e.g. 1000")
+	minVelocity := fs.Float64("min-velocity-duration", 0, "Seconds the gaze speed must stay above --max-velocity (0 = any sample)")
+	velocityAction := fs.String("velocity-action", "remove", "What to do with implausibly fast samples: 'remove', 'nan' or 'label'")
+	impute := fs.String("impute", "", "Comma-separated imputation rules 'column:method' ("+strings.Join(cleaner.ImputeMethods, ", ")+"), applied before the missing-data filter")
+	blinks := fs.String("blinks", "", "Detect blinks and 'remove', 'interpolate' or 'label' them (default: off)")
+	blinkPupil := fs.String("blink-pupil", "", "Pupil column whose dropouts mark blinks (default: the inferred pupil column)")
+	blinkValidity := fs.String("blink-validity", "", "Validity flag column that marks blink samples")
+	blinkInvalid := fs.Float64("blink-invalid-value", 0, "Value of --blink-validity that marks an invalid sample")
+	minBlink := fs.Float64("min-blink", 0.05, "Minimum blink duration in seconds")
+	maxBlink := fs.Float64("max-blink", 0.5, "Maximum blink duration in seconds; longer dropouts are treated as tracking loss")
+	rolesSpec := addRolesFlag(fs)
+	hampel := fs.Bool("hampel", false, "Replace isolated spikes with the local median instead of removing rows")
+	hampelWindow := fs.Int("hampel-window", 7, "Hampel filter window size in samples")
+	hampelThreshold := fs.Float64("hampel-threshold", 3.0, "Hampel filter threshold in scaled MADs from the local median")
+	smooth := fs.String("smooth", "", "Smooth signals with a 'mean' (moving average), 'median' or 'savgol' (Savitzky-Golay) filter (default: off)")
+	window := fs.Int("window", 5, "Smoothing window size in samples")
+	windowMs := fs.Float64("window-ms", 0, "Smoothing window size in milliseconds (overrides --window)")
+	poly := fs.Int("poly", 2, "Polynomial order for the Savitzky-Golay filter")
+	report := fs.String("report", "", "Optional JSON file for a machine-readable cleaning report (per-column, per-participant and outlier bounds)")
+	rejects := fs.String("rejects", "", "Optional CSV file for the removed rows, with a removal_reason column")
+	pipelineFile := fs.String("pipeline", "", "YAML file listing the cleaning stages to run, in order, with per-stage parameters; other flags provide the defaults")
+	cacheDir := fs.String("cache-dir", "", "Directory caching the output of every cleaning stage, so --from-stage can re-run part of the pipeline")
+	fromStage := fs.String("from-stage", "", "Re-run the pipeline from this stage, starting from the cached output of the stage before it (needs --cache-dir)")
+	toStage := fs.String("to-stage", "", "Stop the pipeline after this stage")
+	dryRun := fs.Bool("dry-run", false, "Keep every row and write the input with is_* flag columns for what cleaning would remove, to audit before committing")
+	compare := fs.String("compare", "", "Second pipeline YAML file to compare with this cleaning (the other flags and --pipeline): reports retention and column statistics side by side instead of writing a cleaned dataset")
+	compareOutput := fs.String("compare-output", "", "Save the --compare report as long-format CSV, or JSON with a .json extension")
+	diagnosticsOutput := fs.String("diagnostics", "", "Save the warnings about the data from loading and cleaning as CSV, or JSON with a .json extension")
+	splitRows, splitMB := addSplitFlags(fs)
 
 	fs.Parse(os.Args[2:])
 
-	if *input == "" || *output == "" {
+	if *input == "" || (*output == "" && *compare == "") {
 		fs.Usage()
-		fmt.Printf("Input and output are required fields.\n")
+		fmt.Printf("Input and output are required fields (or --compare instead of output).\n")
 		fmt.Printf("Sample usage: mbdvr clean --input 'data.csv' --output 'cleaned.csv' --required 'X_Gaze,Y_Gaze' --remove-outliers --outlier-method 'zscore' --max-missing 10 --z-threshold 3.0\n")
 		os.Exit(1)
 	}
 
-	fmt.Printf("Cleaning data: %s → %s\n", *input, *output)
+	if *compare != "" {
+		if *output != "" || *cacheDir != "" || *fromStage != "" || *toStage != "" || *dryRun || *rejects != "" || *report != "" {
+			fmt.Println("Error: --compare only reports; it can't be combined with --output, --cache-dir, --from-stage, --to-stage, --dry-run, --rejects or --report")
+			os.Exit(1)
+		}
+		fmt.Printf("Comparing cleanings of %s\n", *input)
+	} else {
+		fmt.Printf("Cleaning data: %s → %s\n", *input, *output)
+	}
 
-	loader := &loader.Loader{}
+	diagnostics := &diag.Collector{}
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB, Diagnostics: diagnostics}
 	dataset, err := loader.LoadFiles(*input)
 	if err != nil {
 		fmt.Printf("Error loading input file: %v\n", err)
 		os.Exit(1)
 	}
+	trackInput(*input, len(dataset.Points))
+	if *blinks != "" && *blinkPupil == "" && *blinkValidity == "" {
+		if pupil, ok := columnRoles(dataset, *rolesSpec).First(roles.Pupil); ok {
+			*blinkPupil = pupil
+			fmt.Printf("Blink pupil column: %s (inferred)\n", pupil)
+		}
+	}
 
 	var reqCols []string
 	if *requiredCols != "" {
@@ -140,15 +587,122 @@ func cleanCommand() {
 		}
 	}
 
+	var pipeline []cleaner.Stage
+	if *pipelineFile != "" {
+		var err error
+		if pipeline, err = cleaner.LoadPipeline(*pipelineFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	sentinels, err := cleaner.ParseSentinels(*sentinelSpec)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	ranges, err := cleaner.ParseValidRanges(*validRanges)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	anchors, err := cleaner.ParseDriftAnchors(*driftAnchors)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	imputeRules, err := cleaner.ParseImputeRules(*impute)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var eyes []string
+	if *disparity != "" {
+		eyes = strings.Split(*disparity, ":")
+		if len(eyes) != 4 {
+			fmt.Printf("Error: invalid --disparity %q (use left_x:left_y:right_x:right_y)\n", *disparity)
+			os.Exit(1)
+		}
+		for i := range eyes {
+			eyes[i] = strings.TrimSpace(eyes[i])
+		}
+	}
+
+	var gazeCols []string
+	if *maxVelocity > 0 {
+		gazeCols = strings.Split(*velocityColumns, ":")
+		if len(gazeCols) != 2 && len(gazeCols) != 3 {
+			fmt.Printf("Error: --max-velocity needs --velocity-columns x:y or x:y:z, got %q\n", *velocityColumns)
+			os.Exit(1)
+		}
+		for i := range gazeCols {
+			gazeCols[i] = strings.TrimSpace(gazeCols[i])
+		}
+	}
+
 	cleanConfig := cleaner.CleanConfig{
-		RequiredColumns:   reqCols,
-		RemoveOutliers:    *removeOutliers,
-		OutlierMethod:     *outlierMethod,
-		MaxMissingPercent: *maxMissing,
-		ZScoreThreshold:   *zThreshold,
+		RequiredColumns:    reqCols,
+		RemoveOutliers:     *removeOutliers,
+		OutlierMethod:      *outlierMethod,
+		MaxMissingPercent:  *maxMissing,
+		ZScoreThreshold:    *zThreshold,
+		OutlierGrouping:    *outlierGrouping,
+		OutlierWindow:      *outlierWindow,
+		OutlierAction:      *outlierAction,
+		DryRun:             *dryRun,
+		Pipeline:           pipeline,
+		CacheDir:           *cacheDir,
+		FromStage:          *fromStage,
+		ToStage:            *toStage,
+		CollectRejects:     *rejects != "",
+		Duplicates:         *duplicates,
+		DuplicateTolerance: *duplicateTolerance,
+		DuplicateKeep:      *duplicateKeep,
+		FixTimestamps:      *fixTimestamps,
+		MaxInversion:       *maxInversion,
+		DriftAnchors:       anchors,
+		Sentinels:          sentinels,
+		ValidRanges:        ranges,
+		ConfidenceColumn:   *confidenceColumn,
+		MinConfidence:      *minConfidence,
+		ConfidenceAction:   *confidenceAction,
+		Interpolate:        *interpolate,
+		MaxGap:             *maxGap,
+
+		DisparityEyes:        eyes,
+		MaxDisparity:         *maxDisparity,
+		MinDisparityDuration: *minDisparity,
+
+		BlinkAction:         *blinks,
+		BlinkPupilColumn:    *blinkPupil,
+		BlinkValidityColumn: *blinkValidity,
+		BlinkInvalidValue:   *blinkInvalid,
+		MinBlinkDuration:    *minBlink,
+		MaxBlinkDuration:    *maxBlink,
+
+		VelocityColumns:     gazeCols,
+		MaxVelocity:         *maxVelocity,
+		MinVelocityDuration: *minVelocity,
+		VelocityAction:      *velocityAction,
+
+		Impute: imputeRules,
+
+		Hampel:          *hampel,
+		HampelWindow:    *hampelWindow,
+		HampelThreshold: *hampelThreshold,
+
+		Smooth:         *smooth,
+		SmoothWindow:   *window,
+		SmoothWindowMs: *windowMs,
+		SmoothPoly:     *poly,
+	}
+
+	if *compare != "" {
+		compareCleanings(dataset, cleanConfig, *pipelineFile, *compare, *compareOutput)
+		return
 	}
 
 	//Clean the data
+	cleanConfig.Diagnostics = diagnostics
 	cleanedDataset, stats, err := cleaner.CleanDataset(dataset, cleanConfig)
 	if err != nil {
 		fmt.Printf("Error cleaning dataset: %v\n", err)
@@ -156,213 +710,2432 @@ func cleanCommand() {
 	}
 
 	//Save cleaned dataset
-	err = loader.SaveDatasetAsCSV(cleanedDataset, *output)
+	err = loader.SaveDataset(cleanedDataset, *output)
 	if err != nil {
 		fmt.Printf("Error saving cleaned dataset: %v\n", err)
 		os.Exit(1)
 	}
 
 	//Print cleaning summary
-	fmt.Printf("Cleaning complete. Original points: %d, Removed missing: %d, Removed outliers: %d, Final points: %d\n",
-		stats.OriginalPoints, stats.RemovedMissing, stats.RemovedOutliers, stats.FinalPoints)
-	fmt.Printf("Cleaned dataset saved to %s\n", *output)
-}
-
-func clipCommand() {
-	fs := flag.NewFlagSet("clip", flag.ExitOnError)
-	input := fs.String("input", "", "Input CSV file to clip")
-	output := fs.String("output", "", "Output clipped CSV file")
-	startTime := fs.Float64("start", -1.0, "Start time in seconds")
-	endTime := fs.Float64("end", -1.0, "End time in seconds")
-
-	fs.Parse(os.Args[2:])
+	fmt.Printf("Cleaning complete. Original points: %d, Interpolated values: %d, Removed missing: %d, Removed outliers: %d, Removed duplicates: %d, Final points: %d\n",
+		stats.OriginalPoints, stats.Interpolated, stats.RemovedMissing, stats.RemovedOutliers, stats.ExactDuplicates+stats.NearDuplicates, stats.FinalPoints)
+	if stats.Blinks > 0 {
+		total := 0.0
+		for _, d := range stats.BlinkDurations {
+			total += d
+		}
+		fmt.Printf("Blinks: %d, mean duration: %.3fs\n", stats.Blinks, total/float64(stats.Blinks))
+	}
+	if *dryRun {
+		fmt.Printf("Dry run: no rows were removed; flagged copy of the input saved to %s\n", *output)
+	} else {
+		fmt.Printf("Cleaned dataset saved to %s\n", *output)
+	}
 
-	if *input == "" || *output == "" || *startTime < 0 || *endTime < 0 {
-		fs.Usage()
-		fmt.Printf("Input, output, start, and end are required fields.\n")
-		fmt.Printf("Sample usage: mbdvr clip --input 'data.csv' --output 'clipped.csv' --start 10.0 --end 20.0\n")
-		os.Exit(1)
+	if *rejects != "" {
+		if err := cleaner.SaveRejects(stats.Rejects, dataset.Columns, *rejects); err != nil {
+			fmt.Printf("Error saving rejects: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d removed rows saved to %s\n", len(stats.Rejects), *rejects)
 	}
 
-	fmt.Printf("Clipping data: %s → %s (%.2f to %.2f seconds)\n", *input, *output, *startTime, *endTime)
+	if *report != "" {
+		if err := cleaner.SaveReport(stats, *report); err != nil {
+			fmt.Printf("Error saving cleaning report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cleaning report saved to %s\n", *report)
+	}
+	printDiagnostics(diagnostics)
+	saveDiagnostics(diagnostics, *diagnosticsOutput)
+}
 
-	loader := &loader.Loader{}
-	dataset, err := loader.LoadFiles(*input)
+// compareCleanings cleans a dataset with a config and with the same config running another pipeline, and
+// prints or saves the differences between the results
+func compareCleanings(dataset *types.Dataset, config cleaner.CleanConfig, pipelineFile, compareFile, output string) {
+	other, err := cleaner.LoadPipeline(compareFile)
 	if err != nil {
-		fmt.Printf("Error loading input file: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	clipConfig := clipper.ClipConfig{}
-
-	if !math.IsNaN(*startTime) {
-		clipConfig.StartTime = startTime
+	labels := [2]string{"flags", strings.TrimSuffix(filepath.Base(compareFile), filepath.Ext(compareFile))}
+	if pipelineFile != "" {
+		labels[0] = strings.TrimSuffix(filepath.Base(pipelineFile), filepath.Ext(pipelineFile))
 	}
-	if !math.IsNaN(*endTime) {
-		clipConfig.EndTime = endTime
+	if labels[0] == labels[1] {
+		labels = [2]string{"a", "b"}
 	}
 
-	// Perform clipping
-	clippedDataset, info, err := clipper.ClipDataset(dataset, clipConfig)
-	if err != nil {
-		fmt.Printf("Error clipping data: %v\n", err)
-		os.Exit(1)
+	configs := [2]cleaner.CleanConfig{config, config}
+	configs[1].Pipeline = other
+	var cleaned [2]*types.Dataset
+	var cleanStats [2]cleaner.CleanStats
+	for i := range configs {
+		if cleaned[i], cleanStats[i], err = cleaner.CleanDataset(dataset, configs[i]); err != nil {
+			fmt.Printf("Error cleaning dataset with %s: %v\n", labels[i], err)
+			os.Exit(1)
+		}
 	}
 
-	// Save clipped dataset
-	err = loader.SaveDatasetAsCSV(clippedDataset, *output)
+	columns := config.RequiredColumns
+	if len(columns) == 0 {
+		columns = dataset.Columns[1:]
+	}
+	comparison, err := stats.CompareCleanings(labels, cleaned, cleanStats, stats.StatsConfig{AnalyzeColumns: columns})
 	if err != nil {
-		fmt.Printf("Error saving clipped dataset: %v\n", err)
+		fmt.Printf("Error comparing cleanings: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Print clipping summary
-	fmt.Printf("Data clipped successfully!\n")
-	fmt.Printf("Original: %d points (%.3fs to %.3fs, %s)\n",
-		info.OriginalPoints,
-		info.MinTimestamp,
-		info.MaxTimestamp,
-		clipper.FormatDuration(info.TotalDuration))
-
-	fmt.Printf("Clipped: %d points (%.3fs to %.3fs, %s)\n",
-		info.ClippedPoints,
-		info.ActualStartTime,
-		info.ActualEndTime,
-		clipper.FormatDuration(info.ActualEndTime-info.ActualStartTime))
-
-	if clipConfig.StartTime != nil || clipConfig.EndTime != nil {
-		fmt.Printf("Requested range: %.3fs to %.3fs\n",
-			getFloat64OrDefault(clipConfig.StartTime, info.MinTimestamp),
-			getFloat64OrDefault(clipConfig.EndTime, info.MaxTimestamp))
-
-		if clipConfig.StartTime != nil {
-			diff := math.Abs(info.ActualStartTime - *clipConfig.StartTime)
-			fmt.Printf("Start frame difference: %.3fs\n", diff)
+	fmt.Printf("%-44s %14s %14s %14s\n", "", labels[0], labels[1], "difference")
+	for _, v := range comparison.Values {
+		if v.Section == "distribution" && v.Statistic != "count" && v.Statistic != "mean" && v.Statistic != "sd" && v.Statistic != "median" {
+			continue
 		}
-		if clipConfig.EndTime != nil {
-			diff := math.Abs(info.ActualEndTime - *clipConfig.EndTime)
-			fmt.Printf("End frame difference: %.3fs\n", diff)
+		name := v.Statistic
+		if v.Section == "distribution" {
+			name = v.Column + " " + v.Statistic + " (" + v.Group + ")"
 		}
+		fmt.Printf("%-44s %14.4g %14.4g %+14.4g\n", name, v.A, v.B, v.Difference)
 	}
 
-	retentionPercent := float64(info.ClippedPoints) / float64(info.OriginalPoints) * 100
-	fmt.Printf("Retained: %.1f%% of original data\n", retentionPercent)
-	fmt.Printf("Saved to: %s\n", *output)
-}
-
-func getFloat64OrDefault(val *float64, def float64) float64 {
-	if val != nil {
-		return *val
+	if output != "" {
+		if err := stats.SaveComparison(comparison, output); err != nil {
+			fmt.Printf("Error saving comparison: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Comparison saved to %s\n", output)
 	}
-	return def
 }
 
-func statsCommand() {
-	fs := flag.NewFlagSet("stats", flag.ExitOnError)
-	inputs := fs.String("inputs", "", "Comma-separated input CSV files (required)")
-	analyzeColumns := fs.String("analyze", "", "Comma-separated columns to analyze (required)")
-	byCondition := fs.Bool("by-condition", true, "Group statistics by condition")
-	byParticipant := fs.Bool("by-participant", false, "Group statistics by participant")
-	output := fs.String("output", "", "Output file for detailed results (optional)")
+func resampleCommand() {
+	fs := flag.NewFlagSet("resample", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file (required)")
+	output := fs.String("output", "", "Output data file (required)")
+	rate := fs.Float64("rate", 0, "Target sampling rate in Hz (required)")
+	method := fs.String("method", "linear", "Interpolation method: 'linear', 'cubic' or 'nearest'")
+	maxGap := fs.Float64("max-gap", 0.1, "Leave grid points missing inside gaps longer than this many seconds (0 = no limit)")
+	align := fs.String("align", "", "Share one grid across participants: 'grid' (multiples of the step from --origin) or 'onset' (time since each condition started)")
+	origin := fs.Float64("origin", 0, "Grid origin in seconds for --align grid")
+	splitRows, splitMB := addSplitFlags(fs)
 
 	fs.Parse(os.Args[2:])
 
-	if *inputs == "" || *analyzeColumns == "" {
-		fmt.Println("Error: --inputs and --analyze are required")
-		fmt.Println("\nExample:")
-		fmt.Println("  mbdvr stats --inputs \"boring.csv,interesting.csv\" --analyze \"gaze_x,gaze_y,pupil_size\"")
+	if *input == "" || *output == "" || *rate <= 0 {
 		fs.Usage()
+		fmt.Printf("Input, output and rate are required fields.\n")
+		fmt.Printf("Sample usage: mbdvr resample --input 'raw.csv' --output 'uniform.csv' --rate 120\n")
 		os.Exit(1)
 	}
 
-	inputFiles := strings.Split(*inputs, ",")
-	for i := range inputFiles {
-		inputFiles[i] = strings.TrimSpace(inputFiles[i])
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
 	}
+	trackInput(*input, len(dataset.Points))
 
-	columns := strings.Split(*analyzeColumns, ",")
-	for i := range columns {
-		columns[i] = strings.TrimSpace(columns[i])
+	resampled, stats, err := cleaner.Resample(dataset, cleaner.ResampleConfig{Rate: *rate, Method: *method, MaxGap: *maxGap, Align: *align, Origin: *origin})
+	if err != nil {
+		fmt.Printf("Error resampling dataset: %v\n", err)
+		os.Exit(1)
 	}
 
-	loader := &loader.Loader{}
-	var allPoints []types.DataPoint
+	err = loader.SaveDataset(resampled, *output)
+	if err != nil {
+		fmt.Printf("Error saving dataset: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Resampled %d points to %d points at %g Hz (%s), %d values left missing in gaps\n",
+		stats.OriginalPoints, stats.ResampledPoints, *rate, *method, stats.MissingValues)
+	fmt.Printf("Saved to: %s\n", *output)
+}
+
+func classifyCommand() {
+	fs := flag.NewFlagSet("classify", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file (required)")
+	output := fs.String("output", "", "Output data file with velocity and movement columns (required)")
+	xCol := fs.String("x", "", "Gaze x column (default: the inferred gaze columns)")
+	yCol := fs.String("y", "", "Gaze y column (default: the inferred gaze columns)")
+	rolesSpec := addRolesFlag(fs)
+	saccadeThreshold := fs.Float64("saccade-threshold", 70, "Velocity above which samples are saccades (gaze units per second, e.g. deg/s)")
+	fixationThreshold := fs.Float64("fixation-threshold", 20, "Velocity below which samples are fixations; samples in between are smooth pursuit")
+	minPursuit := fs.Float64("min-pursuit", 0.04, "Minimum pursuit duration in seconds; shorter runs become fixations (0 = keep all)")
+	direction := fs.String("direction", "", "Eye-in-head gaze direction columns 'x:y:z'; with --head-rotation, classify gaze-in-world angular velocity in deg/s")
+	headRotation := fs.String("head-rotation", "", "Head rotation quaternion columns 'w:x:y:z' for world-coordinate classification")
+	targetX := fs.String("target-x", "", "Target trajectory x column for pursuit gain")
+	targetY := fs.String("target-y", "", "Target trajectory y column for pursuit gain")
+	minTargetSpeed := fs.Float64("min-target-speed", 1, "Only compute gain while the target moves at least this fast")
+	gainOutput := fs.String("gain-output", "", "Save pursuit gain per participant and condition to a CSV file")
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" || *output == "" {
+		fs.Usage()
+		fmt.Printf("Input and output are required fields.\n")
+		fmt.Printf("Sample usage: mbdvr classify --input 'task.csv' --output 'classified.csv' --target-x target_x --target-y target_y\n")
+		os.Exit(1)
+	}
+	if (*targetX == "") != (*targetY == "") {
+		fmt.Println("Error: --target-x and --target-y must be given together")
+		os.Exit(1)
+	}
+	if *gainOutput != "" && *targetX == "" {
+		fmt.Println("Error: --gain-output requires --target-x and --target-y")
+		os.Exit(1)
+	}
+	var world *gaze.WorldConfig
+	if *direction != "" || *headRotation != "" {
+		dir := strings.Split(*direction, ":")
+		quat := strings.Split(*headRotation, ":")
+		if len(dir) != 3 || len(quat) != 4 {
+			fmt.Println("Error: --direction (x:y:z) and --head-rotation (w:x:y:z) must be given together")
+			os.Exit(1)
+		}
+		world = &gaze.WorldConfig{}
+		for i := range dir {
+			world.Direction[i] = strings.TrimSpace(dir[i])
+		}
+		for i := range quat {
+			world.Quaternion[i] = strings.TrimSpace(quat[i])
+		}
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+	inferGazeColumns(dataset, *rolesSpec, xCol, yCol)
+
+	classified, stats, err := gaze.Classify(dataset, gaze.ClassifyConfig{
+		X:                  *xCol,
+		Y:                  *yCol,
+		SaccadeThreshold:   *saccadeThreshold,
+		FixationThreshold:  *fixationThreshold,
+		MinPursuitDuration: *minPursuit,
+		World:              world,
+		TargetX:            *targetX,
+		TargetY:            *targetY,
+		MinTargetSpeed:     *minTargetSpeed,
+	})
+	if err != nil {
+		fmt.Printf("Error classifying eye movements: %v\n", err)
+		os.Exit(1)
+	}
+
+	if stats.Samples > 0 {
+		percent := func(n int) float64 { return 100 * float64(n) / float64(stats.Samples) }
+		fmt.Printf("Classified %d samples: %.1f%% fixation, %.1f%% saccade, %.1f%% pursuit\n",
+			stats.Samples, percent(stats.Fixation), percent(stats.Saccade), percent(stats.Pursuit))
+	}
+	for _, g := range stats.Gains {
+		fmt.Printf("Participant: %s | Condition: %s | Pursuit samples: %d | Mean gain: %.3f | Median gain: %.3f\n",
+			g.ParticipantID, g.Condition, g.Samples, g.MeanGain, g.MedianGain)
+	}
+
+	err = loader.SaveDataset(classified, *output)
+	if err != nil {
+		fmt.Printf("Error saving dataset: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved to: %s\n", *output)
+
+	if *gainOutput != "" {
+		if err := gaze.SaveGains(stats.Gains, *gainOutput); err != nil {
+			fmt.Printf("Error saving pursuit gain to %s: %v\n", *gainOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Pursuit gain saved to %s\n", *gainOutput)
+	}
+}
+
+// inferGazeColumns fills in the gaze x and y columns not given as flags from the inferred column roles
+func inferGazeColumns(dataset *types.Dataset, rolesSpec string, xCol, yCol *string) {
+	if *xCol != "" && *yCol != "" {
+		return
+	}
+	x, y, ok := columnRoles(dataset, rolesSpec).GazePoint()
+	if !ok {
+		fmt.Println("Error: no gaze columns found; give them with --x and --y, or name their role with --roles")
+		os.Exit(1)
+	}
+	if *xCol == "" {
+		*xCol = x
+	}
+	if *yCol == "" {
+		*yCol = y
+	}
+	fmt.Printf("Gaze columns: %s, %s (inferred)\n", *xCol, *yCol)
+}
+
+func microsaccadesCommand() {
+	fs := flag.NewFlagSet("microsaccades", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file, ideally classified so fixations are labelled (required)")
+	output := fs.String("output", "", "Output data file with a microsaccade column (use .mbd or .db to keep events)")
+	xCol := fs.String("x", "", "Gaze x column (default: the inferred gaze columns)")
+	yCol := fs.String("y", "", "Gaze y column (default: the inferred gaze columns)")
+	rolesSpec := addRolesFlag(fs)
+	lambda := fs.Float64("lambda", 6, "Velocity threshold in median-based standard deviations")
+	minSamples := fs.Int("min-samples", 3, "Minimum microsaccade length in samples")
+	maxAmplitude := fs.Float64("max-amplitude", 1, "Larger movements are not microsaccades, in gaze units (0 = no limit)")
+	minFixation := fs.Float64("min-fixation", 0.1, "Skip fixation segments shorter than this many seconds")
+	eventsOutput := fs.String("events-output", "", "Save one row per microsaccade to a CSV file")
+	statsOutput := fs.String("stats-output", "", "Save rate and amplitude statistics per participant and condition to a CSV file")
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" || (*output == "" && *eventsOutput == "" && *statsOutput == "") {
+		fs.Usage()
+		fmt.Printf("Input and at least one of output, events output or stats output are required.\n")
+		fmt.Printf("Sample usage: mbdvr microsaccades --input 'classified.csv' --stats-output 'microsaccades.csv'\n")
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+	inferGazeColumns(dataset, *rolesSpec, xCol, yCol)
+
+	result, detected, summaries, err := gaze.DetectMicrosaccades(dataset, gaze.MicrosaccadeConfig{
+		X:            *xCol,
+		Y:            *yCol,
+		Lambda:       *lambda,
+		MinSamples:   *minSamples,
+		MaxAmplitude: *maxAmplitude,
+		MinFixation:  *minFixation,
+	})
+	if err != nil {
+		fmt.Printf("Error detecting microsaccades: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Detected %d microsaccades\n", len(detected))
+	for _, s := range summaries {
+		fmt.Printf("Participant: %s | Condition: %s | Fixation time: %.1fs | Count: %d | Rate: %.2f/s | Mean amplitude: %.3f | Mean peak velocity: %.1f\n",
+			s.ParticipantID, s.Condition, s.FixationTime, s.Count, s.Rate, s.MeanAmplitude, s.MeanPeakVelocity)
+	}
+
+	if *output != "" {
+		if err := loader.SaveDataset(result, *output); err != nil {
+			fmt.Printf("Error saving dataset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved to: %s\n", *output)
+	}
+
+	if *eventsOutput != "" {
+		if err := gaze.SaveMicrosaccades(detected, *eventsOutput); err != nil {
+			fmt.Printf("Error saving microsaccades to %s: %v\n", *eventsOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Microsaccades saved to %s\n", *eventsOutput)
+	}
+
+	if *statsOutput != "" {
+		if err := gaze.SaveMicrosaccadeStats(summaries, *statsOutput); err != nil {
+			fmt.Printf("Error saving microsaccade statistics to %s: %v\n", *statsOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Microsaccade statistics saved to %s\n", *statsOutput)
+	}
+}
+
+func calibrationCommand() {
+	fs := flag.NewFlagSet("calibration", flag.ExitOnError)
+	input := fs.String("input", "", "Calibration recording with target and gaze columns (required)")
+	output := fs.String("output", "", "Output data file with the calibration quality in its metadata (use .mbd or .db to keep it)")
+	target := fs.String("target", "", "Target position columns as x:y, in the gaze units (default: the inferred target columns)")
+	eyes := fs.String("eyes", "", "Comma-separated gaze columns per eye as name=x:y, e.g. 'left=left_gaze_x:left_gaze_y,right=right_gaze_x:right_gaze_y' (default: the inferred gaze columns of each eye)")
+	settle := fs.Float64("settle", 0, "Seconds skipped after each target appears, while the eye moves onto it")
+	maxOffset := fs.Float64("max-offset", 0, "Fail when an eye's mean offset from the targets exceeds this, in gaze units (0 = no limit)")
+	maxRMS := fs.Float64("max-rms", 0, "Fail when an eye's sample-to-sample RMS exceeds this, in gaze units (0 = no limit)")
+	qualityOutput := fs.String("quality-output", "", "Save the offset and RMS per participant, eye and target to a CSV file")
+	rolesSpec := addRolesFlag(fs)
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" {
+		fs.Usage()
+		fmt.Printf("Input is a required field.\n")
+		fmt.Printf("Sample usage: mbdvr calibration --input 'calibration.csv' --settle 0.3 --max-offset 1.0 --output 'session.mbd'\n")
+		os.Exit(1)
+	}
+	targetCols := strings.Split(*target, ":")
+	if *target != "" && len(targetCols) != 2 {
+		fmt.Printf("Error: invalid --target %q (use x:y)\n", *target)
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+	inferred := columnRoles(dataset, *rolesSpec)
+	if *target == "" {
+		if targetCols = inferred.Vector(roles.Target, "", "x", "y"); targetCols == nil {
+			fmt.Println("Error: no target columns found; give them with --target x:y, or name their role with --roles")
+			os.Exit(1)
+		}
+		fmt.Printf("Target columns: %s, %s (inferred)\n", targetCols[0], targetCols[1])
+	}
+
+	config := gaze.CalibrationConfig{TargetX: targetCols[0], TargetY: targetCols[1], Settle: *settle}
+	if *eyes != "" {
+		for _, spec := range strings.Split(*eyes, ",") {
+			name, cols, ok := strings.Cut(strings.TrimSpace(spec), "=")
+			xy := strings.Split(cols, ":")
+			if !ok || name == "" || len(xy) != 2 {
+				fmt.Printf("Error: invalid eye %q (use name=x:y)\n", spec)
+				os.Exit(1)
+			}
+			config.Eyes = append(config.Eyes, gaze.Eye{Name: name, X: xy[0], Y: xy[1]})
+		}
+	} else {
+		for _, side := range inferred.Sides(roles.Gaze) {
+			if xy := inferred.Vector(roles.Gaze, side, "x", "y"); xy != nil {
+				name := side
+				if name == "" {
+					name = "gaze"
+				}
+				config.Eyes = append(config.Eyes, gaze.Eye{Name: name, X: xy[0], Y: xy[1]})
+				fmt.Printf("Eye %s: %s, %s (inferred)\n", name, xy[0], xy[1])
+			}
+		}
+	}
+
+	result, qualities, err := gaze.ScoreCalibration(dataset, config)
+	if err != nil {
+		fmt.Printf("Error scoring calibration: %v\n", err)
+		os.Exit(1)
+	}
+
+	var failed []string
+	for _, q := range qualities {
+		label := q.ParticipantID
+		if q.Condition != "" {
+			label += " (" + q.Condition + ")"
+		}
+		fmt.Printf("Participant: %s | Eye: %s | Targets: %d | Mean offset: %.3f | Max offset: %.3f | RMS: %.3f\n",
+			label, q.Eye, len(q.Targets), q.MeanOffset, q.MaxOffset, q.RMS)
+		if *maxOffset > 0 && q.MeanOffset > *maxOffset {
+			failed = append(failed, fmt.Sprintf("%s %s: mean offset %.3f > %g", label, q.Eye, q.MeanOffset, *maxOffset))
+		}
+		if *maxRMS > 0 && q.RMS > *maxRMS {
+			failed = append(failed, fmt.Sprintf("%s %s: RMS %.3f > %g", label, q.Eye, q.RMS, *maxRMS))
+		}
+	}
+
+	if *output != "" {
+		if err := loader.SaveDataset(result, *output); err != nil {
+			fmt.Printf("Error saving dataset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved to: %s\n", *output)
+	}
+
+	if *qualityOutput != "" {
+		if err := gaze.SaveCalibration(qualities, *qualityOutput); err != nil {
+			fmt.Printf("Error saving calibration quality to %s: %v\n", *qualityOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Calibration quality saved to %s\n", *qualityOutput)
+	}
+
+	if len(failed) > 0 {
+		fmt.Println("Error: calibration is below the minimum quality:")
+		for _, f := range failed {
+			fmt.Printf("  %s\n", f)
+		}
+		os.Exit(1)
+	}
+}
+
+func detectCommand() {
+	fs := flag.NewFlagSet("detect", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file (required)")
+	output := fs.String("output", "", "Output data file with the detected events (use .mbd or .db to keep events)")
+	eventsOutput := fs.String("events-output", "", "Save the detected events to a CSV file")
+	column := fs.String("column", "", "Signal column to detect events in (required)")
+	enter := fs.Float64("enter", math.NaN(), "Events start when the signal reaches this threshold (required)")
+	exit := fs.Float64("exit", math.NaN(), "Events end when the signal passes back beyond this threshold (default: --enter)")
+	below := fs.Bool("below", false, "Detect dips below --enter instead of peaks above it")
+	minDuration := fs.Float64("min-duration", 0, "Discard events shorter than this many seconds")
+	maxDuration := fs.Float64("max-duration", 0, "Discard events longer than this many seconds (0 = no limit)")
+	missingActive := fs.Bool("missing-active", false, "Treat missing samples as part of an event (otherwise they keep the current state)")
+	eventType := fs.String("type", "THRESHOLD", "Event type stored on the detected events")
+	label := fs.String("label", "", "Add a column with this name set to 1 during events")
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" || *column == "" || math.IsNaN(*enter) || (*output == "" && *eventsOutput == "") {
+		fs.Usage()
+		fmt.Printf("Input, column, enter and an output or events output are required fields.\n")
+		fmt.Printf("Sample usage: mbdvr detect --input 'task.csv' --column pupil --enter 4.5 --exit 4.2 --min-duration 0.2 --events-output dilations.csv\n")
+		os.Exit(1)
+	}
+	if math.IsNaN(*exit) {
+		*exit = *enter
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+
+	result, detected, err := events.DetectThreshold(dataset, events.ThresholdConfig{
+		Column:      *column,
+		Type:        *eventType,
+		LabelColumn: *label,
+		Hysteresis: events.Hysteresis{
+			Enter:         *enter,
+			Exit:          *exit,
+			Below:         *below,
+			MinDuration:   *minDuration,
+			MaxDuration:   *maxDuration,
+			MissingActive: *missingActive,
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error detecting events: %v\n", err)
+		os.Exit(1)
+	}
+
+	total := 0.0
+	for _, e := range detected {
+		total += e.End - e.Start
+	}
+	fmt.Printf("Detected %d events in %s", len(detected), *column)
+	if len(detected) > 0 {
+		fmt.Printf(" (mean duration: %.3fs)", total/float64(len(detected)))
+	}
+	fmt.Println()
+
+	if *output != "" {
+		if err := loader.SaveDataset(result, *output); err != nil {
+			fmt.Printf("Error saving dataset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved to: %s\n", *output)
+	}
+
+	if *eventsOutput != "" {
+		if err := events.SaveEvents(detected, *eventsOutput); err != nil {
+			fmt.Printf("Error saving events to %s: %v\n", *eventsOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Events saved to %s\n", *eventsOutput)
+	}
+}
+
+func exportCommand() {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file, usually the cleaned dataset (required)")
+	outputDir := fs.String("output-dir", "", "Directory for the per-condition zip bundles (required)")
+	analyzeColumns := fs.String("analyze", "", "Comma-separated columns for each bundle's statistics (default: all data columns)")
+	byParticipant := fs.Bool("by-participant", false, "Also break each bundle's statistics down by participant")
+	include := fs.String("include", "", "Comma-separated extra files copied into every bundle, e.g. a cleaning report or figures")
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" || *outputDir == "" {
+		fs.Usage()
+		fmt.Printf("Input and output directory are required fields.\n")
+		fmt.Printf("Sample usage: mbdvr export --input 'cleaned.mbd' --output-dir deliverables --analyze 'gaze_x,gaze_y,pupil' --include report.json\n")
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+
+	config := export.BundleConfig{
+		OutputDir: *outputDir,
+		Stats: stats.StatsConfig{
+			ByParticipant: *byParticipant,
+		},
+	}
+	if *analyzeColumns != "" {
+		config.Stats.AnalyzeColumns = strings.Split(*analyzeColumns, ",")
+	}
+	if *include != "" {
+		config.Include = strings.Split(*include, ",")
+	}
+
+	paths, err := export.WriteBundles(dataset, config)
+	if err != nil {
+		fmt.Printf("Error exporting bundles: %v\n", err)
+		os.Exit(1)
+	}
+	for _, path := range paths {
+		fmt.Printf("Saved bundle: %s\n", path)
+	}
+}
+
+func clipCommand() {
+	fs := flag.NewFlagSet("clip", flag.ExitOnError)
+	input := fs.String("input", "", "Input CSV file to clip")
+	output := fs.String("output", "", "Output clipped CSV file")
+	startTime := fs.String("start", "", "Start time in seconds; '-30' is 30 seconds before the end of the data")
+	endTime := fs.String("end", "", "End time in seconds; '-0' is the end of the data and '+60' is 60 seconds after --start")
+	duration := fs.String("duration", "", "Length of the clip from --start, e.g. '30' or '2m', instead of --end")
+	autoStart := fs.String("auto-start", "", "Start each participant's clip at the first stable samples of these comma-separated columns, e.g. 'gaze_x,gaze_y', instead of --start")
+	stable := fs.Int("stable", 10, "With --auto-start, consecutive samples with values needed to count as stable")
+	startFrame := fs.Int("start-frame", -1, "Index of the first point to keep (from 0), instead of --start")
+	endFrame := fs.Int("end-frame", -1, "Index of the last point to keep, instead of --end")
+	segments := fs.String("segments", "", "Comma-separated start-end ranges in seconds to clip in one run, e.g. '10-20,45-60,90-120' (instead of --start/--end)")
+	perSegment := fs.Bool("per-segment", false, "With --segments, --from-event or --window, write one file per segment (output_seg1.csv, ...) instead of one joined dataset")
+	exclude := fs.String("exclude", "", "Comma-separated start-end ranges in seconds to remove, keeping everything else, e.g. '0-30,600-660' for calibration and a break")
+	window := fs.String("window", "", "Cut each participant's data into windows of this length, e.g. '5s' or '500ms', instead of --start/--end")
+	step := fs.String("step", "", "Start a --window every this long, e.g. '1s' for overlapping windows (default: the window length)")
+	fromEvent := fs.String("from-event", "", "Clip from each of these events (type or message), per participant, instead of --start/--end")
+	toEvent := fs.String("to-event", "", "to the next of these events (default: the end of the --from-event itself)")
+	pre := fs.Float64("pre", 0, "Seconds of padding before each --from-event")
+	post := fs.Float64("post", 0, "Seconds of padding after each --to-event")
+	eventsFile := fs.String("events-file", "", "CSV of events to clip by, instead of the events stored in the input (columns: start or timestamp, type or event, optional participant_id)")
+	eventColumn := fs.String("event-column", "", "Data column of marker codes to clip by, e.g. a trigger channel: each change to a non-zero value is an event named by the value")
+	participants := fs.String("participants", "", "Comma-separated participant IDs to keep, e.g. 'P01,P02' (default: all)")
+	conditions := fs.String("conditions", "", "Comma-separated conditions to keep (default: all); without --start/--end the whole recording of the kept data is written")
+	splitColumn := fs.String("split-column", "", "Write one file per trial (output_trial1.csv, ...), starting a trial wherever this marker column changes value")
+	splitEvent := fs.String("split-event", "", "Write one file per trial, starting a trial at each of these events (type or message) until the next one")
+	keepZero := fs.Bool("keep-zero", false, "With --split-column, keep runs of marker value 0 as trials instead of treating them as gaps between trials")
+	relative := fs.Bool("relative", false, "Times in --start/--end and --segments are seconds from each participant's first timestamp, e.g. '--start 0 --end 60' for the first minute of every session")
+	rebase := fs.Bool("rebase", false, "Subtract each participant's first clipped timestamp, so every clip, segment or trial starts at t=0 for epoch-aligned averaging")
+	clamp := fs.Bool("clamp", false, "Snap --start/--end and --segments times slightly outside the recording to its first or last timestamp instead of failing")
+	pattern := fs.String("pattern", "", "Clip each file matching this pattern on its own, e.g. 'cleaned_*.csv', instead of merging them (needs --output-dir)")
+	outputDir := fs.String("output-dir", "", "With --pattern, directory for the clipped files, saved under their input names")
+	batchFile := fs.String("config", "", "YAML or CSV file listing clips to run in one go, each with its input, output and start/end, segments or from_event")
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+	framed := *startFrame >= 0 || *endFrame >= 0
+	if *duration != "" {
+		if *endTime != "" {
+			fmt.Println("Error: use either --end or --duration")
+			os.Exit(1)
+		}
+		d, err := clipper.ParseSeconds(*duration)
+		if err != nil || d <= 0 {
+			fmt.Printf("Error: --duration must be a positive length of time, e.g. 30 or 30s, not %q\n", *duration)
+			os.Exit(1)
+		}
+		*endTime = "+" + strconv.FormatFloat(d, 'f', -1, 64)
+	}
+	timed := *startTime != "" || *endTime != ""
+
+	if *batchFile != "" {
+		if *input != "" || *output != "" || timed || framed || *autoStart != "" || *segments != "" || *exclude != "" || *window != "" || *fromEvent != "" || *splitColumn != "" || *splitEvent != "" {
+			fmt.Println("Error: --config sets the input, output and times of every clip; it can't be combined with --input, --output or the clipping modes")
+			os.Exit(1)
+		}
+		clipBatch(*batchFile, *splitRows, *splitMB)
+		return
+	}
+
+	if *pattern != "" || *outputDir != "" {
+		if *pattern == "" || *outputDir == "" {
+			fmt.Println("Error: --pattern and --output-dir go together")
+			os.Exit(1)
+		}
+		if *input != "" || *output != "" || framed || *autoStart != "" || *exclude != "" || *window != "" || *splitColumn != "" || *splitEvent != "" || *eventsFile != "" || *eventColumn != "" || *participants != "" || *conditions != "" {
+			fmt.Println("Error: --pattern clips by --start/--end, --segments or --from-event; it can't be combined with --input, --output or the other clipping modes")
+			os.Exit(1)
+		}
+		if !timed && *segments == "" && *fromEvent == "" {
+			fmt.Println("Error: --pattern needs --start/--end, --segments or --from-event")
+			os.Exit(1)
+		}
+		settings := map[string]string{"input": *pattern, "output": *outputDir, "start": *startTime, "end": *endTime, "segments": *segments,
+			"from_event": *fromEvent, "to_event": *toEvent, "relative": strconv.FormatBool(*relative), "rebase": strconv.FormatBool(*rebase), "per_segment": strconv.FormatBool(*perSegment), "clamp": strconv.FormatBool(*clamp)}
+		if *pre != 0 {
+			settings["pre"] = strconv.FormatFloat(*pre, 'f', -1, 64)
+		}
+		if *post != 0 {
+			settings["post"] = strconv.FormatFloat(*post, 'f', -1, 64)
+		}
+		template, err := clipper.NewBatchClip(settings)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		clipFiles(*pattern, *outputDir, template, &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB})
+		return
+	}
+
+	if *autoStart != "" {
+		if *startTime != "" || framed || *relative || *segments != "" || *exclude != "" || *window != "" || *fromEvent != "" || *splitColumn != "" || *splitEvent != "" || *perSegment {
+			fmt.Println("Error: --auto-start finds each participant's start itself; it can't be combined with --start, --relative or the other clipping modes")
+			os.Exit(1)
+		}
+		config := clipper.ClipConfig{Relative: true, Rebase: *rebase}
+		if *endTime != "" {
+			end, anchor, err := clipper.ParseClipTime(*endTime)
+			if err != nil {
+				fmt.Printf("Error: --end: %v\n", err)
+				os.Exit(1)
+			}
+			start := 0.0
+			config.StartTime, config.EndTime, config.EndAnchor = &start, &end, anchor
+		}
+		if *input == "" || *output == "" {
+			fs.Usage()
+			fmt.Printf("Input and output are required fields.\n")
+			os.Exit(1)
+		}
+
+		loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+		dataset, err := loader.LoadFiles(*input)
+		if err != nil {
+			fmt.Printf("Error loading input file: %v\n", err)
+			os.Exit(1)
+		}
+		trackInput(*input, len(dataset.Points))
+		dataset = filterCohort(dataset, *participants, *conditions)
+		clipOnset(dataset, *output, strings.Split(*autoStart, ","), *stable, config, loader)
+		return
+	}
+
+	if *exclude != "" {
+		if timed || framed || *segments != "" || *fromEvent != "" || *window != "" || *splitColumn != "" || *splitEvent != "" || *perSegment {
+			fmt.Println("Error: --exclude can't be combined with the other clipping modes")
+			os.Exit(1)
+		}
+		ranges, err := clipper.ParseSegments(*exclude)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *input == "" || *output == "" {
+			fs.Usage()
+			fmt.Printf("Input and output are required fields.\n")
+			os.Exit(1)
+		}
+		loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+		dataset, err := loader.LoadFiles(*input)
+		if err != nil {
+			fmt.Printf("Error loading input file: %v\n", err)
+			os.Exit(1)
+		}
+		trackInput(*input, len(dataset.Points))
+		dataset = filterCohort(dataset, *participants, *conditions)
+
+		kept, info, exclusions, err := clipper.ExcludeSegments(dataset, clipper.ClipConfig{Segments: ranges, Relative: *relative, Rebase: *rebase})
+		if err != nil {
+			fmt.Printf("Error excluding ranges: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range exclusions {
+			fmt.Printf("Excluded %s: %d points (%s of recording)\n", e.Range, e.Points, clipper.FormatDuration(e.Duration))
+		}
+		if err := loader.SaveDataset(kept, *output); err != nil {
+			fmt.Printf("Error saving clipped dataset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Retained: %d of %d points (%.1f%%)\n", info.ClippedPoints, info.OriginalPoints, float64(info.ClippedPoints)/float64(info.OriginalPoints)*100)
+		fmt.Printf("Saved to: %s\n", *output)
+		return
+	}
+
+	if *splitColumn != "" || *splitEvent != "" {
+		if timed || framed || *segments != "" || *fromEvent != "" || *window != "" || *relative {
+			fmt.Println("Error: --split-column and --split-event can't be combined with the other clipping modes")
+			os.Exit(1)
+		}
+		if *input == "" || *output == "" {
+			fs.Usage()
+			fmt.Printf("Input and output are required fields.\n")
+			os.Exit(1)
+		}
+		loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+		dataset, err := loader.LoadFiles(*input)
+		if err != nil {
+			fmt.Printf("Error loading input file: %v\n", err)
+			os.Exit(1)
+		}
+		trackInput(*input, len(dataset.Points))
+		dataset = filterCohort(dataset, *participants, *conditions)
+
+		config := clipper.TrialConfig{MarkerColumn: *splitColumn, KeepZero: *keepZero, Event: *splitEvent, Rebase: *rebase}
+		if *eventsFile != "" {
+			if config.Events, err = events.LoadEvents(*eventsFile); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		splitTrials(dataset, *output, config, loader)
+		return
+	}
+
+	if *segments != "" || *fromEvent != "" || *window != "" {
+		modes := 0
+		for _, set := range []bool{*segments != "", *fromEvent != "", *window != ""} {
+			if set {
+				modes++
+			}
+		}
+		if timed || framed || modes > 1 {
+			fmt.Println("Error: use one of --start/--end, --start-frame/--end-frame, --segments, --from-event and --window")
+			os.Exit(1)
+		}
+		if *step != "" && *window == "" {
+			fmt.Println("Error: --step needs --window")
+			os.Exit(1)
+		}
+		if *relative && (*fromEvent != "" || *window != "") {
+			fmt.Println("Error: --relative can't be used with --from-event, which clips around the event times, or --window, which always starts at each participant's first timestamp")
+			os.Exit(1)
+		}
+		if *eventsFile != "" && *eventColumn != "" {
+			fmt.Println("Error: use either --events-file or --event-column")
+			os.Exit(1)
+		}
+		var parsed []clipper.Segment
+		if *segments != "" {
+			var err error
+			if parsed, err = clipper.ParseSegments(*segments); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *input == "" || *output == "" {
+			fs.Usage()
+			fmt.Printf("Input and output are required fields.\n")
+			os.Exit(1)
+		}
+
+		loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+		dataset, err := loader.LoadFiles(*input)
+		if err != nil {
+			fmt.Printf("Error loading input file: %v\n", err)
+			os.Exit(1)
+		}
+		trackInput(*input, len(dataset.Points))
+		dataset = filterCohort(dataset, *participants, *conditions)
+
+		if *fromEvent != "" {
+			markers := dataset.Events
+			switch {
+			case *eventsFile != "":
+				markers, err = events.LoadEvents(*eventsFile)
+			case *eventColumn != "":
+				markers, err = events.MarkerEvents(dataset, *eventColumn)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			window := clipper.EventWindow{FromEvent: *fromEvent, ToEvent: *toEvent, Pre: *pre, Post: *post}
+			var skipped int
+			parsed, skipped, err = clipper.EventSegments(dataset, markers, window)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Found %d segments from %s events", len(parsed), *fromEvent)
+			if *toEvent != "" {
+				fmt.Printf(" to %s events", *toEvent)
+			}
+			fmt.Println()
+			if skipped > 0 {
+				fmt.Printf("Skipped %d %s events without a following %s event or data\n", skipped, *fromEvent, *toEvent)
+			}
+		}
+		if *window != "" {
+			length, err := clipper.ParseSeconds(*window)
+			if err != nil {
+				fmt.Printf("Error in --window: %v\n", err)
+				os.Exit(1)
+			}
+			every := length
+			if *step != "" {
+				if every, err = clipper.ParseSeconds(*step); err != nil {
+					fmt.Printf("Error in --step: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			var skipped int
+			if parsed, skipped, err = clipper.WindowSegments(dataset, length, every); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Found %d windows of %s every %s\n", len(parsed), clipper.FormatDuration(length), clipper.FormatDuration(every))
+			if skipped > 0 {
+				fmt.Printf("Skipped %d windows without data\n", skipped)
+			}
+		}
+		clipSegments(dataset, *output, clipper.ClipConfig{Segments: parsed, Relative: *relative, Rebase: *rebase, Clamp: *clamp}, *perSegment, loader)
+		return
+	}
+	if *perSegment || *toEvent != "" || *eventsFile != "" || *eventColumn != "" || *keepZero || *step != "" {
+		fmt.Println("Error: --per-segment needs --segments, --from-event or --window, --to-event, --events-file and --event-column need --from-event, --keep-zero needs --split-column, and --step needs --window")
+		os.Exit(1)
+	}
+
+	if framed && (timed || *relative || *clamp) {
+		fmt.Println("Error: --start-frame and --end-frame can't be combined with --start, --end, --relative or --clamp")
+		os.Exit(1)
+	}
+	filterOnly := !timed && !framed && (*participants != "" || *conditions != "")
+	if *input == "" || *output == "" || (!filterOnly && !framed && (*startTime == "" || *endTime == "")) {
+		fs.Usage()
+		fmt.Printf("Input, output, start, and end (or duration) are required fields (or --start-frame/--end-frame or --segments instead of start and end, or only --participants/--conditions, or a --config file of clips).\n")
+		fmt.Printf("Sample usage: mbdvr clip --input 'data.csv' --output 'clipped.csv' --start 10.0 --end 20.0\n")
+		os.Exit(1)
+	}
+
+	var start, end float64
+	var startAnchor, endAnchor clipper.Anchor
+	if timed {
+		var err error
+		if start, startAnchor, err = clipper.ParseClipTime(*startTime); err != nil {
+			fmt.Printf("Error: --start: %v\n", err)
+			os.Exit(1)
+		}
+		if end, endAnchor, err = clipper.ParseClipTime(*endTime); err != nil {
+			fmt.Printf("Error: --end: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch {
+	case filterOnly:
+		fmt.Printf("Filtering data: %s → %s\n", *input, *output)
+	case framed:
+		fmt.Printf("Clipping data: %s → %s (frames %s to %s)\n", *input, *output, frameLabel(*startFrame, "first"), frameLabel(*endFrame, "last"))
+	case *relative:
+		fmt.Printf("Clipping data: %s → %s (%s to %s seconds into each participant's data)\n", *input, *output, *startTime, *endTime)
+	default:
+		fmt.Printf("Clipping data: %s → %s (%s to %s seconds)\n", *input, *output, *startTime, *endTime)
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+	original := len(dataset.Points)
+	dataset = filterCohort(dataset, *participants, *conditions)
+
+	if filterOnly {
+		if *rebase {
+			dataset = clipper.Rebase(dataset)
+		}
+		if err := loader.SaveDataset(dataset, *output); err != nil {
+			fmt.Printf("Error saving filtered dataset: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Retained: %d of %d points (%.1f%%)\n", len(dataset.Points), original, float64(len(dataset.Points))/float64(original)*100)
+		fmt.Printf("Saved to: %s\n", *output)
+		return
+	}
+
+	clipConfig := clipper.ClipConfig{Relative: *relative, Rebase: *rebase, Clamp: *clamp}
+
+	if framed {
+		if *startFrame >= 0 {
+			clipConfig.StartFrame = startFrame
+		}
+		if *endFrame >= 0 {
+			clipConfig.EndFrame = endFrame
+		}
+	} else {
+		clipConfig.StartTime, clipConfig.StartAnchor = &start, startAnchor
+		clipConfig.EndTime, clipConfig.EndAnchor = &end, endAnchor
+	}
+
+	// Perform clipping
+	clippedDataset, info, err := clipper.ClipDataset(dataset, clipConfig)
+	if err != nil {
+		fmt.Printf("Error clipping data: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Save clipped dataset
+	err = loader.SaveDataset(clippedDataset, *output)
+	if err != nil {
+		fmt.Printf("Error saving clipped dataset: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Print clipping summary
+	fmt.Printf("Data clipped successfully!\n")
+	fmt.Printf("Original: %d points (%.3fs to %.3fs, %s)\n",
+		info.OriginalPoints,
+		info.MinTimestamp,
+		info.MaxTimestamp,
+		clipper.FormatDuration(info.TotalDuration))
+
+	fmt.Printf("Clipped: %d points (%.3fs to %.3fs, %s)\n",
+		info.ClippedPoints,
+		info.ActualStartTime,
+		info.ActualEndTime,
+		clipper.FormatDuration(info.ActualEndTime-info.ActualStartTime))
+	printClamps(info, "")
+	if info.SkippedParticipants > 0 {
+		fmt.Printf("Skipped %d participants with no data from %ss to %ss\n", info.SkippedParticipants, *startTime, *endTime)
+	}
+
+	if framed {
+		fmt.Printf("Frames: %d to %d\n", info.StartFrame, info.EndFrame)
+	}
+
+	if clipConfig.StartTime != nil || clipConfig.EndTime != nil {
+		fmt.Printf("Requested range: %.3fs to %.3fs\n", info.RequestedStart, info.RequestedEnd)
+
+		if clipConfig.StartTime != nil {
+			diff := math.Abs(info.ActualStartTime - info.RequestedStart)
+			fmt.Printf("Start frame difference: %.3fs\n", diff)
+		}
+		if clipConfig.EndTime != nil {
+			diff := math.Abs(info.ActualEndTime - info.RequestedEnd)
+			fmt.Printf("End frame difference: %.3fs\n", diff)
+		}
+	}
+
+	if *rebase {
+		fmt.Printf("Rebased: timestamps start at 0s for each participant\n")
+	}
+
+	retentionPercent := float64(info.ClippedPoints) / float64(info.OriginalPoints) * 100
+	fmt.Printf("Retained: %.1f%% of original data\n", retentionPercent)
+	fmt.Printf("Saved to: %s\n", *output)
+}
+
+// clipBatch runs the clips of a batch config file in order, loading an input once for consecutive clips of it
+func clipBatch(filename string, splitRows int, splitMB float64) {
+	clips, err := clipper.LoadBatch(filename)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Running %d clips from %s\n", len(clips), filename)
+	runClips(clips, &loader.Loader{SplitRows: splitRows, SplitMB: splitMB})
+}
+
+// clipFiles clips each file matching pattern on its own, with the same settings, into outputDir
+func clipFiles(pattern, outputDir string, template clipper.BatchClip, loader *loader.Loader) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		fmt.Printf("Error: invalid pattern %s: %v\n", pattern, err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Printf("Error: no files found matching pattern %s\n", pattern)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Printf("Error: failed to create output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	clips := make([]clipper.BatchClip, len(files))
+	for i, file := range files {
+		clip := template
+		clip.Input, clip.Output = file, filepath.Join(outputDir, filepath.Base(file))
+		if same, err := filepath.Abs(clip.Output); err == nil {
+			if in, err := filepath.Abs(file); err == nil && in == same {
+				fmt.Printf("Error: clipping %s would overwrite it; choose another --output-dir\n", file)
+				os.Exit(1)
+			}
+		}
+		clips[i] = clip
+	}
+	fmt.Printf("Clipping %d files matching %s → %s\n", len(files), pattern, outputDir)
+	runClips(clips, loader)
+}
+
+// runClips runs clips in order, loading each input once for consecutive clips of it, and stops at the
+// first that fails
+func runClips(clips []clipper.BatchClip, loader *loader.Loader) {
+	var dataset *types.Dataset
+	var err error
+	loaded := ""
+	for i, clip := range clips {
+		label := fmt.Sprintf("clip %d", i+1)
+		heading := fmt.Sprintf("Clip %d of %d", i+1, len(clips))
+		if clip.Line > 0 {
+			label += fmt.Sprintf(" (line %d)", clip.Line)
+			heading += fmt.Sprintf(" (line %d)", clip.Line)
+		}
+		fmt.Printf("\n%s: %s → %s\n", heading, clip.Input, clip.Output)
+		failed := func(err error) {
+			fmt.Printf("Error in %s: %v\n", label, err)
+			os.Exit(1)
+		}
+		if clip.Input != loaded {
+			if dataset, err = loader.LoadFiles(clip.Input); err != nil {
+				failed(fmt.Errorf("failed to load input: %v", err))
+			}
+			trackInput(clip.Input, len(dataset.Points))
+			loaded = clip.Input
+		}
+
+		if clip.Events != nil {
+			segments, skipped, err := clipper.EventSegments(dataset, dataset.Events, *clip.Events)
+			if err != nil {
+				failed(err)
+			}
+			fmt.Printf("Found %d segments from %s events\n", len(segments), clip.Events.FromEvent)
+			if skipped > 0 {
+				fmt.Printf("Skipped %d %s events without a following %s event or data\n", skipped, clip.Events.FromEvent, clip.Events.ToEvent)
+			}
+			clip.Config.Segments = segments
+		}
+		if clip.Config.Segments != nil {
+			clipSegments(dataset, clip.Output, clip.Config, clip.PerSegment, loader)
+			continue
+		}
+
+		clipped, info, err := clipper.ClipDataset(dataset, clip.Config)
+		if err != nil {
+			failed(err)
+		}
+		if err := loader.SaveDataset(clipped, clip.Output); err != nil {
+			failed(fmt.Errorf("failed to save clipped dataset: %v", err))
+		}
+		fmt.Printf("Clipped: %d of %d points (%.3fs to %.3fs, %s)\n", info.ClippedPoints, info.OriginalPoints,
+			info.ActualStartTime, info.ActualEndTime, clipper.FormatDuration(info.ActualEndTime-info.ActualStartTime))
+		printClamps(info, "")
+		fmt.Printf("Saved to: %s\n", clip.Output)
+	}
+	fmt.Printf("\nFinished %d clips\n", len(clips))
+}
+
+// printClamps reports how far --clamp moved a clip's start and end into the recording (moved the other
+// way, the range would be empty and the clip would have failed)
+func printClamps(info clipper.ClipInfo, indent string) {
+	if info.StartClamped != 0 {
+		fmt.Printf("%sClamped start: moved %.3fs later, to the first timestamp\n", indent, info.StartClamped)
+	}
+	if info.EndClamped != 0 {
+		fmt.Printf("%sClamped end: moved %.3fs earlier, to the last timestamp\n", indent, -info.EndClamped)
+	}
+}
+
+// clipSegments cuts several segments from one loaded dataset, into one joined dataset or one file each
+func clipSegments(dataset *types.Dataset, output string, config clipper.ClipConfig, perSegment bool, loader *loader.Loader) {
+	segments := config.Segments
+	fmt.Printf("Clipping %d segments → %s\n", len(segments), output)
+
+	clipped, infos, err := clipper.ClipSegments(dataset, config)
+	if err != nil {
+		fmt.Printf("Error clipping data: %v\n", err)
+		os.Exit(1)
+	}
+
+	total := 0
+	for i, info := range infos {
+		total += info.ClippedPoints
+		line := fmt.Sprintf("Segment %d (%s): %d points (%.3fs to %.3fs, %s)", i+1, segments[i],
+			info.ClippedPoints, info.ActualStartTime, info.ActualEndTime, clipper.FormatDuration(info.ActualEndTime-info.ActualStartTime))
+		if perSegment {
+			path := clipper.SegmentPath(output, i+1, len(clipped))
+			if err := loader.SaveDataset(clipped[i], path); err != nil {
+				fmt.Printf("Error saving segment %d: %v\n", i+1, err)
+				os.Exit(1)
+			}
+			line += " saved to " + path
+		}
+		fmt.Println(line)
+		printClamps(info, "  ")
+	}
+
+	if !perSegment {
+		joined, err := clipper.ConcatSegments(clipped, infos)
+		if err != nil {
+			fmt.Printf("Error joining segments: %v\n", err)
+			os.Exit(1)
+		}
+		if err := loader.SaveDataset(joined, output); err != nil {
+			fmt.Printf("Error saving clipped dataset: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Retained: %d of %d points (%.1f%%)\n", total, len(dataset.Points), float64(total)/float64(len(dataset.Points))*100)
+	if !perSegment {
+		fmt.Printf("Saved to: %s\n", output)
+	}
+}
+
+// clipOnset trims each participant's data to its onset and, with an end time in config, clips that
+// far from the onset
+func clipOnset(dataset *types.Dataset, output string, columns []string, samples int, config clipper.ClipConfig, loader *loader.Loader) {
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	fmt.Printf("Clipping data from the onset of %s → %s\n", strings.Join(columns, ", "), output)
+
+	trimmed, onsets, unstable, err := clipper.TrimToOnset(dataset, columns, samples)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, onset := range onsets {
+		fmt.Printf("  %s: onset at %.3fs (dropped %d earlier samples)\n", onset.ParticipantID, onset.Time, onset.Dropped)
+	}
+	if len(unstable) > 0 {
+		fmt.Printf("Skipped %d participants without %d consecutive samples with values: %s\n", len(unstable), samples, strings.Join(unstable, ", "))
+	}
+
+	result := trimmed
+	if config.EndTime != nil {
+		if result, _, err = clipper.ClipDataset(trimmed, config); err != nil {
+			fmt.Printf("Error clipping data: %v\n", err)
+			os.Exit(1)
+		}
+		result.Metadata["onset_columns"] = columns
+		result.Metadata[clipper.OnsetMetadataKey] = trimmed.Metadata[clipper.OnsetMetadataKey]
+	} else if config.Rebase {
+		result = clipper.Rebase(trimmed)
+	}
+	if err := loader.SaveDataset(result, output); err != nil {
+		fmt.Printf("Error saving clipped dataset: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Retained: %d of %d points (%.1f%%)\n", len(result.Points), len(dataset.Points), float64(len(result.Points))/float64(len(dataset.Points))*100)
+	fmt.Printf("Saved to: %s\n", output)
+}
+
+// filterCohort keeps the participants and conditions named in the comma-separated lists (empty = all)
+func filterCohort(dataset *types.Dataset, participants, conditions string) *types.Dataset {
+	if participants == "" && conditions == "" {
+		return dataset
+	}
+	var keepParticipants, keepConditions []string
+	if participants != "" {
+		keepParticipants = strings.Split(participants, ",")
+	}
+	if conditions != "" {
+		keepConditions = strings.Split(conditions, ",")
+	}
+	filtered, err := clipper.FilterDataset(dataset, keepParticipants, keepConditions)
+	if err != nil {
+		fmt.Printf("Error filtering data: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Kept %d of %d points from the selected participants and conditions\n", len(filtered.Points), len(dataset.Points))
+	return filtered
+}
+
+// splitTrials writes each trial of a dataset to its own file
+func splitTrials(dataset *types.Dataset, output string, config clipper.TrialConfig, loader *loader.Loader) {
+	datasets, trials, err := clipper.SplitTrials(dataset, config)
+	if err != nil {
+		fmt.Printf("Error splitting trials: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Splitting %d trials → %s\n", len(trials), output)
+
+	total := 0
+	for i, t := range trials {
+		path := clipper.TrialPath(output, t.Number, len(trials))
+		if err := loader.SaveDataset(datasets[i], path); err != nil {
+			fmt.Printf("Error saving trial %d: %v\n", t.Number, err)
+			os.Exit(1)
+		}
+		total += t.Points
+		label := t.ParticipantID
+		if label != "" {
+			label += " "
+		}
+		fmt.Printf("Trial %d (%strial %d, marker %s): %d points (%.3fs to %.3fs) saved to %s\n",
+			t.Number, label, t.ParticipantTrial, t.Marker, t.Points, t.Start, t.End, path)
+	}
+	fmt.Printf("Retained: %d of %d points (%.1f%%)\n", total, len(dataset.Points), float64(total)/float64(len(dataset.Points))*100)
+}
+
+// frameLabel prints a frame index, or name when it isn't set
+func frameLabel(frame int, name string) string {
+	if frame < 0 {
+		return name
+	}
+	return strconv.Itoa(frame)
+}
+
+// percentileSummary lists a column's percentiles for the stats summary lines
+func percentileSummary(s stats.ColumnStats) string {
+	var sb strings.Builder
+	for _, p := range s.Percentiles {
+		fmt.Fprintf(&sb, " | %s: %.3f", p.Label(), p.Value)
+	}
+	return sb.String()
+}
+
+// bootstrapSummary gives a column's bootstrap intervals for the stats summary lines
+func bootstrapSummary(s stats.ColumnStats, level float64) string {
+	if s.Bootstrap == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf(" | Mean %g%% CI: [%.3f, %.3f] | Median %g%% CI: [%.3f, %.3f]",
+		level*100, s.MeanLower, s.MeanUpper, level*100, s.MedianLower, s.MedianUpper)
+	if s.BootstrapUnit == "participant" {
+		summary += " (over participant means)"
+	}
+	return summary
+}
+
+func printFrequencies(title string, groups map[string][]stats.FrequencyTable) {
+	printed := false
+	for group, tables := range groups {
+		for _, table := range tables {
+			if !printed {
+				fmt.Printf("\n%s:\n", title)
+				printed = true
+			}
+			label := table.Column
+			if group != "" {
+				label = group + " / " + table.Column
+			}
+			fmt.Printf("Column: %s | Total: %d | Categories: %d | Mode: %g (%d, %.1f%%)\n",
+				label, table.Total, len(table.Categories), table.Mode, table.ModeCount, 100*float64(table.ModeCount)/float64(table.Total))
+		}
+	}
+}
+
+func statsCommand() {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	inputs := fs.String("inputs", "", "Comma-separated input CSV files (required)")
+	analyzeColumns := fs.String("analyze", "", "Comma-separated columns to analyze (required)")
+	byCondition := fs.Bool("by-condition", true, "Group statistics by condition")
+	byParticipant := fs.Bool("by-participant", false, "Group statistics by participant")
+	output := fs.String("output", "", "Output file for detailed results (optional)")
+	outlierMethod := fs.String("outlier-method", "zscore", "Outlier counting method: 'zscore' or 'iqr' (use the same as clean to get matching counts)")
+	zThreshold := fs.Float64("z-threshold", 3.0, "Z-score threshold for outlier counting")
+	digits := fs.Int("digits", 0, "Significant digits in formatted tables (default: 4 decimal places)")
+	fields := fs.String("fields", "", "Comma-separated statistics to show in formatted tables ("+strings.Join(stats.FieldNames(), ",")+", or percentiles such as p5,p95)")
+	layout := fs.String("layout", "", "Print statistics as a table: 'wide' (one row per column) or 'long' (one row per statistic)")
+	markdown := fs.Bool("markdown", false, "Print statistics as Markdown tables")
+	histogramBins := fs.Int("histogram-bins", 20, "Number of bins per column for --histograms")
+	histogramOutput := fs.String("histograms", "", "Export per-column histograms to a CSV or JSON (.json) file")
+	trim := fs.Float64("trim", 0.2, "Proportion trimmed from each tail for the trimmed mean")
+	winsorize := fs.Float64("winsorize", 0.2, "Proportion clamped in each tail for the winsorized mean")
+	percentiles := fs.String("percentiles", "", "Comma-separated percentiles to report for each column, e.g. '10,50,90' (default: 5,25,75,95)")
+	bootstrap := fs.Int("bootstrap", 0, "Bootstrap resamples for --confidence intervals of each column's mean and median, e.g. 2000 (0 = none)")
+	bootstrapSeed := fs.Int64("bootstrap-seed", 1, "Random seed for --bootstrap; the same seed and data give the same intervals")
+	quantileOutput := fs.String("quantiles", "", "Export per-column quantile functions to a CSV file")
+	quantileSteps := fs.Int("quantile-steps", 100, "Number of quantile steps for --quantiles (100 = percentiles)")
+	ecdfOutput := fs.String("ecdf", "", "Export per-column empirical CDFs to a CSV file")
+	maxRows := fs.Int("max-rows", 0, "Stop after loading this many rows from each input (0 = no limit)")
+	sampleEvery := fs.Int("sample-every", 0, "Analyze only every Nth row for a quick exploratory run")
+	categorical := fs.String("categorical", "", "Comma-separated categorical columns to summarize as frequency tables (e.g. scene or object IDs)")
+	radial := fs.String("radial", "", "Gaze columns 'x:y' for a radial (bullseye) analysis of distance from --radial-center")
+	radialCenter := fs.String("radial-center", "0.5,0.5", "Center point 'x,y' for --radial, in the gaze column units")
+	radialBands := fs.String("radial-bands", "0.05,0.1,0.2,0.3", "Comma-separated radii for the proportion of gaze within each band")
+	radialBin := fs.Float64("radial-bin", 0, "Seconds per timeline bin for the radial band proportions over time (0 = no timeline)")
+	radialOutput := fs.String("radial-output", "", "Export radial band proportions (and timeline) to a CSV file")
+	tracking := fs.String("tracking", "", "Gaze and target columns 'x:y:target_x:target_y' for target-tracking error metrics")
+	trackingTrial := fs.String("tracking-trial", "", "Trial ID column for --tracking (default: one trial per participant and condition)")
+	trackingMaxLag := fs.Float64("tracking-max-lag", 1, "Largest lag in seconds searched when cross-correlating gaze with the target")
+	trackingOutput := fs.String("tracking-output", "", "Export per-trial tracking metrics to a CSV file")
+	trackingSeries := fs.String("tracking-series", "", "Export the per-sample tracking error time series to a CSV file")
+	gapThreshold := fs.Float64("gap-threshold", 0, "Report gaps between samples longer than this many seconds and the resulting data loss (0 = off)")
+	gapsOutput := fs.String("gaps-output", "", "Export the gaps report (start, end, duration per gap) to a CSV file")
+	grandAverage := fs.String("grand-average", "", "Export pointwise across-participant averages of the --analyze columns per condition and time (with CIs) to a CSV file; align timestamps with resample --align first")
+	grandAveragePlot := fs.String("grand-average-plot", "", "Render the grand average time courses with ±1 SE bands as an SVG figure, one panel per --analyze column")
+	confidence := fs.Float64("confidence", 0.95, "Confidence level of the --grand-average, --effect-sizes and --bootstrap intervals")
+	sensitivity := fs.String("sensitivity", "", "Leave-one-participant-out sensitivity of each condition's "+strings.Join(stats.SensitivityStatistics, " or ")+" of the --analyze columns")
+	sensitivityOutput := fs.String("sensitivity-output", "", "Export the --sensitivity shifts per left-out participant to a CSV file")
+	correlations := fs.String("correlations", "", "Export the Pearson and Spearman correlation matrices of the --analyze columns, overall and per condition, to a CSV file")
+	ttest := fs.String("ttest", "", "Compare two conditions (A,B) with Welch's and paired t-tests of the --analyze columns over participant means")
+	ttestOutput := fs.String("ttest-output", "", "Export the --ttest results to a CSV file")
+	anova := fs.Bool("anova", false, "One-way ANOVA of the --analyze columns across all conditions, over participant means")
+	anovaOutput := fs.String("anova-output", "", "Export the --anova results with per-condition descriptives to a CSV file")
+	effectSizes := fs.Bool("effect-sizes", false, "Cohen's d and Hedges' g with confidence intervals of the --analyze columns for every pair of conditions, over participant means")
+	effectSizesOutput := fs.String("effect-sizes-output", "", "Export the --effect-sizes results to a CSV file")
+	coverage := fs.Bool("coverage", false, "Print the coverage matrix: points per participant for each input and condition")
+	coverageOutput := fs.String("coverage-output", "", "Export the coverage matrix to a CSV file")
+	diagnosticsOutput := fs.String("diagnostics", "", "Save the warnings about the inputs and the analysis as CSV, or JSON with a .json extension")
+
+	fs.Parse(os.Args[2:])
+
+	if *inputs == "" || (*analyzeColumns == "" && *categorical == "" && *radial == "" && *tracking == "" && *gapThreshold <= 0) {
+		fmt.Println("Error: --inputs and --analyze (or --categorical, --radial, --tracking or --gap-threshold) are required")
+		fmt.Println("\nExample:")
+		fmt.Println("  mbdvr stats --inputs \"boring.csv,interesting.csv\" --analyze \"gaze_x,gaze_y,pupil_size\"")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	inputFiles := strings.Split(*inputs, ",")
+	for i := range inputFiles {
+		inputFiles[i] = strings.TrimSpace(inputFiles[i])
+	}
+
+	var columns []string
+	if *analyzeColumns != "" {
+		columns = strings.Split(*analyzeColumns, ",")
+		for i := range columns {
+			columns[i] = strings.TrimSpace(columns[i])
+		}
+	}
+
+	var categoricalColumns []string
+	if *categorical != "" {
+		categoricalColumns = strings.Split(*categorical, ",")
+		for i := range categoricalColumns {
+			categoricalColumns[i] = strings.TrimSpace(categoricalColumns[i])
+		}
+	}
+
+	diagnostics := &diag.Collector{}
+	loader := &loader.Loader{MaxRows: *maxRows, SampleEvery: *sampleEvery, Diagnostics: diagnostics}
+	var allPoints []types.DataPoint
 	var allColumns []string
+	inputCoverage := &stats.Coverage{}
 	for _, file := range inputFiles {
 		dataset, err := loader.LoadFiles(file)
 		if err != nil {
 			fmt.Printf("Error loading file %s: %v\n", file, err)
 			os.Exit(1)
 		}
-		allPoints = append(allPoints, dataset.Points...)
-		allColumns = append(allColumns, dataset.Columns...)
+		trackInput(file, len(dataset.Points))
+		inputCoverage.Add(file, dataset.Points)
+		allPoints = append(allPoints, dataset.Points...)
+		allColumns = append(allColumns, dataset.Columns...)
+	}
+
+	// Remove duplicate columns
+	columnSet := make(map[string]struct{})
+	for _, col := range allColumns {
+		columnSet[col] = struct{}{}
+	}
+	uniqueColumns := make([]string, 0, len(columnSet))
+	for col := range columnSet {
+		uniqueColumns = append(uniqueColumns, col)
+	}
+
+	dataset := &types.Dataset{
+		Points:  allPoints,
+		Columns: uniqueColumns,
+	}
+
+	statsConfig := stats.StatsConfig{
+		ByCondition:     *byCondition,
+		ByParticipant:   *byParticipant,
+		AnalyzeColumns:  columns,
+		OutlierMethod:   *outlierMethod,
+		ZScoreThreshold: *zThreshold,
+
+		CategoricalColumns: categoricalColumns,
+
+		TrimProportion:      *trim,
+		WinsorizeProportion: *winsorize,
+
+		Bootstrap:     *bootstrap,
+		BootstrapSeed: *bootstrapSeed,
+
+		Diagnostics: diagnostics,
+	}
+	if *bootstrap < 0 {
+		fmt.Println("Error: --bootstrap must not be negative")
+		os.Exit(1)
+	}
+	if *percentiles != "" {
+		statsConfig.Percentiles = []float64{}
+		for _, field := range strings.Split(*percentiles, ",") {
+			p, err := strconv.ParseFloat(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(field)), "p"), 64)
+			if err != nil || p < 0 || p > 100 {
+				fmt.Printf("Error: invalid percentile %q in --percentiles (use numbers from 0 to 100)\n", field)
+				os.Exit(1)
+			}
+			statsConfig.Percentiles = append(statsConfig.Percentiles, p)
+		}
+	}
+	if *histogramOutput != "" {
+		if *histogramBins < 1 {
+			fmt.Println("Error: --histogram-bins must be at least 1")
+			os.Exit(1)
+		}
+		statsConfig.HistogramBins = *histogramBins
+	}
+	if *quantileOutput != "" || *ecdfOutput != "" {
+		if *quantileSteps < 1 {
+			fmt.Println("Error: --quantile-steps must be at least 1")
+			os.Exit(1)
+		}
+		statsConfig.QuantileSteps = *quantileSteps
+	}
+	if *radial != "" {
+		cols := strings.Split(*radial, ":")
+		if len(cols) != 2 {
+			fmt.Printf("Error: invalid --radial %q (use x:y)\n", *radial)
+			os.Exit(1)
+		}
+		radialConfig := &stats.RadialConfig{X: strings.TrimSpace(cols[0]), Y: strings.TrimSpace(cols[1]), TimeBin: *radialBin}
+		if _, err := fmt.Sscanf(*radialCenter, "%f,%f", &radialConfig.CenterX, &radialConfig.CenterY); err != nil {
+			fmt.Printf("Error: invalid --radial-center %q (use x,y)\n", *radialCenter)
+			os.Exit(1)
+		}
+		for _, band := range strings.Split(*radialBands, ",") {
+			radius, err := strconv.ParseFloat(strings.TrimSpace(band), 64)
+			if err != nil || radius <= 0 {
+				fmt.Printf("Error: invalid radius %q in --radial-bands\n", band)
+				os.Exit(1)
+			}
+			radialConfig.Bands = append(radialConfig.Bands, radius)
+		}
+		statsConfig.Radial = radialConfig
+	}
+	if *tracking != "" {
+		cols := strings.Split(*tracking, ":")
+		if len(cols) != 4 {
+			fmt.Printf("Error: invalid --tracking %q (use x:y:target_x:target_y)\n", *tracking)
+			os.Exit(1)
+		}
+		for i := range cols {
+			cols[i] = strings.TrimSpace(cols[i])
+		}
+		if *trackingMaxLag <= 0 {
+			fmt.Println("Error: --tracking-max-lag must be positive")
+			os.Exit(1)
+		}
+		statsConfig.Tracking = &stats.TrackingConfig{
+			X:           cols[0],
+			Y:           cols[1],
+			TargetX:     cols[2],
+			TargetY:     cols[3],
+			TrialColumn: *trackingTrial,
+			MaxLag:      *trackingMaxLag,
+		}
+	}
+	if *gapThreshold < 0 {
+		fmt.Println("Error: --gap-threshold must not be negative")
+		os.Exit(1)
+	}
+	statsConfig.GapThreshold = *gapThreshold
+	if *grandAverage != "" || *grandAveragePlot != "" {
+		if len(columns) == 0 {
+			fmt.Println("Error: --grand-average and --grand-average-plot need the columns to average in --analyze")
+			os.Exit(1)
+		}
+		statsConfig.GrandAverage = true
+	}
+	statsConfig.ConfidenceLevel = *confidence
+	if *sensitivityOutput != "" && *sensitivity == "" {
+		*sensitivity = "mean"
+	}
+	if *sensitivity != "" {
+		if len(columns) == 0 {
+			fmt.Println("Error: --sensitivity needs the columns to analyze in --analyze")
+			os.Exit(1)
+		}
+		statsConfig.Sensitivity = *sensitivity
+	}
+	if *correlations != "" {
+		if len(columns) < 2 {
+			fmt.Println("Error: --correlations needs at least two columns in --analyze")
+			os.Exit(1)
+		}
+		statsConfig.Correlations = true
+	}
+	if *ttest != "" {
+		conditions := strings.Split(*ttest, ",")
+		if len(conditions) != 2 || conditions[0] == "" || conditions[1] == "" {
+			fmt.Println("Error: --ttest takes two conditions as A,B")
+			os.Exit(1)
+		}
+		if len(columns) == 0 {
+			fmt.Println("Error: --ttest needs the columns to analyze in --analyze")
+			os.Exit(1)
+		}
+		statsConfig.TTestConditions = conditions
+	} else if *ttestOutput != "" {
+		fmt.Println("Error: --ttest-output needs --ttest")
+		os.Exit(1)
+	}
+	if *anova || *anovaOutput != "" {
+		if len(columns) == 0 {
+			fmt.Println("Error: --anova needs the columns to analyze in --analyze")
+			os.Exit(1)
+		}
+		statsConfig.ANOVA = true
+	}
+	if *effectSizes || *effectSizesOutput != "" {
+		if len(columns) == 0 {
+			fmt.Println("Error: --effect-sizes needs the columns to analyze in --analyze")
+			os.Exit(1)
+		}
+		statsConfig.EffectSizes = true
+	}
+	if len(columns) == 0 {
+		// Only frequency tables were requested
+		statsConfig.AnalyzeColumns = []string{}
+	}
+
+	report, err := stats.ComputeStats(dataset, statsConfig)
+	if err != nil {
+		fmt.Printf("Error computing statistics: %v\n", err)
+		os.Exit(1)
+	}
+	pooled := len(inputFiles) > 1
+	if pooled || *coverage || *coverageOutput != "" {
+		report.Coverage = inputCoverage
+	}
+	// Imbalanced pooling is worth a warning even when the matrix itself wasn't asked for
+	if *coverage || pooled {
+		for _, w := range inputCoverage.Warnings() {
+			diagnostics.Add(diag.Diagnostic{Stage: "stats", Kind: diag.Coverage, Message: w})
+		}
+	}
+	report.Diagnostics = diagnostics.Diagnostics()
+
+	formatOpts := stats.FormatOptions{
+		Digits:   *digits,
+		Layout:   *layout,
+		Markdown: *markdown,
+	}
+	if *fields != "" {
+		formatOpts.Fields = strings.Split(*fields, ",")
+		for i := range formatOpts.Fields {
+			formatOpts.Fields[i] = strings.TrimSpace(formatOpts.Fields[i])
+		}
+	}
+	formatted := *digits > 0 || *fields != "" || *layout != "" || *markdown
+
+	if formatted {
+		table, err := report.Format(formatOpts)
+		if err != nil {
+			fmt.Printf("Error formatting statistics: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(table)
+	}
+
+	// Print summary
+	if !formatted && report.OverallStats != nil {
+		fmt.Println("Overall Statistics:")
+		for _, colStats := range report.OverallStats {
+			fmt.Printf("Column: %s | Count: %d | Min: %.3f | Max: %.3f | Mean: %.3f | Median: %.3f | StdDev: %.3f%s\n",
+				colStats.Column, colStats.Count, colStats.Min, colStats.Max, colStats.Mean, colStats.Median, colStats.StdDev, percentileSummary(colStats)+bootstrapSummary(colStats, report.ConfidenceLevel))
+		}
+	}
+
+	if !formatted && len(report.ConditionStats) > 0 {
+		fmt.Println("\nStatistics by Condition:")
+		for condition, stats := range report.ConditionStats {
+			fmt.Printf("Condition: %s\n", condition)
+			for _, colStats := range stats {
+				fmt.Printf("  Column: %s | Count: %d | Min: %.3f | Max: %.3f | Mean: %.3f | Median: %.3f | StdDev: %.3f%s\n",
+					colStats.Column, colStats.Count, colStats.Min, colStats.Max, colStats.Mean, colStats.Median, colStats.StdDev, percentileSummary(colStats)+bootstrapSummary(colStats, report.ConfidenceLevel))
+			}
+		}
+	}
+
+	if !formatted && len(report.ParticipantStats) > 0 {
+		fmt.Println("\nStatistics by Participant:")
+		for participant, stats := range report.ParticipantStats {
+			fmt.Printf("Participant: %s\n", participant)
+			for _, colStats := range stats {
+				fmt.Printf("  Column: %s | Count: %d | Min: %.3f | Max: %.3f | Mean: %.3f | Median: %.3f | StdDev: %.3f%s\n",
+					colStats.Column, colStats.Count, colStats.Min, colStats.Max, colStats.Mean, colStats.Median, colStats.StdDev, percentileSummary(colStats)+bootstrapSummary(colStats, report.ConfidenceLevel))
+			}
+		}
+	}
+
+	if !formatted {
+		printFrequencies("Overall Frequencies", map[string][]stats.FrequencyTable{"": report.OverallFrequencies})
+		printFrequencies("Frequencies by Condition", report.ConditionFrequencies)
+		printFrequencies("Frequencies by Participant", report.ParticipantFrequencies)
+	}
+
+	if len(report.Radial) > 0 {
+		fmt.Printf("\nRadial Distribution (%s around %s):\n", *radial, *radialCenter)
+		for _, r := range report.Radial {
+			label := r.Grouping
+			if r.Group != "" {
+				label = r.Group
+			}
+			var bands []string
+			for b, radius := range statsConfig.Radial.Bands {
+				bands = append(bands, fmt.Sprintf("<=%g: %.1f%%", radius, r.BandProportions[b]*100))
+			}
+			fmt.Printf("%s | Count: %d | Mean distance: %.3f | Median: %.3f | %s\n",
+				label, r.Count, r.MeanDistance, r.MedianDistance, strings.Join(bands, " | "))
+		}
+	}
+
+	if len(report.TrackingSummaries) > 0 {
+		fmt.Printf("\nTarget Tracking (%s):\n", *tracking)
+		for _, t := range report.TrackingSummaries {
+			condition := t.Condition
+			if condition == "" {
+				condition = "unknown"
+			}
+			fmt.Printf("Condition: %s | Trials: %d | Mean error: %.3f | RMSE: %.3f (SD %.3f) | Lag: %.3f s\n",
+				condition, t.Trials, t.MeanError, t.MeanRMSE, t.SDRMSE, t.MeanLag)
+		}
+	}
+
+	if len(report.GapSummaries) > 0 {
+		fmt.Printf("\nGaps (longer than %gs):\n", *gapThreshold)
+		for _, g := range report.GapSummaries {
+			fmt.Printf("Participant: %s | Duration: %.1fs | Gaps: %d | Longest: %.3fs | Data loss: %.2f%%\n",
+				g.ParticipantID, g.Duration, g.Gaps, g.LongestGap, g.LossPercent)
+		}
+		fmt.Printf("Total data loss: %.2f%%\n", report.TotalLossPercent())
+	}
+
+	if len(report.Sensitivity) > 0 {
+		fmt.Printf("\nLeave-One-Participant-Out Sensitivity (%s):\n", report.SensitivityStatistic)
+		for _, s := range report.Sensitivity {
+			condition := s.Condition
+			if condition == "" {
+				condition = "unknown"
+			}
+			fmt.Printf("Condition: %s | Column: %s | Full: %.3f | Jackknife SE: %.3f | Most influential: %s (shift %+.3f)\n",
+				condition, s.Column, s.Full, s.JackknifeSE, s.MostInfluential, s.MaxShift)
+		}
+	}
+
+	for _, m := range report.Correlations {
+		label := m.Grouping
+		if m.Group != "" {
+			label = m.Group
+		}
+		fmt.Printf("\n%s correlations (%s):\n", strings.ToUpper(m.Method[:1])+m.Method[1:], label)
+		width := 0
+		for _, col := range m.Columns {
+			width = max(width, len(col))
+		}
+		fmt.Printf("%*s", width, "")
+		for _, col := range m.Columns {
+			fmt.Printf("  %*s", max(len(col), 6), col)
+		}
+		fmt.Println()
+		for i, col := range m.Columns {
+			fmt.Printf("%-*s", width, col)
+			for j, other := range m.Columns {
+				fmt.Printf("  %*.3f", max(len(other), 6), m.R[i][j])
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(report.TTests) > 0 {
+		fmt.Printf("\nT-tests (%s vs %s, participant means):\n", report.TTests[0].ConditionA, report.TTests[0].ConditionB)
+		for _, t := range report.TTests {
+			fmt.Printf("  %s: %s, difference %+.4f (%.4f vs %.4f)\n", t.Column, t.Summary(), t.MeanDiff, t.MeanA, t.MeanB)
+		}
+	}
+
+	for _, a := range report.ANOVAs {
+		fmt.Printf("\nANOVA of %s (participant means): %s\n", a.Column, a.Summary())
+		for _, g := range a.Groups {
+			condition := g.Condition
+			if condition == "" {
+				condition = "unknown"
+			}
+			fmt.Printf("  %s: mean %.4f, SD %.4f, n = %d\n", condition, g.Mean, g.SD, g.Participants)
+		}
+	}
+
+	if len(report.EffectSizes) > 0 {
+		fmt.Printf("\nEffect sizes (participant means, %g%% CI):\n", report.ConfidenceLevel*100)
+		for _, e := range report.EffectSizes {
+			fmt.Printf("  %s, %s vs %s (n = %d, %d): d = %.3f [%.3f, %.3f], g = %.3f [%.3f, %.3f]\n",
+				e.Column, e.ConditionA, e.ConditionB, e.NA, e.NB, e.CohensD, e.DLower, e.DUpper, e.HedgesG, e.GLower, e.GUpper)
+		}
+	}
+
+	if *coverage {
+		fmt.Printf("\nCoverage (points per participant, input and condition):\n%s", inputCoverage)
+	}
+	// Formatted tables end with the diagnostics section
+	if !formatted {
+		printDiagnostics(diagnostics)
+	}
+
+	// Optionally save detailed report
+	if *output != "" {
+		var err error
+		if formatted {
+			err = stats.SaveFormattedReport(report, *output, formatOpts)
+		} else {
+			err = stats.SaveReport(report, *output)
+		}
+		if err != nil {
+			fmt.Printf("Error saving report to %s: %v\n", *output, err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nDetailed report saved to %s\n", *output)
+	}
+
+	if *histogramOutput != "" {
+		if err := stats.SaveHistograms(report, *histogramOutput); err != nil {
+			fmt.Printf("Error saving histograms to %s: %v\n", *histogramOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Histograms saved to %s\n", *histogramOutput)
+	}
+
+	if *quantileOutput != "" {
+		if err := stats.SaveQuantiles(report, *quantileOutput); err != nil {
+			fmt.Printf("Error saving quantiles to %s: %v\n", *quantileOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Quantiles saved to %s\n", *quantileOutput)
+	}
+
+	if *ecdfOutput != "" {
+		if err := stats.SaveECDF(report, *ecdfOutput); err != nil {
+			fmt.Printf("Error saving ECDF to %s: %v\n", *ecdfOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("ECDF saved to %s\n", *ecdfOutput)
+	}
+
+	if *radialOutput != "" {
+		if err := stats.SaveRadial(report, *radialOutput); err != nil {
+			fmt.Printf("Error saving radial analysis to %s: %v\n", *radialOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Radial analysis saved to %s\n", *radialOutput)
+	}
+
+	if *trackingOutput != "" {
+		if err := stats.SaveTrackingTrials(report, *trackingOutput); err != nil {
+			fmt.Printf("Error saving tracking metrics to %s: %v\n", *trackingOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Tracking metrics saved to %s\n", *trackingOutput)
+	}
+
+	if *trackingSeries != "" {
+		if err := stats.SaveTrackingSeries(report, *trackingSeries); err != nil {
+			fmt.Printf("Error saving tracking error series to %s: %v\n", *trackingSeries, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Tracking error series saved to %s\n", *trackingSeries)
 	}
 
-	// Remove duplicate columns
-	columnSet := make(map[string]struct{})
-	for _, col := range allColumns {
-		columnSet[col] = struct{}{}
+	if *correlations != "" {
+		if err := stats.SaveCorrelations(report, *correlations); err != nil {
+			fmt.Printf("Error saving correlations to %s: %v\n", *correlations, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Correlations saved to %s\n", *correlations)
 	}
-	uniqueColumns := make([]string, 0, len(columnSet))
-	for col := range columnSet {
-		uniqueColumns = append(uniqueColumns, col)
+
+	if *ttestOutput != "" {
+		if err := stats.SaveTTests(report, *ttestOutput); err != nil {
+			fmt.Printf("Error saving t-tests to %s: %v\n", *ttestOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("T-tests saved to %s\n", *ttestOutput)
 	}
 
-	dataset := &types.Dataset{
-		Points:  allPoints,
-		Columns: uniqueColumns,
+	if *anovaOutput != "" {
+		if err := stats.SaveANOVAs(report, *anovaOutput); err != nil {
+			fmt.Printf("Error saving ANOVA to %s: %v\n", *anovaOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("ANOVA saved to %s\n", *anovaOutput)
 	}
 
-	statsConfig := stats.StatsConfig{
-		ByCondition:    *byCondition,
-		ByParticipant:  *byParticipant,
-		AnalyzeColumns: columns,
+	if *effectSizesOutput != "" {
+		if err := stats.SaveEffectSizes(report, *effectSizesOutput); err != nil {
+			fmt.Printf("Error saving effect sizes to %s: %v\n", *effectSizesOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Effect sizes saved to %s\n", *effectSizesOutput)
 	}
 
-	report, err := stats.ComputeStats(dataset, statsConfig)
+	if *gapsOutput != "" {
+		if err := stats.SaveGaps(report, *gapsOutput); err != nil {
+			fmt.Printf("Error saving gaps report to %s: %v\n", *gapsOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Gaps report saved to %s\n", *gapsOutput)
+	}
+
+	if *grandAverage != "" {
+		if err := stats.SaveGrandAverages(report, *grandAverage); err != nil {
+			fmt.Printf("Error saving grand averages to %s: %v\n", *grandAverage, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Grand averages saved to %s\n", *grandAverage)
+	}
+	if *grandAveragePlot != "" {
+		if err := stats.SaveGrandAveragePlot(report, *grandAveragePlot); err != nil {
+			fmt.Printf("Error saving grand average plot to %s: %v\n", *grandAveragePlot, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Grand average plot saved to %s\n", *grandAveragePlot)
+	}
+
+	if *sensitivityOutput != "" {
+		if err := stats.SaveSensitivity(report, *sensitivityOutput); err != nil {
+			fmt.Printf("Error saving sensitivity analysis to %s: %v\n", *sensitivityOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Sensitivity analysis saved to %s\n", *sensitivityOutput)
+	}
+	if *coverageOutput != "" {
+		if err := stats.SaveCoverage(inputCoverage, *coverageOutput); err != nil {
+			fmt.Printf("Error saving coverage matrix to %s: %v\n", *coverageOutput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Coverage matrix saved to %s\n", *coverageOutput)
+	}
+	saveDiagnostics(diagnostics, *diagnosticsOutput)
+}
+
+func transformCommand() {
+	fs := flag.NewFlagSet("transform", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file (required)")
+	output := fs.String("output", "", "Output data file (required)")
+	from := fs.String("from", "", "Source coordinate frame, e.g. 'pixels,top-left' or 'normalized,center,y-up' (default: frame recorded in the input)")
+	to := fs.String("to", transform.CanonicalFrame.String(), "Target coordinate frame")
+	screen := fs.String("screen", "", "Screen size in pixels for pixel frames, e.g. '1920x1080'")
+	pairs := fs.String("pairs", "", "Comma-separated x:y column pairs to convert (default: all *gaze_x/*gaze_y pairs)")
+	ops := fs.String("ops", "", "Geometric transforms applied in order, e.g. 'flip-y,rotate:180,scale:2,offset:0.1:0' (flip-x, flip-y, swap, rotate:DEG, scale:S[:SY], offset:DX:DY)")
+	center := fs.String("center", "", "Center for flips and rotations as 'x,y' (default: screen center of the coordinate frame)")
+	exprs := fs.String("expr", "", "Semicolon-separated column expressions evaluated per point, e.g. 'speed = hypot(vel_x, vel_y); fast = speed > 30'")
+	filter := fs.String("filter", "", "Keep only points where this expression is true, e.g. 'pupil_size > 0 && condition == \"boring\"'")
+	scale := fs.String("scale", "", "Normalize columns: 'zscore' (mean 0, SD 1) or 'minmax' (0 to 1); use a .mbd or .db output to keep the parameters for --unscale")
+	scaleColumns := fs.String("scale-columns", "", "Comma-separated columns to normalize with --scale (default: all data columns)")
+	scalePerParticipant := fs.Bool("scale-per-participant", false, "Normalize each participant's values separately")
+	unscale := fs.Bool("unscale", false, "Restore the original values of the scaling recorded in the input's metadata")
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+
+	toSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "to" {
+			toSet = true
+		}
+	})
+	// Without other transforms the command converts to the target frame; otherwise only when a frame is given
+	convert := (*ops == "" && *exprs == "" && *filter == "" && *scale == "" && !*unscale) || *from != "" || toSet
+
+	operations, err := transform.ParseOperations(*ops)
 	if err != nil {
-		fmt.Printf("Error computing statistics: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Print summary
-	if report.OverallStats != nil {
-		fmt.Println("Overall Statistics:")
-		for _, colStats := range report.OverallStats {
-			fmt.Printf("Column: %s | Count: %d | Min: %.3f | Max: %.3f | Mean: %.3f | Median: %.3f | StdDev: %.3f\n",
-				colStats.Column, colStats.Count, colStats.Min, colStats.Max, colStats.Mean, colStats.Median, colStats.StdDev)
+	script := transform.ScriptConfig{}
+	if script.Assignments, err = expr.ParseAssignments(*exprs); err != nil {
+		fmt.Printf("Error in --expr: %v\n", err)
+		os.Exit(1)
+	}
+	if *filter != "" {
+		if script.Filter, err = expr.Compile(*filter); err != nil {
+			fmt.Printf("Error in --filter: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
-	if len(report.ConditionStats) > 0 {
-		fmt.Println("\nStatistics by Condition:")
-		for condition, stats := range report.ConditionStats {
-			fmt.Printf("Condition: %s\n", condition)
-			for _, colStats := range stats {
-				fmt.Printf("  Column: %s | Count: %d | Min: %.3f | Max: %.3f | Mean: %.3f | Median: %.3f | StdDev: %.3f\n",
-					colStats.Column, colStats.Count, colStats.Min, colStats.Max, colStats.Mean, colStats.Median, colStats.StdDev)
+	if *input == "" || *output == "" {
+		fs.Usage()
+		fmt.Printf("Input and output are required fields.\n")
+		fmt.Printf("Sample usage: mbdvr transform --input 'data.csv' --output 'normalized.csv' --from 'pixels,bottom-left' --screen 1920x1080\n")
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+
+	var width, height float64
+	if *screen != "" {
+		if _, err := fmt.Sscanf(*screen, "%fx%f", &width, &height); err != nil {
+			fmt.Printf("Error: invalid --screen %q (use WIDTHxHEIGHT)\n", *screen)
+			os.Exit(1)
+		}
+	}
+
+	config := transform.CoordinateConfig{}
+	if *from != "" {
+		if config.From, err = transform.ParseFrame(*from); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		config.From.Width, config.From.Height = width, height
+	}
+	if config.To, err = transform.ParseFrame(*to); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.To.Width, config.To.Height = width, height
+
+	if *pairs != "" {
+		for _, pair := range strings.Split(*pairs, ",") {
+			cols := strings.Split(strings.TrimSpace(pair), ":")
+			if len(cols) != 2 {
+				fmt.Printf("Error: invalid column pair %q (use x:y)\n", pair)
+				os.Exit(1)
+			}
+			config.Pairs = append(config.Pairs, [2]string{cols[0], cols[1]})
+		}
+	} else {
+		for _, col := range dataset.Columns {
+			if strings.HasSuffix(col, "gaze_x") {
+				config.Pairs = append(config.Pairs, [2]string{col, strings.TrimSuffix(col, "x") + "y"})
 			}
 		}
 	}
 
-	if len(report.ParticipantStats) > 0 {
-		fmt.Println("\nStatistics by Participant:")
-		for participant, stats := range report.ParticipantStats {
-			fmt.Printf("Participant: %s\n", participant)
-			for _, colStats := range stats {
-				fmt.Printf("  Column: %s | Count: %d | Min: %.3f | Max: %.3f | Mean: %.3f | Median: %.3f | StdDev: %.3f\n",
-					colStats.Column, colStats.Count, colStats.Min, colStats.Max, colStats.Mean, colStats.Median, colStats.StdDev)
+	transformed := dataset
+	if convert {
+		transformed, err = transform.NormalizeCoordinates(dataset, config)
+		if err != nil {
+			fmt.Printf("Error transforming coordinates: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Converted %d column pairs to %s\n", len(config.Pairs), transformed.Metadata[transform.FrameMetadataKey])
+	}
+
+	if len(operations) > 0 {
+		geometry := transform.GeometryConfig{Pairs: config.Pairs, Operations: operations}
+		if *center != "" {
+			if _, err := fmt.Sscanf(*center, "%f,%f", &geometry.CenterX, &geometry.CenterY); err != nil {
+				fmt.Printf("Error: invalid --center %q (use x,y)\n", *center)
+				os.Exit(1)
+			}
+		} else if transform.NeedsCenter(operations) {
+			frame, ok, err := transform.RecordedFrame(transformed)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !ok && config.From.Units != "" {
+				frame, ok = config.From, true
+			}
+			if !ok {
+				fmt.Printf("Error: coordinate frame is unknown; specify --center or --from\n")
+				os.Exit(1)
 			}
+			if frame.Units == "pixels" && (frame.Width <= 0 || frame.Height <= 0) {
+				fmt.Printf("Error: screen size is unknown; specify --center or --screen\n")
+				os.Exit(1)
+			}
+			geometry.CenterX, geometry.CenterY = frame.Center()
+		}
+
+		transformed, err = transform.ApplyGeometry(transformed, geometry)
+		if err != nil {
+			fmt.Printf("Error transforming coordinates: %v\n", err)
+			os.Exit(1)
 		}
+		fmt.Printf("Applied %s to %d column pairs\n", *ops, len(config.Pairs))
 	}
 
-	// Optionally save detailed report
-	if *output != "" {
-		err := stats.SaveReport(report, *output)
+	if len(script.Assignments) > 0 || script.Filter != nil {
+		var removed int
+		transformed, removed, err = transform.ApplyExpressions(transformed, script)
 		if err != nil {
-			fmt.Printf("Error saving report to %s: %v\n", *output, err)
+			fmt.Printf("Error evaluating expressions: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("\nDetailed report saved to %s\n", *output)
+		if len(script.Assignments) > 0 {
+			fmt.Printf("Computed %d expression columns\n", len(script.Assignments))
+		}
+		if script.Filter != nil {
+			fmt.Printf("Filter removed %d points, kept %d\n", removed, len(transformed.Points))
+		}
+	}
+
+	if *unscale {
+		var n int
+		transformed, n, err = transform.UnscaleColumns(transformed)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Undid %d recorded scalings\n", n)
+	}
+
+	if *scale != "" {
+		scaleConfig := transform.ScaleConfig{Method: *scale, PerParticipant: *scalePerParticipant}
+		if *scaleColumns != "" {
+			scaleConfig.Columns = strings.Split(*scaleColumns, ",")
+		} else {
+			scaleConfig.Columns = transformed.Columns[1:]
+		}
+		var scalings []transform.Scaling
+		transformed, scalings, err = transform.ScaleColumns(transformed, scaleConfig)
+		if err != nil {
+			fmt.Printf("Error scaling columns: %v\n", err)
+			os.Exit(1)
+		}
+		for _, s := range scalings {
+			label := s.Column
+			if s.ParticipantID != "" {
+				label = s.ParticipantID + "/" + s.Column
+			}
+			fmt.Printf("Scaled %s (%s): center %.6g, scale %.6g\n", label, s.Method, s.Center, s.Scale)
+		}
+	}
+
+	err = loader.SaveDataset(transformed, *output)
+	if err != nil {
+		fmt.Printf("Error saving dataset: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Saved to: %s\n", *output)
+}
+
+func deriveCommand() {
+	fs := flag.NewFlagSet("derive", flag.ExitOnError)
+	input := fs.String("input", "", "Input data file or glob pattern (required)")
+	output := fs.String("output", "", "Output data file (required)")
+	configFile := fs.String("config", "", "YAML file listing derived columns and filters, applied in order in one pass (required)")
+	cacheDir := fs.String("cache-dir", "", "Directory caching derived datasets by input and config, so unchanged derivations are not recomputed")
+	splitRows, splitMB := addSplitFlags(fs)
+
+	fs.Parse(os.Args[2:])
+
+	if *input == "" || *output == "" || *configFile == "" {
+		fs.Usage()
+		fmt.Printf("Input, output and config are required fields.\n")
+		fmt.Printf("Sample usage: mbdvr derive --input 'data.csv' --config 'features.yaml' --output 'features.mbd'\n")
+		os.Exit(1)
+	}
+
+	steps, err := transform.LoadDerivation(*configFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	loader := &loader.Loader{SplitRows: *splitRows, SplitMB: *splitMB}
+	var cachePath string
+	if *cacheDir != "" {
+		key, err := deriveCacheKey(*input, *configFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		cachePath = filepath.Join(*cacheDir, key+".mbd")
+		if _, err := os.Stat(cachePath); err == nil {
+			derived, err := loader.LoadFiles(cachePath)
+			if err != nil {
+				fmt.Printf("Error loading cached derivation: %v\n", err)
+				os.Exit(1)
+			}
+			if err := loader.SaveDataset(derived, *output); err != nil {
+				fmt.Printf("Error saving derived dataset: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Input and config unchanged; reused the cached derivation %s\n", cachePath)
+			fmt.Printf("Saved to: %s\n", *output)
+			return
+		}
+	}
+
+	dataset, err := loader.LoadFiles(*input)
+	if err != nil {
+		fmt.Printf("Error loading input file: %v\n", err)
+		os.Exit(1)
+	}
+	trackInput(*input, len(dataset.Points))
+
+	derived, removed, err := transform.ApplyExpressions(dataset, transform.ScriptConfig{Steps: steps})
+	if err != nil {
+		fmt.Printf("Error evaluating %s: %v\n", *configFile, err)
+		os.Exit(1)
+	}
+	columns, filters := 0, 0
+	for _, step := range steps {
+		if step.Filter != nil {
+			filters++
+		} else {
+			columns++
+		}
+	}
+	fmt.Printf("Computed %d columns", columns)
+	if filters > 0 {
+		fmt.Printf("; %d filters removed %d points, kept %d", filters, removed, len(derived.Points))
+	}
+	fmt.Println()
+
+	if cachePath != "" {
+		if err := os.MkdirAll(*cacheDir, 0755); err != nil {
+			fmt.Printf("Error creating cache directory: %v\n", err)
+			os.Exit(1)
+		}
+		if err := loader.SaveDataset(derived, cachePath); err != nil {
+			fmt.Printf("Error caching derived dataset: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := loader.SaveDataset(derived, *output); err != nil {
+		fmt.Printf("Error saving derived dataset: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved to: %s\n", *output)
+}
+
+// deriveCacheKey fingerprints a derivation: the input files (names and contents), the config and the
+// mbdvr version
+func deriveCacheKey(pattern, configFile string) (string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to find files matching pattern %s: %v", pattern, err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no files found matching pattern %s", pattern)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", version.Current())
+	for _, file := range append(matches, configFile) {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.Base(file))
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", file, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func versionCommand() {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	check := fs.Bool("check", false, "Check GitHub for a newer release")
+	pin := fs.Bool("pin", false, "Pin the current version in the study manifest ("+version.ManifestName+" in the working directory)")
+	study := fs.String("study", "", "Study name recorded by --pin")
+
+	fs.Parse(os.Args[2:])
+
+	current := version.Current()
+	fmt.Printf("mbdvr %s\n", current)
+
+	if path, err := version.FindManifest("."); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	} else if path != "" && !*pin {
+		manifest, err := version.LoadManifest(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if manifest.PinnedVersion != "" {
+			fmt.Printf("Study manifest %s pins mbdvr %s\n", path, manifest.PinnedVersion)
+		}
+	}
+	warnPinnedVersion()
+
+	if *pin {
+		manifest := &version.Manifest{}
+		if _, err := os.Stat(version.ManifestName); err == nil {
+			if manifest, err = version.LoadManifest(version.ManifestName); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *study != "" {
+			manifest.Study = *study
+		}
+		manifest.PinnedVersion = current
+		if err := version.SaveManifest(version.ManifestName, manifest); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Pinned mbdvr %s in %s\n", current, version.ManifestName)
+	}
+
+	if *check {
+		latest, err := version.Latest()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if c, err := version.Compare(current, latest); err != nil || c < 0 {
+			fmt.Printf("A newer release is available: %s\n", latest)
+			fmt.Println("Download it from https://github.com/mbd888/mbdvr/releases")
+		} else {
+			fmt.Println("mbdvr is up to date")
+		}
+	}
+}
+
+// warnPinnedVersion prints a prominent warning when the study manifest pins a different tool version
+func warnPinnedVersion() {
+	warning, err := version.CheckPin(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	if warning == "" {
+		return
+	}
+	banner := strings.Repeat("!", 72)
+	fmt.Fprintf(os.Stderr, "%s\nWARNING: %s\n%s\n", banner, warning, banner)
+}
+
+// runInput accumulates the data read by the current command for the local usage metrics
+var runInput struct {
+	bytes  int64
+	points int
+}
+
+func trackInput(pattern string, points int) {
+	files, _ := filepath.Glob(pattern)
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			runInput.bytes += info.Size()
+		}
+	}
+	runInput.points += points
+}
+
+// usageFile returns the local metrics log: $MBDVR_USAGE_FILE, or the study's log when its manifest enables metrics
+func usageFile() (string, string) {
+	path, err := version.FindManifest(".")
+	var manifest *version.Manifest
+	if err == nil && path != "" {
+		manifest, _ = version.LoadManifest(path)
+	}
+	study := ""
+	if manifest != nil {
+		study = manifest.Study
+	}
+
+	if env := os.Getenv("MBDVR_USAGE_FILE"); env != "" {
+		return env, study
+	}
+	if manifest == nil || !manifest.UsageMetrics {
+		return "", study
+	}
+	if study == "" {
+		study = filepath.Base(filepath.Dir(path))
+	}
+	return filepath.Join(filepath.Dir(path), usage.FileName), study
+}
+
+// recordUsage logs a successful run when metrics are enabled; nothing is ever sent over the network
+func recordUsage(command string, started time.Time) {
+	if command == "usage" || command == "version" {
+		return
+	}
+	path, study := usageFile()
+	if path == "" {
+		return
+	}
+	err := usage.Append(path, usage.Record{
+		Time:       started,
+		Study:      study,
+		Command:    command,
+		Duration:   time.Since(started).Seconds(),
+		InputBytes: runInput.bytes,
+		Points:     runInput.points,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+func usageCommand() {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	file := fs.String("file", "", "Usage metrics log (default: $MBDVR_USAGE_FILE or "+usage.FileName+" next to the study manifest)")
+	since := fs.String("since", "", "Only include runs on or after this date (YYYY-MM-DD)")
+	output := fs.String("output", "", "Save the report to a file (.md for Markdown)")
+
+	fs.Parse(os.Args[2:])
+
+	if *file == "" {
+		*file, _ = usageFile()
+	}
+	if *file == "" {
+		fmt.Println("Usage metrics are not enabled.")
+		fmt.Printf("Set \"usage_metrics\": true in the study manifest (%s) or MBDVR_USAGE_FILE, or pass --file.\n", version.ManifestName)
+		os.Exit(1)
+	}
+
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = time.ParseInLocation("2006-01-02", *since, time.Local); err != nil {
+			fmt.Printf("Error: invalid --since %q (use YYYY-MM-DD)\n", *since)
+			os.Exit(1)
+		}
+	}
+
+	records, err := usage.Load(*file)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	summaries := usage.Summarize(records, from)
+
+	markdown := strings.HasSuffix(strings.ToLower(*output), ".md")
+	var sb strings.Builder
+	if markdown {
+		sb.WriteString("# Lab Usage Report\n\n")
+		sb.WriteString("| study | command | runs | total time (h) | input (MB) | points | first run | last run |\n")
+		sb.WriteString("|---|---|---|---|---|---|---|---|\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("%-20s %-10s %6s %14s %12s %14s %-10s %s\n", "study", "command", "runs", "total time (h)", "input (MB)", "points", "first run", "last run"))
+	}
+
+	var runs, points int
+	var seconds float64
+	var bytes int64
+	for _, s := range summaries {
+		study := s.Study
+		if study == "" {
+			study = "(none)"
+		}
+		cells := []interface{}{study, s.Command, s.Runs, s.Duration / 3600, float64(s.InputBytes) / 1e6, s.Points, s.First.Format("2006-01-02"), s.Last.Format("2006-01-02")}
+		if markdown {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d | %.2f | %.1f | %d | %s | %s |\n", cells...))
+		} else {
+			sb.WriteString(fmt.Sprintf("%-20s %-10s %6d %14.2f %12.1f %14d %-10s %s\n", cells...))
+		}
+		runs += s.Runs
+		points += s.Points
+		seconds += s.Duration
+		bytes += s.InputBytes
+	}
+	if markdown {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("Total: %d runs, %.2f hours, %.1f MB read, %d points\n", runs, seconds/3600, float64(bytes)/1e6, points))
+
+	if *output == "" {
+		fmt.Print(sb.String())
+		return
+	}
+	if err := os.WriteFile(*output, []byte(sb.String()), 0644); err != nil {
+		fmt.Printf("Error saving report: %v\n", err)
+		os.Exit(1)
 	}
+	fmt.Printf("Usage report saved to %s\n", *output)
 }
@@ -0,0 +1,182 @@
+package cleaner
+
+import (
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Flag columns added by a dry run, 1 for rows the stage would act on and 0 otherwise
+const (
+	DuplicateFlag     = "is_duplicate"
+	LowConfidenceFlag = "is_low_confidence"
+	BlinkFlag         = "is_blink"
+	VelocityFlag      = "is_implausible_velocity"
+	MissingFlag       = "is_missing"
+	FilteredFlag      = "is_filtered"
+	OutlierFlagPrefix = "is_outlier_" // Followed by the column name
+	RemovedFlag       = "is_removed"  // Any reason; the row would not be in the cleaned output
+)
+
+// Reject is an input row removed by cleaning. Reason is the flag name of the stage that removed
+// it without the "is_" prefix: duplicate, low_confidence, blink, implausible_velocity, filtered, missing or
+// outlier_<column>.
+type Reject struct {
+	Point  types.DataPoint
+	Reason string
+}
+
+// rowColumn tags every point with its input row during a dry run or when collecting rejects, so
+// rows can be traced through stages that copy, reorder and drop points. It never reaches the output.
+const rowColumn = "\x00row"
+
+// rowAudit collects per-row flags and removal reasons while the pipeline runs on tagged copies of the input
+type rowAudit struct {
+	input   []types.DataPoint
+	flags   map[string]map[int]bool // Flag column -> flagged input rows
+	order   []string
+	reasons map[int]string // Input row -> first stage that removed it
+}
+
+// newRowAudit returns the audit and the tagged points to clean
+func newRowAudit(points []types.DataPoint) (*rowAudit, []types.DataPoint) {
+	tagged := make([]types.DataPoint, len(points))
+	for i, p := range points {
+		data := types.CloneData(p.Data, 1)
+		data[rowColumn] = float64(i)
+		p.Data = data
+		tagged[i] = p
+	}
+	return &rowAudit{input: points, flags: make(map[string]map[int]bool), reasons: make(map[int]string)}, tagged
+}
+
+// add makes sure a flag column is written, even if no row ends up flagged
+func (a *rowAudit) add(name string) {
+	if _, ok := a.flags[name]; !ok {
+		a.flags[name] = make(map[int]bool)
+		a.order = append(a.order, name)
+	}
+}
+
+func (a *rowAudit) flag(name string, p types.DataPoint) {
+	a.add(name)
+	if row, ok := p.Data[rowColumn]; ok {
+		a.flags[name][int(row)] = true
+	}
+}
+
+// reject records why a row was removed, unless an earlier stage already removed it
+func (a *rowAudit) reject(name string, p types.DataPoint) {
+	row, ok := p.Data[rowColumn]
+	if !ok {
+		return
+	}
+	if _, seen := a.reasons[int(row)]; !seen {
+		a.reasons[int(row)] = strings.TrimPrefix(name, "is_")
+	}
+}
+
+// dropped flags the rows a stage removed and records the stage as their removal reason
+func (a *rowAudit) dropped(name string, before, after []types.DataPoint) {
+	a.add(name)
+	kept := make(map[float64]bool, len(after))
+	for _, p := range after {
+		kept[p.Data[rowColumn]] = true
+	}
+	for _, p := range before {
+		if !kept[p.Data[rowColumn]] {
+			a.flag(name, p)
+			a.reject(name, p)
+		}
+	}
+}
+
+// points returns a copy of the input with the flag columns added, given the points that survived cleaning
+func (a *rowAudit) points(final []types.DataPoint) []types.DataPoint {
+	kept := make(map[int]bool, len(final))
+	for _, p := range final {
+		kept[int(p.Data[rowColumn])] = true
+	}
+	a.add(RemovedFlag)
+	for i := range a.input {
+		if !kept[i] {
+			a.flags[RemovedFlag][i] = true
+		}
+	}
+
+	points := make([]types.DataPoint, len(a.input))
+	for i, p := range a.input {
+		data := types.CloneData(p.Data, len(a.order))
+		for _, name := range a.order {
+			data[name] = 0
+			if a.flags[name][i] {
+				data[name] = 1
+			}
+		}
+		p.Data = data
+		points[i] = p
+	}
+	return points
+}
+
+// rejects returns the removed input rows in input order
+func (a *rowAudit) rejects() []Reject {
+	var rejects []Reject
+	for i, p := range a.input {
+		if reason, ok := a.reasons[i]; ok {
+			rejects = append(rejects, Reject{Point: p, Reason: reason})
+		}
+	}
+	return rejects
+}
+
+// untag removes the row tags from cleaned points
+func untag(points []types.DataPoint) []types.DataPoint {
+	result := make([]types.DataPoint, len(points))
+	for i, p := range points {
+		p.Data = maps.Clone(p.Data)
+		delete(p.Data, rowColumn)
+		result[i] = p
+	}
+	return result
+}
+
+// SaveRejects writes removed rows as CSV in the layout of the cleaned output (columns follows
+// Dataset.Columns, timestamp first) with an added removal_reason column
+func SaveRejects(rejects []Reject, columns []string, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	var dataCols []string
+	if len(columns) > 0 {
+		dataCols = columns[1:]
+	}
+	w := csv.NewWriter(f)
+	w.Write(append(append([]string{"timestamp", "participant_id", "condition"}, dataCols...), "removal_reason"))
+	row := make([]string, len(dataCols)+4)
+	for _, r := range rejects {
+		row[0] = fmt.Sprintf("%f", r.Point.Timestamp)
+		row[1] = r.Point.ParticipantID
+		row[2] = r.Point.Condition
+		for i, col := range dataCols {
+			row[i+3] = ""
+			if v, ok := r.Point.Data[col]; ok {
+				row[i+3] = fmt.Sprintf("%f", v)
+			}
+		}
+		row[len(row)-1] = r.Reason
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
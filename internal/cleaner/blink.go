@@ -0,0 +1,155 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+
+	"mbdvr/internal/events"
+	"mbdvr/internal/roles"
+	"mbdvr/internal/types"
+)
+
+// BlinkColumn is added by the "label" blink action: 1 during a blink, 0 otherwise
+const BlinkColumn = "blink"
+
+type blink struct {
+	indices    []int // Point indices inside the blink, in timestamp order
+	before     int   // Last valid point before the blink (-1 if none)
+	after      int   // First valid point after the blink
+	start, end float64
+}
+
+// detectBlinks finds runs of samples with a pupil dropout (missing, NaN or <= 0) or an invalid
+// validity flag whose duration lies within [minDuration, maxDuration]. Longer runs are tracking loss.
+func detectBlinks(points []types.DataPoint, config CleanConfig) []blink {
+	invalid := func(p types.DataPoint) bool {
+		if config.BlinkValidityColumn != "" {
+			if v, ok := p.Data[config.BlinkValidityColumn]; ok && v == config.BlinkInvalidValue {
+				return true
+			}
+		}
+		if config.BlinkPupilColumn != "" {
+			v, ok := p.Data[config.BlinkPupilColumn]
+			return !ok || math.IsNaN(v) || v <= 0
+		}
+		return false
+	}
+
+	// Each sample is 1 during a dropout, so the detector's exit sample is the first valid one after it
+	detector := events.Hysteresis{Enter: 1, Exit: 1, MinDuration: config.MinBlinkDuration, MaxDuration: config.MaxBlinkDuration}
+
+	var blinks []blink
+	for _, idx := range types.RecordingIndices(points) {
+		times := make([]float64, len(idx))
+		signal := make([]float64, len(idx))
+		for k, i := range idx {
+			times[k] = points[i].Timestamp
+			if invalid(points[i]) {
+				signal[k] = 1
+			}
+		}
+
+		for _, iv := range detector.Detect(times, signal) {
+			// A dropout at the end of the recording has no measurable duration
+			if iv.Open {
+				continue
+			}
+			b := blink{indices: idx[iv.Start:iv.End], before: -1, after: idx[iv.End], start: iv.StartTime, end: iv.EndTime}
+			if iv.Start > 0 {
+				b.before = idx[iv.Start-1]
+			}
+			blinks = append(blinks, b)
+		}
+	}
+	return blinks
+}
+
+func handleBlinks(points []types.DataPoint, columns []string, config CleanConfig) ([]types.DataPoint, []string, []blink, error) {
+	if config.BlinkPupilColumn == "" && config.BlinkValidityColumn == "" {
+		// Default to the pupil column found by role inference
+		inferred, err := roles.Infer(&types.Dataset{Points: points, Columns: columns}, nil)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if config.BlinkPupilColumn, _ = inferred.First(roles.Pupil); config.BlinkPupilColumn == "" {
+			return nil, nil, nil, fmt.Errorf("blink detection needs a pupil or validity column")
+		}
+	}
+
+	blinks := detectBlinks(points, config)
+	inBlink := make(map[int]bool)
+	for _, b := range blinks {
+		for _, i := range b.indices {
+			inBlink[i] = true
+		}
+	}
+
+	switch config.BlinkAction {
+	case "remove":
+		var kept []types.DataPoint
+		for i, p := range points {
+			if !inBlink[i] {
+				kept = append(kept, p)
+			}
+		}
+		return kept, columns, blinks, nil
+
+	case "interpolate":
+		cols := targetColumns(config, columns)
+		result := make([]types.DataPoint, len(points))
+		copy(result, points)
+		for _, b := range blinks {
+			// Blinks at the start of a recording have no sample to interpolate from
+			if b.before < 0 {
+				continue
+			}
+			p0, p1 := points[b.before], points[b.after]
+			for _, i := range b.indices {
+				data := maps.Clone(points[i].Data)
+				frac := 0.0
+				if p1.Timestamp > p0.Timestamp {
+					frac = (points[i].Timestamp - p0.Timestamp) / (p1.Timestamp - p0.Timestamp)
+				}
+				for _, col := range cols {
+					v0, ok0 := p0.Data[col]
+					v1, ok1 := p1.Data[col]
+					if ok0 && ok1 {
+						data[col] = v0 + (v1-v0)*frac
+					} else {
+						delete(data, col)
+					}
+				}
+				result[i].Data = data
+			}
+		}
+		return result, columns, blinks, nil
+
+	case "label":
+		result := make([]types.DataPoint, len(points))
+		for i, p := range points {
+			data := types.CloneData(p.Data, 1)
+			data[BlinkColumn] = 0
+			if inBlink[i] {
+				data[BlinkColumn] = 1
+			}
+			result[i] = p
+			result[i].Data = data
+		}
+		return result, appendColumn(columns, BlinkColumn), blinks, nil
+
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown blink action %q (use 'remove', 'interpolate' or 'label')", config.BlinkAction)
+	}
+}
+
+func appendColumn(columns []string, col string) []string {
+	for _, c := range columns {
+		if c == col {
+			return columns
+		}
+	}
+	result := make([]string, len(columns), len(columns)+1)
+	copy(result, columns)
+	return append(result, col)
+}
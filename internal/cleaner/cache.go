@@ -0,0 +1,179 @@
+package cleaner
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"mbdvr/internal/types"
+)
+
+// stageCache is the state of a cleaning run after one pipeline stage, saved so later stages can be
+// re-run without repeating it. Hash identifies the input and the stage settings up to and including it.
+type stageCache struct {
+	Hash    string
+	Points  []types.DataPoint
+	Columns []string
+	Events  []types.Event
+	Stats   CleanStats
+	Ran     map[string]bool
+
+	Sentinels, OutOfRange, Outliers, OutlierValues map[string]int
+}
+
+// stepIDs returns the unique name of each pipeline step: its ID, or the stage name numbered from the
+// second occurrence on ("smooth", "smooth-2")
+func stepIDs(pipeline []Stage) ([]string, error) {
+	ids := make([]string, len(pipeline))
+	seen := make(map[string]bool)
+	count := make(map[string]int)
+	for i, s := range pipeline {
+		id := s.ID
+		if id == "" {
+			count[s.Name]++
+			id = s.Name
+			if count[s.Name] > 1 {
+				id = s.Name + "-" + strconv.Itoa(count[s.Name])
+			}
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("pipeline stage name %q is used twice", id)
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// stageRange resolves FromStage and ToStage to step indices (0 and len-1 when not set)
+func stageRange(ids []string, from, to string) (int, int, error) {
+	find := func(id string) (int, error) {
+		for i, s := range ids {
+			if s == id {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("pipeline has no stage %q (stages: %v)", id, ids)
+	}
+	start, end := 0, len(ids)-1
+	var err error
+	if from != "" {
+		if start, err = find(from); err != nil {
+			return 0, 0, err
+		}
+	}
+	if to != "" {
+		if end, err = find(to); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("stage %s comes after stage %s", from, to)
+	}
+	return start, end, nil
+}
+
+func writeFloat(h hash.Hash, v float64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	h.Write(buf[:])
+}
+
+// datasetHash fingerprints the points and events a run starts from
+func datasetHash(dataset *types.Dataset) string {
+	h := sha256.New()
+	for _, col := range dataset.Columns {
+		fmt.Fprintf(h, "%s\x00", col)
+	}
+	var keys []string
+	for _, p := range dataset.Points {
+		writeFloat(h, p.Timestamp)
+		fmt.Fprintf(h, "%s\x00%s\x00", p.ParticipantID, p.Condition)
+		keys = keys[:0]
+		for key := range p.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(h, "%s\x00", key)
+			writeFloat(h, p.Data[key])
+		}
+	}
+	for _, e := range dataset.Events {
+		fmt.Fprintf(h, "%#v\x00", e)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// stageHash chains the hash of everything before a step with the settings the step runs with. Settings
+// that don't change the step's output (run control, the pipeline itself) are left out.
+func stageHash(previous, id string, step Stage, config CleanConfig) string {
+	config.Pipeline = nil
+	config.DryRun, config.CollectRejects = false, false
+	config.CacheDir, config.FromStage, config.ToStage = "", "", ""
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%#v", previous, id, step.Name, config)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func cachePath(dir, id string) string {
+	return filepath.Join(dir, id+".cache")
+}
+
+// save writes the run state after the step id to the cache directory
+func (r *cleanRun) save(dir, id, hash string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	f, err := os.Create(cachePath(dir, id))
+	if err != nil {
+		return fmt.Errorf("failed to create stage cache: %v", err)
+	}
+	defer f.Close()
+
+	cache := stageCache{Hash: hash, Points: r.points, Columns: r.columns, Events: r.events, Stats: r.stats, Ran: r.ran,
+		Sentinels: r.sentinels, OutOfRange: r.outOfRange, Outliers: r.outliers, OutlierValues: r.outlierValues}
+	if err := gob.NewEncoder(f).Encode(cache); err != nil {
+		return fmt.Errorf("failed to write stage cache: %v", err)
+	}
+	return nil
+}
+
+// restore replaces the run state with the cached output of the step id, which must have been produced
+// from the same input and stage settings
+func (r *cleanRun) restore(dir, id, hash string) error {
+	f, err := os.Open(cachePath(dir, id))
+	if err != nil {
+		return fmt.Errorf("no cached output of stage %s to resume from; run the pipeline up to it with a cache directory first", id)
+	}
+	defer f.Close()
+
+	var cache stageCache
+	if err := gob.NewDecoder(f).Decode(&cache); err != nil {
+		return fmt.Errorf("failed to read the cached output of stage %s: %v", id, err)
+	}
+	if cache.Hash != hash {
+		return fmt.Errorf("the cached output of stage %s is stale: the input or a stage up to it has changed since; re-run from an earlier stage", id)
+	}
+
+	r.points, r.columns, r.events, r.stats, r.ran = cache.Points, cache.Columns, cache.Events, cache.Stats, cache.Ran
+	r.sentinels, r.outOfRange, r.outliers, r.outlierValues = cache.Sentinels, cache.OutOfRange, cache.Outliers, cache.OutlierValues
+	// gob leaves empty maps nil
+	for _, m := range []*map[string]int{&r.sentinels, &r.outOfRange, &r.outliers, &r.outlierValues} {
+		if *m == nil {
+			*m = make(map[string]int)
+		}
+	}
+	if r.ran == nil {
+		r.ran = make(map[string]bool)
+	}
+	return nil
+}
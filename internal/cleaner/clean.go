@@ -2,9 +2,14 @@ package cleaner
 
 import (
 	"fmt"
+	"maps"
 	"math"
 	"sort"
+	"strings"
 
+	"mbdvr/internal/diag"
+	"mbdvr/internal/expr"
+	"mbdvr/internal/transform"
 	"mbdvr/internal/types"
 )
 
@@ -14,49 +19,620 @@ type CleanConfig struct {
 	OutlierMethod     string  // "iqr" or "zscore"
 	MaxMissingPercent float64 // 0-100, max % of missing data per row
 	ZScoreThreshold   float64 // for zscore outlier detection
+	OutlierGrouping   string  // "" (whole dataset), "participant" or "participant-condition": where outlier bounds are computed
+	OutlierWindow     float64 // Seconds; with "zscore", judge each value against a rolling window instead of fixed bounds (0 = off)
+	OutlierAction     string  // "remove" (the rows, default), "winsorize" (clamp the values to the bounds) or "nan" (mark the values missing)
+	DryRun            bool    // Keep every input row and add is_* flag columns for what cleaning would do instead
+	CollectRejects    bool    // Return the removed input rows with their removal reason in CleanStats.Rejects
+	Pipeline          []Stage // Stages to run in this order, instead of the DefaultStages this config enables
+	CacheDir          string  // Save the output of every stage here, so FromStage can resume from it ("" = no cache)
+	FromStage         string  // Resume at this stage from the cached output of the stage before it ("" = first stage)
+	ToStage           string  // Stop after this stage ("" = last stage)
+
+	Duplicates         string  // "", "exact" (identical rows) or "near" (also rows within DuplicateTolerance)
+	DuplicateTolerance float64 // Seconds between timestamps of near-duplicates
+	DuplicateKeep      string  // Which near-duplicate survives: "first", "last" or "average"
+
+	FixTimestamps bool          // Re-time repeated and slightly backwards timestamps
+	MaxInversion  float64       // Largest backwards step in seconds that is repaired; larger ones are clock jumps
+	DriftAnchors  []DriftAnchor // Recorded/reference time pairs for a linear clock drift correction
+
+	Sentinels   []Sentinel   // Tracker codes for invalid samples (e.g. -1), marked missing before any other stage
+	ValidRanges []ValidRange // Values outside these ranges are marked missing
+
+	DisparityEyes        []string // Left x, left y, right x, right y gaze columns for binocular disparity
+	MaxDisparity         float64  // Samples diverging more than this are marked missing (0 = only add the disparity column)
+	MinDisparityDuration float64  // Seconds; shorter divergence is left alone
+
+	ConfidenceColumn string  // Per-sample confidence/validity column, e.g. Pupil Labs "confidence"
+	MinConfidence    float64 // Samples below this confidence (or without one) are low confidence
+	ConfidenceAction string  // "drop" (remove the sample) or "nan" (clear its values)
+
+	Interpolate string  // "", "linear" or "cubic": fill missing values from neighbouring samples
+	MaxGap      float64 // Longest gap in seconds that is interpolated (0 = no limit)
+
+	BlinkAction         string  // "", "remove", "interpolate" or "label"
+	BlinkPupilColumn    string  // Pupil column whose dropouts (missing or <= 0) mark blinks (default: first pupil column)
+	BlinkValidityColumn string  // Optional validity flag column
+	BlinkInvalidValue   float64 // Validity flag value that marks an invalid sample
+	MinBlinkDuration    float64 // Seconds; shorter dropouts are not blinks
+	MaxBlinkDuration    float64 // Seconds; longer dropouts are tracking loss (0 = no limit)
+
+	VelocityColumns     []string // Gaze angle columns in degrees (x, y) or a gaze direction vector (x, y, z)
+	MaxVelocity         float64  // Degrees per second; faster samples are implausible tracker glitches (0 = off)
+	MinVelocityDuration float64  // Seconds the speed must stay at or above MaxVelocity (0 = any sample)
+	VelocityAction      string   // "remove", "nan" (clear the target columns) or "label"
+
+	Impute []ImputeRule // Per-column imputation of values still missing before the missing-data filter
+
+	Expressions      string // ';'-separated column expressions evaluated per point, as in transform --expr
+	ExpressionFilter string // Keep only points where this expression is true, as in transform --filter
+
+	Hampel          bool    // Replace isolated spikes with the local median
+	HampelWindow    int     // Window size in samples
+	HampelThreshold float64 // Spikes deviate from the median by more than this many scaled MADs
+
+	Smooth         string  // "", "mean" (moving average), "median" or "savgol" (Savitzky-Golay)
+	SmoothWindow   int     // Window size in samples
+	SmoothWindowMs float64 // Window size in milliseconds; overrides SmoothWindow when set
+	SmoothPoly     int     // Polynomial order for the Savitzky-Golay filter
+
+	Diagnostics *diag.Collector // Receives the warnings raised while cleaning (nil = CleanStats.Diagnostics only)
 }
 
 type CleanStats struct {
-	OriginalPoints  int
-	RemovedMissing  int
-	RemovedOutliers int
-	FinalPoints     int
+	OriginalPoints   int            `json:"original_points"`
+	RemovedMissing   int            `json:"removed_missing"`
+	RemovedOutliers  int            `json:"removed_outliers"`
+	RemovedFiltered  int            `json:"removed_filtered"` // Rows the expression filter dropped
+	OutlierValues    int            `json:"outlier_values"`   // Outlying values winsorized or marked missing instead of removing rows
+	ExactDuplicates  int            `json:"exact_duplicates"` // Identical rows removed
+	NearDuplicates   int            `json:"near_duplicates"`  // Rows merged into a near-duplicate within the tolerance
+	Timestamps       TimestampStats `json:"timestamps"`
+	DriftScale       float64        `json:"drift_scale"`       // Clock rate correction applied (1 = none)
+	DriftOffset      float64        `json:"drift_offset"`      // Clock offset correction applied in seconds
+	LowConfidence    int            `json:"low_confidence"`    // Samples below the confidence threshold
+	SentinelValues   int            `json:"sentinel_values"`   // Values marked missing as tracker sentinels
+	OutOfRange       int            `json:"out_of_range"`      // Values marked missing by the valid-range rules
+	Divergent        int            `json:"divergent_samples"` // Samples marked missing for implausible binocular disparity
+	DivergentPeriods int            `json:"divergent_periods"`
+	Interpolated     int            `json:"values_interpolated"` // Missing values filled by interpolation
+	Imputed          int            `json:"values_imputed"`      // Missing values filled by the imputation rules
+	Blinks           int            `json:"blinks"`
+	BlinkSamples     int            `json:"blink_samples"`
+	BlinkDurations   []float64      `json:"blink_durations"`              // Seconds, in detection order
+	FastSamples      int            `json:"implausible_velocity_samples"` // Samples at or above the velocity limit
+	FastPeriods      int            `json:"implausible_velocity_periods"`
+	SpikesReplaced   int            `json:"spikes_replaced"` // Values replaced by the Hampel filter
+	Smoothed         int            `json:"smoothed"`        // Values replaced by the smoothing filter
+	FinalPoints      int            `json:"final_points"`
+
+	Columns       []ColumnReport      `json:"columns"`                  // Per-column breakdown of the data columns
+	OutlierBounds []OutlierBound      `json:"outlier_bounds,omitempty"` // Bounds used for outlier removal
+	Participants  []ParticipantReport `json:"participants"`
+
+	Diagnostics []diag.Diagnostic `json:"diagnostics,omitempty"` // Warnings from loading and cleaning
+
+	Rejects []Reject `json:"-"` // Removed input rows, with CollectRejects
+}
+
+// cleanRun is the state a cleaning run threads through its stages
+type cleanRun struct {
+	points  []types.DataPoint
+	columns []string
+	events  []types.Event
+	stats   CleanStats
+	audit   *rowAudit       // Records which rows each stage acts on, for dry runs and rejects
+	diag    *diag.Collector // Warnings raised by the stages
+	ran     map[string]bool // Stages that ran, by name
+
+	// Per-column counts for the column reports
+	sentinels, outOfRange, outliers, outlierValues map[string]int
 }
 
 func CleanDataset(dataset *types.Dataset, config CleanConfig) (*types.Dataset, CleanStats, error) {
-	stats := CleanStats{
-		OriginalPoints: len(dataset.Points),
+	r := &cleanRun{
+		points:  dataset.Points,
+		columns: dataset.Columns,
+		events:  dataset.Events,
+		stats: CleanStats{
+			OriginalPoints: len(dataset.Points),
+			DriftScale:     1,
+		},
+		ran:           make(map[string]bool),
+		sentinels:     make(map[string]int),
+		outOfRange:    make(map[string]int),
+		outliers:      make(map[string]int),
+		outlierValues: make(map[string]int),
+		diag:          config.Diagnostics,
+	}
+	// Without a collector, the warnings stored with the input are carried on
+	if r.diag == nil {
+		r.diag = &diag.Collector{}
+		r.diag.Merge(diag.FromMetadata(dataset.Metadata))
+	}
+	config.Diagnostics = nil // Not part of the stage hashes or the recorded config
+
+	// Dry runs and rejects clean tagged copies to record which rows each stage acts on
+	if config.DryRun || config.CollectRejects {
+		r.audit, r.points = newRowAudit(dataset.Points)
 	}
 
-	cleanedPoints := dataset.Points
+	pipeline := config.Pipeline
+	if pipeline == nil {
+		pipeline = defaultPipeline(config)
+	}
+	ids, err := stepIDs(pipeline)
+	if err != nil {
+		return nil, r.stats, err
+	}
+	from, to := 0, len(pipeline)-1
+	if config.FromStage != "" || config.ToStage != "" {
+		if from, to, err = stageRange(ids, config.FromStage, config.ToStage); err != nil {
+			return nil, r.stats, err
+		}
+	}
+	if from > 0 && config.CacheDir == "" {
+		return nil, r.stats, fmt.Errorf("resuming at a later stage needs the cache directory of an earlier run")
+	}
+	if from > 0 && r.audit != nil {
+		return nil, r.stats, fmt.Errorf("dry runs and rejects need the whole pipeline; they can't resume from a cached stage")
+	}
 
-	if config.MaxMissingPercent > 0 {
-		cleanedPoints, stats.RemovedMissing = filterMissingData(cleanedPoints, config.RequiredColumns, config.MaxMissingPercent)
-		fmt.Printf("Removed %d points due to missing data\n", stats.RemovedMissing)
+	// Each stage's hash covers the input and every stage setting up to it, so stale caches are detected
+	hash := ""
+	if config.CacheDir != "" {
+		hash = datasetHash(dataset)
 	}
+	for i, step := range pipeline[:to+1] {
+		stageConfig, err := step.config(config)
+		if err != nil {
+			return nil, r.stats, err
+		}
+		if hash != "" {
+			hash = stageHash(hash, ids[i], step, stageConfig)
+		}
+		if i < from {
+			if i == from-1 {
+				if err := r.restore(config.CacheDir, ids[i], hash); err != nil {
+					return nil, r.stats, err
+				}
+				fmt.Printf("Resuming after stage %s from %s\n", ids[i], config.CacheDir)
+			}
+			continue
+		}
 
-	if config.RemoveOutliers {
-		cleanedPoints, stats.RemovedOutliers = filterOutliers(cleanedPoints, config.RequiredColumns, config.OutlierMethod, config.ZScoreThreshold)
-		fmt.Printf("Removed %d points as outliers\n", stats.RemovedOutliers)
+		if err := stages[step.Name].run(r, stageConfig); err != nil {
+			if config.Pipeline != nil {
+				err = fmt.Errorf("stage %s: %v", ids[i], err)
+			}
+			return nil, r.stats, err
+		}
+		r.ran[step.Name] = true
+		if config.CacheDir != "" {
+			if err := r.save(config.CacheDir, ids[i], hash); err != nil {
+				return nil, r.stats, err
+			}
+		}
+	}
+
+	stats := r.stats
+	cleanedPoints := r.points
+	if config.CollectRejects {
+		stats.Rejects = r.audit.rejects()
+	}
+	if r.audit != nil && !config.DryRun {
+		cleanedPoints = untag(cleanedPoints)
 	}
 
 	stats.FinalPoints = len(cleanedPoints)
+	dataColumns := targetColumns(CleanConfig{}, dataset.Columns)
+	stats.Columns = columnReports(dataset.Points, cleanedPoints, dataColumns, r.sentinels, r.outOfRange, r.outliers, r.outlierValues)
+	stats.Participants = participantReports(dataset.Points, cleanedPoints, dataColumns)
+	r.diag.CheckMissing("clean", "", cleanedPoints, dataColumns)
+	stats.Diagnostics = r.diag.Diagnostics()
 
 	cleanedDataset := &types.Dataset{
 		Points:  cleanedPoints,
-		Columns: dataset.Columns,
+		Columns: r.columns,
+		Events:  r.events,
 		Metadata: map[string]interface{}{
-			"original_points":    stats.OriginalPoints,
-			"cleaned_points":     stats.FinalPoints,
-			"cleaning_config":    config,
-			"points_removed":     stats.OriginalPoints - stats.FinalPoints,
-			"removal_percentage": float64(stats.OriginalPoints-stats.FinalPoints) / float64(stats.OriginalPoints) * 100,
+			"original_points":     stats.OriginalPoints,
+			"cleaned_points":      stats.FinalPoints,
+			"cleaning_config":     config,
+			"points_removed":      stats.OriginalPoints - stats.FinalPoints,
+			"low_confidence":      stats.LowConfidence,
+			"out_of_range":        stats.OutOfRange,
+			"divergent_samples":   stats.Divergent,
+			"values_interpolated": stats.Interpolated,
+			"values_imputed":      stats.Imputed,
+			"spikes_replaced":     stats.SpikesReplaced,
+			"removal_percentage":  float64(stats.OriginalPoints-stats.FinalPoints) / float64(stats.OriginalPoints) * 100,
 		},
 	}
 
+	if r.ran["duplicates"] {
+		cleanedDataset.Metadata["exact_duplicates"] = stats.ExactDuplicates
+		cleanedDataset.Metadata["near_duplicates"] = stats.NearDuplicates
+	}
+	if r.ran["sentinels"] {
+		cleanedDataset.Metadata["sentinel_values"] = stats.SentinelValues
+	}
+	if r.ran["timestamps"] {
+		cleanedDataset.Metadata["timestamps_repeated"] = stats.Timestamps.Repeated
+		cleanedDataset.Metadata["timestamps_inverted"] = stats.Timestamps.Inverted
+		cleanedDataset.Metadata["timestamps_repaired"] = stats.Timestamps.Repaired
+		cleanedDataset.Metadata["clock_jumps"] = stats.Timestamps.ClockJumps
+	}
+	if r.ran["drift"] {
+		cleanedDataset.Metadata["drift_scale"] = stats.DriftScale
+		cleanedDataset.Metadata["drift_offset"] = stats.DriftOffset
+	}
+
+	if r.ran["blink"] {
+		total := 0.0
+		for _, d := range stats.BlinkDurations {
+			total += d
+		}
+		cleanedDataset.Metadata["blink_count"] = stats.Blinks
+		cleanedDataset.Metadata["blink_samples"] = stats.BlinkSamples
+		cleanedDataset.Metadata["blink_total_duration"] = total
+		if stats.Blinks > 0 {
+			cleanedDataset.Metadata["blink_mean_duration"] = total / float64(stats.Blinks)
+		}
+	}
+	if r.ran["velocity"] {
+		cleanedDataset.Metadata["implausible_velocity_samples"] = stats.FastSamples
+		cleanedDataset.Metadata["implausible_velocity_periods"] = stats.FastPeriods
+	}
+	if r.ran["outliers"] && config.OutlierAction != "" && config.OutlierAction != "remove" {
+		cleanedDataset.Metadata["outlier_values"] = stats.OutlierValues
+	}
+	if len(stats.Diagnostics) > 0 {
+		cleanedDataset.Metadata[diag.MetadataKey] = stats.Diagnostics
+	}
+
+	// The dry run output is the input with flags; the statistics describe what cleaning would do
+	if config.DryRun {
+		cleanedDataset.Points = r.audit.points(cleanedPoints)
+		cleanedDataset.Columns = append(append([]string{}, dataset.Columns...), r.audit.order...)
+		cleanedDataset.Events = dataset.Events
+		cleanedDataset.Metadata["dry_run"] = true
+	}
+
 	return cleanedDataset, stats, nil
 }
 
+func cleanSentinels(r *cleanRun, config CleanConfig) error {
+	points, cleared, err := applySentinels(r.points, r.columns, config.Sentinels)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for col, c := range cleared {
+		r.sentinels[col] += c
+		n += c
+	}
+	r.points = points
+	r.stats.SentinelValues += n
+	fmt.Printf("Marked %d sentinel values as missing\n", n)
+	return nil
+}
+
+func cleanDuplicates(r *cleanRun, config CleanConfig) error {
+	before := r.points
+	points, exact, near, err := removeDuplicates(r.points, config.Duplicates, config.DuplicateTolerance, config.DuplicateKeep)
+	if err != nil {
+		return err
+	}
+	r.points = points
+	if r.audit != nil {
+		r.audit.dropped(DuplicateFlag, before, r.points)
+	}
+	r.stats.ExactDuplicates += exact
+	r.stats.NearDuplicates += near
+	fmt.Printf("Removed %d exact duplicates", exact)
+	if config.Duplicates == "near" {
+		fmt.Printf(" and %d near-duplicates (keep: %s)", near, config.DuplicateKeep)
+	}
+	fmt.Println()
+	return nil
+}
+
+func cleanTimestamps(r *cleanRun, config CleanConfig) error {
+	points, ts := repairTimestamps(r.points, config.MaxInversion)
+	r.points = points
+	r.stats.Timestamps.Repeated += ts.Repeated
+	r.stats.Timestamps.Inverted += ts.Inverted
+	r.stats.Timestamps.Repaired += ts.Repaired
+	r.stats.Timestamps.ClockJumps += ts.ClockJumps
+	fmt.Printf("Found %d repeated and %d backwards timestamps, repaired %d\n", ts.Repeated, ts.Inverted, ts.Repaired)
+	if ts.ClockJumps > 0 {
+		r.diag.Warnf("clean", diag.Timestamps, "", "%d backwards clock jumps larger than %gs were left unchanged", ts.ClockJumps, config.MaxInversion)
+	}
+	return nil
+}
+
+func cleanDrift(r *cleanRun, config CleanConfig) error {
+	scale, offset, err := fitDrift(config.DriftAnchors)
+	if err != nil {
+		return err
+	}
+	r.points, r.events = correctDrift(r.points, r.events, scale, offset)
+	r.stats.DriftScale, r.stats.DriftOffset = scale, offset
+	fmt.Printf("Corrected clock drift: %.1f ppm, offset %.4fs\n", (scale-1)*1e6, offset)
+	return nil
+}
+
+func cleanConfidence(r *cleanRun, config CleanConfig) error {
+	if r.audit != nil {
+		r.audit.add(LowConfidenceFlag)
+		for _, p := range r.points {
+			if !confident(p, config.ConfidenceColumn, config.MinConfidence) {
+				r.audit.flag(LowConfidenceFlag, p)
+			}
+		}
+	}
+	before := r.points
+	points, n, err := filterConfidence(r.points, targetColumns(config, r.columns), config.ConfidenceColumn, config.MinConfidence, config.ConfidenceAction)
+	if err != nil {
+		return err
+	}
+	r.points = points
+	if r.audit != nil {
+		r.audit.dropped(LowConfidenceFlag, before, r.points)
+	}
+	r.stats.LowConfidence += n
+	fmt.Printf("Found %d samples below confidence %g (action: %s)\n", n, config.MinConfidence, config.ConfidenceAction)
+	return nil
+}
+
+func cleanRanges(r *cleanRun, config CleanConfig) error {
+	points, cleared, err := applyValidRanges(r.points, r.columns, config.ValidRanges)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for col, c := range cleared {
+		r.outOfRange[col] += c
+		n += c
+	}
+	r.points = points
+	r.stats.OutOfRange += n
+	fmt.Printf("Marked %d out-of-range values as missing\n", n)
+	return nil
+}
+
+func cleanDisparity(r *cleanRun, config CleanConfig) error {
+	points, columns, flagged, periods, err := markDisparity(r.points, r.columns, targetColumns(config, r.columns), config.DisparityEyes, config.MaxDisparity, config.MinDisparityDuration)
+	if err != nil {
+		return err
+	}
+	r.points, r.columns = points, columns
+	r.stats.Divergent += flagged
+	r.stats.DivergentPeriods += periods
+	if config.MaxDisparity > 0 {
+		fmt.Printf("Marked %d samples in %d periods of binocular disparity above %g as missing\n", flagged, periods, config.MaxDisparity)
+	}
+	return nil
+}
+
+func cleanBlinks(r *cleanRun, config CleanConfig) error {
+	before := r.points
+	points, columns, blinks, err := handleBlinks(r.points, r.columns, config)
+	if err != nil {
+		return err
+	}
+	r.points, r.columns = points, columns
+	if r.audit != nil {
+		r.audit.add(BlinkFlag)
+		for _, b := range blinks {
+			for _, i := range b.indices {
+				r.audit.flag(BlinkFlag, before[i])
+			}
+		}
+		r.audit.dropped(BlinkFlag, before, r.points)
+	}
+	samples := 0
+	for _, b := range blinks {
+		samples += len(b.indices)
+		r.stats.BlinkDurations = append(r.stats.BlinkDurations, b.end-b.start)
+	}
+	r.stats.Blinks += len(blinks)
+	r.stats.BlinkSamples += samples
+	fmt.Printf("Detected %d blinks (%d samples, action: %s)\n", len(blinks), samples, config.BlinkAction)
+	return nil
+}
+
+func cleanVelocity(r *cleanRun, config CleanConfig) error {
+	before := r.points
+	implausible, periods, err := detectImplausibleVelocity(r.points, r.columns, config)
+	if err != nil {
+		return err
+	}
+	points, columns, err := handleImplausibleVelocity(r.points, r.columns, targetColumns(config, r.columns), implausible, config.VelocityAction)
+	if err != nil {
+		return err
+	}
+	r.points, r.columns = points, columns
+	if r.audit != nil {
+		r.audit.add(VelocityFlag)
+		for i := range implausible {
+			r.audit.flag(VelocityFlag, before[i])
+		}
+		r.audit.dropped(VelocityFlag, before, r.points)
+	}
+	r.stats.FastSamples += len(implausible)
+	r.stats.FastPeriods += periods
+	fmt.Printf("Found %d samples in %d periods at or above %g deg/s (action: %s)\n", len(implausible), periods, config.MaxVelocity, config.VelocityAction)
+	return nil
+}
+
+func cleanInterpolate(r *cleanRun, config CleanConfig) error {
+	if config.Interpolate != "linear" && config.Interpolate != "cubic" {
+		return fmt.Errorf("unknown interpolation method %q (use 'linear' or 'cubic')", config.Interpolate)
+	}
+	points, n := interpolateMissing(r.points, targetColumns(config, r.columns), config.Interpolate, config.MaxGap)
+	r.points = points
+	r.stats.Interpolated += n
+	fmt.Printf("Interpolated %d missing values\n", n)
+	return nil
+}
+
+func cleanHampel(r *cleanRun, config CleanConfig) error {
+	points, n, err := hampelFilter(r.points, targetColumns(config, r.columns), config.HampelWindow, config.HampelThreshold)
+	if err != nil {
+		return err
+	}
+	r.points = points
+	r.stats.SpikesReplaced += n
+	fmt.Printf("Replaced %d spikes with the local median\n", n)
+	return nil
+}
+
+func cleanSmooth(r *cleanRun, config CleanConfig) error {
+	points, n, err := smoothColumns(r.points, targetColumns(config, r.columns), config.Smooth, config.SmoothWindow, config.SmoothWindowMs/1000, config.SmoothPoly)
+	if err != nil {
+		return err
+	}
+	r.points = points
+	r.stats.Smoothed += n
+	fmt.Printf("Smoothed %d values (%s filter)\n", n, config.Smooth)
+	return nil
+}
+
+func cleanImpute(r *cleanRun, config CleanConfig) error {
+	points, n, err := imputeMissing(r.points, r.columns, config.Impute)
+	if err != nil {
+		return err
+	}
+	r.points = points
+	r.stats.Imputed += n
+	fmt.Printf("Imputed %d missing values\n", n)
+	return nil
+}
+
+func cleanExpressions(r *cleanRun, config CleanConfig) error {
+	script := transform.ScriptConfig{}
+	var err error
+	if script.Assignments, err = expr.ParseAssignments(config.Expressions); err != nil {
+		return fmt.Errorf("expressions: %v", err)
+	}
+	if config.ExpressionFilter != "" {
+		if script.Filter, err = expr.Compile(config.ExpressionFilter); err != nil {
+			return fmt.Errorf("filter: %v", err)
+		}
+	}
+	if len(r.points) == 0 {
+		return nil
+	}
+	before := r.points
+	derived, n, err := transform.ApplyExpressions(&types.Dataset{Points: r.points, Columns: r.columns}, script)
+	if err != nil {
+		return err
+	}
+	r.points, r.columns = derived.Points, derived.Columns
+	if r.audit != nil {
+		r.audit.dropped(FilteredFlag, before, r.points)
+	}
+	r.stats.RemovedFiltered += n
+	fmt.Printf("Computed %d columns, removed %d points by the expression filter\n", len(script.Assignments), n)
+	return nil
+}
+
+func cleanMissing(r *cleanRun, config CleanConfig) error {
+	before := r.points
+	points, n := filterMissingData(r.points, config.RequiredColumns, config.MaxMissingPercent)
+	r.points = points
+	if r.audit != nil {
+		r.audit.dropped(MissingFlag, before, r.points)
+	}
+	r.stats.RemovedMissing += n
+	fmt.Printf("Removed %d points due to missing data\n", n)
+	return nil
+}
+
+func cleanOutliers(r *cleanRun, config CleanConfig) error {
+	removing := true
+	switch config.OutlierAction {
+	case "", "remove":
+	case "winsorize":
+		if config.OutlierWindow > 0 {
+			return fmt.Errorf("winsorizing needs fixed outlier bounds; use the 'nan' outlier action with a rolling window")
+		}
+		removing = false
+	case "nan":
+		removing = false
+	default:
+		return fmt.Errorf("unknown outlier action %q (use 'remove', 'winsorize' or 'nan')", config.OutlierAction)
+	}
+
+	if r.audit != nil || !removing {
+		outlying, bounds, err := outlierColumns(r.points, config.RequiredColumns, config.OutlierMethod, config.ZScoreThreshold, config.OutlierGrouping, config.OutlierWindow)
+		if err != nil {
+			return err
+		}
+		if r.audit != nil {
+			for _, col := range config.RequiredColumns {
+				r.audit.add(OutlierFlagPrefix + col)
+			}
+			for i, cols := range outlying {
+				for _, col := range cols {
+					r.audit.flag(OutlierFlagPrefix+col, r.points[i])
+				}
+				if len(cols) > 0 && removing {
+					r.audit.reject(OutlierFlagPrefix+cols[0], r.points[i])
+				}
+			}
+		}
+		if !removing {
+			points, changed := treatOutliers(r.points, outlying, bounds, config.OutlierGrouping, config.OutlierAction)
+			n := 0
+			for col, c := range changed {
+				r.outlierValues[col] += c
+				n += c
+			}
+			r.points = points
+			r.stats.OutlierValues += n
+			r.stats.OutlierBounds = append(r.stats.OutlierBounds, bounds...)
+			if config.OutlierAction == "winsorize" {
+				fmt.Printf("Winsorized %d outlying values\n", n)
+			} else {
+				fmt.Printf("Marked %d outlying values as missing\n", n)
+			}
+			return nil
+		}
+	}
+	points, removed, bounds, err := filterOutliers(r.points, config.RequiredColumns, config.OutlierMethod, config.ZScoreThreshold, config.OutlierGrouping, config.OutlierWindow)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for col, c := range removed {
+		r.outliers[col] += c
+		n += c
+	}
+	r.points = points
+	r.stats.RemovedOutliers += n
+	r.stats.OutlierBounds = append(r.stats.OutlierBounds, bounds...)
+	fmt.Printf("Removed %d points as outliers\n", n)
+	return nil
+}
+
+// targetColumns are the columns processed by per-column stages: the required columns, or every data column
+func targetColumns(config CleanConfig, columns []string) []string {
+	if len(config.RequiredColumns) > 0 {
+		return config.RequiredColumns
+	}
+	var cols []string
+	for i, col := range columns {
+		if i > 0 && col != BlinkColumn && col != DisparityColumn {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
 func filterMissingData(points []types.DataPoint, requiredCols []string, maxMissingPercent float64) ([]types.DataPoint, int) {
 	var filtered []types.DataPoint
 	removedCount := 0
@@ -79,58 +655,140 @@ func filterMissingData(points []types.DataPoint, requiredCols []string, maxMissi
 	return filtered, removedCount
 }
 
-func filterOutliers(points []types.DataPoint, cols []string, method string, zThreshold float64) ([]types.DataPoint, int) {
-	if len(cols) == 0 {
-		return points, 0
+// filterOutliers removes rows with a value outside its column's bounds and returns the removals per
+// column (attributed to the first outlying column of each row) and the bounds used. With a window,
+// z-scores are judged against rolling local statistics and there are no fixed bounds to return.
+func filterOutliers(points []types.DataPoint, cols []string, method string, zThreshold float64, grouping string, window float64) ([]types.DataPoint, map[string]int, []OutlierBound, error) {
+	outlying, bounds, err := outlierColumns(points, cols, method, zThreshold, grouping, window)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
+	removed := make(map[string]int)
 	var filtered []types.DataPoint
-	removedCount := 0
+	for i, p := range points {
+		if len(outlying[i]) == 0 {
+			filtered = append(filtered, p)
+		} else {
+			removed[outlying[i][0]]++
+		}
+	}
+	return filtered, removed, bounds, nil
+}
 
-	outlierBounds := make(map[string][2]float64) // col -> (min, max)
+// outlierGroupKey returns the function that assigns points to the groups outlier bounds are computed in
+func outlierGroupKey(grouping string) (func(types.DataPoint) string, error) {
+	switch grouping {
+	case "":
+		return func(p types.DataPoint) string { return "" }, nil
+	case "participant":
+		return func(p types.DataPoint) string { return p.ParticipantID }, nil
+	case "participant-condition":
+		return func(p types.DataPoint) string { return p.ParticipantID + "\x00" + p.Condition }, nil
+	default:
+		return nil, fmt.Errorf("unknown outlier grouping %q (use 'participant' or 'participant-condition')", grouping)
+	}
+}
+
+// groupLabel is the OutlierBound.Group of a group key
+func groupLabel(key string) string {
+	return strings.Replace(key, "\x00", "/", 1)
+}
 
-	for _, col := range cols {
-		values := extractColumnValues(points, col)
-		if len(values) == 0 {
+// treatOutliers keeps every row, and clamps each outlying value to the bound it crossed ("winsorize") or
+// marks it missing ("nan"). It returns the values changed per column.
+func treatOutliers(points []types.DataPoint, outlying [][]string, bounds []OutlierBound, grouping, action string) ([]types.DataPoint, map[string]int) {
+	limits := make(map[[2]string]OutlierBound, len(bounds))
+	for _, b := range bounds {
+		limits[[2]string{b.Group, b.Column}] = b
+	}
+	groupKey, _ := outlierGroupKey(grouping)
+
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+	changed := make(map[string]int)
+	for i, cols := range outlying {
+		if len(cols) == 0 {
 			continue
 		}
+		data := maps.Clone(points[i].Data)
+		group := groupLabel(groupKey(points[i]))
+		for _, col := range cols {
+			if action == "nan" {
+				delete(data, col)
+			} else {
+				b := limits[[2]string{group, col}]
+				data[col] = math.Max(b.Lower, math.Min(b.Upper, data[col]))
+			}
+			changed[col]++
+		}
+		result[i].Data = data
+	}
+	return result, changed
+}
 
-		var lowerBound, upperBound float64
+// outlierColumns returns, per point, the columns (in cols order) whose value is an outlier, and the
+// fixed bounds used
+func outlierColumns(points []types.DataPoint, cols []string, method string, zThreshold float64, grouping string, window float64) ([][]string, []OutlierBound, error) {
+	outlying := make([][]string, len(points))
+	if len(cols) == 0 {
+		return outlying, nil, nil
+	}
 
-		switch method {
-		case "iqr":
-			lowerBound, upperBound = calculateIQRBounds(values)
-		case "zscore":
-			lowerBound, upperBound = calculateZScoreBounds(values, zThreshold)
-		default:
-			lowerBound, upperBound = calculateIQRBounds(values) // Default to IQR
-		}
+	// Bounds are computed per group, so e.g. a participant with naturally larger pupils keeps their data
+	groupKey, err := outlierGroupKey(grouping)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		outlierBounds[col] = [2]float64{lowerBound, upperBound}
+	if window > 0 {
+		if method != "zscore" {
+			return nil, nil, fmt.Errorf("a rolling outlier window requires the zscore method, not %q", method)
+		}
+		return rollingZScoreOutliers(points, cols, zThreshold, window), nil, nil
 	}
 
+	groups := make(map[string][]types.DataPoint)
 	for _, p := range points {
-		isOutlier := false
+		key := groupKey(p)
+		groups[key] = append(groups[key], p)
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for key := range groups {
+		groupNames = append(groupNames, key)
+	}
+	sort.Strings(groupNames)
+
+	outlierBounds := make(map[string]map[string][2]float64) // group -> col -> (min, max)
+	var used []OutlierBound
+	for _, key := range groupNames {
+		outlierBounds[key] = make(map[string][2]float64)
+		for _, col := range cols {
+			values := extractColumnValues(groups[key], col)
+			if len(values) == 0 {
+				continue
+			}
+
+			lowerBound, upperBound := OutlierBounds(values, method, zThreshold)
+			outlierBounds[key][col] = [2]float64{lowerBound, upperBound}
+			used = append(used, OutlierBound{Group: groupLabel(key), Column: col, Lower: lowerBound, Upper: upperBound})
+		}
+	}
 
+	for i, p := range points {
+		groupBounds := outlierBounds[groupKey(p)]
 		for _, col := range cols {
-			if bounds, ok := outlierBounds[col]; ok {
+			if bounds, ok := groupBounds[col]; ok {
 				if val, ok := p.Data[col]; ok {
 					if val < bounds[0] || val > bounds[1] {
-						isOutlier = true
-						break
+						outlying[i] = append(outlying[i], col)
 					}
 				}
 			}
 		}
-
-		if !isOutlier {
-			filtered = append(filtered, p)
-		} else {
-			removedCount++
-		}
 	}
-
-	return filtered, removedCount
+	return outlying, used, nil
 }
 
 func extractColumnValues(points []types.DataPoint, col string) []float64 {
@@ -143,6 +801,18 @@ func extractColumnValues(points []types.DataPoint, col string) []float64 {
 	return values
 }
 
+// OutlierBounds returns the (lower, upper) bounds outside of which a value counts as an outlier
+func OutlierBounds(values []float64, method string, zThreshold float64) (float64, float64) {
+	switch method {
+	case "iqr":
+		return calculateIQRBounds(values)
+	case "zscore":
+		return calculateZScoreBounds(values, zThreshold)
+	default:
+		return calculateIQRBounds(values) // Default to IQR
+	}
+}
+
 func calculateIQRBounds(values []float64) (float64, float64) {
 	if len(values) == 0 {
 		return math.NaN(), math.NaN()
@@ -0,0 +1,58 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+
+	"mbdvr/internal/types"
+)
+
+// filterConfidence handles samples whose confidence is below minConfidence (or missing): "drop" removes
+// them, "nan" clears the values of cols so later stages treat them as missing.
+func filterConfidence(points []types.DataPoint, cols []string, column string, minConfidence float64, action string) ([]types.DataPoint, int, error) {
+	if action != "drop" && action != "nan" {
+		return nil, 0, fmt.Errorf("unknown confidence action %q (use 'drop' or 'nan')", action)
+	}
+
+	found := false
+	for _, p := range points {
+		if _, ok := p.Data[column]; ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("confidence column %s not found", column)
+	}
+
+	var result []types.DataPoint
+	low := 0
+	for _, p := range points {
+		if confident(p, column, minConfidence) {
+			result = append(result, p)
+			continue
+		}
+
+		low++
+		if action == "drop" {
+			continue
+		}
+		data := maps.Clone(p.Data)
+		for _, col := range cols {
+			if col != column {
+				delete(data, col)
+			}
+		}
+		p.Data = data
+		result = append(result, p)
+	}
+
+	return result, low, nil
+}
+
+// confident reports whether a sample has a confidence of at least minConfidence
+func confident(p types.DataPoint, column string, minConfidence float64) bool {
+	conf, ok := p.Data[column]
+	return ok && !math.IsNaN(conf) && conf >= minConfidence
+}
@@ -0,0 +1,74 @@
+package cleaner
+
+import (
+	"fmt"
+	"math"
+
+	"mbdvr/internal/events"
+	"mbdvr/internal/types"
+)
+
+// DisparityColumn holds the distance between the left and right eye gaze points
+const DisparityColumn = "disparity"
+
+// markDisparity adds the per-sample binocular disparity, the distance between the left and right gaze
+// points given as left x, left y, right x, right y columns. Periods where it stays above maxDisparity for
+// at least minDuration seconds are implausible divergence (one eye mistracked): their cols are cleared
+// so they count as missing. maxDisparity 0 only adds the column. Returns the flagged samples and periods.
+func markDisparity(points []types.DataPoint, columns, cols, eyes []string, maxDisparity, minDuration float64) ([]types.DataPoint, []string, int, int, error) {
+	if len(eyes) != 4 {
+		return nil, nil, 0, 0, fmt.Errorf("disparity needs left x, left y, right x and right y columns")
+	}
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+	for _, col := range eyes {
+		if !known[col] {
+			return nil, nil, 0, 0, fmt.Errorf("disparity column %s not found", col)
+		}
+	}
+
+	result := make([]types.DataPoint, len(points))
+	for i, p := range points {
+		data := types.CloneData(p.Data, 1)
+		lx, ok1 := data[eyes[0]]
+		ly, ok2 := data[eyes[1]]
+		rx, ok3 := data[eyes[2]]
+		ry, ok4 := data[eyes[3]]
+		if d := math.Hypot(lx-rx, ly-ry); ok1 && ok2 && ok3 && ok4 && !math.IsNaN(d) {
+			data[DisparityColumn] = d
+		}
+		result[i] = p
+		result[i].Data = data
+	}
+	columns = appendColumn(columns, DisparityColumn)
+	if maxDisparity <= 0 {
+		return result, columns, 0, 0, nil
+	}
+
+	detector := events.Hysteresis{Enter: maxDisparity, Exit: maxDisparity, MinDuration: minDuration}
+	flagged, periods := 0, 0
+	for _, idx := range types.RecordingIndices(result) {
+		times := make([]float64, len(idx))
+		disparity := make([]float64, len(idx))
+		for k, i := range idx {
+			times[k] = result[i].Timestamp
+			disparity[k] = math.NaN()
+			if d, ok := result[i].Data[DisparityColumn]; ok {
+				disparity[k] = d
+			}
+		}
+		for _, iv := range detector.Detect(times, disparity) {
+			periods++
+			for k := iv.Start; k < iv.End; k++ {
+				data := result[idx[k]].Data
+				for _, col := range cols {
+					delete(data, col)
+				}
+				flagged++
+			}
+		}
+	}
+	return result, columns, flagged, periods, nil
+}
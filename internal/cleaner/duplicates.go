@@ -0,0 +1,138 @@
+package cleaner
+
+import (
+	"fmt"
+	"math"
+
+	"mbdvr/internal/types"
+)
+
+// removeDuplicates drops repeated samples of each recording. Exact duplicates (same timestamp,
+// condition and values) are always dropped. In "near" mode, samples whose timestamps lie within
+// tolerance seconds of the first sample of a run are also duplicates even if their values differ,
+// and keep decides which survives: the "first", the "last", or their "average".
+func removeDuplicates(points []types.DataPoint, mode string, tolerance float64, keep string) ([]types.DataPoint, int, int, error) {
+	if mode != "exact" && mode != "near" {
+		return nil, 0, 0, fmt.Errorf("unknown duplicate mode %q (use 'exact' or 'near')", mode)
+	}
+	if keep != "first" && keep != "last" && keep != "average" {
+		return nil, 0, 0, fmt.Errorf("unknown duplicate keep policy %q (use 'first', 'last' or 'average')", keep)
+	}
+	if mode == "exact" || tolerance < 0 {
+		tolerance = 0
+	}
+
+	exact, near := 0, 0
+	drop := make(map[int]bool)
+	replace := make(map[int]types.DataPoint)
+
+	for _, idx := range types.RecordingIndices(points) {
+		for k := 0; k < len(idx); {
+			// A run of samples within tolerance of its first sample, in the same condition
+			first := points[idx[k]]
+			end := k + 1
+			for end < len(idx) {
+				p := points[idx[end]]
+				if p.Timestamp-first.Timestamp > tolerance || p.Condition != first.Condition {
+					break
+				}
+				end++
+			}
+
+			var distinct []int
+			for _, i := range idx[k:end] {
+				duplicate := false
+				for _, j := range distinct {
+					if points[j].Timestamp == points[i].Timestamp && sameValues(points[j].Data, points[i].Data) {
+						duplicate = true
+						break
+					}
+				}
+				if duplicate {
+					drop[i] = true
+					exact++
+				} else {
+					distinct = append(distinct, i)
+				}
+			}
+
+			if mode == "near" && len(distinct) > 1 {
+				near += len(distinct) - 1
+				kept := distinct[0]
+				if keep == "last" {
+					kept = distinct[len(distinct)-1]
+				}
+				for _, i := range distinct {
+					if i != kept {
+						drop[i] = true
+					}
+				}
+				if keep == "average" {
+					replace[kept] = averagePoints(points, distinct)
+				}
+			}
+			k = end
+		}
+	}
+
+	if len(drop) == 0 {
+		return points, 0, 0, nil
+	}
+	result := make([]types.DataPoint, 0, len(points)-len(drop))
+	for i, p := range points {
+		if drop[i] {
+			continue
+		}
+		if r, ok := replace[i]; ok {
+			p = r
+		}
+		result = append(result, p)
+	}
+	return result, exact, near, nil
+}
+
+// averagePoints merges samples into one with their mean timestamp and the mean of each column
+func averagePoints(points []types.DataPoint, indices []int) types.DataPoint {
+	merged := points[indices[0]]
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	timestamp := 0.0
+	for _, i := range indices {
+		timestamp += points[i].Timestamp
+		for key, value := range points[i].Data {
+			if math.IsNaN(value) {
+				continue
+			}
+			sums[key] += value
+			counts[key]++
+		}
+	}
+	merged.Timestamp = timestamp / float64(len(indices))
+	merged.Data = make(map[string]float64, len(sums))
+	for key, sum := range sums {
+		merged.Data[key] = sum / float64(counts[key])
+	}
+	if row, ok := points[indices[0]].Data[rowColumn]; ok {
+		merged.Data[rowColumn] = row // The merged sample stands in for the first row
+	}
+	return merged
+}
+
+func sameValues(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, va := range a {
+		if key == rowColumn {
+			continue
+		}
+		vb, ok := b[key]
+		if !ok {
+			return false
+		}
+		if va != vb && !(math.IsNaN(va) && math.IsNaN(vb)) {
+			return false
+		}
+	}
+	return true
+}
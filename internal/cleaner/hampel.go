@@ -0,0 +1,90 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+
+	"mbdvr/internal/types"
+)
+
+// madScale makes the median absolute deviation a consistent estimate of the standard deviation
+const madScale = 1.4826
+
+// hampelFilter replaces values that deviate from the median of a centered window of the same
+// recording's samples by more than threshold scaled MADs with that median. Rows are never removed.
+func hampelFilter(points []types.DataPoint, cols []string, window int, threshold float64) ([]types.DataPoint, int, error) {
+	if window < 3 {
+		return nil, 0, fmt.Errorf("hampel window must be at least 3 samples")
+	}
+	if threshold <= 0 {
+		return nil, 0, fmt.Errorf("hampel threshold must be positive")
+	}
+
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+	replaced := 0
+	copied := make([]bool, len(points))
+
+	values := make([]float64, 0, window)
+	deviations := make([]float64, 0, window)
+	for _, idx := range types.RecordingIndices(points) {
+		for _, col := range cols {
+			for k, i := range idx {
+				val, ok := points[i].Data[col]
+				if !ok || math.IsNaN(val) {
+					continue
+				}
+
+				from := k - window/2
+				to := from + window - 1
+				if from < 0 {
+					from = 0
+				}
+				if to >= len(idx) {
+					to = len(idx) - 1
+				}
+
+				values = values[:0]
+				for w := from; w <= to; w++ {
+					if v, ok := points[idx[w]].Data[col]; ok && !math.IsNaN(v) {
+						values = append(values, v)
+					}
+				}
+				if len(values) < 3 {
+					continue
+				}
+				sort.Float64s(values)
+				median := sortedMedian(values)
+
+				deviations = deviations[:0]
+				for _, v := range values {
+					deviations = append(deviations, math.Abs(v-median))
+				}
+				sort.Float64s(deviations)
+				mad := madScale * sortedMedian(deviations)
+
+				if math.Abs(val-median) > threshold*mad && mad > 0 {
+					// Copy the map on first write so the input dataset is unchanged
+					if !copied[i] {
+						result[i].Data = maps.Clone(points[i].Data)
+						copied[i] = true
+					}
+					result[i].Data[col] = median
+					replaced++
+				}
+			}
+		}
+	}
+
+	return result, replaced, nil
+}
+
+func sortedMedian(sorted []float64) float64 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
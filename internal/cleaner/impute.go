@@ -0,0 +1,150 @@
+package cleaner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// ImputeMethods lists the supported imputation methods
+var ImputeMethods = []string{"mean", "median", "ffill", "bfill", "participant-mean", "participant-median"}
+
+// ImputeRule fills the missing values of a column
+type ImputeRule struct {
+	Column string
+	Method string // One of ImputeMethods
+}
+
+// ParseImputeRules reads rules such as "heart_rate:ffill,eda:participant-median"
+func ParseImputeRules(spec string) ([]ImputeRule, error) {
+	var rules []ImputeRule
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		sep := strings.LastIndex(rule, ":")
+		if sep <= 0 {
+			return nil, fmt.Errorf("invalid imputation rule %q (use column:method)", rule)
+		}
+		r := ImputeRule{Column: strings.TrimSpace(rule[:sep]), Method: strings.TrimSpace(rule[sep+1:])}
+		known := false
+		for _, m := range ImputeMethods {
+			known = known || m == r.Method
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown imputation method %q (use %s)", r.Method, strings.Join(ImputeMethods, ", "))
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// imputeMissing fills missing values by rule. Mean and median use all samples of the column, their
+// participant- variants only the participant's own samples; ffill and bfill carry the previous or next
+// value of the same recording (participant and condition) forward or back in time. Values that can't be filled stay missing.
+func imputeMissing(points []types.DataPoint, columns []string, rules []ImputeRule) ([]types.DataPoint, int, error) {
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+	for _, r := range rules {
+		if !known[r.Column] {
+			return nil, 0, fmt.Errorf("imputation column %s not found", r.Column)
+		}
+	}
+
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+	copied := make(map[int]bool)
+	set := func(i int, col string, value float64) {
+		if !copied[i] {
+			result[i].Data = types.CloneData(result[i].Data, 1)
+			copied[i] = true
+		}
+		result[i].Data[col] = value
+	}
+	missing := func(i int, col string) bool {
+		v, ok := result[i].Data[col]
+		return !ok || math.IsNaN(v)
+	}
+
+	recordings := types.RecordingIndices(result)
+	var participants [][]int
+	seen := make(map[string]int)
+	for i, p := range result {
+		k, ok := seen[p.ParticipantID]
+		if !ok {
+			k = len(participants)
+			seen[p.ParticipantID] = k
+			participants = append(participants, nil)
+		}
+		participants[k] = append(participants[k], i)
+	}
+	filled := 0
+	for _, r := range rules {
+		switch r.Method {
+		case "mean", "median":
+			all := make([]int, len(result))
+			for i := range all {
+				all[i] = i
+			}
+			filled += fillConstant(result, all, r.Column, r.Method, missing, set)
+		case "participant-mean", "participant-median":
+			for _, idx := range participants {
+				filled += fillConstant(result, idx, r.Column, strings.TrimPrefix(r.Method, "participant-"), missing, set)
+			}
+		case "ffill", "bfill":
+			for _, idx := range recordings {
+				order := idx
+				if r.Method == "bfill" {
+					order = make([]int, len(idx))
+					for k, i := range idx {
+						order[len(idx)-1-k] = i
+					}
+				}
+				last, have := 0.0, false
+				for _, i := range order {
+					if !missing(i, r.Column) {
+						last, have = result[i].Data[r.Column], true
+					} else if have {
+						set(i, r.Column, last)
+						filled++
+					}
+				}
+			}
+		}
+	}
+	return result, filled, nil
+}
+
+// fillConstant fills missing values of col among the indexed points with their mean or median
+func fillConstant(points []types.DataPoint, idx []int, col, method string, missing func(int, string) bool, set func(int, string, float64)) int {
+	var values []float64
+	for _, i := range idx {
+		if !missing(i, col) {
+			values = append(values, points[i].Data[col])
+		}
+	}
+	if len(values) == 0 || len(values) == len(idx) {
+		return 0
+	}
+	var fill float64
+	if method == "mean" {
+		fill = mean(values)
+	} else {
+		sort.Float64s(values)
+		fill = percentile(values, 50)
+	}
+	filled := 0
+	for _, i := range idx {
+		if missing(i, col) {
+			set(i, col, fill)
+			filled++
+		}
+	}
+	return filled
+}
@@ -0,0 +1,118 @@
+package cleaner
+
+import (
+	"math"
+
+	"mbdvr/internal/types"
+)
+
+// interpolateMissing fills gaps in each column from neighbouring valid samples of the same
+// participant. Gaps longer than maxGap seconds (between the surrounding valid samples) and
+// missing values at the start or end of a recording are left as they are.
+func interpolateMissing(points []types.DataPoint, cols []string, method string, maxGap float64) ([]types.DataPoint, int) {
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+
+	filled := 0
+	copied := make(map[int]bool) // Points whose Data map has been copied before modification
+
+	for _, idx := range types.RecordingIndices(result) {
+		for _, col := range cols {
+			var xs, ys []float64
+			var valid []int // Positions in idx with a value
+			for k, i := range idx {
+				if val, ok := result[i].Data[col]; ok && !math.IsNaN(val) {
+					xs = append(xs, result[i].Timestamp)
+					ys = append(ys, val)
+					valid = append(valid, k)
+				}
+			}
+			if len(valid) < 2 {
+				continue
+			}
+
+			var spline *naturalSpline
+			if method == "cubic" && len(valid) >= 3 {
+				spline = newNaturalSpline(xs, ys)
+			}
+
+			for v := 0; v+1 < len(valid); v++ {
+				start, end := valid[v], valid[v+1]
+				if end-start < 2 {
+					continue
+				}
+				t0, t1 := xs[v], xs[v+1]
+				if maxGap > 0 && t1-t0 > maxGap {
+					continue
+				}
+
+				for k := start + 1; k < end; k++ {
+					i := idx[k]
+					t := result[i].Timestamp
+					var val float64
+					if spline != nil {
+						val = spline.at(t, v)
+					} else if t1 > t0 {
+						val = ys[v] + (ys[v+1]-ys[v])*(t-t0)/(t1-t0)
+					} else {
+						val = ys[v]
+					}
+
+					if !copied[i] {
+						result[i].Data = types.CloneData(result[i].Data, 1)
+						copied[i] = true
+					}
+					result[i].Data[col] = val
+					filled++
+				}
+			}
+		}
+	}
+
+	return result, filled
+}
+
+// naturalSpline is a cubic spline with zero second derivative at both ends
+type naturalSpline struct {
+	xs, ys, m []float64 // m holds the second derivatives at each knot
+}
+
+func newNaturalSpline(xs, ys []float64) *naturalSpline {
+	n := len(xs)
+	m := make([]float64, n)
+
+	// Solve the tridiagonal system for the interior second derivatives (Thomas algorithm)
+	c := make([]float64, n)
+	d := make([]float64, n)
+	for i := 1; i < n-1; i++ {
+		h0 := xs[i] - xs[i-1]
+		h1 := xs[i+1] - xs[i]
+		if h0 <= 0 || h1 <= 0 {
+			continue
+		}
+		a := h0 / 6
+		b := (h0 + h1) / 3
+		cc := h1 / 6
+		rhs := (ys[i+1]-ys[i])/h1 - (ys[i]-ys[i-1])/h0
+
+		denom := b - a*c[i-1]
+		c[i] = cc / denom
+		d[i] = (rhs - a*d[i-1]) / denom
+	}
+	for i := n - 2; i >= 1; i-- {
+		m[i] = d[i] - c[i]*m[i+1]
+	}
+
+	return &naturalSpline{xs: xs, ys: ys, m: m}
+}
+
+// at evaluates the spline at t within the interval starting at knot k
+func (s *naturalSpline) at(t float64, k int) float64 {
+	h := s.xs[k+1] - s.xs[k]
+	if h <= 0 {
+		return s.ys[k]
+	}
+	a := (s.xs[k+1] - t) / h
+	b := (t - s.xs[k]) / h
+	return a*s.ys[k] + b*s.ys[k+1] + ((a*a*a-a)*s.m[k]+(b*b*b-b)*s.m[k+1])*h*h/6
+}
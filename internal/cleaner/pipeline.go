@@ -0,0 +1,477 @@
+package cleaner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stage is one step of a cleaning pipeline: a stage name and its parameters. Parameters override the
+// settings of the CleanConfig the pipeline runs with, for this stage only.
+type Stage struct {
+	Name   string
+	ID     string            `json:",omitempty"` // Unique name in the pipeline (default: Name, numbered when repeated)
+	Params map[string]string `json:",omitempty"`
+}
+
+// stageParam parses a parameter value into the stage's config
+type stageParam func(c *CleanConfig, value string) error
+
+type stageSpec struct {
+	run     func(r *cleanRun, config CleanConfig) error
+	enabled func(c CleanConfig) bool // Whether the config turns the stage on
+	enable  func(c *CleanConfig)     // Defaults that turn the stage on when it is listed in a pipeline (nil = none)
+	main    string                   // Parameter set by a plain value, as in "range: gaze_x:0..1"
+	params  map[string]stageParam
+}
+
+// DefaultStages is the order in which CleanDataset runs the stages a CleanConfig enables when it has
+// no Pipeline:
+//   - sentinels first, so no stage mistakes them for real values, e.g. in means or outlier bounds
+//   - duplicates before timestamp repair, which would otherwise re-time them as repeated timestamps
+//   - timestamps next: every later stage relies on samples being in time order
+//   - range: physically impossible values become missing so they can be interpolated or filtered like dropouts
+//   - blinks before gap filling, so their dropouts aren't treated as ordinary gaps
+//   - velocity glitches are cleared before interpolation so they can be filled like dropouts
+//   - interpolate: short dropouts are filled before rows with missing data are dropped
+//   - impute: sparse auxiliary channels are filled so they don't get otherwise valid rows dropped
+var DefaultStages = []string{"sentinels", "duplicates", "timestamps", "drift", "confidence", "range", "disparity",
+	"blink", "velocity", "interpolate", "hampel", "smooth", "impute", "missing", "outliers"}
+
+// stageAliases are shorthand stage names with preset parameters
+var stageAliases = map[string]Stage{
+	"blink-remove":      {Name: "blink", Params: map[string]string{"action": "remove"}},
+	"blink-interpolate": {Name: "blink", Params: map[string]string{"action": "interpolate"}},
+	"blink-label":       {Name: "blink", Params: map[string]string{"action": "label"}},
+	"mean":              {Name: "smooth", Params: map[string]string{"method": "mean"}},
+	"median":            {Name: "smooth", Params: map[string]string{"method": "median"}},
+	"savgol":            {Name: "smooth", Params: map[string]string{"method": "savgol"}},
+}
+
+var stages = map[string]stageSpec{
+	"sentinels": {
+		run:     cleanSentinels,
+		enabled: func(c CleanConfig) bool { return len(c.Sentinels) > 0 },
+		main:    "rules",
+		params: map[string]stageParam{
+			"rules": func(c *CleanConfig, v string) (err error) { c.Sentinels, err = ParseSentinels(v); return err },
+		},
+	},
+	"duplicates": {
+		run:     cleanDuplicates,
+		enabled: func(c CleanConfig) bool { return c.Duplicates != "" },
+		enable: func(c *CleanConfig) {
+			if c.Duplicates == "" {
+				c.Duplicates = "exact"
+			}
+		},
+		main: "mode",
+		params: map[string]stageParam{
+			"mode":      stringParam(func(c *CleanConfig) *string { return &c.Duplicates }),
+			"tolerance": floatParam(func(c *CleanConfig) *float64 { return &c.DuplicateTolerance }),
+			"keep":      stringParam(func(c *CleanConfig) *string { return &c.DuplicateKeep }),
+		},
+	},
+	"timestamps": {
+		run:     cleanTimestamps,
+		enabled: func(c CleanConfig) bool { return c.FixTimestamps },
+		enable:  func(c *CleanConfig) { c.FixTimestamps = true },
+		params: map[string]stageParam{
+			"max-inversion": floatParam(func(c *CleanConfig) *float64 { return &c.MaxInversion }),
+		},
+	},
+	"drift": {
+		run:     cleanDrift,
+		enabled: func(c CleanConfig) bool { return len(c.DriftAnchors) > 0 },
+		main:    "anchors",
+		params: map[string]stageParam{
+			"anchors": func(c *CleanConfig, v string) (err error) { c.DriftAnchors, err = ParseDriftAnchors(v); return err },
+		},
+	},
+	"confidence": {
+		run:     cleanConfidence,
+		enabled: func(c CleanConfig) bool { return c.ConfidenceColumn != "" },
+		main:    "column",
+		params: map[string]stageParam{
+			"column": stringParam(func(c *CleanConfig) *string { return &c.ConfidenceColumn }),
+			"min":    floatParam(func(c *CleanConfig) *float64 { return &c.MinConfidence }),
+			"action": stringParam(func(c *CleanConfig) *string { return &c.ConfidenceAction }),
+		},
+	},
+	"range": {
+		run:     cleanRanges,
+		enabled: func(c CleanConfig) bool { return len(c.ValidRanges) > 0 },
+		main:    "rules",
+		params: map[string]stageParam{
+			"rules": func(c *CleanConfig, v string) (err error) { c.ValidRanges, err = ParseValidRanges(v); return err },
+		},
+	},
+	"disparity": {
+		run:     cleanDisparity,
+		enabled: func(c CleanConfig) bool { return len(c.DisparityEyes) > 0 },
+		main:    "eyes",
+		params: map[string]stageParam{
+			"eyes":         listParam(func(c *CleanConfig) *[]string { return &c.DisparityEyes }),
+			"max":          floatParam(func(c *CleanConfig) *float64 { return &c.MaxDisparity }),
+			"min-duration": floatParam(func(c *CleanConfig) *float64 { return &c.MinDisparityDuration }),
+		},
+	},
+	"blink": {
+		run:     cleanBlinks,
+		enabled: func(c CleanConfig) bool { return c.BlinkAction != "" },
+		main:    "action",
+		params: map[string]stageParam{
+			"action":        stringParam(func(c *CleanConfig) *string { return &c.BlinkAction }),
+			"pupil":         stringParam(func(c *CleanConfig) *string { return &c.BlinkPupilColumn }),
+			"validity":      stringParam(func(c *CleanConfig) *string { return &c.BlinkValidityColumn }),
+			"invalid-value": floatParam(func(c *CleanConfig) *float64 { return &c.BlinkInvalidValue }),
+			"min":           floatParam(func(c *CleanConfig) *float64 { return &c.MinBlinkDuration }),
+			"max":           floatParam(func(c *CleanConfig) *float64 { return &c.MaxBlinkDuration }),
+		},
+	},
+	"velocity": {
+		run:     cleanVelocity,
+		enabled: func(c CleanConfig) bool { return c.MaxVelocity > 0 },
+		main:    "max",
+		params: map[string]stageParam{
+			"max":          floatParam(func(c *CleanConfig) *float64 { return &c.MaxVelocity }),
+			"gaze":         listParam(func(c *CleanConfig) *[]string { return &c.VelocityColumns }),
+			"min-duration": floatParam(func(c *CleanConfig) *float64 { return &c.MinVelocityDuration }),
+			"action":       stringParam(func(c *CleanConfig) *string { return &c.VelocityAction }),
+		},
+	},
+	"interpolate": {
+		run:     cleanInterpolate,
+		enabled: func(c CleanConfig) bool { return c.Interpolate != "" },
+		enable: func(c *CleanConfig) {
+			if c.Interpolate == "" {
+				c.Interpolate = "linear"
+			}
+		},
+		main: "method",
+		params: map[string]stageParam{
+			"method":  stringParam(func(c *CleanConfig) *string { return &c.Interpolate }),
+			"max-gap": floatParam(func(c *CleanConfig) *float64 { return &c.MaxGap }),
+		},
+	},
+	"hampel": {
+		run:     cleanHampel,
+		enabled: func(c CleanConfig) bool { return c.Hampel },
+		enable:  func(c *CleanConfig) { c.Hampel = true },
+		params: map[string]stageParam{
+			"window":    intParam(func(c *CleanConfig) *int { return &c.HampelWindow }),
+			"threshold": floatParam(func(c *CleanConfig) *float64 { return &c.HampelThreshold }),
+		},
+	},
+	"smooth": {
+		run:     cleanSmooth,
+		enabled: func(c CleanConfig) bool { return c.Smooth != "" },
+		main:    "method",
+		params: map[string]stageParam{
+			"method":    stringParam(func(c *CleanConfig) *string { return &c.Smooth }),
+			"window":    intParam(func(c *CleanConfig) *int { return &c.SmoothWindow }),
+			"window-ms": floatParam(func(c *CleanConfig) *float64 { return &c.SmoothWindowMs }),
+			"poly":      intParam(func(c *CleanConfig) *int { return &c.SmoothPoly }),
+		},
+	},
+	"impute": {
+		run:     cleanImpute,
+		enabled: func(c CleanConfig) bool { return len(c.Impute) > 0 },
+		main:    "rules",
+		params: map[string]stageParam{
+			"rules": func(c *CleanConfig, v string) (err error) { c.Impute, err = ParseImputeRules(v); return err },
+		},
+	},
+	"expr": {
+		run:     cleanExpressions,
+		enabled: func(c CleanConfig) bool { return c.Expressions != "" || c.ExpressionFilter != "" },
+		main:    "assign",
+		params: map[string]stageParam{
+			"assign": stringParam(func(c *CleanConfig) *string { return &c.Expressions }),
+			"filter": stringParam(func(c *CleanConfig) *string { return &c.ExpressionFilter }),
+		},
+	},
+	"missing": {
+		run:     cleanMissing,
+		enabled: func(c CleanConfig) bool { return c.MaxMissingPercent > 0 },
+		main:    "max-percent",
+		params: map[string]stageParam{
+			"max-percent": floatParam(func(c *CleanConfig) *float64 { return &c.MaxMissingPercent }),
+		},
+	},
+	"outliers": {
+		run:     cleanOutliers,
+		enabled: func(c CleanConfig) bool { return c.RemoveOutliers },
+		enable:  func(c *CleanConfig) { c.RemoveOutliers = true },
+		main:    "method",
+		params: map[string]stageParam{
+			"method":    stringParam(func(c *CleanConfig) *string { return &c.OutlierMethod }),
+			"threshold": floatParam(func(c *CleanConfig) *float64 { return &c.ZScoreThreshold }),
+			"grouping":  stringParam(func(c *CleanConfig) *string { return &c.OutlierGrouping }),
+			"window":    floatParam(func(c *CleanConfig) *float64 { return &c.OutlierWindow }),
+			"action":    stringParam(func(c *CleanConfig) *string { return &c.OutlierAction }),
+		},
+	},
+}
+
+// columnsParam is accepted by every stage: the columns it processes, instead of the required columns
+const columnsParam = "columns"
+
+// idParam names a pipeline step, e.g. for FromStage and ToStage
+const idParam = "name"
+
+func stringParam(field func(c *CleanConfig) *string) stageParam {
+	return func(c *CleanConfig, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func floatParam(field func(c *CleanConfig) *float64) stageParam {
+	return func(c *CleanConfig, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func intParam(field func(c *CleanConfig) *int) stageParam {
+	return func(c *CleanConfig, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// listParam reads column lists separated by commas or colons
+func listParam(field func(c *CleanConfig) *[]string) stageParam {
+	return func(c *CleanConfig, v string) error {
+		var cols []string
+		for _, col := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ':' }) {
+			cols = append(cols, strings.TrimSpace(col))
+		}
+		*field(c) = cols
+		return nil
+	}
+}
+
+// StageNames returns the stage names and aliases a pipeline can use, sorted
+func StageNames() []string {
+	names := make([]string, 0, len(stages)+len(stageAliases))
+	for name := range stages {
+		names = append(names, name)
+	}
+	for name := range stageAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultPipeline lists the stages config enables, in DefaultStages order
+func defaultPipeline(config CleanConfig) []Stage {
+	var pipeline []Stage
+	for _, name := range DefaultStages {
+		if stages[name].enabled(config) {
+			pipeline = append(pipeline, Stage{Name: name})
+		}
+	}
+	return pipeline
+}
+
+// apply sets the stage's parameters in c
+func (s Stage) apply(c *CleanConfig) error {
+	spec, ok := stages[s.Name]
+	if !ok {
+		return fmt.Errorf("unknown cleaning stage %q (use %s)", s.Name, strings.Join(StageNames(), ", "))
+	}
+	keys := make([]string, 0, len(s.Params))
+	for key := range s.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		set, ok := spec.params[key]
+		if key == columnsParam {
+			set, ok = listParam(func(c *CleanConfig) *[]string { return &c.RequiredColumns }), true
+		}
+		if !ok {
+			known := []string{columnsParam}
+			for name := range spec.params {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("stage %s has no parameter %q (use %s)", s.Name, key, strings.Join(known, ", "))
+		}
+		if err := set(c, s.Params[key]); err != nil {
+			return fmt.Errorf("stage %s parameter %s: %v", s.Name, key, err)
+		}
+	}
+	return nil
+}
+
+// config returns the settings the stage runs with: base, turned on for the stage and with its parameters
+func (s Stage) config(base CleanConfig) (CleanConfig, error) {
+	c := base
+	spec, ok := stages[s.Name]
+	if ok && spec.enable != nil {
+		spec.enable(&c)
+	}
+	if err := s.apply(&c); err != nil {
+		return c, err
+	}
+	if !spec.enabled(c) {
+		return c, fmt.Errorf("stage %s needs its %s parameter", s.Name, spec.main)
+	}
+	return c, nil
+}
+
+// ParsePipeline reads a cleaning pipeline from YAML: a list of stages, on its own or under a "pipeline"
+// key. Each stage is a name ("outliers"), a name with its main parameter ("range: gaze_x:0..1") or a
+// name with a map of parameters ("savgol: {window: 7, poly: 2}"); list values are joined with commas. A
+// "name" parameter names the step.
+func ParsePipeline(data []byte) ([]Stage, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid pipeline: %v", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("pipeline is empty")
+	}
+	list := root.Content[0]
+	if list.Kind == yaml.MappingNode {
+		var found *yaml.Node
+		for i := 0; i+1 < len(list.Content); i += 2 {
+			if key := list.Content[i].Value; key != "pipeline" {
+				return nil, fmt.Errorf("line %d: unknown pipeline key %q", list.Content[i].Line, key)
+			}
+			found = list.Content[i+1]
+		}
+		if found == nil {
+			return nil, fmt.Errorf("pipeline is empty")
+		}
+		list = found
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: the pipeline must be a list of stages", list.Line)
+	}
+
+	var pipeline []Stage
+	for _, item := range list.Content {
+		s, err := parseStage(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", item.Line, err)
+		}
+		// Catch bad parameter values before any data is loaded
+		var scratch CleanConfig
+		if err := s.apply(&scratch); err != nil {
+			return nil, fmt.Errorf("line %d: %v", item.Line, err)
+		}
+		pipeline = append(pipeline, s)
+	}
+	if len(pipeline) == 0 {
+		return nil, fmt.Errorf("pipeline is empty")
+	}
+	if _, err := stepIDs(pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+func parseStage(node *yaml.Node) (Stage, error) {
+	var s Stage
+	params := make(map[string]string)
+	switch {
+	case node.Kind == yaml.ScalarNode:
+		s.Name = node.Value
+	case node.Kind == yaml.MappingNode && len(node.Content) == 2:
+		s.Name = node.Content[0].Value
+		value := node.Content[1]
+		switch value.Kind {
+		case yaml.ScalarNode:
+			if value.Tag == "!!null" {
+				break
+			}
+			main := ""
+			if spec, ok := stages[s.Name]; ok {
+				main = spec.main
+			} else if alias, ok := stageAliases[s.Name]; ok {
+				main = stages[alias.Name].main
+			}
+			if main == "" {
+				return s, fmt.Errorf("stage %s takes named parameters, not a plain value", s.Name)
+			}
+			params[main] = value.Value
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if value.Content[i].Value == idParam {
+					s.ID = value.Content[i+1].Value
+					continue
+				}
+				v, err := paramValue(value.Content[i+1])
+				if err != nil {
+					return s, fmt.Errorf("stage %s parameter %s: %v", s.Name, value.Content[i].Value, err)
+				}
+				params[value.Content[i].Value] = v
+			}
+		default:
+			return s, fmt.Errorf("stage %s: parameters must be a value or a map", s.Name)
+		}
+	default:
+		return s, fmt.Errorf("a stage must be a name or a name with parameters")
+	}
+
+	if alias, ok := stageAliases[s.Name]; ok {
+		s.Name = alias.Name
+		for key, value := range alias.Params {
+			if _, set := params[key]; !set {
+				params[key] = value
+			}
+		}
+	}
+	if len(params) > 0 {
+		s.Params = params
+	}
+	return s, nil
+}
+
+// paramValue reads a scalar, or a list of scalars joined with commas
+func paramValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("lists may only hold plain values")
+			}
+			values = append(values, item.Value)
+		}
+		return strings.Join(values, ","), nil
+	}
+	return "", fmt.Errorf("must be a value or a list of values")
+}
+
+// LoadPipeline reads a YAML cleaning pipeline file
+func LoadPipeline(filename string) ([]Stage, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline: %v", err)
+	}
+	pipeline, err := ParsePipeline(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return pipeline, nil
+}
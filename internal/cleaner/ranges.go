@@ -0,0 +1,89 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// ValidRange is the physically possible range of a column; values outside it are marked missing
+type ValidRange struct {
+	Column string
+	Min    float64 // -Inf for no lower bound
+	Max    float64 // +Inf for no upper bound
+}
+
+// ParseValidRanges reads rules such as "gaze_x:0..1,pupil:1.5..9"; either bound may be omitted ("pupil:0..")
+func ParseValidRanges(spec string) ([]ValidRange, error) {
+	var ranges []ValidRange
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		sep := strings.LastIndex(rule, ":")
+		if sep <= 0 {
+			return nil, fmt.Errorf("invalid range rule %q (use column:min..max)", rule)
+		}
+		bounds := strings.SplitN(rule[sep+1:], "..", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid range rule %q (use column:min..max)", rule)
+		}
+
+		r := ValidRange{Column: strings.TrimSpace(rule[:sep]), Min: math.Inf(-1), Max: math.Inf(1)}
+		var err error
+		if s := strings.TrimSpace(bounds[0]); s != "" {
+			if r.Min, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid lower bound in range rule %q", rule)
+			}
+		}
+		if s := strings.TrimSpace(bounds[1]); s != "" {
+			if r.Max, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid upper bound in range rule %q", rule)
+			}
+		}
+		if r.Min > r.Max {
+			return nil, fmt.Errorf("lower bound is above upper bound in range rule %q", rule)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// applyValidRanges marks values outside their column's valid range as missing and returns the count per column
+func applyValidRanges(points []types.DataPoint, columns []string, ranges []ValidRange) ([]types.DataPoint, map[string]int, error) {
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+	for _, r := range ranges {
+		if !known[r.Column] {
+			return nil, nil, fmt.Errorf("range rule column %s not found", r.Column)
+		}
+	}
+
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+	cleared := make(map[string]int)
+	for i, p := range points {
+		copied := false
+		for _, r := range ranges {
+			val, ok := p.Data[r.Column]
+			if !ok || math.IsNaN(val) || (val >= r.Min && val <= r.Max) {
+				continue
+			}
+			if !copied {
+				result[i].Data = maps.Clone(p.Data)
+				copied = true
+			}
+			delete(result[i].Data, r.Column)
+			cleared[r.Column]++
+		}
+	}
+
+	return result, cleared, nil
+}
@@ -0,0 +1,122 @@
+package cleaner
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"mbdvr/internal/types"
+)
+
+// ColumnReport counts what cleaning did to one column
+type ColumnReport struct {
+	Column          string `json:"column"`
+	MissingBefore   int    `json:"missing_before"`   // Missing values in the input
+	MissingAfter    int    `json:"missing_after"`    // Missing values in the output
+	Sentinels       int    `json:"sentinels"`        // Values cleared as tracker sentinels
+	OutOfRange      int    `json:"out_of_range"`     // Values cleared by the valid-range rules
+	OutlierRemovals int    `json:"outlier_removals"` // Rows removed because this column was an outlier
+	OutlierValues   int    `json:"outlier_values"`   // Values winsorized or marked missing as outliers
+}
+
+// OutlierBound is the range used for outlier removal in one group and column
+type OutlierBound struct {
+	Group  string  `json:"group,omitempty"` // Participant (and condition) for grouped bounds
+	Column string  `json:"column"`
+	Lower  float64 `json:"lower"`
+	Upper  float64 `json:"upper"`
+}
+
+// ParticipantReport is the per-participant breakdown of a cleaning run
+type ParticipantReport struct {
+	ParticipantID     string  `json:"participant_id"`
+	OriginalPoints    int     `json:"original_points"`
+	FinalPoints       int     `json:"final_points"`
+	RemovalPercentage float64 `json:"removal_percentage"`
+	MissingBefore     int     `json:"missing_before"`
+	MissingAfter      int     `json:"missing_after"`
+}
+
+// columnReports compares the input and output points per column
+func columnReports(original, cleaned []types.DataPoint, cols []string, sentinels, outOfRange, outliers, outlierValues map[string]int) []ColumnReport {
+	reports := make([]ColumnReport, 0, len(cols))
+	for _, col := range cols {
+		reports = append(reports, ColumnReport{
+			Column:          col,
+			MissingBefore:   countMissing(original, col),
+			MissingAfter:    countMissing(cleaned, col),
+			Sentinels:       sentinels[col],
+			OutOfRange:      outOfRange[col],
+			OutlierRemovals: outliers[col],
+			OutlierValues:   outlierValues[col],
+		})
+	}
+	return reports
+}
+
+// participantReports compares the input and output points per participant
+func participantReports(original, cleaned []types.DataPoint, cols []string) []ParticipantReport {
+	byID := make(map[string]*ParticipantReport)
+	get := func(id string) *ParticipantReport {
+		r, ok := byID[id]
+		if !ok {
+			r = &ParticipantReport{ParticipantID: id}
+			byID[id] = r
+		}
+		return r
+	}
+	for _, p := range original {
+		r := get(p.ParticipantID)
+		r.OriginalPoints++
+		r.MissingBefore += missingValues(p, cols)
+	}
+	for _, p := range cleaned {
+		r := get(p.ParticipantID)
+		r.FinalPoints++
+		r.MissingAfter += missingValues(p, cols)
+	}
+
+	reports := make([]ParticipantReport, 0, len(byID))
+	for _, r := range byID {
+		if r.OriginalPoints > 0 {
+			r.RemovalPercentage = float64(r.OriginalPoints-r.FinalPoints) / float64(r.OriginalPoints) * 100
+		}
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ParticipantID < reports[j].ParticipantID })
+	return reports
+}
+
+func countMissing(points []types.DataPoint, col string) int {
+	missing := 0
+	for _, p := range points {
+		if v, ok := p.Data[col]; !ok || math.IsNaN(v) {
+			missing++
+		}
+	}
+	return missing
+}
+
+func missingValues(p types.DataPoint, cols []string) int {
+	missing := 0
+	for _, col := range cols {
+		if v, ok := p.Data[col]; !ok || math.IsNaN(v) {
+			missing++
+		}
+	}
+	return missing
+}
+
+// SaveReport writes the cleaning statistics as JSON for QC dashboards
+func SaveReport(stats CleanStats, filename string) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cleaning report: %v", err)
+	}
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return nil
+}
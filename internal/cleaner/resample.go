@@ -0,0 +1,168 @@
+package cleaner
+
+import (
+	"fmt"
+	"math"
+
+	"mbdvr/internal/types"
+)
+
+type ResampleConfig struct {
+	Rate   float64 // Target sampling rate in Hz
+	Method string  // "linear" (default), "cubic" or "nearest"
+	MaxGap float64 // Grid points inside gaps longer than this many seconds are left missing (0 = no limit)
+
+	// Align puts every recording on a shared grid so time courses can be averaged pointwise:
+	// "" (each grid starts at the recording's first sample), "grid" (absolute times that are
+	// Origin plus a multiple of the step) or "onset" (time since the start of each recording, from 0)
+	Align  string
+	Origin float64 // Grid origin in seconds for "grid"
+}
+
+type ResampleStats struct {
+	OriginalPoints  int
+	ResampledPoints int
+	MissingValues   int // Grid values left missing because they fall in a gap
+}
+
+// Resample interpolates each recording (a participant in one condition) onto a regular time grid starting
+// at its first sample, or on a grid shared by all recordings with config.Align.
+func Resample(dataset *types.Dataset, config ResampleConfig) (*types.Dataset, ResampleStats, error) {
+	stats := ResampleStats{OriginalPoints: len(dataset.Points)}
+	if config.Rate <= 0 {
+		return nil, stats, fmt.Errorf("resampling rate must be positive")
+	}
+	if config.Method == "" {
+		config.Method = "linear"
+	}
+	if config.Method != "linear" && config.Method != "cubic" && config.Method != "nearest" {
+		return nil, stats, fmt.Errorf("unknown resampling method %q (use 'linear', 'cubic' or 'nearest')", config.Method)
+	}
+	if config.Align != "" && config.Align != "grid" && config.Align != "onset" {
+		return nil, stats, fmt.Errorf("unknown alignment %q (use 'grid' or 'onset')", config.Align)
+	}
+
+	points := dataset.Points
+	cols := dataset.Columns[1:]
+	step := 1 / config.Rate
+	var resampled []types.DataPoint
+
+	for _, idx := range types.RecordingIndices(points) {
+		start := points[idx[0]].Timestamp
+		end := points[idx[len(idx)-1]].Timestamp
+		n := int(math.Floor((end-start)/step+1e-9)) + 1
+
+		// Grid times are computed from integer step counts so that recordings share them exactly
+		gridTime := func(g int) float64 { return start + float64(g)*step }
+		if config.Align == "grid" {
+			first := int(math.Ceil((start-config.Origin)/step - 1e-9))
+			last := int(math.Floor((end-config.Origin)/step + 1e-9))
+			n = last - first + 1
+			gridTime = func(g int) float64 { return config.Origin + float64(first+g)*step }
+		}
+		if n <= 0 {
+			continue // Shorter than one step and between grid points
+		}
+
+		grid := make([]types.DataPoint, n)
+		recording := points[idx[0]]
+		for g := range grid {
+			grid[g] = types.DataPoint{
+				Timestamp:     gridTime(g),
+				ParticipantID: recording.ParticipantID,
+				Condition:     recording.Condition,
+				Data:          make(map[string]float64, len(cols)),
+			}
+		}
+
+		for _, col := range cols {
+			var xs, ys []float64
+			for _, i := range idx {
+				if val, ok := points[i].Data[col]; ok && !math.IsNaN(val) {
+					// Duplicate timestamps would make the interpolation ill-defined
+					if len(xs) > 0 && points[i].Timestamp <= xs[len(xs)-1] {
+						continue
+					}
+					xs = append(xs, points[i].Timestamp)
+					ys = append(ys, val)
+				}
+			}
+			if len(xs) == 0 {
+				stats.MissingValues += n
+				continue
+			}
+
+			var spline *naturalSpline
+			if config.Method == "cubic" && len(xs) >= 3 {
+				spline = newNaturalSpline(xs, ys)
+			}
+
+			k := 0 // Knot interval [xs[k], xs[k+1]] containing the grid time
+			for g := range grid {
+				t := grid[g].Timestamp
+				for k+1 < len(xs) && xs[k+1] < t {
+					k++
+				}
+
+				var val float64
+				switch {
+				case t == xs[k]:
+					val = ys[k]
+				case k+1 < len(xs) && t == xs[k+1]:
+					val = ys[k+1]
+				case t < xs[k] || k+1 >= len(xs):
+					// Before the first or after the last valid sample
+					stats.MissingValues++
+					continue
+				case config.MaxGap > 0 && xs[k+1]-xs[k] > config.MaxGap:
+					stats.MissingValues++
+					continue
+				case config.Method == "nearest":
+					val = ys[k]
+					if xs[k+1]-t < t-xs[k] {
+						val = ys[k+1]
+					}
+				case spline != nil:
+					val = spline.at(t, k)
+				default:
+					val = ys[k] + (ys[k+1]-ys[k])*(t-xs[k])/(xs[k+1]-xs[k])
+				}
+				grid[g].Data[col] = val
+			}
+		}
+
+		if config.Align == "onset" {
+			for g := range grid {
+				grid[g].Timestamp = float64(g) * step
+			}
+		}
+
+		resampled = append(resampled, grid...)
+	}
+	stats.ResampledPoints = len(resampled)
+
+	metadata := make(map[string]interface{}, len(dataset.Metadata)+3)
+	for key, value := range dataset.Metadata {
+		metadata[key] = value
+	}
+	// The grid replaces any decimation of the original samples
+	delete(metadata, "sample_every")
+	metadata["resample_rate_hz"] = config.Rate
+	metadata["resample_method"] = config.Method
+	metadata["original_points"] = stats.OriginalPoints
+	events := dataset.Events
+	if config.Align != "" {
+		metadata["resample_align"] = config.Align
+	}
+	if config.Align == "onset" {
+		// Event times are absolute and no longer match the relative timestamps
+		events = nil
+	}
+
+	return &types.Dataset{
+		Points:   resampled,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   events,
+	}, stats, nil
+}
@@ -0,0 +1,76 @@
+package cleaner
+
+import (
+	"math"
+
+	"mbdvr/internal/types"
+)
+
+// minRollingSamples is the fewest valid samples in a window for a rolling z-score to be trusted
+const minRollingSamples = 3
+
+// rollingZScoreOutliers judges each value against the mean and standard deviation of the same
+// column within window/2 seconds on either side, so slow drifts in non-stationary signals (e.g.
+// pupil size) don't hide local artifacts or flag whole stretches. Windows run over each
+// recording's samples in time order. It returns, per point, the columns in cols that are outliers.
+func rollingZScoreOutliers(points []types.DataPoint, cols []string, zThreshold, window float64) [][]string {
+	outlierCols := make([][]string, len(points))
+	half := window / 2
+
+	for _, s := range types.RecordingIndices(points) {
+		for _, col := range cols {
+			values := make([]float64, len(s))
+			shift := math.NaN()
+			for k, i := range s {
+				v, ok := points[i].Data[col]
+				if !ok {
+					v = math.NaN()
+				}
+				values[k] = v
+				if math.IsNaN(shift) && !math.IsNaN(v) {
+					shift = v
+				}
+			}
+			if math.IsNaN(shift) {
+				continue
+			}
+
+			// Running sums over the window, shifted by the first value to keep the variance accurate
+			sum, sumSq, n := 0.0, 0.0, 0
+			lo, hi := 0, 0
+			for k, i := range s {
+				t := points[i].Timestamp
+				for hi < len(s) && points[s[hi]].Timestamp <= t+half {
+					if v := values[hi]; !math.IsNaN(v) {
+						sum += v - shift
+						sumSq += (v - shift) * (v - shift)
+						n++
+					}
+					hi++
+				}
+				for lo < hi && points[s[lo]].Timestamp < t-half {
+					if v := values[lo]; !math.IsNaN(v) {
+						sum -= v - shift
+						sumSq -= (v - shift) * (v - shift)
+						n--
+					}
+					lo++
+				}
+
+				v := values[k]
+				if math.IsNaN(v) || n < minRollingSamples {
+					continue
+				}
+				mean := sum / float64(n)
+				variance := sumSq/float64(n) - mean*mean
+				if variance <= 0 {
+					continue
+				}
+				if math.Abs(v-shift-mean) > zThreshold*math.Sqrt(variance) {
+					outlierCols[i] = append(outlierCols[i], col)
+				}
+			}
+		}
+	}
+	return outlierCols
+}
@@ -0,0 +1,81 @@
+package cleaner
+
+import "math"
+
+// savitzkyGolay fits a least-squares polynomial of the given order to the window and evaluates it at
+// offset 0. Fitting on the actual sample times keeps the filter valid at edges, around missing samples
+// and for irregular sampling; the order is reduced when the window has too few samples.
+func savitzkyGolay(offsets, values []float64, order int) float64 {
+	if order >= len(values) {
+		order = len(values) - 1
+	}
+
+	// Scale offsets to [-1, 1] so the normal equations stay well-conditioned
+	scale := 0.0
+	for _, dt := range offsets {
+		scale = math.Max(scale, math.Abs(dt))
+	}
+	if scale == 0 {
+		return mean(values)
+	}
+
+	n := order + 1
+	a := make([][]float64, n)
+	for r := range a {
+		a[r] = make([]float64, n+1)
+	}
+	for k, dt := range offsets {
+		x := dt / scale
+		powers := make([]float64, 2*n-1)
+		powers[0] = 1
+		for p := 1; p < len(powers); p++ {
+			powers[p] = powers[p-1] * x
+		}
+		for r := 0; r < n; r++ {
+			for c := 0; c < n; c++ {
+				a[r][c] += powers[r+c]
+			}
+			a[r][n] += powers[r] * values[k]
+		}
+	}
+
+	coeffs, ok := solveLinear(a)
+	if !ok {
+		return mean(values)
+	}
+	return coeffs[0]
+}
+
+// solveLinear solves an augmented n x (n+1) system by Gaussian elimination with partial pivoting
+func solveLinear(a [][]float64) ([]float64, bool) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := col + 1; r < n; r++ {
+			f := a[r][col] / a[col][col]
+			for c := col; c <= n; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+		}
+	}
+
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		sum := a[r][n]
+		for c := r + 1; c < n; c++ {
+			sum -= a[r][c] * x[c]
+		}
+		x[r] = sum / a[r][r]
+	}
+	return x, true
+}
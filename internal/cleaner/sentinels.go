@@ -0,0 +1,97 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// AllColumns is the Sentinel column that applies a sentinel to every data column
+const AllColumns = "*"
+
+// Sentinel lists the values a tracker writes into a column for invalid samples, such as -1 or 9999
+type Sentinel struct {
+	Column string // A data column, or AllColumns
+	Values []float64
+}
+
+// ParseSentinels reads rules such as "gaze_x:-1,pupil:0|-1,*:9999"; "|" separates several values of one column
+func ParseSentinels(spec string) ([]Sentinel, error) {
+	var sentinels []Sentinel
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		sep := strings.LastIndex(rule, ":")
+		if sep <= 0 {
+			return nil, fmt.Errorf("invalid sentinel rule %q (use column:value)", rule)
+		}
+		s := Sentinel{Column: strings.TrimSpace(rule[:sep])}
+		for _, field := range strings.Split(rule[sep+1:], "|") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q in sentinel rule %q", field, rule)
+			}
+			s.Values = append(s.Values, v)
+		}
+		sentinels = append(sentinels, s)
+	}
+	return sentinels, nil
+}
+
+// applySentinels marks sentinel values as missing and returns the count per column
+func applySentinels(points []types.DataPoint, columns []string, sentinels []Sentinel) ([]types.DataPoint, map[string]int, error) {
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+	byColumn := make(map[string][]float64)
+	for _, s := range sentinels {
+		if s.Column == AllColumns {
+			for _, col := range columns {
+				if col != "timestamp" {
+					byColumn[col] = append(byColumn[col], s.Values...)
+				}
+			}
+			continue
+		}
+		if !known[s.Column] || s.Column == "timestamp" {
+			return nil, nil, fmt.Errorf("sentinel column %s not found", s.Column)
+		}
+		byColumn[s.Column] = append(byColumn[s.Column], s.Values...)
+	}
+
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+	cleared := make(map[string]int)
+	for i, p := range points {
+		copied := false
+		for col, values := range byColumn {
+			val, ok := p.Data[col]
+			if !ok || !isSentinel(val, values) {
+				continue
+			}
+			if !copied {
+				result[i].Data = maps.Clone(p.Data)
+				copied = true
+			}
+			delete(result[i].Data, col)
+			cleared[col]++
+		}
+	}
+
+	return result, cleared, nil
+}
+
+func isSentinel(v float64, values []float64) bool {
+	for _, s := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
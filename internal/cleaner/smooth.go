@@ -0,0 +1,102 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+
+	"mbdvr/internal/types"
+)
+
+// smoothColumns replaces each value with the mean, median or Savitzky-Golay fit of a centered window
+// of the same recording's samples. The window is windowSamples wide, or windowSeconds wide when set.
+// Missing values are skipped inside windows and stay missing.
+func smoothColumns(points []types.DataPoint, cols []string, method string, windowSamples int, windowSeconds float64, poly int) ([]types.DataPoint, int, error) {
+	if method != "mean" && method != "median" && method != "savgol" {
+		return nil, 0, fmt.Errorf("unknown smoothing method %q (use 'mean', 'median' or 'savgol')", method)
+	}
+	if windowSeconds <= 0 && windowSamples < 2 {
+		return nil, 0, fmt.Errorf("smoothing window must be at least 2 samples")
+	}
+	if method == "savgol" {
+		if poly < 0 {
+			return nil, 0, fmt.Errorf("polynomial order must not be negative")
+		}
+		if windowSeconds <= 0 && windowSamples <= poly {
+			return nil, 0, fmt.Errorf("smoothing window (%d samples) must be larger than the polynomial order (%d)", windowSamples, poly)
+		}
+	}
+
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+	for i := range result {
+		result[i].Data = maps.Clone(points[i].Data)
+	}
+
+	smoothed := 0
+	window := make([]float64, 0, windowSamples)
+	offsets := make([]float64, 0, windowSamples) // Seconds relative to the smoothed sample
+	for _, idx := range types.RecordingIndices(points) {
+		for _, col := range cols {
+			lo, hi := 0, 0 // Window bounds in idx for time-based windows
+			for k, i := range idx {
+				if val, ok := points[i].Data[col]; !ok || math.IsNaN(val) {
+					continue
+				}
+
+				var from, to int
+				if windowSeconds > 0 {
+					t := points[i].Timestamp
+					for lo < k && t-points[idx[lo]].Timestamp > windowSeconds/2 {
+						lo++
+					}
+					if hi < k {
+						hi = k
+					}
+					for hi+1 < len(idx) && points[idx[hi+1]].Timestamp-t <= windowSeconds/2 {
+						hi++
+					}
+					from, to = lo, hi
+				} else {
+					from = k - windowSamples/2
+					to = from + windowSamples - 1
+					if from < 0 {
+						from = 0
+					}
+					if to >= len(idx) {
+						to = len(idx) - 1
+					}
+				}
+
+				window, offsets = window[:0], offsets[:0]
+				for w := from; w <= to; w++ {
+					if val, ok := points[idx[w]].Data[col]; ok && !math.IsNaN(val) {
+						window = append(window, val)
+						offsets = append(offsets, points[idx[w]].Timestamp-points[i].Timestamp)
+					}
+				}
+				if method == "savgol" {
+					result[i].Data[col] = savitzkyGolay(offsets, window, poly)
+				} else {
+					result[i].Data[col] = windowValue(window, method)
+				}
+				smoothed++
+			}
+		}
+	}
+
+	return result, smoothed, nil
+}
+
+func windowValue(window []float64, method string) float64 {
+	if method == "median" {
+		sort.Float64s(window)
+		mid := len(window) / 2
+		if len(window)%2 == 0 {
+			return (window[mid-1] + window[mid]) / 2
+		}
+		return window[mid]
+	}
+	return mean(window)
+}
@@ -0,0 +1,192 @@
+package cleaner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// TimestampStats reports what the timestamp repair changed
+type TimestampStats struct {
+	Repeated   int `json:"repeated"`    // Samples with the same timestamp as the previous sample
+	Inverted   int `json:"inverted"`    // Samples with an earlier timestamp than the previous sample
+	Repaired   int `json:"repaired"`    // Repeated or inverted samples that were re-timed
+	ClockJumps int `json:"clock_jumps"` // Backwards jumps larger than the repair limit, left as they are
+}
+
+// DriftAnchor pairs a recorded timestamp with the reference time it should map to,
+// e.g. a sync pulse logged by both the headset and the experiment computer
+type DriftAnchor struct {
+	Recorded  float64
+	Reference float64
+}
+
+// ParseDriftAnchors reads anchors such as "12.5=12.5,1805.2=1806.1" (recorded=reference, in seconds)
+func ParseDriftAnchors(spec string) ([]DriftAnchor, error) {
+	var anchors []DriftAnchor
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid drift anchor %q (use recorded=reference)", pair)
+		}
+		recorded, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		reference, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid drift anchor %q (use recorded=reference)", pair)
+		}
+		anchors = append(anchors, DriftAnchor{Recorded: recorded, Reference: reference})
+	}
+	return anchors, nil
+}
+
+// repairTimestamps re-times repeated and slightly backwards timestamps of each recording, in
+// sample order. A run of such samples is spread evenly between the last good timestamp and the
+// next later one. Backwards jumps larger than maxInversion seconds are taken as clock resets:
+// they are counted but left for the user to handle, since no nearby time can be trusted.
+func repairTimestamps(points []types.DataPoint, maxInversion float64) ([]types.DataPoint, TimestampStats) {
+	result := make([]types.DataPoint, len(points))
+	copy(result, points)
+
+	var stats TimestampStats
+	for _, idx := range recordingOrder(result) {
+		for k := 1; k < len(idx); {
+			prev := result[idx[k-1]].Timestamp
+			t := result[idx[k]].Timestamp
+			if t > prev {
+				k++
+				continue
+			}
+			if prev-t > maxInversion {
+				stats.ClockJumps++
+				k++
+				continue
+			}
+
+			// Collect the run of samples not after prev (within the repair limit)
+			run := k
+			for k < len(idx) {
+				t := result[idx[k]].Timestamp
+				if t > prev || prev-t > maxInversion {
+					break
+				}
+				if t == prev {
+					stats.Repeated++
+				} else {
+					stats.Inverted++
+				}
+				k++
+			}
+
+			var next float64
+			if k < len(idx) && result[idx[k]].Timestamp > prev {
+				next = result[idx[k]].Timestamp
+			} else {
+				// Nothing later to anchor on: continue at the recording's typical interval
+				next = prev + float64(k-run+1)*typicalInterval(result, idx)
+			}
+			step := (next - prev) / float64(k-run+1)
+			if step <= 0 {
+				continue
+			}
+			for j := run; j < k; j++ {
+				result[idx[j]].Timestamp = prev + float64(j-run+1)*step
+				stats.Repaired++
+			}
+		}
+	}
+	return result, stats
+}
+
+// recordingOrder groups point indices by recording (participant and condition), keeping the order in
+// which they were recorded; unlike types.RecordingIndices it doesn't sort, since the timestamps are
+// what is being repaired
+func recordingOrder(points []types.DataPoint) [][]int {
+	type recording struct{ participant, condition string }
+	groups := make(map[recording][]int)
+	var order []recording
+	for i, p := range points {
+		key := recording{p.ParticipantID, p.Condition}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	result := make([][]int, 0, len(order))
+	for _, key := range order {
+		result = append(result, groups[key])
+	}
+	return result
+}
+
+// typicalInterval is the median positive interval between consecutive samples (default 1ms)
+func typicalInterval(points []types.DataPoint, idx []int) float64 {
+	var intervals []float64
+	for k := 1; k < len(idx); k++ {
+		if d := points[idx[k]].Timestamp - points[idx[k-1]].Timestamp; d > 0 {
+			intervals = append(intervals, d)
+		}
+	}
+	if len(intervals) == 0 {
+		return 0.001
+	}
+	sort.Float64s(intervals)
+	return intervals[len(intervals)/2]
+}
+
+// fitDrift fits reference = offset + scale*recorded through the anchors by least squares.
+// A single anchor only shifts the clock.
+func fitDrift(anchors []DriftAnchor) (scale, offset float64, err error) {
+	if len(anchors) == 0 {
+		return 1, 0, fmt.Errorf("no drift anchors")
+	}
+	if len(anchors) == 1 {
+		return 1, anchors[0].Reference - anchors[0].Recorded, nil
+	}
+	var mx, my float64
+	for _, a := range anchors {
+		mx += a.Recorded
+		my += a.Reference
+	}
+	mx /= float64(len(anchors))
+	my /= float64(len(anchors))
+	var sxy, sxx float64
+	for _, a := range anchors {
+		sxy += (a.Recorded - mx) * (a.Reference - my)
+		sxx += (a.Recorded - mx) * (a.Recorded - mx)
+	}
+	if sxx == 0 {
+		return 1, 0, fmt.Errorf("drift anchors need distinct recorded times")
+	}
+	scale = sxy / sxx
+	if scale <= 0 || math.IsNaN(scale) {
+		return 1, 0, fmt.Errorf("drift anchors give a non-increasing clock mapping")
+	}
+	return scale, my - scale*mx, nil
+}
+
+// correctDrift maps every sample and event time through the fitted drift correction
+func correctDrift(points []types.DataPoint, events []types.Event, scale, offset float64) ([]types.DataPoint, []types.Event) {
+	result := make([]types.DataPoint, len(points))
+	for i, p := range points {
+		p.Timestamp = offset + scale*p.Timestamp
+		result[i] = p
+	}
+	var corrected []types.Event
+	if events != nil {
+		corrected = make([]types.Event, len(events))
+		for i, e := range events {
+			e.Start = offset + scale*e.Start
+			e.End = offset + scale*e.End
+			corrected[i] = e
+		}
+	}
+	return result, corrected
+}
@@ -0,0 +1,152 @@
+package cleaner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+
+	"mbdvr/internal/events"
+	"mbdvr/internal/spatial"
+	"mbdvr/internal/types"
+)
+
+// ImplausibleVelocityColumn is added by the "label" velocity action: 1 for implausibly fast samples, 0 otherwise
+const ImplausibleVelocityColumn = "implausible_velocity"
+
+// gazeSpeeds returns each point's angular speed in degrees per second: the slower of the moves from the
+// previous valid sample to it and from it to the next, so a glitch is fast both ways while the samples
+// around it are not. Two columns are angles in degrees, three a gaze direction vector. Points without a
+// valid gaze or neighbour get NaN.
+func gazeSpeeds(points []types.DataPoint, cols []string) []float64 {
+	speeds := make([]float64, len(points))
+	for i := range speeds {
+		speeds[i] = math.NaN()
+	}
+	gaze := func(p types.DataPoint) (spatial.Vec3, bool) {
+		var v spatial.Vec3
+		for k, col := range cols {
+			x, ok := p.Data[col]
+			if !ok || math.IsNaN(x) {
+				return v, false
+			}
+			v[k] = x
+		}
+		return v, true
+	}
+	distance := func(a, b spatial.Vec3) float64 {
+		if len(cols) == 3 {
+			return spatial.AngleBetween(a, b)
+		}
+		return math.Hypot(a[0]-b[0], a[1]-b[1])
+	}
+
+	for _, idx := range types.RecordingIndices(points) {
+		var valid []int
+		var positions []spatial.Vec3
+		for _, i := range idx {
+			if v, ok := gaze(points[i]); ok {
+				valid = append(valid, i)
+				positions = append(positions, v)
+			}
+		}
+		// step[k] is the speed from valid sample k-1 to k
+		step := make([]float64, len(valid))
+		for k := 1; k < len(valid); k++ {
+			step[k] = math.NaN()
+			if dt := points[valid[k]].Timestamp - points[valid[k-1]].Timestamp; dt > 0 {
+				step[k] = distance(positions[k-1], positions[k]) / dt
+			}
+		}
+		for k, i := range valid {
+			switch {
+			case len(valid) < 2:
+			case k == 0:
+				speeds[i] = step[1]
+			case k == len(valid)-1:
+				speeds[i] = step[k]
+			default:
+				speeds[i] = math.Min(step[k], step[k+1])
+			}
+		}
+	}
+	return speeds
+}
+
+// detectImplausibleVelocity finds the points at or above MaxVelocity degrees per second for at least
+// MinVelocityDuration seconds, and returns them with the number of periods
+func detectImplausibleVelocity(points []types.DataPoint, columns []string, config CleanConfig) (map[int]bool, int, error) {
+	if len(config.VelocityColumns) != 2 && len(config.VelocityColumns) != 3 {
+		return nil, 0, fmt.Errorf("velocity filtering needs two gaze angle columns or three gaze direction columns")
+	}
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+	for _, col := range config.VelocityColumns {
+		if !known[col] {
+			return nil, 0, fmt.Errorf("velocity column %s not found", col)
+		}
+	}
+
+	speeds := gazeSpeeds(points, config.VelocityColumns)
+	detector := events.Hysteresis{Enter: config.MaxVelocity, Exit: config.MaxVelocity, MinDuration: config.MinVelocityDuration}
+	implausible := make(map[int]bool)
+	periods := 0
+	for _, idx := range types.RecordingIndices(points) {
+		times := make([]float64, len(idx))
+		signal := make([]float64, len(idx))
+		for k, i := range idx {
+			times[k] = points[i].Timestamp
+			signal[k] = speeds[i]
+		}
+		for _, iv := range detector.Detect(times, signal) {
+			periods++
+			for k := iv.Start; k < iv.End; k++ {
+				implausible[idx[k]] = true
+			}
+		}
+	}
+	return implausible, periods, nil
+}
+
+// handleImplausibleVelocity removes, clears or labels the points in implausible
+func handleImplausibleVelocity(points []types.DataPoint, columns, cols []string, implausible map[int]bool, action string) ([]types.DataPoint, []string, error) {
+	switch action {
+	case "remove":
+		var kept []types.DataPoint
+		for i, p := range points {
+			if !implausible[i] {
+				kept = append(kept, p)
+			}
+		}
+		return kept, columns, nil
+
+	case "nan":
+		result := make([]types.DataPoint, len(points))
+		copy(result, points)
+		for i := range implausible {
+			data := maps.Clone(points[i].Data)
+			for _, col := range cols {
+				delete(data, col)
+			}
+			result[i].Data = data
+		}
+		return result, columns, nil
+
+	case "label":
+		result := make([]types.DataPoint, len(points))
+		for i, p := range points {
+			data := types.CloneData(p.Data, 1)
+			data[ImplausibleVelocityColumn] = 0
+			if implausible[i] {
+				data[ImplausibleVelocityColumn] = 1
+			}
+			result[i] = p
+			result[i].Data = data
+		}
+		return result, appendColumn(columns, ImplausibleVelocityColumn), nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown velocity action %q (use 'remove', 'nan' or 'label')", action)
+	}
+}
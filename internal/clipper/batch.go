@@ -0,0 +1,286 @@
+package clipper
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BatchClip is one clip of a batch config: an input file cut by times, segments or events
+type BatchClip struct {
+	Input      string
+	Output     string
+	Config     ClipConfig
+	Events     *EventWindow // Clip from events instead of times; the segments are found once the input is loaded
+	PerSegment bool         // Write one file per segment instead of one joined dataset
+	Line       int          // Line of the clip in the config file, for error messages
+}
+
+// batchKeys are the settings of a clip, in the order they are documented
+var batchKeys = []string{"input", "output", "start", "end", "duration", "segments", "from_event", "to_event", "pre", "post", "relative", "rebase", "clamp", "per_segment"}
+
+// ParseBatch reads a batch clip config from YAML: a list of clips, on its own or under a "clips" key
+// next to "defaults" applied to every clip. Each clip is a map of settings named like the clip flags
+// (input, output, start, end, duration, segments, from_event, to_event, pre, post, relative, rebase, clamp, per_segment).
+func ParseBatch(data []byte) ([]BatchClip, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid clip config: %v", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("clip config is empty")
+	}
+	list := root.Content[0]
+	defaults := map[string]string{}
+	if list.Kind == yaml.MappingNode {
+		var found *yaml.Node
+		for i := 0; i+1 < len(list.Content); i += 2 {
+			key, value := list.Content[i], list.Content[i+1]
+			switch key.Value {
+			case "clips":
+				found = value
+			case "defaults":
+				var err error
+				if defaults, err = batchSettings(value); err != nil {
+					return nil, fmt.Errorf("line %d: defaults: %v", value.Line, err)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: unknown clip config key %q", key.Line, key.Value)
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("clip config has no clips")
+		}
+		list = found
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: the clips must be a list", list.Line)
+	}
+
+	var clips []BatchClip
+	for _, item := range list.Content {
+		settings, err := batchSettings(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", item.Line, err)
+		}
+		clip, err := batchClip(settings, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", item.Line, err)
+		}
+		clip.Line = item.Line
+		clips = append(clips, clip)
+	}
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("clip config has no clips")
+	}
+	return clips, nil
+}
+
+// ParseBatchCSV reads a batch clip config from CSV, e.g. exported from a spreadsheet of segment times:
+// a header row of the settings used (input and output required) and one clip per row. Empty cells
+// leave a setting unset.
+func ParseBatchCSV(data []byte) ([]BatchClip, error) {
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid clip config: %v", err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("clip config has no clips")
+	}
+	header := rows[0]
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+		if !contains(batchKeys, header[i]) {
+			return nil, fmt.Errorf("unknown clip config column %q (columns: %s)", header[i], strings.Join(batchKeys, ", "))
+		}
+	}
+
+	var clips []BatchClip
+	for n, row := range rows[1:] {
+		settings := make(map[string]string)
+		for i, value := range row {
+			if value = strings.TrimSpace(value); value != "" {
+				settings[header[i]] = value
+			}
+		}
+		if len(settings) == 0 {
+			continue
+		}
+		clip, err := batchClip(settings, nil)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+2, err)
+		}
+		clip.Line = n + 2
+		clips = append(clips, clip)
+	}
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("clip config has no clips")
+	}
+	return clips, nil
+}
+
+// LoadBatch reads a batch clip config file, as CSV (.csv) or YAML
+func LoadBatch(filename string) ([]BatchClip, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clip config: %v", err)
+	}
+	var clips []BatchClip
+	if strings.ToLower(filepath.Ext(filename)) == ".csv" {
+		clips, err = ParseBatchCSV(data)
+	} else {
+		clips, err = ParseBatch(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return clips, nil
+}
+
+// NewBatchClip checks the settings of one clip, named as in a batch config, e.g. to run the same clip
+// over many files
+func NewBatchClip(settings map[string]string) (BatchClip, error) {
+	for key := range settings {
+		if !contains(batchKeys, key) {
+			return BatchClip{}, fmt.Errorf("unknown clip setting %q (settings: %s)", key, strings.Join(batchKeys, ", "))
+		}
+	}
+	return batchClip(settings, nil)
+}
+
+// batchSettings reads a map of clip settings
+func batchSettings(node *yaml.Node) (map[string]string, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("a clip must be a map of settings such as 'input', 'output', 'start' and 'end'")
+	}
+	settings := make(map[string]string)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if !contains(batchKeys, key) {
+			return nil, fmt.Errorf("unknown clip setting %q (settings: %s)", key, strings.Join(batchKeys, ", "))
+		}
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s must be a single value", key)
+		}
+		settings[key] = value.Value
+	}
+	return settings, nil
+}
+
+// batchClip checks the settings of one clip, falling back to defaults for those it doesn't set
+func batchClip(settings, defaults map[string]string) (BatchClip, error) {
+	get := func(key string) string {
+		if v, ok := settings[key]; ok {
+			return v
+		}
+		return defaults[key]
+	}
+	flag := func(key string) (bool, error) {
+		v := get(key)
+		if v == "" {
+			return false, nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("%s must be true or false, not %q", key, v)
+		}
+		return b, nil
+	}
+	seconds := func(key string) (*float64, error) {
+		v := get(key)
+		if v == "" {
+			return nil, nil
+		}
+		s, err := ParseSeconds(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		return &s, nil
+	}
+
+	clip := BatchClip{Input: get("input"), Output: get("output")}
+	if clip.Input == "" || clip.Output == "" {
+		return clip, fmt.Errorf("a clip needs an input and an output")
+	}
+	for _, t := range []struct {
+		key    string
+		time   **float64
+		anchor *Anchor
+	}{{"start", &clip.Config.StartTime, &clip.Config.StartAnchor}, {"end", &clip.Config.EndTime, &clip.Config.EndAnchor}} {
+		if v := get(t.key); v != "" {
+			s, anchor, err := ParseClipTime(v)
+			if err != nil {
+				return clip, fmt.Errorf("%s: %v", t.key, err)
+			}
+			*t.time, *t.anchor = &s, anchor
+		}
+	}
+	if v := get("duration"); v != "" {
+		if clip.Config.EndTime != nil {
+			return clip, fmt.Errorf("use either end or duration")
+		}
+		d, err := ParseSeconds(v)
+		if err != nil || d <= 0 {
+			return clip, fmt.Errorf("duration must be a positive length of time, not %q", v)
+		}
+		clip.Config.EndTime, clip.Config.EndAnchor = &d, FromStart
+	}
+	var err error
+	if clip.Config.Relative, err = flag("relative"); err != nil {
+		return clip, err
+	}
+	if clip.Config.Rebase, err = flag("rebase"); err != nil {
+		return clip, err
+	}
+	if clip.Config.Clamp, err = flag("clamp"); err != nil {
+		return clip, err
+	}
+	if clip.PerSegment, err = flag("per_segment"); err != nil {
+		return clip, err
+	}
+	timed := clip.Config.StartTime != nil || clip.Config.EndTime != nil
+
+	if segments := get("segments"); segments != "" {
+		if timed {
+			return clip, fmt.Errorf("use either start/end or segments")
+		}
+		if clip.Config.Segments, err = ParseSegments(segments); err != nil {
+			return clip, err
+		}
+	}
+	if from := get("from_event"); from != "" {
+		if timed || clip.Config.Segments != nil {
+			return clip, fmt.Errorf("use one of start/end, segments and from_event")
+		}
+		if clip.Config.Relative {
+			return clip, fmt.Errorf("relative can't be used with from_event, which clips around the event times")
+		}
+		clip.Events = &EventWindow{FromEvent: from, ToEvent: get("to_event")}
+		for key, pad := range map[string]*float64{"pre": &clip.Events.Pre, "post": &clip.Events.Post} {
+			v, err := seconds(key)
+			if err != nil {
+				return clip, err
+			}
+			if v != nil {
+				*pad = *v
+			}
+		}
+	} else if get("to_event") != "" || get("pre") != "" || get("post") != "" {
+		return clip, fmt.Errorf("to_event, pre and post need from_event")
+	}
+
+	if !timed && clip.Config.Segments == nil && clip.Events == nil {
+		return clip, fmt.Errorf("a clip needs start/end, segments or from_event")
+	}
+	if clip.PerSegment && clip.Config.Segments == nil && clip.Events == nil {
+		return clip, fmt.Errorf("per_segment needs segments or from_event")
+	}
+	return clip, nil
+}
@@ -3,15 +3,120 @@ package clipper
 import (
 	"fmt"
 	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"mbdvr/internal/types"
 )
 
 type ClipConfig struct {
-	StartTime *float64 // nil = from beginning
-	EndTime   *float64 // nil = to end
+	StartTime   *float64  // nil = from beginning
+	EndTime     *float64  // nil = to end
+	StartAnchor Anchor    // What StartTime is measured from (default: a timestamp)
+	EndAnchor   Anchor    // What EndTime is measured from
+	Segments    []Segment // Several time ranges clipped by ClipSegments; StartTime and EndTime are then ignored
+	Relative    bool      // Times are offsets from each participant's first timestamp instead of absolute
+	Rebase      bool      // Shift each participant's clipped data to start at t=0 (see Rebase)
+	Clamp       bool      // Snap times outside the recording to its first or last timestamp instead of failing
+
+	StartFrame *int // Index of the first point to keep, instead of StartTime (nil = from beginning)
+	EndFrame   *int // Index of the last point to keep, instead of EndTime (nil = to end)
+}
+
+// Anchor is what a clip time is measured from
+type Anchor int
+
+const (
+	Absolute  Anchor = iota // A timestamp (with Relative, seconds after each participant's first timestamp)
+	FromStart               // Seconds after the start: of the data for StartTime, of the clip for EndTime
+	FromEnd                 // Seconds before the end of the data
+)
+
+// ParseClipTime reads a --start or --end time: seconds ("12.5", or with a unit such as "90s"), seconds
+// before the end of the data ("-30"; "-0" is the end) or seconds after the start ("+60")
+func ParseClipTime(s string) (float64, Anchor, error) {
+	s = strings.TrimSpace(s)
+	anchor := Absolute
+	switch {
+	case strings.HasPrefix(s, "-"):
+		anchor, s = FromEnd, s[1:]
+	case strings.HasPrefix(s, "+"):
+		anchor, s = FromStart, s[1:]
+	}
+	t, err := ParseSeconds(s)
+	if err != nil {
+		return 0, anchor, err
+	}
+	if t < 0 {
+		return 0, anchor, fmt.Errorf("invalid time %q", s)
+	}
+	return t, anchor, nil
 }
 
+func (a Anchor) String() string {
+	switch a {
+	case FromStart:
+		return "start"
+	case FromEnd:
+		return "end"
+	}
+	return "absolute"
+}
+
+// anchored resolves the configured start and end times for a recording from first to last into
+// timestamps (or offsets, when first is 0); unset times stay nil
+func anchored(config ClipConfig, first, last float64) (*float64, *float64) {
+	var start, end *float64
+	if config.StartTime != nil {
+		t := *config.StartTime
+		switch config.StartAnchor {
+		case FromStart:
+			t = first + t
+		case FromEnd:
+			t = last - t
+		}
+		start = &t
+	}
+	if config.EndTime != nil {
+		t := *config.EndTime
+		switch config.EndAnchor {
+		case FromStart:
+			from := first
+			if start != nil {
+				from = *start
+			}
+			t = from + t
+		case FromEnd:
+			t = last - t
+		}
+		end = &t
+	}
+	return start, end
+}
+
+// Segment is a time range in seconds
+type Segment struct {
+	Start         float64
+	End           float64
+	ParticipantID string // Clip only this participant's data ("" = all participants)
+}
+
+func (s Segment) String() string {
+	r := strconv.FormatFloat(s.Start, 'g', -1, 64) + "-" + strconv.FormatFloat(s.End, 'g', -1, 64)
+	if s.ParticipantID != "" {
+		return s.ParticipantID + " " + r
+	}
+	return r
+}
+
+// SegmentColumn numbers the segments (from 1) in a dataset built by ConcatSegments
+const SegmentColumn = "segment"
+
+// ClipInfo describes a clip. With ClipConfig.Relative, ActualStartTime and ActualEndTime are the
+// earliest and latest offsets from a participant's first timestamp.
 type ClipInfo struct {
 	MinTimestamp    float64
 	MaxTimestamp    float64
@@ -22,12 +127,26 @@ type ClipInfo struct {
 	EndFrame        int // Index of last clipped point
 	ActualStartTime float64
 	ActualEndTime   float64
+	RequestedStart  float64 // Start of the range asked for, with times from the start or end resolved
+	RequestedEnd    float64 // (with Relative, the widest range over the participants)
+	StartClamped    float64 // With Clamp, seconds the start was moved into the recording (0 = not moved, < 0 = earlier)
+	EndClamped      float64 // With Clamp, seconds the end was moved into the recording
+
+	SkippedParticipants int // With Relative, participants without data in the range
 }
 
 func ClipDataset(dataset *types.Dataset, config ClipConfig) (*types.Dataset, ClipInfo, error) {
 	if dataset == nil || len(dataset.Points) == 0 {
 		return nil, ClipInfo{}, fmt.Errorf("dataset is empty")
 	}
+	if config.Rebase {
+		config.Rebase = false
+		clipped, info, err := ClipDataset(dataset, config)
+		if err != nil {
+			return nil, info, err
+		}
+		return Rebase(clipped), info, nil
+	}
 
 	info := ClipInfo{
 		OriginalPoints: len(dataset.Points),
@@ -46,9 +165,34 @@ func ClipDataset(dataset *types.Dataset, config ClipConfig) (*types.Dataset, Cli
 
 	info.TotalDuration = info.MaxTimestamp - info.MinTimestamp
 
+	framed := config.StartFrame != nil || config.EndFrame != nil
+	if framed && (config.StartTime != nil || config.EndTime != nil || config.Relative) {
+		return nil, info, fmt.Errorf("clip by either frames or times, not both")
+	}
+	if config.Relative {
+		return clipRelative(dataset, config, info)
+	}
+	if framed {
+		return clipFrames(dataset, config, info)
+	}
+	requested := config
+	config.StartTime, config.EndTime = anchored(config, info.MinTimestamp, info.MaxTimestamp)
+
 	startTime := info.MinTimestamp
 	endTime := info.MaxTimestamp
 
+	if config.Clamp {
+		clamp := func(t *float64) (*float64, float64) {
+			if t == nil {
+				return nil, 0
+			}
+			c := math.Max(info.MinTimestamp, math.Min(info.MaxTimestamp, *t))
+			return &c, c - *t
+		}
+		config.StartTime, info.StartClamped = clamp(config.StartTime)
+		config.EndTime, info.EndClamped = clamp(config.EndTime)
+	}
+
 	if config.StartTime != nil {
 		if *config.StartTime < info.MinTimestamp || *config.StartTime > info.MaxTimestamp {
 			return nil, info, fmt.Errorf("start time %.2f is out of bounds (%.2f - %.2f)", *config.StartTime, info.MinTimestamp, info.MaxTimestamp)
@@ -66,6 +210,7 @@ func ClipDataset(dataset *types.Dataset, config ClipConfig) (*types.Dataset, Cli
 	if endTime <= startTime {
 		return nil, info, fmt.Errorf("end time %.2f must be greater than start time %.2f", endTime, startTime)
 	}
+	info.RequestedStart, info.RequestedEnd = startTime, endTime
 
 	//Find closest frames to start and end times
 	startFrame := -1
@@ -105,10 +250,698 @@ func ClipDataset(dataset *types.Dataset, config ClipConfig) (*types.Dataset, Cli
 			"requested_end":     endTime,
 		},
 	}
+	recordAnchors(clippedDataset, requested)
+	recordClamps(clippedDataset, info)
 
 	return clippedDataset, info, nil
 }
 
+// recordAnchors notes in the metadata the times given from the start or end of the data, as given
+func recordAnchors(dataset *types.Dataset, config ClipConfig) {
+	if config.StartTime != nil && config.StartAnchor != Absolute {
+		dataset.Metadata["start_anchor"] = config.StartAnchor.String()
+		dataset.Metadata["start_offset"] = *config.StartTime
+	}
+	if config.EndTime != nil && config.EndAnchor != Absolute {
+		dataset.Metadata["end_anchor"] = config.EndAnchor.String()
+		dataset.Metadata["end_offset"] = *config.EndTime
+	}
+}
+
+// recordClamps notes in the metadata how far Clamp moved the start and end, if at all
+func recordClamps(dataset *types.Dataset, info ClipInfo) {
+	if info.StartClamped != 0 {
+		dataset.Metadata["start_clamped"] = info.StartClamped
+	}
+	if info.EndClamped != 0 {
+		dataset.Metadata["end_clamped"] = info.EndClamped
+	}
+}
+
+// clipFrames keeps the points from index StartFrame to EndFrame, inclusive
+func clipFrames(dataset *types.Dataset, config ClipConfig, info ClipInfo) (*types.Dataset, ClipInfo, error) {
+	last := len(dataset.Points) - 1
+	startFrame, endFrame := 0, last
+	if config.StartFrame != nil {
+		startFrame = *config.StartFrame
+	}
+	if config.EndFrame != nil {
+		endFrame = *config.EndFrame
+	}
+	if startFrame < 0 || startFrame > last {
+		return nil, info, fmt.Errorf("start frame %d is out of bounds (0 - %d)", startFrame, last)
+	}
+	if endFrame < 0 || endFrame > last {
+		return nil, info, fmt.Errorf("end frame %d is out of bounds (0 - %d)", endFrame, last)
+	}
+	if endFrame < startFrame {
+		return nil, info, fmt.Errorf("end frame %d must not be before start frame %d", endFrame, startFrame)
+	}
+
+	clippedPoints := dataset.Points[startFrame : endFrame+1]
+
+	info.ClippedPoints = len(clippedPoints)
+	info.StartFrame = startFrame
+	info.EndFrame = endFrame
+	info.ActualStartTime = clippedPoints[0].Timestamp
+	info.ActualEndTime = clippedPoints[len(clippedPoints)-1].Timestamp
+
+	clippedDataset := &types.Dataset{
+		Points:  clippedPoints,
+		Columns: dataset.Columns,
+		Metadata: map[string]interface{}{
+			"original_points":   info.OriginalPoints,
+			"clipped_points":    info.ClippedPoints,
+			"original_duration": info.TotalDuration,
+			"clipped_duration":  info.ActualEndTime - info.ActualStartTime,
+			"start_time":        info.ActualStartTime,
+			"end_time":          info.ActualEndTime,
+			"start_frame":       startFrame,
+			"end_frame":         endFrame,
+		},
+	}
+
+	return clippedDataset, info, nil
+}
+
+// clipRelative keeps each participant's points from StartTime to EndTime seconds after its own first
+// timestamp, so the same range can be cut from every session of a merged dataset. Times from the end
+// count back from each participant's last timestamp. Sessions shorter than the range are clipped to
+// their end; those that end before StartTime are skipped.
+func clipRelative(dataset *types.Dataset, config ClipConfig, info ClipInfo) (*types.Dataset, ClipInfo, error) {
+	startTime, endTime := 0.0, info.TotalDuration
+	if config.StartTime != nil {
+		startTime = *config.StartTime
+	}
+	if config.EndTime != nil {
+		endTime = *config.EndTime
+	}
+	if config.Clamp && config.StartAnchor == Absolute && startTime < 0 {
+		info.StartClamped, startTime = -startTime, 0
+		config.StartTime = &startTime
+	}
+	if config.StartAnchor == Absolute && config.EndAnchor == Absolute {
+		if startTime < 0 {
+			return nil, info, fmt.Errorf("relative start time %.2f must not be negative", startTime)
+		}
+		if endTime <= startTime {
+			return nil, info, fmt.Errorf("end time %.2f must be greater than start time %.2f", endTime, startTime)
+		}
+	}
+
+	first := make(map[string]float64)
+	last := make(map[string]float64)
+	var participants []string
+	for _, point := range dataset.Points {
+		if t, ok := first[point.ParticipantID]; !ok || point.Timestamp < t {
+			if !ok {
+				participants = append(participants, point.ParticipantID)
+				last[point.ParticipantID] = point.Timestamp
+			}
+			first[point.ParticipantID] = point.Timestamp
+		}
+		last[point.ParticipantID] = math.Max(last[point.ParticipantID], point.Timestamp)
+	}
+	// Each participant's range as offsets from their first timestamp
+	ranges := make(map[string][2]float64, len(participants))
+	info.RequestedStart, info.RequestedEnd = math.Inf(1), math.Inf(-1)
+	for _, id := range participants {
+		start, end := anchored(config, 0, last[id]-first[id])
+		r := [2]float64{startTime, endTime}
+		if start != nil {
+			r[0] = *start
+		}
+		if end != nil {
+			r[1] = *end
+		}
+		ranges[id] = r
+		info.RequestedStart = math.Min(info.RequestedStart, r[0])
+		info.RequestedEnd = math.Max(info.RequestedEnd, r[1])
+	}
+
+	var clippedPoints []types.DataPoint
+	clipped := make(map[string]bool)
+	info.StartFrame, info.EndFrame = -1, -1
+	info.ActualStartTime, info.ActualEndTime = math.Inf(1), math.Inf(-1)
+	for i, point := range dataset.Points {
+		offset := point.Timestamp - first[point.ParticipantID]
+		if r := ranges[point.ParticipantID]; offset < r[0] || offset > r[1] {
+			continue
+		}
+		if info.StartFrame == -1 {
+			info.StartFrame = i
+		}
+		info.EndFrame = i
+		info.ActualStartTime = math.Min(info.ActualStartTime, offset)
+		info.ActualEndTime = math.Max(info.ActualEndTime, offset)
+		clipped[point.ParticipantID] = true
+		clippedPoints = append(clippedPoints, point)
+	}
+	if len(clippedPoints) == 0 {
+		return nil, info, fmt.Errorf("no data points found in the specified time range")
+	}
+	info.ClippedPoints = len(clippedPoints)
+	info.SkippedParticipants = len(participants) - len(clipped)
+
+	clippedDataset := &types.Dataset{
+		Points:  clippedPoints,
+		Columns: dataset.Columns,
+		Metadata: map[string]interface{}{
+			"original_points":   info.OriginalPoints,
+			"clipped_points":    info.ClippedPoints,
+			"original_duration": info.TotalDuration,
+			"clipped_duration":  info.ActualEndTime - info.ActualStartTime,
+			"start_time":        info.ActualStartTime,
+			"end_time":          info.ActualEndTime,
+			"requested_start":   startTime,
+			"requested_end":     endTime,
+			"relative":          true,
+		},
+	}
+	recordAnchors(clippedDataset, config)
+	recordClamps(clippedDataset, info)
+
+	return clippedDataset, info, nil
+}
+
+// FilterDataset keeps the points of the listed participants and conditions (either list may be empty to
+// keep all), along with the events of those participants, so a sub-cohort can be taken from a merged
+// dataset. Names that match no point are an error, to catch typos.
+func FilterDataset(dataset *types.Dataset, participants, conditions []string) (*types.Dataset, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, fmt.Errorf("dataset is empty")
+	}
+	toSet := func(names []string) map[string]bool {
+		if len(names) == 0 {
+			return nil
+		}
+		set := make(map[string]bool, len(names))
+		for _, name := range names {
+			set[strings.TrimSpace(name)] = true
+		}
+		return set
+	}
+	keepParticipant, keepCondition := toSet(participants), toSet(conditions)
+
+	seenParticipant, seenCondition := make(map[string]bool), make(map[string]bool)
+	var points []types.DataPoint
+	for _, p := range dataset.Points {
+		seenParticipant[p.ParticipantID], seenCondition[p.Condition] = true, true
+		if (keepParticipant == nil || keepParticipant[p.ParticipantID]) && (keepCondition == nil || keepCondition[p.Condition]) {
+			points = append(points, p)
+		}
+	}
+	for _, check := range []struct {
+		kind       string
+		keep, seen map[string]bool
+	}{{"participant", keepParticipant, seenParticipant}, {"condition", keepCondition, seenCondition}} {
+		var missing []string
+		for name := range check.keep {
+			if !check.seen[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf("no data for %s %s", check.kind, strings.Join(missing, ", "))
+		}
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no data points match the participant and condition filters")
+	}
+
+	kept := make(map[string]bool)
+	for _, p := range points {
+		kept[p.ParticipantID] = true
+	}
+	var events []types.Event
+	for _, e := range dataset.Events {
+		if e.ParticipantID == "" || kept[e.ParticipantID] {
+			events = append(events, e)
+		}
+	}
+
+	metadata := make(map[string]interface{}, len(dataset.Metadata)+2)
+	for key, value := range dataset.Metadata {
+		metadata[key] = value
+	}
+	for key, set := range map[string]map[string]bool{"participants": keepParticipant, "conditions": keepCondition} {
+		if set == nil {
+			continue
+		}
+		names := make([]string, 0, len(set))
+		for name := range set {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		metadata[key] = names
+	}
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   events,
+	}, nil
+}
+
+// Exclusion is one range removed by ExcludeSegments
+type Exclusion struct {
+	Range    string  `json:"range"`
+	Points   int     `json:"points"`   // Points removed by this range (not by an earlier overlapping one)
+	Duration float64 `json:"duration"` // Seconds of recording inside the range, summed over participants
+}
+
+// ExcludeSegments removes config.Segments from the dataset and keeps everything else, e.g. to drop
+// calibration periods and breaks. Ranges include both ends and, with config.Relative, are offsets from
+// each participant's first timestamp. The remaining points stay in order, and the excluded ranges are
+// recorded under "excluded" in the metadata.
+func ExcludeSegments(dataset *types.Dataset, config ClipConfig) (*types.Dataset, ClipInfo, []Exclusion, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, ClipInfo{}, nil, fmt.Errorf("dataset is empty")
+	}
+	if len(config.Segments) == 0 {
+		return nil, ClipInfo{}, nil, fmt.Errorf("no ranges to exclude")
+	}
+	for _, segment := range config.Segments {
+		if segment.End <= segment.Start {
+			return nil, ClipInfo{}, nil, fmt.Errorf("range %s must end after it starts", segment)
+		}
+	}
+
+	info := ClipInfo{OriginalPoints: len(dataset.Points), MinTimestamp: math.Inf(1), MaxTimestamp: math.Inf(-1)}
+	spans := make(map[string][2]float64)
+	var participants []string
+	for _, p := range dataset.Points {
+		info.MinTimestamp = math.Min(info.MinTimestamp, p.Timestamp)
+		info.MaxTimestamp = math.Max(info.MaxTimestamp, p.Timestamp)
+		span, ok := spans[p.ParticipantID]
+		if !ok {
+			participants = append(participants, p.ParticipantID)
+			span = [2]float64{p.Timestamp, p.Timestamp}
+		}
+		spans[p.ParticipantID] = [2]float64{math.Min(span[0], p.Timestamp), math.Max(span[1], p.Timestamp)}
+	}
+	info.TotalDuration = info.MaxTimestamp - info.MinTimestamp
+
+	// The range of a segment in a participant's recording
+	bounds := func(segment Segment, id string) (float64, float64) {
+		if config.Relative {
+			return spans[id][0] + segment.Start, spans[id][0] + segment.End
+		}
+		return segment.Start, segment.End
+	}
+
+	exclusions := make([]Exclusion, len(config.Segments))
+	for i, segment := range config.Segments {
+		exclusions[i].Range = segment.String()
+		for _, id := range participants {
+			if segment.ParticipantID != "" && segment.ParticipantID != id {
+				continue
+			}
+			start, end := bounds(segment, id)
+			exclusions[i].Duration += math.Max(0, math.Min(end, spans[id][1])-math.Max(start, spans[id][0]))
+		}
+	}
+
+	var points []types.DataPoint
+	info.StartFrame, info.EndFrame = -1, -1
+	info.ActualStartTime, info.ActualEndTime = math.Inf(1), math.Inf(-1)
+	for i, p := range dataset.Points {
+		excluded := false
+		for k, segment := range config.Segments {
+			if segment.ParticipantID != "" && segment.ParticipantID != p.ParticipantID {
+				continue
+			}
+			if start, end := bounds(segment, p.ParticipantID); p.Timestamp >= start && p.Timestamp <= end {
+				exclusions[k].Points++
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+		if info.StartFrame == -1 {
+			info.StartFrame = i
+		}
+		info.EndFrame = i
+		info.ActualStartTime = math.Min(info.ActualStartTime, p.Timestamp)
+		info.ActualEndTime = math.Max(info.ActualEndTime, p.Timestamp)
+		points = append(points, p)
+	}
+	if len(points) == 0 {
+		return nil, info, exclusions, fmt.Errorf("the excluded ranges cover all of the data")
+	}
+	info.ClippedPoints = len(points)
+
+	excludedDuration := 0.0
+	for _, e := range exclusions {
+		excludedDuration += e.Duration
+	}
+	kept := &types.Dataset{
+		Points:  points,
+		Columns: dataset.Columns,
+		Metadata: map[string]interface{}{
+			"original_points":   info.OriginalPoints,
+			"clipped_points":    info.ClippedPoints,
+			"original_duration": info.TotalDuration,
+			"excluded":          exclusions,
+			"excluded_duration": excludedDuration,
+		},
+	}
+	if config.Rebase {
+		kept = Rebase(kept)
+	}
+	return kept, info, exclusions, nil
+}
+
+// RebaseMetadataKey records the seconds Rebase subtracted from each participant's timestamps
+const RebaseMetadataKey = "rebase_offsets"
+
+// Rebase subtracts each participant's first timestamp from their points and events, so every
+// participant's data starts at t=0 for averaging epochs aligned on their start. Events without a
+// participant are shifted by the earliest first timestamp. The offsets, by participant, are recorded
+// under RebaseMetadataKey so the original times can be recovered.
+func Rebase(dataset *types.Dataset) *types.Dataset {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return dataset
+	}
+	offsets := make(map[string]float64)
+	earliest := math.Inf(1)
+	for _, p := range dataset.Points {
+		if first, ok := offsets[p.ParticipantID]; !ok || p.Timestamp < first {
+			offsets[p.ParticipantID] = p.Timestamp
+		}
+		earliest = math.Min(earliest, p.Timestamp)
+	}
+
+	points := make([]types.DataPoint, len(dataset.Points))
+	for i, p := range dataset.Points {
+		p.Timestamp -= offsets[p.ParticipantID]
+		points[i] = p
+	}
+	var events []types.Event
+	for _, e := range dataset.Events {
+		offset, ok := offsets[e.ParticipantID]
+		if !ok {
+			offset = earliest
+		}
+		e.Start -= offset
+		e.End -= offset
+		events = append(events, e)
+	}
+
+	metadata := make(map[string]interface{}, len(dataset.Metadata)+1)
+	for key, value := range dataset.Metadata {
+		metadata[key] = value
+	}
+	metadata[RebaseMetadataKey] = offsets
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   events,
+	}
+}
+
+// ParseSegments reads comma-separated start-end ranges such as "10-20,45-60,90-120"
+func ParseSegments(spec string) ([]Segment, error) {
+	var segments []Segment
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// The range separator is the first '-' after the start time, which may itself be negative
+		sep := strings.Index(part[1:], "-") + 1
+		if sep <= 0 {
+			return nil, fmt.Errorf("invalid segment %q (use start-end)", part)
+		}
+		start, err := strconv.ParseFloat(strings.TrimSpace(part[:sep]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time in segment %q", part)
+		}
+		end, err := strconv.ParseFloat(strings.TrimSpace(part[sep+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end time in segment %q", part)
+		}
+		segments = append(segments, Segment{Start: start, End: end})
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no segments given")
+	}
+	return segments, nil
+}
+
+// ClipSegments clips each of config.Segments (or the StartTime-EndTime range when there are none) from
+// the dataset, so many trials are cut from one load. It returns one dataset per segment, in order.
+func ClipSegments(dataset *types.Dataset, config ClipConfig) ([]*types.Dataset, []ClipInfo, error) {
+	if len(config.Segments) == 0 {
+		clipped, info, err := ClipDataset(dataset, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*types.Dataset{clipped}, []ClipInfo{info}, nil
+	}
+
+	byParticipant := make(map[string]*types.Dataset)
+	datasets := make([]*types.Dataset, len(config.Segments))
+	infos := make([]ClipInfo, len(config.Segments))
+	for i, segment := range config.Segments {
+		source := dataset
+		if segment.ParticipantID != "" {
+			if source = byParticipant[segment.ParticipantID]; source == nil {
+				source = &types.Dataset{Columns: dataset.Columns}
+				for _, p := range dataset.Points {
+					if p.ParticipantID == segment.ParticipantID {
+						source.Points = append(source.Points, p)
+					}
+				}
+				byParticipant[segment.ParticipantID] = source
+			}
+		}
+		start, end := segment.Start, segment.End
+		clipped, info, err := ClipDataset(source, ClipConfig{StartTime: &start, EndTime: &end, Relative: config.Relative, Rebase: config.Rebase, Clamp: config.Clamp})
+		if err != nil {
+			return nil, nil, fmt.Errorf("segment %d (%s): %v", i+1, segment, err)
+		}
+		clipped.Metadata["segment"] = i + 1
+		datasets[i], infos[i] = clipped, info
+	}
+	return datasets, infos, nil
+}
+
+// ConcatSegments joins clipped segments into one dataset, with each point's segment number in
+// SegmentColumn. Points in overlapping segments appear once per segment.
+func ConcatSegments(segments []*types.Dataset, infos []ClipInfo) (*types.Dataset, error) {
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no segments to join")
+	}
+	for _, col := range segments[0].Columns {
+		if col == SegmentColumn {
+			return nil, fmt.Errorf("dataset already has a %s column", SegmentColumn)
+		}
+	}
+
+	var points []types.DataPoint
+	ranges := make([]string, len(segments))
+	for i, segment := range segments {
+		for _, p := range segment.Points {
+			data := types.CloneData(p.Data, 1)
+			data[SegmentColumn] = float64(i + 1)
+			p.Data = data
+			points = append(points, p)
+		}
+		ranges[i] = Segment{Start: infos[i].ActualStartTime, End: infos[i].ActualEndTime, ParticipantID: segmentParticipant(segment)}.String()
+	}
+
+	return &types.Dataset{
+		Points:  points,
+		Columns: append(append([]string{}, segments[0].Columns...), SegmentColumn),
+		Metadata: map[string]interface{}{
+			"original_points": infos[0].OriginalPoints,
+			"clipped_points":  len(points),
+			"segments":        ranges,
+		},
+	}, nil
+}
+
+// segmentParticipant returns the participant of a clipped segment, or "" if it has several
+func segmentParticipant(segment *types.Dataset) string {
+	for _, p := range segment.Points {
+		if p.ParticipantID != segment.Points[0].ParticipantID {
+			return ""
+		}
+	}
+	return segment.Points[0].ParticipantID
+}
+
+// EventWindow selects segments relative to events instead of absolute times. An event matches a name
+// when its type, its message or the first word of its message equals it.
+type EventWindow struct {
+	FromEvent string  // Segments start at each of these events
+	ToEvent   string  // and end at the next of these ("" = the end of the FromEvent itself)
+	Pre       float64 // Seconds of padding before the start
+	Post      float64 // Seconds of padding after the end
+}
+
+func eventMatches(e types.Event, name string) bool {
+	if e.Type == name || e.Message == name {
+		return true
+	}
+	fields := strings.Fields(e.Message)
+	return len(fields) > 0 && fields[0] == name
+}
+
+// EventSegments finds each participant's segments from events, within the participant's recording.
+// Events without a participant apply to everyone. A FromEvent without a matching ToEvent before the
+// next FromEvent is skipped and counted.
+func EventSegments(dataset *types.Dataset, events []types.Event, window EventWindow) ([]Segment, int, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, 0, fmt.Errorf("dataset is empty")
+	}
+	if window.FromEvent == "" {
+		return nil, 0, fmt.Errorf("no start event given")
+	}
+	if window.Pre < 0 || window.Post < 0 {
+		return nil, 0, fmt.Errorf("padding must not be negative")
+	}
+
+	// Each participant's recording range, in order of appearance
+	var participants []string
+	ranges := make(map[string][2]float64)
+	for _, p := range dataset.Points {
+		r, ok := ranges[p.ParticipantID]
+		if !ok {
+			participants = append(participants, p.ParticipantID)
+			r = [2]float64{p.Timestamp, p.Timestamp}
+		}
+		ranges[p.ParticipantID] = [2]float64{math.Min(r[0], p.Timestamp), math.Max(r[1], p.Timestamp)}
+	}
+
+	var segments []Segment
+	skipped, found := 0, 0
+	for _, id := range participants {
+		var own []types.Event
+		for _, e := range events {
+			if e.ParticipantID == id || e.ParticipantID == "" {
+				own = append(own, e)
+			}
+		}
+		sort.SliceStable(own, func(a, b int) bool { return own[a].Start < own[b].Start })
+
+		for k, e := range own {
+			if !eventMatches(e, window.FromEvent) {
+				continue
+			}
+			found++
+			end := e.End
+			if window.ToEvent != "" {
+				matched := false
+				for _, next := range own[k+1:] {
+					if eventMatches(next, window.ToEvent) {
+						end, matched = next.End, true
+						break
+					}
+					if eventMatches(next, window.FromEvent) {
+						break
+					}
+				}
+				if !matched {
+					skipped++
+					continue
+				}
+			}
+
+			r := ranges[id]
+			start := math.Max(e.Start-window.Pre, r[0])
+			end = math.Min(end+window.Post, r[1])
+			if end <= start {
+				skipped++
+				continue
+			}
+			segments = append(segments, Segment{Start: start, End: end, ParticipantID: id})
+		}
+	}
+	if found == 0 {
+		return nil, 0, fmt.Errorf("no %q events found", window.FromEvent)
+	}
+	if len(segments) == 0 {
+		return nil, skipped, fmt.Errorf("no %q event is followed by a %q event", window.FromEvent, window.ToEvent)
+	}
+	return segments, skipped, nil
+}
+
+// ParseSeconds reads a duration in seconds ("2.5") or with a unit ("5s", "500ms", "1m")
+func ParseSeconds(s string) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (use seconds, or a unit such as 5s or 500ms)", s)
+	}
+	return d.Seconds(), nil
+}
+
+// WindowSegments cuts each participant's recording into windows of the given length, starting every
+// step seconds from the participant's first timestamp; windows overlap when step is shorter than the
+// window. Only whole windows are returned, and windows without data (in gaps of the recording) are
+// skipped and counted.
+func WindowSegments(dataset *types.Dataset, window, step float64) ([]Segment, int, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, 0, fmt.Errorf("dataset is empty")
+	}
+	if window <= 0 || step <= 0 {
+		return nil, 0, fmt.Errorf("window and step must be positive")
+	}
+
+	var participants []string
+	timestamps := make(map[string][]float64)
+	for _, p := range dataset.Points {
+		if _, ok := timestamps[p.ParticipantID]; !ok {
+			participants = append(participants, p.ParticipantID)
+		}
+		timestamps[p.ParticipantID] = append(timestamps[p.ParticipantID], p.Timestamp)
+	}
+
+	var segments []Segment
+	skipped := 0
+	for _, id := range participants {
+		ts := timestamps[id]
+		sort.Float64s(ts)
+		first, last := ts[0], ts[len(ts)-1]
+		next := 0 // First timestamp at or after the window start
+		for k := 0; ; k++ {
+			// Offsets from the first timestamp keep long recordings from accumulating rounding errors
+			start := first + float64(k)*step
+			end := start + window
+			if end > last+1e-9 {
+				break
+			}
+			for next < len(ts) && ts[next] < start {
+				next++
+			}
+			if next == len(ts) || ts[next] > end {
+				skipped++
+				continue
+			}
+			segments = append(segments, Segment{Start: start, End: math.Min(end, last), ParticipantID: id})
+		}
+	}
+	if len(segments) == 0 {
+		return nil, skipped, fmt.Errorf("no recording is at least %gs long", window)
+	}
+	return segments, skipped, nil
+}
+
+// SegmentPath is the output file of segment n (from 1) of total, e.g. trials_seg03.csv
+func SegmentPath(outputPath string, n, total int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s_seg%0*d%s", strings.TrimSuffix(outputPath, ext), len(strconv.Itoa(total)), n, ext)
+}
+
 func FormatDuration(seconds float64) string {
 	if seconds < 60 {
 		return fmt.Sprintf("%.1fs", seconds)
@@ -0,0 +1,113 @@
+package clipper
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// OnsetMetadataKey holds each participant's onset time in a dataset trimmed by TrimToOnset
+const OnsetMetadataKey = "onsets"
+
+// Onset is where TrimToOnset found a participant's data to become stable
+type Onset struct {
+	ParticipantID string
+	Time          float64 // Timestamp of the first sample of the stable run
+	Dropped       int     // Samples before the onset
+}
+
+// TrimToOnset drops each participant's samples before the first run of at least samples consecutive
+// samples in which all of the columns have values (not missing or NaN), e.g. the first stable gaze
+// after the headset is put on. Participants whose data never becomes stable are dropped and returned
+// by ID; it is an error if none remain.
+func TrimToOnset(dataset *types.Dataset, columns []string, samples int) (*types.Dataset, []Onset, []string, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, nil, nil, fmt.Errorf("dataset is empty")
+	}
+	if len(columns) == 0 {
+		return nil, nil, nil, fmt.Errorf("no onset column given")
+	}
+	if samples < 1 {
+		return nil, nil, nil, fmt.Errorf("the stable run must be at least 1 sample")
+	}
+	for _, col := range columns {
+		if !contains(dataset.Columns, col) {
+			return nil, nil, nil, fmt.Errorf("column %q not found (available: %s)", col, strings.Join(dataset.Columns[1:], ", "))
+		}
+	}
+	valid := func(p types.DataPoint) bool {
+		for _, col := range columns {
+			if v, ok := p.Data[col]; !ok || math.IsNaN(v) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Each participant's point indices in time order, in order of appearance
+	var participants []string
+	indices := make(map[string][]int)
+	for i, p := range dataset.Points {
+		if _, ok := indices[p.ParticipantID]; !ok {
+			participants = append(participants, p.ParticipantID)
+		}
+		indices[p.ParticipantID] = append(indices[p.ParticipantID], i)
+	}
+
+	var onsets []Onset
+	var unstable []string
+	times := make(map[string]float64)
+	for _, id := range participants {
+		own := indices[id]
+		sort.SliceStable(own, func(a, b int) bool { return dataset.Points[own[a]].Timestamp < dataset.Points[own[b]].Timestamp })
+		run := 0
+		for k, i := range own {
+			if !valid(dataset.Points[i]) {
+				run = 0
+				continue
+			}
+			if run++; run == samples {
+				start := k - samples + 1
+				times[id] = dataset.Points[own[start]].Timestamp
+				onsets = append(onsets, Onset{ParticipantID: id, Time: times[id], Dropped: start})
+				break
+			}
+		}
+		if _, ok := times[id]; !ok {
+			unstable = append(unstable, id)
+		}
+	}
+	if len(onsets) == 0 {
+		return nil, nil, unstable, fmt.Errorf("no participant has %d consecutive samples with %s", samples, strings.Join(columns, ", "))
+	}
+
+	var points []types.DataPoint
+	for _, p := range dataset.Points {
+		if onset, ok := times[p.ParticipantID]; ok && p.Timestamp >= onset {
+			points = append(points, p)
+		}
+	}
+	// Events of dropped participants, and those over before the onset, are dropped with the samples
+	var events []types.Event
+	for _, e := range dataset.Events {
+		if onset, ok := times[e.ParticipantID]; e.ParticipantID == "" || (ok && e.End >= onset) {
+			events = append(events, e)
+		}
+	}
+
+	metadata := make(map[string]interface{}, len(dataset.Metadata)+2)
+	for key, value := range dataset.Metadata {
+		metadata[key] = value
+	}
+	metadata["onset_columns"] = columns
+	metadata[OnsetMetadataKey] = times
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   events,
+	}, onsets, unstable, nil
+}
@@ -0,0 +1,165 @@
+package clipper
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// TrialConfig cuts a dataset into trials, either at changes of a marker column or at each occurrence
+// of an event
+type TrialConfig struct {
+	MarkerColumn string        // A trial starts wherever this column changes value
+	KeepZero     bool          // Keep runs of marker value 0 as trials (by default they are the gaps between trials)
+	Event        string        // Or: a trial starts at each of these events and lasts until the next one
+	Events       []types.Event // Events searched for Event (nil = the dataset's)
+	Rebase       bool          // Shift each trial to start at t=0 (see Rebase)
+}
+
+// Trial describes one trial cut by SplitTrials
+type Trial struct {
+	Number           int // From 1, over the whole dataset
+	ParticipantTrial int // From 1, per participant
+	ParticipantID    string
+	Marker           string // Marker value or event name that started the trial
+	Start, End       float64
+	Points           int
+}
+
+// SplitTrials cuts each participant's data into trials and returns one dataset per trial, with the trial
+// numbers and marker in its metadata. In marker mode, samples without a marker value continue the current
+// trial. In event mode, samples before a participant's first event belong to no trial and the last trial
+// runs to the end of the recording. Events without a participant apply to everyone.
+func SplitTrials(dataset *types.Dataset, config TrialConfig) ([]*types.Dataset, []Trial, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, nil, fmt.Errorf("dataset is empty")
+	}
+	if (config.MarkerColumn == "") == (config.Event == "") {
+		return nil, nil, fmt.Errorf("split trials by either a marker column or an event")
+	}
+	if config.MarkerColumn != "" && !contains(dataset.Columns, config.MarkerColumn) {
+		return nil, nil, fmt.Errorf("column %q not found", config.MarkerColumn)
+	}
+	events := config.Events
+	if events == nil {
+		events = dataset.Events
+	}
+
+	// Each participant's points, in order of appearance
+	var participants []string
+	byParticipant := make(map[string][]types.DataPoint)
+	for _, p := range dataset.Points {
+		if _, ok := byParticipant[p.ParticipantID]; !ok {
+			participants = append(participants, p.ParticipantID)
+		}
+		byParticipant[p.ParticipantID] = append(byParticipant[p.ParticipantID], p)
+	}
+
+	var datasets []*types.Dataset
+	var trials []Trial
+	add := func(id, marker string, points []types.DataPoint, participantTrial int) {
+		t := Trial{Number: len(trials) + 1, ParticipantTrial: participantTrial, ParticipantID: id, Marker: marker,
+			Start: points[0].Timestamp, End: points[len(points)-1].Timestamp, Points: len(points)}
+		var own []types.Event
+		for _, e := range dataset.Events {
+			if (e.ParticipantID == id || e.ParticipantID == "") && e.End >= t.Start && e.Start <= t.End {
+				own = append(own, e)
+			}
+		}
+		trial := &types.Dataset{
+			Points:  points,
+			Columns: dataset.Columns,
+			Metadata: map[string]interface{}{
+				"trial":             t.Number,
+				"participant_trial": t.ParticipantTrial,
+				"participant_id":    id,
+				"trial_marker":      marker,
+				"start_time":        t.Start,
+				"end_time":          t.End,
+				"clipped_points":    t.Points,
+			},
+			Events: own,
+		}
+		if config.Rebase {
+			trial = Rebase(trial)
+		}
+		datasets = append(datasets, trial)
+		trials = append(trials, t)
+	}
+
+	for _, id := range participants {
+		points := byParticipant[id]
+		n := 0
+		if config.MarkerColumn != "" {
+			start, current, started := 0, 0.0, false
+			flush := func(end int) {
+				if started && end > start && (current != 0 || config.KeepZero) {
+					n++
+					add(id, strconv.FormatFloat(current, 'g', -1, 64), points[start:end], n)
+				}
+			}
+			for i, p := range points {
+				v, ok := p.Data[config.MarkerColumn]
+				if !ok || math.IsNaN(v) || (started && v == current) {
+					continue
+				}
+				flush(i)
+				start, current, started = i, v, true
+			}
+			flush(len(points))
+			continue
+		}
+
+		var onsets []float64
+		for _, e := range events {
+			if (e.ParticipantID == id || e.ParticipantID == "") && eventMatches(e, config.Event) {
+				onsets = append(onsets, e.Start)
+			}
+		}
+		sort.Float64s(onsets)
+		for k, onset := range onsets {
+			end := math.Inf(1)
+			if k+1 < len(onsets) {
+				end = onsets[k+1]
+			}
+			var trial []types.DataPoint
+			for _, p := range points {
+				if p.Timestamp >= onset && p.Timestamp < end {
+					trial = append(trial, p)
+				}
+			}
+			if len(trial) > 0 {
+				n++
+				add(id, config.Event, trial, n)
+			}
+		}
+	}
+
+	if len(trials) == 0 {
+		if config.Event != "" {
+			return nil, nil, fmt.Errorf("no %q events found within the data", config.Event)
+		}
+		return nil, nil, fmt.Errorf("column %s has no non-zero marker values", config.MarkerColumn)
+	}
+	return datasets, trials, nil
+}
+
+// TrialPath is the output file of trial n (from 1) of total, e.g. session_trial03.csv
+func TrialPath(outputPath string, n, total int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s_trial%0*d%s", strings.TrimSuffix(outputPath, ext), len(strconv.Itoa(total)), n, ext)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+// Package diag collects the warnings raised while loading, cleaning and analyzing a dataset, so they
+// can be reported together as one section instead of scattered through the progress output.
+package diag
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Kind classifies a diagnostic
+type Kind string
+
+const (
+	Units         Kind = "units"          // Timestamps or values that look like the wrong unit
+	Missing       Kind = "missing"        // A column with a high share of missing values
+	SkippedColumn Kind = "skipped_column" // A requested column left out of an analysis
+	Timestamps    Kind = "timestamps"     // Clock problems left in the data
+	Coverage      Kind = "coverage"       // Participants or conditions unevenly covered by pooled inputs
+)
+
+// Diagnostic is one warning about the data
+type Diagnostic struct {
+	Stage   string `json:"stage"` // "load", "clean" or "stats"
+	Kind    Kind   `json:"kind"`
+	Source  string `json:"source,omitempty"` // Input file or pattern
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	label := d.Stage + " " + string(d.Kind)
+	if d.Source != "" {
+		label += " " + d.Source
+	}
+	return "[" + label + "] " + d.Message
+}
+
+// MetadataKey stores a dataset's diagnostics in its metadata, so warnings raised by earlier commands
+// travel with datasets saved as .mbd or .db
+const MetadataKey = "diagnostics"
+
+// HighMissing is the share of missing values above which a column gets a Missing warning
+const HighMissing = 0.5
+
+// Collector accumulates diagnostics in the order they are raised, dropping repeats. A nil Collector
+// discards everything, so stages can be run without one.
+type Collector struct {
+	items []Diagnostic
+	seen  map[Diagnostic]bool
+}
+
+func (c *Collector) Add(d Diagnostic) {
+	if c == nil || c.seen[d] {
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[Diagnostic]bool)
+	}
+	c.seen[d] = true
+	c.items = append(c.items, d)
+}
+
+// Merge adds each of the diagnostics
+func (c *Collector) Merge(diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		c.Add(d)
+	}
+}
+
+// Warnf adds a diagnostic with a formatted message
+func (c *Collector) Warnf(stage string, kind Kind, column, format string, args ...interface{}) {
+	c.Add(Diagnostic{Stage: stage, Kind: kind, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// Diagnostics returns the collected diagnostics in the order they were raised
+func (c *Collector) Diagnostics() []Diagnostic {
+	if c == nil {
+		return nil
+	}
+	return append([]Diagnostic(nil), c.items...)
+}
+
+func (c *Collector) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.items)
+}
+
+// CheckMissing adds a Missing warning for each column whose share of missing values (absent or NaN)
+// is above HighMissing
+func (c *Collector) CheckMissing(stage, source string, points []types.DataPoint, columns []string) {
+	if c == nil || len(points) == 0 {
+		return
+	}
+	for _, col := range columns {
+		missing := 0
+		for _, p := range points {
+			if v, ok := p.Data[col]; !ok || math.IsNaN(v) {
+				missing++
+			}
+		}
+		if share := float64(missing) / float64(len(points)); share > HighMissing {
+			c.Add(Diagnostic{Stage: stage, Kind: Missing, Source: source, Column: col,
+				Message: fmt.Sprintf("column %s is missing in %.1f%% of %d points", col, share*100, len(points))})
+		}
+	}
+}
+
+// FromMetadata returns the diagnostics stored under MetadataKey, as recorded in memory or decoded
+// from a saved dataset's JSON metadata
+func FromMetadata(metadata map[string]interface{}) []Diagnostic {
+	switch stored := metadata[MetadataKey].(type) {
+	case []Diagnostic:
+		return stored
+	case []interface{}:
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return nil
+		}
+		var diagnostics []Diagnostic
+		if json.Unmarshal(data, &diagnostics) != nil {
+			return nil
+		}
+		return diagnostics
+	}
+	return nil
+}
+
+// Format renders diagnostics as a report section, or "" when there are none
+func Format(diagnostics []Diagnostic) string {
+	if len(diagnostics) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Diagnostics (%d):\n", len(diagnostics)))
+	for _, d := range diagnostics {
+		sb.WriteString("  " + d.String() + "\n")
+	}
+	return sb.String()
+}
+
+// Save writes diagnostics as JSON (.json) or CSV
+func Save(diagnostics []Diagnostic, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create diagnostics file: %v", err)
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		if diagnostics == nil {
+			diagnostics = []Diagnostic{}
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diagnostics); err != nil {
+			return fmt.Errorf("failed to write diagnostics: %v", err)
+		}
+		return f.Close()
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"stage", "kind", "source", "column", "message"})
+	for _, d := range diagnostics {
+		w.Write([]string{d.Stage, string(d.Kind), d.Source, d.Column, d.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write diagnostics: %v", err)
+	}
+	return f.Close()
+}
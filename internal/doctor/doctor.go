@@ -0,0 +1,479 @@
+// Package doctor examines a problem file with the structural, unit, timestamp and quality checks of
+// the other commands, and explains each problem found together with the options that fix it.
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/diag"
+	"mbdvr/internal/loader"
+	"mbdvr/internal/roles"
+	"mbdvr/internal/types"
+)
+
+// Severity ranks findings: problems keep a file from loading or analyzing correctly, warnings bias
+// results unless handled, and notes are worth knowing
+type Severity int
+
+const (
+	Note Severity = iota
+	Warning
+	Problem
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Problem:
+		return "problem"
+	case Warning:
+		return "warning"
+	}
+	return "note"
+}
+
+// Finding is one issue with the file and how to fix it
+type Finding struct {
+	Severity Severity
+	Check    string // "structure", "units", "timestamps", "quality" or "columns"
+	Column   string
+	Message  string
+	Fix      string  // Suggested options or command ("" = nothing to do)
+	Share    float64 // Share of the samples affected, ranking findings of the same severity
+}
+
+// Report is the examination of one file
+type Report struct {
+	File         string
+	Points       int
+	Participants int
+	Columns      []string // Data columns, without the timestamp
+	SampleRate   float64  // Median samples per second (0 = unknown)
+	LoadOptions  []string // Options the file needed to load, to pass to every command
+	Findings     []Finding
+}
+
+// Problems returns the number of findings with Problem severity
+func (r *Report) Problems() int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == Problem {
+			n++
+		}
+	}
+	return n
+}
+
+const (
+	maxRecoveries = 20   // Text columns left out before giving up on loading
+	maxInversion  = 0.5  // Largest backwards step repaired by clean --fix-timestamps, in seconds
+	gapFactor     = 5    // Intervals this many times the median are gaps
+	irregularCV   = 0.25 // Coefficient of variation of the sample intervals above which sampling is irregular
+	spreadMADs    = 10   // Values this many scaled MADs from the median are extreme
+)
+
+// sentinelCodes are values trackers commonly write for invalid samples
+var sentinelCodes = []float64{-1, -999, -9999, 999, 9999, 99999, -99999}
+
+// Examine loads file and runs every check, ranking the findings by severity and then by the share of
+// the data they affect. overrides are column role overrides as for roles.Infer. Text columns that
+// stop the file from loading are left out, so the other checks can still run.
+func Examine(file string, overrides map[string]string) *Report {
+	r := &Report{File: file}
+	collector := &diag.Collector{}
+	l := &loader.Loader{Diagnostics: collector}
+
+	var dataset *types.Dataset
+	var skipped []string
+	for attempt := 0; ; attempt++ {
+		var err error
+		if dataset, err = l.LoadFiles(file); err == nil {
+			break
+		}
+		var value *loader.ValueError
+		if !errors.As(err, &value) || attempt == maxRecoveries {
+			r.add(Problem, "structure", "", 1, fmt.Sprintf("the file can't be loaded: %v", err), loadFix(err))
+			return r.rank()
+		}
+		skipped = append(skipped, value.Column)
+		var keep []string
+		for _, col := range value.Columns {
+			if col != value.Column {
+				keep = append(keep, col)
+			}
+		}
+		l.SelectColumns = keep
+	}
+	for _, col := range skipped {
+		r.add(Problem, "structure", col, 1,
+			fmt.Sprintf("column %s has text values; mbdvr only reads numbers, so the file doesn't load with it", col),
+			"leave it out with --select-columns (below), or recode it as numbers, e.g. condition labels in a condition column")
+	}
+	if len(skipped) > 0 {
+		r.LoadOptions = append(r.LoadOptions, "--select-columns '"+strings.Join(l.SelectColumns, ",")+"'")
+	}
+
+	r.Points = len(dataset.Points)
+	r.Columns = dataset.Columns[1:]
+	r.checkStructure(dataset)
+	for _, d := range collector.Diagnostics() {
+		r.addDiagnostic(d)
+	}
+	r.checkTimestamps(dataset)
+	r.checkColumns(dataset, overrides)
+	return r.rank()
+}
+
+func (r *Report) add(severity Severity, check, column string, share float64, message, fix string) {
+	r.Findings = append(r.Findings, Finding{Severity: severity, Check: check, Column: column, Message: message, Fix: fix, Share: share})
+}
+
+func (r *Report) rank() *Report {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		return a.Share > b.Share
+	})
+	return r
+}
+
+// loadFix suggests how to get a file that fails to load past the loader
+func loadFix(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no files found"):
+		return "check the path; quote patterns with wildcards so the shell doesn't expand them"
+	case strings.Contains(msg, "incorrect number of columns"):
+		return "rows must all have as many fields as the header: look for a truncated last row after a crash, unquoted delimiters inside values or notes appended to the file"
+	case strings.Contains(msg, "invalid timestamp"):
+		return "the first column must be the timestamp in seconds; reorder the columns, or convert clock times such as 12:03:04.5 to seconds"
+	case strings.Contains(msg, "no data rows found"):
+		return "pass the number of metadata rows before the header with --skip-rows"
+	case strings.Contains(msg, "insufficient columns"):
+		return "the delimiter wasn't recognized; mbdvr reads comma, tab, semicolon and pipe-separated text, JSON lines, .mbd, SQLite and EyeLink ASC"
+	case strings.Contains(msg, "insufficient data"):
+		return "the file has a header but no data rows"
+	}
+	return ""
+}
+
+// checkStructure looks for header and file naming mistakes that load without an error
+func (r *Report) checkStructure(dataset *types.Dataset) {
+	numeric := 0
+	for _, col := range dataset.Columns {
+		if _, err := strconv.ParseFloat(col, 64); err == nil {
+			numeric++
+		}
+	}
+	headerless := numeric > len(dataset.Columns)/2
+	if headerless {
+		r.add(Problem, "structure", "", 1, "the first row is numbers, so the file has no header row and its first sample was read as column names",
+			"load with --no-header (columns col_0, col_1, ...) or name them with --columns 'timestamp,gaze_x,...'")
+	}
+
+	first := strings.ToLower(dataset.Columns[0])
+	if !headerless && !strings.Contains(first, "time") && first != "t" && first != "ts" {
+		r.add(Note, "structure", dataset.Columns[0], 0,
+			fmt.Sprintf("the first column, %s, is used as the timestamp in seconds", dataset.Columns[0]),
+			"if it isn't the timestamp, move the timestamp column first")
+	}
+
+	participants := make(map[string]bool)
+	for _, p := range dataset.Points {
+		participants[p.ParticipantID] = true
+	}
+	r.Participants = len(participants)
+	prefix := strings.SplitN(filepath.Base(r.File), "_", 2)[0]
+	if len(participants) == 1 && participants[prefix] {
+		r.add(Note, "structure", "", 0,
+			fmt.Sprintf("every sample belongs to participant %q, the start of the file name; without a participant_id column the ID is the text before the first _", prefix),
+			"name files like P01_task.csv, or add a participant_id column")
+	}
+}
+
+// addDiagnostic turns a load diagnostic into a finding with a fix
+func (r *Report) addDiagnostic(d diag.Diagnostic) {
+	switch d.Kind {
+	case diag.Units:
+		if d.Column == "" {
+			r.add(Problem, "units", "", 1, d.Message,
+				"convert the timestamps to seconds (divide by 1000 for milliseconds) before loading; every time option of mbdvr is in seconds")
+			return
+		}
+		r.add(Warning, "units", d.Column, 1, d.Message,
+			fmt.Sprintf("mbdvr transform --expr '%s = %s / 1000' converts micrometres to millimetres", d.Column, d.Column))
+	case diag.Missing:
+		r.add(Warning, "quality", d.Column, 0.5, d.Message,
+			fmt.Sprintf("clean --max-missing drops whole rows for it; fill it with clean --impute '%s:ffill' or leave it out with --select-columns", d.Column))
+	default:
+		r.add(Warning, string(d.Kind), d.Column, 0, d.Message, "")
+	}
+}
+
+// checkTimestamps checks each participant's timestamps, in recording order, for repeats, small and
+// large backwards steps, gaps and irregular sampling
+func (r *Report) checkTimestamps(dataset *types.Dataset) {
+	var participants []string
+	byParticipant := make(map[string][]float64)
+	for _, p := range dataset.Points {
+		if _, ok := byParticipant[p.ParticipantID]; !ok {
+			participants = append(participants, p.ParticipantID)
+		}
+		byParticipant[p.ParticipantID] = append(byParticipant[p.ParticipantID], p.Timestamp)
+	}
+
+	total := float64(len(dataset.Points))
+	var repeated, inverted, jumps, gaps int
+	var gapTime, largestJump float64
+	var jumpers, irregular []string
+	var rates []float64
+	for _, id := range participants {
+		ts := byParticipant[id]
+		var steps []float64
+		own := 0
+		for i := 1; i < len(ts); i++ {
+			switch d := ts[i] - ts[i-1]; {
+			case d == 0:
+				repeated++
+			case d < -maxInversion:
+				jumps++
+				own++
+				largestJump = math.Max(largestJump, -d)
+			case d < 0:
+				inverted++
+			default:
+				steps = append(steps, d)
+			}
+		}
+		if own > 0 {
+			jumpers = append(jumpers, id)
+		}
+		if len(steps) < 2 {
+			continue
+		}
+		sorted := append([]float64(nil), steps...)
+		sort.Float64s(sorted)
+		interval := sorted[len(sorted)/2]
+		rates = append(rates, 1/interval)
+
+		var regular []float64
+		for _, d := range steps {
+			if d > gapFactor*interval {
+				gaps++
+				gapTime += d
+			} else {
+				regular = append(regular, d)
+			}
+		}
+		if mean, sd := meanSD(regular); mean > 0 && sd/mean > irregularCV {
+			irregular = append(irregular, id)
+		}
+	}
+	if len(rates) > 0 {
+		sort.Float64s(rates)
+		r.SampleRate = rates[len(rates)/2]
+	}
+
+	if jumps > 0 {
+		r.add(Problem, "timestamps", "", float64(jumps)/total,
+			fmt.Sprintf("%d backwards clock jumps of more than %gs (largest %.3fs) in %s; the timestamps reset or the file is several recordings appended", jumps, maxInversion, largestJump, strings.Join(jumpers, ", ")),
+			"split the recording at the jumps with clip --start/--end (or --segments) and shift the later part, or map the clock with clean --drift-anchors")
+	}
+	if repeated+inverted > 0 {
+		r.add(Warning, "timestamps", "", float64(repeated+inverted)/total,
+			fmt.Sprintf("%d samples repeat the previous timestamp and %d step back by up to %gs, which breaks velocities and resampling", repeated, inverted, maxInversion),
+			"clean --fix-timestamps re-times them; exact duplicate rows are dropped with load --dedupe or clean --duplicates exact")
+	}
+	if gaps > 0 {
+		r.add(Warning, "timestamps", "", gapTime/math.Max(duration(byParticipant), 1e-9),
+			fmt.Sprintf("%d gaps longer than %d sample intervals, %.1fs of recording in total (tracking loss or paused logging)", gaps, gapFactor, gapTime),
+			"clean --interpolate --max-gap 0.1 fills short gaps; resample --rate leaves longer ones missing")
+	}
+	if len(irregular) > 0 && r.SampleRate > 0 {
+		r.add(Warning, "timestamps", "", float64(len(irregular))/float64(len(participants)),
+			fmt.Sprintf("sampling is irregular for %s (sample intervals vary by more than %.0f%%), so sample-based windows and filters cover different times", strings.Join(irregular, ", "), irregularCV*100),
+			fmt.Sprintf("resample --rate %g puts the data on a regular grid", math.Round(r.SampleRate)))
+	}
+}
+
+// duration is the total recording time over participants
+func duration(byParticipant map[string][]float64) float64 {
+	total := 0.0
+	for _, ts := range byParticipant {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, t := range ts {
+			lo, hi = math.Min(lo, t), math.Max(hi, t)
+		}
+		total += hi - lo
+	}
+	return total
+}
+
+// checkColumns checks the values of each data column, and whether the column roles commands rely on
+// were recognized
+func (r *Report) checkColumns(dataset *types.Dataset, overrides map[string]string) {
+	inferred, err := roles.Infer(dataset, overrides)
+	if err != nil {
+		r.add(Problem, "columns", "", 0, err.Error(), "fix the column_roles of the study manifest or the --roles overrides")
+		return
+	}
+	roleOf := make(map[string]roles.Role)
+	for _, c := range inferred.Columns {
+		roleOf[c.Name] = c.Role
+	}
+
+	total := float64(len(dataset.Points))
+	var constant []string
+	for _, col := range dataset.Columns[1:] {
+		var values []float64
+		for _, p := range dataset.Points {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		if sorted[0] == sorted[len(sorted)-1] {
+			constant = append(constant, fmt.Sprintf("%s (%g)", col, sorted[0]))
+			continue
+		}
+
+		if roleOf[col] == roles.Pupil {
+			if n := countBelow(sorted, 0); n > 0 {
+				r.add(Warning, "quality", col, float64(n)/total,
+					fmt.Sprintf("%d samples of %s are zero or negative, which trackers write during blinks", n, col),
+					fmt.Sprintf("clean --blinks interpolate --blink-pupil %s", col))
+				values = dropBelow(values, 0)
+			}
+		} else {
+			for _, code := range sentinelCodes {
+				n := countValue(sorted, code)
+				if n == 0 || float64(n)/float64(len(values)) < 0.005 {
+					continue
+				}
+				if rest := dropValue(sorted, code); len(rest) > 0 && (code < rest[0] || code > rest[len(rest)-1] || extremeTest(rest)(code)) {
+					r.add(Warning, "quality", col, float64(n)/total,
+						fmt.Sprintf("%d samples of %s are %g, away from its other values; this looks like a tracker code for invalid samples", n, col, code),
+						fmt.Sprintf("clean --sentinels '%s:%g' marks them missing", col, code))
+					values = dropValue(values, code)
+				}
+			}
+		}
+
+		if roleOf[col] == roles.Confidence && sorted[0] < sorted[len(sorted)-1] {
+			r.add(Note, "quality", col, 0, fmt.Sprintf("column %s looks like tracking confidence (%g to %g)", col, sorted[0], sorted[len(sorted)-1]),
+				fmt.Sprintf("clean --confidence-column %s --min-confidence 0.8 removes poorly tracked samples", col))
+		}
+
+		extreme := 0
+		isExtreme := extremeTest(values)
+		for _, v := range values {
+			if isExtreme(v) {
+				extreme++
+			}
+		}
+		if extreme > 0 {
+			r.add(Warning, "quality", col, float64(extreme)/total,
+				fmt.Sprintf("%d values of %s are more than %d MADs from its median", extreme, col, spreadMADs),
+				"clean --hampel replaces isolated spikes; clean --remove-outliers --outlier-action nan marks outliers missing")
+		}
+	}
+
+	if len(constant) > 0 {
+		r.add(Note, "quality", "", 0, fmt.Sprintf("%d columns never change: %s", len(constant), strings.Join(constant, ", ")),
+			"leave them out with --select-columns if they carry no information")
+	}
+
+	if _, _, ok := inferred.GazePoint(); !ok && len(inferred.Find(roles.GazeDirection)) == 0 {
+		r.add(Note, "columns", "", 0, "no gaze columns were recognized by name, so classify, microsaccades and calibration need their columns as flags",
+			"name the roles once for the study in mbdvr.json (column_roles), e.g. {\"PORX\": \"gaze_x\", \"PORY\": \"gaze_y\"}, or pass --roles")
+	}
+}
+
+// extremeTest returns a test for values more than spreadMADs scaled median absolute deviations from
+// the median of values
+func extremeTest(values []float64) func(float64) bool {
+	med := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+	}
+	mad := median(deviations) * 1.4826
+	return func(v float64) bool { return mad > 0 && math.Abs(v-med) > spreadMADs*mad }
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func meanSD(values []float64) (float64, float64) {
+	if len(values) < 2 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	ss := 0.0
+	for _, v := range values {
+		ss += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(values)-1))
+}
+
+func countValue(values []float64, v float64) int {
+	n := 0
+	for _, x := range values {
+		if x == v {
+			n++
+		}
+	}
+	return n
+}
+
+func countBelow(values []float64, limit float64) int {
+	n := 0
+	for _, x := range values {
+		if x <= limit {
+			n++
+		}
+	}
+	return n
+}
+
+func dropValue(values []float64, v float64) []float64 {
+	var kept []float64
+	for _, x := range values {
+		if x != v {
+			kept = append(kept, x)
+		}
+	}
+	return kept
+}
+
+func dropBelow(values []float64, limit float64) []float64 {
+	var kept []float64
+	for _, x := range values {
+		if x > limit {
+			kept = append(kept, x)
+		}
+	}
+	return kept
+}
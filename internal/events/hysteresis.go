@@ -0,0 +1,105 @@
+// Package events detects events in sampled signals, as shared building blocks for the blink,
+// eye-movement and threshold detectors.
+package events
+
+import (
+	"fmt"
+	"math"
+)
+
+// Hysteresis detects events with two thresholds, so a noisy signal hovering around a single
+// threshold doesn't produce a burst of short events: an event starts when the signal reaches
+// Enter and only ends once it passes back beyond Exit.
+type Hysteresis struct {
+	Enter       float64 // Event starts when the signal is at or above this (at or below with Below)
+	Exit        float64 // Event ends when the signal drops below this (rises above with Below)
+	Below       bool    // Detect dips instead of peaks; then Exit >= Enter
+	MinDuration float64 // Seconds; shorter events are discarded
+	MaxDuration float64 // Seconds; longer events are discarded (0 = no limit)
+
+	// MissingActive treats NaN samples as part of an event (e.g. pupil dropouts during a blink).
+	// Otherwise NaN samples keep the current state: they neither start nor end an event.
+	MissingActive bool
+}
+
+// Interval is a detected event. Indices refer to the detector input.
+type Interval struct {
+	Start     int     // First sample of the event
+	End       int     // First sample after the event (len(values) if it is still active at the end)
+	StartTime float64 // Time of the first sample
+	EndTime   float64 // Time of the first sample after the event, or of the last sample if Open
+	Open      bool    // The signal was still active at the end of the input
+}
+
+// Duration is the event length in seconds
+func (iv Interval) Duration() float64 {
+	return iv.EndTime - iv.StartTime
+}
+
+// Validate checks that the thresholds are ordered for the detection direction
+func (h Hysteresis) Validate() error {
+	if math.IsNaN(h.Enter) || math.IsNaN(h.Exit) {
+		return fmt.Errorf("hysteresis thresholds must be numbers")
+	}
+	if !h.Below && h.Exit > h.Enter {
+		return fmt.Errorf("exit threshold %g is above enter threshold %g", h.Exit, h.Enter)
+	}
+	if h.Below && h.Exit < h.Enter {
+		return fmt.Errorf("exit threshold %g is below enter threshold %g", h.Exit, h.Enter)
+	}
+	if h.MinDuration < 0 || h.MaxDuration < 0 || (h.MaxDuration > 0 && h.MaxDuration < h.MinDuration) {
+		return fmt.Errorf("invalid event duration range")
+	}
+	return nil
+}
+
+// Detect finds the events in a signal sampled at the given (increasing) times
+func (h Hysteresis) Detect(times, values []float64) []Interval {
+	var intervals []Interval
+	start := -1
+	for i := 0; i <= len(values); i++ {
+		if start < 0 {
+			if i < len(values) && h.enters(values[i]) {
+				start = i
+			}
+			continue
+		}
+		if i < len(values) && !h.exits(values[i]) {
+			continue
+		}
+
+		iv := Interval{Start: start, End: i, StartTime: times[start]}
+		if i < len(values) {
+			iv.EndTime = times[i]
+		} else {
+			iv.EndTime = times[len(values)-1]
+			iv.Open = true
+		}
+		if d := iv.Duration(); d >= h.MinDuration && (h.MaxDuration <= 0 || d <= h.MaxDuration) {
+			intervals = append(intervals, iv)
+		}
+		start = -1
+	}
+	return intervals
+}
+
+func (h Hysteresis) enters(v float64) bool {
+	if math.IsNaN(v) {
+		return h.MissingActive
+	}
+	if h.Below {
+		return v <= h.Enter
+	}
+	return v >= h.Enter
+}
+
+func (h Hysteresis) exits(v float64) bool {
+	if math.IsNaN(v) {
+		// Missing samples are either active or keep the current state; neither ends an event
+		return false
+	}
+	if h.Below {
+		return v > h.Exit
+	}
+	return v < h.Exit
+}
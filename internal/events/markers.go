@@ -0,0 +1,108 @@
+package events
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// LoadEvents reads events from CSV, such as the files SaveEvents writes or a stimulus log. The time
+// is read from a start, timestamp, onset or time column (seconds), the name from type, event, name or
+// label, and the optional end, message and participant_id columns. Events without a participant apply
+// to every participant.
+func LoadEvents(filename string) ([]types.Event, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of %s: %v", filename, err)
+	}
+	find := func(names ...string) int {
+		for _, name := range names {
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), name) {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	startIdx := find("start", "timestamp", "onset", "time")
+	typeIdx := find("type", "event", "name", "label")
+	endIdx := find("end")
+	messageIdx := find("message")
+	participantIdx := find("participant_id")
+	if startIdx < 0 {
+		return nil, fmt.Errorf("%s has no start, timestamp, onset or time column", filename)
+	}
+	if typeIdx < 0 && messageIdx < 0 {
+		return nil, fmt.Errorf("%s has no type, event, name, label or message column", filename)
+	}
+
+	field := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	var events []types.Event
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", filename, err)
+		}
+		start, err := strconv.ParseFloat(field(row, startIdx), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event time on line %d of %s", line, filename)
+		}
+		e := types.Event{Type: field(row, typeIdx), Start: start, End: start, Message: field(row, messageIdx),
+			ParticipantID: field(row, participantIdx)}
+		if s := field(row, endIdx); s != "" {
+			if e.End, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid event end on line %d of %s", line, filename)
+			}
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// MarkerEvents turns a marker column, such as a trigger channel, into events: each participant gets an
+// instantaneous event wherever the column changes to a non-zero value, with the value as its type
+// ("1", "12.5", ...)
+func MarkerEvents(dataset *types.Dataset, column string) ([]types.Event, error) {
+	if !contains(dataset.Columns, column) {
+		return nil, fmt.Errorf("column %q not found", column)
+	}
+	var events []types.Event
+	for _, idx := range types.RecordingIndices(dataset.Points) {
+		previous := 0.0
+		for _, i := range idx {
+			p := dataset.Points[i]
+			v, ok := p.Data[column]
+			if !ok || math.IsNaN(v) {
+				continue
+			}
+			if v != 0 && v != previous {
+				events = append(events, types.Event{Type: strconv.FormatFloat(v, 'g', -1, 64), Start: p.Timestamp,
+					End: p.Timestamp, ParticipantID: p.ParticipantID})
+			}
+			previous = v
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"mbdvr/internal/types"
+)
+
+// ThresholdConfig detects events where a column crosses hysteresis thresholds
+type ThresholdConfig struct {
+	Column      string
+	Type        string // Event type stored on each event (default: "THRESHOLD")
+	LabelColumn string // Optional column set to 1 during events and 0 otherwise
+	Hysteresis
+}
+
+// DetectThreshold adds an event per threshold crossing of each participant's recording to a copy of
+// the dataset. Each event records the peak (or trough, with Below) value of the column in its Data.
+func DetectThreshold(dataset *types.Dataset, config ThresholdConfig) (*types.Dataset, []types.Event, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, nil, fmt.Errorf("dataset is empty")
+	}
+	if !contains(dataset.Columns, config.Column) {
+		return nil, nil, fmt.Errorf("column %q not found", config.Column)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if config.Type == "" {
+		config.Type = "THRESHOLD"
+	}
+
+	points := dataset.Points
+	label := make([]bool, len(points))
+	var detected []types.Event
+	for _, idx := range types.RecordingIndices(points) {
+		times := make([]float64, len(idx))
+		values := make([]float64, len(idx))
+		for k, i := range idx {
+			times[k] = points[i].Timestamp
+			values[k] = math.NaN()
+			if v, ok := points[i].Data[config.Column]; ok {
+				values[k] = v
+			}
+		}
+
+		for _, iv := range config.Detect(times, values) {
+			peak := math.NaN()
+			for k := iv.Start; k < iv.End; k++ {
+				label[idx[k]] = true
+				v := values[k]
+				if math.IsNaN(v) {
+					continue
+				}
+				if math.IsNaN(peak) || (config.Below && v < peak) || (!config.Below && v > peak) {
+					peak = v
+				}
+			}
+			detected = append(detected, types.Event{
+				Type:          config.Type,
+				Start:         iv.StartTime,
+				End:           iv.EndTime,
+				Message:       config.Column,
+				Data:          map[string]float64{"peak": peak, "duration": iv.Duration()},
+				ParticipantID: points[idx[iv.Start]].ParticipantID,
+			})
+		}
+	}
+
+	result := &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: make(map[string]interface{}, len(dataset.Metadata)+1),
+		Events:   append(append([]types.Event{}, dataset.Events...), detected...),
+	}
+	for key, value := range dataset.Metadata {
+		result.Metadata[key] = value
+	}
+	result.Metadata["threshold_events"] = len(detected)
+
+	if config.LabelColumn != "" {
+		result.Points = make([]types.DataPoint, len(points))
+		for i, p := range points {
+			data := types.CloneData(p.Data, 1)
+			data[config.LabelColumn] = 0
+			if label[i] {
+				data[config.LabelColumn] = 1
+			}
+			result.Points[i] = p
+			result.Points[i].Data = data
+		}
+		result.Columns = append([]string{}, dataset.Columns...)
+		if !contains(result.Columns, config.LabelColumn) {
+			result.Columns = append(result.Columns, config.LabelColumn)
+		}
+	}
+
+	return result, detected, nil
+}
+
+// SaveEvents writes events as CSV, one row per event
+func SaveEvents(events []types.Event, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"type", "participant_id", "start", "end", "duration", "peak", "message"})
+	for _, e := range events {
+		w.Write([]string{e.Type, e.ParticipantID,
+			strconv.FormatFloat(e.Start, 'f', 6, 64), strconv.FormatFloat(e.End, 'f', 6, 64),
+			strconv.FormatFloat(e.End-e.Start, 'f', 6, 64), strconv.FormatFloat(e.Data["peak"], 'f', 6, 64), e.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+// Package export packages analysis outputs into deliverables for collaborators.
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mbdvr/internal/events"
+	"mbdvr/internal/loader"
+	"mbdvr/internal/stats"
+	"mbdvr/internal/types"
+	"mbdvr/internal/version"
+)
+
+// BundleConfig controls the per-condition export bundles
+type BundleConfig struct {
+	OutputDir string            // Directory the <condition>.zip bundles are written to
+	Stats     stats.StatsConfig // Statistics computed for each condition's stats.txt
+	Include   []string          // Extra files copied into every bundle, e.g. a cleaning report or figures
+}
+
+// unlabeledCondition names the bundle for points without a condition
+const unlabeledCondition = "unlabeled"
+
+// WriteBundles writes one zip per condition containing the condition's data (data.csv), its events
+// as an epoch table (events.csv, when the dataset has events), its statistics (stats.txt), the
+// Include files and a manifest.txt. It returns the bundle paths in condition order.
+func WriteBundles(dataset *types.Dataset, config BundleConfig) ([]string, error) {
+	if len(dataset.Points) == 0 {
+		return nil, fmt.Errorf("no data points to export")
+	}
+	for _, path := range config.Include {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("include file: %v", err)
+		}
+	}
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", config.OutputDir, err)
+	}
+
+	byCondition := make(map[string][]types.DataPoint)
+	for _, p := range dataset.Points {
+		condition := p.Condition
+		if condition == "" {
+			condition = unlabeledCondition
+		}
+		byCondition[condition] = append(byCondition[condition], p)
+	}
+	conditions := make([]string, 0, len(byCondition))
+	for condition := range byCondition {
+		conditions = append(conditions, condition)
+	}
+	sort.Strings(conditions)
+
+	// Bundle names must stay distinct after making conditions safe as file names
+	names := make(map[string]string)
+	var paths []string
+	for _, condition := range conditions {
+		name := fileName(condition)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("conditions %q and %q would both be exported as %s.zip", other, condition, name)
+		}
+		names[name] = condition
+
+		subset := &types.Dataset{
+			Points:   byCondition[condition],
+			Columns:  dataset.Columns,
+			Metadata: dataset.Metadata,
+			Events:   conditionEvents(dataset.Events, byCondition[condition]),
+		}
+		path := filepath.Join(config.OutputDir, name+".zip")
+		if err := writeBundle(path, name, condition, subset, config); err != nil {
+			return nil, fmt.Errorf("condition %s: %v", condition, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeBundle writes the bundle files to a scratch directory with the usual savers, then zips them
+// under a top-level folder named after the condition
+func writeBundle(path, name, condition string, dataset *types.Dataset, config BundleConfig) error {
+	dir, err := os.MkdirTemp("", "mbdvr-export-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{"data.csv"}
+	if err := (&loader.Loader{}).SaveDatasetAsCSV(dataset, filepath.Join(dir, "data.csv")); err != nil {
+		return err
+	}
+
+	if len(dataset.Events) > 0 {
+		files = append(files, "events.csv")
+		if err := events.SaveEvents(dataset.Events, filepath.Join(dir, "events.csv")); err != nil {
+			return err
+		}
+	}
+
+	statsConfig := config.Stats
+	statsConfig.ByCondition = false
+	if len(statsConfig.AnalyzeColumns) == 0 {
+		statsConfig.AnalyzeColumns = numericColumns(dataset)
+	}
+	report, err := stats.ComputeStats(dataset, statsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to compute statistics: %v", err)
+	}
+	files = append(files, "stats.txt")
+	if err := stats.SaveReport(report, filepath.Join(dir, "stats.txt")); err != nil {
+		return err
+	}
+
+	var manifest strings.Builder
+	fmt.Fprintf(&manifest, "Condition: %s\n", condition)
+	fmt.Fprintf(&manifest, "Participants: %s\n", strings.Join(participants(dataset.Points), ", "))
+	fmt.Fprintf(&manifest, "Samples: %d\n", len(dataset.Points))
+	fmt.Fprintf(&manifest, "Events: %d\n", len(dataset.Events))
+	fmt.Fprintf(&manifest, "Created: %s by mbdvr %s\n", time.Now().Format(time.RFC3339), version.Current())
+	fmt.Fprintf(&manifest, "\nFiles:\n")
+	for _, f := range files {
+		fmt.Fprintf(&manifest, "  %s\n", f)
+	}
+	for _, f := range config.Include {
+		fmt.Fprintf(&manifest, "  %s\n", filepath.Base(f))
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	w, err := zw.CreateHeader(fileHeader(name + "/manifest.txt"))
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, manifest.String()); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := addFile(zw, name+"/"+f, filepath.Join(dir, f)); err != nil {
+			return err
+		}
+	}
+	for _, f := range config.Include {
+		if err := addFile(zw, name+"/"+filepath.Base(f), f); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return out.Close()
+}
+
+func addFile(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.CreateHeader(fileHeader(name))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to add %s: %v", path, err)
+	}
+	return nil
+}
+
+// fileHeader stamps bundle entries with the export time; zip.Writer.Create leaves them at 1980
+func fileHeader(name string) *zip.FileHeader {
+	return &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}
+}
+
+// conditionEvents keeps the events of the condition's participants that start within the time span
+// the participant spent in the condition
+func conditionEvents(all []types.Event, points []types.DataPoint) []types.Event {
+	if len(all) == 0 {
+		return nil
+	}
+	spans := make(map[string][2]float64)
+	for _, p := range points {
+		span, ok := spans[p.ParticipantID]
+		if !ok {
+			span = [2]float64{math.Inf(1), math.Inf(-1)}
+		}
+		span[0] = math.Min(span[0], p.Timestamp)
+		span[1] = math.Max(span[1], p.Timestamp)
+		spans[p.ParticipantID] = span
+	}
+
+	var kept []types.Event
+	for _, e := range all {
+		if span, ok := spans[e.ParticipantID]; ok && e.Start >= span[0] && e.Start <= span[1] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// numericColumns lists the data columns with at least one value, for stats when none were chosen
+func numericColumns(dataset *types.Dataset) []string {
+	var cols []string
+	for i, col := range dataset.Columns {
+		if i == 0 {
+			continue // Timestamp
+		}
+		for _, p := range dataset.Points {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				cols = append(cols, col)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+func participants(points []types.DataPoint) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, p := range points {
+		if !seen[p.ParticipantID] {
+			seen[p.ParticipantID] = true
+			ids = append(ids, p.ParticipantID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// fileName makes a condition safe to use as a file name
+func fileName(condition string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, condition)
+}
@@ -0,0 +1,364 @@
+// Package expr evaluates small arithmetic expressions over data points, for study-specific
+// transforms and filters that don't warrant Go code changes.
+//
+// Expressions use column names as variables (backquote names with spaces: `pupil size`), numbers,
+// "strings", the operators + - * / % ^ == != < <= > >= && || ! and cond ? a : b, and the functions
+// listed in FunctionNames. Missing values are NaN; comparisons with NaN are false.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Value is a number or a string
+type Value struct {
+	Num   float64
+	Str   string
+	IsStr bool
+}
+
+func Number(v float64) Value { return Value{Num: v} }
+func String(s string) Value  { return Value{Str: s, IsStr: true} }
+
+// Truthy reports whether the value counts as true: non-zero numbers and non-empty strings
+func (v Value) Truthy() bool {
+	if v.IsStr {
+		return v.Str != ""
+	}
+	return v.Num != 0 && !math.IsNaN(v.Num)
+}
+
+func (v Value) String() string {
+	if v.IsStr {
+		return v.Str
+	}
+	return fmt.Sprintf("%g", v.Num)
+}
+
+// Env resolves variable names during evaluation
+type Env interface {
+	Lookup(name string) (Value, bool)
+}
+
+// MapEnv is an Env over plain numeric variables
+type MapEnv map[string]float64
+
+func (m MapEnv) Lookup(name string) (Value, bool) {
+	v, ok := m[name]
+	return Number(v), ok
+}
+
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses an expression
+func Compile(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected input")
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression; unknown variables are an error
+func (e *Expr) Eval(env Env) (Value, error) {
+	return e.root.eval(env)
+}
+
+// Variables returns the names the expression reads, sorted
+func (e *Expr) Variables() []string {
+	seen := make(map[string]bool)
+	e.root.vars(seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Assignment computes a column from an expression
+type Assignment struct {
+	Column string
+	Expr   *Expr
+}
+
+// ParseAssignments reads a ';'-separated list such as "speed = sqrt(vx^2 + vy^2); fast = speed > 30"
+func ParseAssignments(src string) ([]Assignment, error) {
+	var assignments []Assignment
+	for _, part := range splitStatements(src) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		eq := assignmentIndex(part)
+		if eq < 0 {
+			return nil, fmt.Errorf("expected 'column = expression' in %q", part)
+		}
+		column := strings.Trim(strings.TrimSpace(part[:eq]), "`")
+		if column == "" {
+			return nil, fmt.Errorf("missing column name in %q", part)
+		}
+		e, err := Compile(strings.TrimSpace(part[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", column, err)
+		}
+		assignments = append(assignments, Assignment{Column: column, Expr: e})
+	}
+	return assignments, nil
+}
+
+// splitStatements splits on ';' outside of quotes
+func splitStatements(src string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == ';':
+			parts = append(parts, src[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, src[start:])
+}
+
+// assignmentIndex finds the '=' of an assignment, skipping ==, !=, <= and >=
+func assignmentIndex(s string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '=':
+			if i+1 < len(s) && s[i+1] == '=' {
+				return -1
+			}
+			if i > 0 && strings.IndexByte("=!<>", s[i-1]) >= 0 {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+type node interface {
+	eval(env Env) (Value, error)
+	vars(seen map[string]bool)
+}
+
+type literal struct{ value Value }
+
+func (n *literal) eval(Env) (Value, error) { return n.value, nil }
+func (n *literal) vars(map[string]bool)    {}
+
+type variable struct{ name string }
+
+func (n *variable) eval(env Env) (Value, error) {
+	v, ok := env.Lookup(n.name)
+	if !ok {
+		return Value{}, fmt.Errorf("unknown column %q", n.name)
+	}
+	return v, nil
+}
+
+func (n *variable) vars(seen map[string]bool) { seen[n.name] = true }
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n *unary) eval(env Env) (Value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	if n.op == "!" {
+		return boolValue(!v.Truthy()), nil
+	}
+	if v.IsStr {
+		return Value{}, fmt.Errorf("cannot negate string %q", v.Str)
+	}
+	return Number(-v.Num), nil
+}
+
+func (n *unary) vars(seen map[string]bool) { n.operand.vars(seen) }
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n *binary) eval(env Env) (Value, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+
+	// Logical operators short-circuit
+	switch n.op {
+	case "&&":
+		if !l.Truthy() {
+			return Number(0), nil
+		}
+		r, err := n.right.eval(env)
+		return boolValue(r.Truthy()), err
+	case "||":
+		if l.Truthy() {
+			return Number(1), nil
+		}
+		r, err := n.right.eval(env)
+		return boolValue(r.Truthy()), err
+	}
+
+	r, err := n.right.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if l.IsStr || r.IsStr {
+		if !l.IsStr || !r.IsStr {
+			return Value{}, fmt.Errorf("cannot apply %s to a string and a number", n.op)
+		}
+		switch n.op {
+		case "==":
+			return boolValue(l.Str == r.Str), nil
+		case "!=":
+			return boolValue(l.Str != r.Str), nil
+		case "<":
+			return boolValue(l.Str < r.Str), nil
+		case "<=":
+			return boolValue(l.Str <= r.Str), nil
+		case ">":
+			return boolValue(l.Str > r.Str), nil
+		case ">=":
+			return boolValue(l.Str >= r.Str), nil
+		case "+":
+			return String(l.Str + r.Str), nil
+		}
+		return Value{}, fmt.Errorf("cannot apply %s to strings", n.op)
+	}
+
+	a, b := l.Num, r.Num
+	switch n.op {
+	case "+":
+		return Number(a + b), nil
+	case "-":
+		return Number(a - b), nil
+	case "*":
+		return Number(a * b), nil
+	case "/":
+		if b == 0 {
+			return Number(math.NaN()), nil
+		}
+		return Number(a / b), nil
+	case "%":
+		return Number(math.Mod(a, b)), nil
+	case "^":
+		return Number(math.Pow(a, b)), nil
+	case "==":
+		return boolValue(a == b), nil
+	case "!=":
+		return boolValue(a != b && !math.IsNaN(a) && !math.IsNaN(b)), nil
+	case "<":
+		return boolValue(a < b), nil
+	case "<=":
+		return boolValue(a <= b), nil
+	case ">":
+		return boolValue(a > b), nil
+	case ">=":
+		return boolValue(a >= b), nil
+	}
+	return Value{}, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func (n *binary) vars(seen map[string]bool) {
+	n.left.vars(seen)
+	n.right.vars(seen)
+}
+
+type conditional struct {
+	cond, then, otherwise node
+}
+
+func (n *conditional) eval(env Env) (Value, error) {
+	c, err := n.cond.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	if c.Truthy() {
+		return n.then.eval(env)
+	}
+	return n.otherwise.eval(env)
+}
+
+func (n *conditional) vars(seen map[string]bool) {
+	n.cond.vars(seen)
+	n.then.vars(seen)
+	n.otherwise.vars(seen)
+}
+
+type call struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *call) eval(env Env) (Value, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return Value{}, err
+		}
+		if v.IsStr {
+			return Value{}, fmt.Errorf("%s expects numbers, got string %q", n.name, v.Str)
+		}
+		args[i] = v.Num
+	}
+	return Number(n.fn.eval(args)), nil
+}
+
+func (n *call) vars(seen map[string]bool) {
+	for _, arg := range n.args {
+		arg.vars(seen)
+	}
+}
+
+func boolValue(b bool) Value {
+	if b {
+		return Number(1)
+	}
+	return Number(0)
+}
@@ -0,0 +1,81 @@
+package expr
+
+import (
+	"math"
+	"sort"
+)
+
+type function struct {
+	args int // Number of arguments, -1 = one or more
+	eval func(args []float64) float64
+}
+
+func math1(f func(float64) float64) function {
+	return function{1, func(a []float64) float64 { return f(a[0]) }}
+}
+
+func math2(f func(float64, float64) float64) function {
+	return function{2, func(a []float64) float64 { return f(a[0], a[1]) }}
+}
+
+var functions = map[string]function{
+	"abs":   math1(math.Abs),
+	"sqrt":  math1(math.Sqrt),
+	"exp":   math1(math.Exp),
+	"log":   math1(math.Log),
+	"log10": math1(math.Log10),
+	"sin":   math1(math.Sin),
+	"cos":   math1(math.Cos),
+	"tan":   math1(math.Tan),
+	"asin":  math1(math.Asin),
+	"acos":  math1(math.Acos),
+	"atan":  math1(math.Atan),
+	"floor": math1(math.Floor),
+	"ceil":  math1(math.Ceil),
+	"round": math1(math.Round),
+	"deg":   math1(func(x float64) float64 { return x * 180 / math.Pi }),
+	"rad":   math1(func(x float64) float64 { return x * math.Pi / 180 }),
+	"atan2": math2(math.Atan2),
+	"pow":   math2(math.Pow),
+	"hypot": math2(math.Hypot),
+	"clamp": {3, func(a []float64) float64 { return math.Max(a[1], math.Min(a[2], a[0])) }},
+	"isnan": math1(func(x float64) float64 {
+		if math.IsNaN(x) {
+			return 1
+		}
+		return 0
+	}),
+	// coalesce returns the first argument that isn't missing
+	"coalesce": {-1, func(a []float64) float64 {
+		for _, v := range a {
+			if !math.IsNaN(v) {
+				return v
+			}
+		}
+		return math.NaN()
+	}},
+	"min": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+// FunctionNames lists the built-in functions, sorted
+func FunctionNames() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
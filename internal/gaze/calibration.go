@@ -0,0 +1,172 @@
+package gaze
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"mbdvr/internal/types"
+)
+
+// CalibrationMetadataKey stores the calibration quality of each participant and eye in the dataset metadata
+const CalibrationMetadataKey = "calibration"
+
+// Eye names a pair of gaze columns
+type Eye struct {
+	Name string
+	X, Y string
+}
+
+type CalibrationConfig struct {
+	TargetX, TargetY string  // Calibration target position, in the gaze units
+	Eyes             []Eye   // Gaze columns scored against the targets
+	Settle           float64 // Seconds skipped after each target appears, while the eye moves onto it
+}
+
+// TargetQuality is the accuracy and precision of one eye on one calibration target
+type TargetQuality struct {
+	TargetX float64 `json:"target_x"`
+	TargetY float64 `json:"target_y"`
+	Samples int     `json:"samples"`
+	Offset  float64 `json:"offset"` // Mean distance of the gaze samples from the target (accuracy)
+	RMS     float64 `json:"rms"`    // Root mean square of sample-to-sample distances (precision)
+}
+
+// CalibrationQuality summarizes one participant's calibration of one eye (per condition if recorded in several)
+type CalibrationQuality struct {
+	ParticipantID string          `json:"participant_id"`
+	Condition     string          `json:"condition,omitempty"`
+	Eye           string          `json:"eye"`
+	MeanOffset    float64         `json:"mean_offset"` // Over targets
+	MaxOffset     float64         `json:"max_offset"`
+	RMS           float64         `json:"rms"` // Mean over targets
+	Targets       []TargetQuality `json:"targets"`
+}
+
+// ScoreCalibration computes accuracy and precision from a calibration recording: samples are grouped
+// into targets by runs of the same target position in each participant's recording, and each eye's
+// gaze is compared with the target it was looking at. Samples without a target or gaze are skipped, and
+// so are targets with fewer than two gaze samples.
+// The result carries the qualities in its metadata under CalibrationMetadataKey.
+func ScoreCalibration(dataset *types.Dataset, config CalibrationConfig) (*types.Dataset, []CalibrationQuality, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, nil, fmt.Errorf("dataset is empty")
+	}
+	if len(config.Eyes) == 0 {
+		return nil, nil, fmt.Errorf("no gaze columns to score")
+	}
+	if err := requireColumns(dataset, config.TargetX, config.TargetY); err != nil {
+		return nil, nil, err
+	}
+	for _, eye := range config.Eyes {
+		if err := requireColumns(dataset, eye.X, eye.Y); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var qualities []CalibrationQuality
+	for _, idx := range types.RecordingIndices(dataset.Points) {
+		// Runs of samples with the same target
+		var runs [][]int
+		var runStart, currentX, currentY float64
+		for _, i := range idx {
+			p := dataset.Points[i]
+			tx, okX := p.Data[config.TargetX]
+			ty, okY := p.Data[config.TargetY]
+			if !okX || !okY || math.IsNaN(tx) || math.IsNaN(ty) {
+				continue
+			}
+			if len(runs) == 0 || tx != currentX || ty != currentY {
+				runs = append(runs, nil)
+				runStart, currentX, currentY = p.Timestamp, tx, ty
+			}
+			if p.Timestamp-runStart >= config.Settle {
+				runs[len(runs)-1] = append(runs[len(runs)-1], i)
+			}
+		}
+
+		for _, eye := range config.Eyes {
+			q := CalibrationQuality{ParticipantID: dataset.Points[idx[0]].ParticipantID, Condition: dataset.Points[idx[0]].Condition, Eye: eye.Name}
+			for _, run := range runs {
+				if len(run) == 0 {
+					continue
+				}
+				t := TargetQuality{TargetX: dataset.Points[run[0]].Data[config.TargetX], TargetY: dataset.Points[run[0]].Data[config.TargetY]}
+				offsets, squares := 0.0, 0.0
+				var prevX, prevY float64
+				for _, i := range run {
+					x, okX := dataset.Points[i].Data[eye.X]
+					y, okY := dataset.Points[i].Data[eye.Y]
+					if !okX || !okY || math.IsNaN(x) || math.IsNaN(y) {
+						continue
+					}
+					offsets += math.Hypot(x-t.TargetX, y-t.TargetY)
+					if t.Samples > 0 {
+						dx, dy := x-prevX, y-prevY
+						squares += dx*dx + dy*dy
+					}
+					prevX, prevY = x, y
+					t.Samples++
+				}
+				if t.Samples < 2 {
+					continue
+				}
+				t.Offset = offsets / float64(t.Samples)
+				t.RMS = math.Sqrt(squares / float64(t.Samples-1))
+				q.Targets = append(q.Targets, t)
+			}
+			if len(q.Targets) == 0 {
+				continue
+			}
+
+			for _, t := range q.Targets {
+				q.MeanOffset += t.Offset
+				q.MaxOffset = math.Max(q.MaxOffset, t.Offset)
+				q.RMS += t.RMS
+			}
+			q.MeanOffset /= float64(len(q.Targets))
+			q.RMS /= float64(len(q.Targets))
+			qualities = append(qualities, q)
+		}
+	}
+	if len(qualities) == 0 {
+		return nil, nil, fmt.Errorf("no samples with both a target and gaze")
+	}
+
+	result := &types.Dataset{
+		Points:   dataset.Points,
+		Columns:  dataset.Columns,
+		Metadata: make(map[string]interface{}, len(dataset.Metadata)+1),
+		Events:   dataset.Events,
+	}
+	for key, value := range dataset.Metadata {
+		result.Metadata[key] = value
+	}
+	result.Metadata[CalibrationMetadataKey] = qualities
+	return result, qualities, nil
+}
+
+// SaveCalibration writes one row per participant, eye and target as CSV
+func SaveCalibration(qualities []CalibrationQuality, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	w := csv.NewWriter(f)
+	w.Write([]string{"participant_id", "condition", "eye", "target_x", "target_y", "samples", "offset", "rms"})
+	for _, q := range qualities {
+		for _, t := range q.Targets {
+			w.Write([]string{q.ParticipantID, q.Condition, q.Eye, format(t.TargetX), format(t.TargetY), strconv.Itoa(t.Samples), format(t.Offset), format(t.RMS)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,228 @@
+package gaze
+
+import (
+	"fmt"
+	"math"
+
+	"mbdvr/internal/events"
+	"mbdvr/internal/types"
+)
+
+// Movement labels stored in the MovementColumn
+const (
+	Fixation = 1
+	Saccade  = 2
+	Pursuit  = 3
+)
+
+const (
+	MovementColumn = "movement"
+	VelocityColumn = "velocity"
+)
+
+// MovementName returns the label of a movement code
+func MovementName(code float64) string {
+	switch code {
+	case Fixation:
+		return "fixation"
+	case Saccade:
+		return "saccade"
+	case Pursuit:
+		return "pursuit"
+	}
+	return "unknown"
+}
+
+type ClassifyConfig struct {
+	X, Y               string  // Gaze columns; velocities are in their units per second
+	SaccadeThreshold   float64 // Faster samples are saccades
+	FixationThreshold  float64 // Slower samples are fixations; samples in between are smooth pursuit
+	MinPursuitDuration float64 // Seconds; shorter pursuit runs are relabelled as fixations (0 = keep all)
+
+	World *WorldConfig // Classify gaze-in-world angular velocity (deg/s) instead of X/Y velocity (nil = X/Y)
+
+	TargetX, TargetY string  // Optional target trajectory columns for pursuit gain
+	MinTargetSpeed   float64 // Gain is only computed while the target moves at least this fast
+}
+
+type ClassifyStats struct {
+	Samples     int // Samples with a velocity estimate
+	Fixation    int
+	Saccade     int
+	Pursuit     int
+	GainSamples int
+	Gains       []PursuitGain
+}
+
+// PursuitGain summarizes how closely gaze velocity followed the target during pursuit
+type PursuitGain struct {
+	ParticipantID string
+	Condition     string
+	Samples       int     // Pursuit samples with a moving target
+	MeanGain      float64 // Gaze velocity projected on the target direction, divided by target speed
+	MedianGain    float64
+}
+
+// Classify labels each sample as fixation, saccade or smooth pursuit with a velocity-velocity threshold
+// (I-VVT) classifier, adding the MovementColumn and VelocityColumn, and computes pursuit gain
+// per participant and condition when target columns are given.
+func Classify(dataset *types.Dataset, config ClassifyConfig) (*types.Dataset, ClassifyStats, error) {
+	var stats ClassifyStats
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, stats, fmt.Errorf("dataset is empty")
+	}
+	if config.FixationThreshold <= 0 || config.SaccadeThreshold <= config.FixationThreshold {
+		return nil, stats, fmt.Errorf("thresholds must satisfy 0 < fixation threshold < saccade threshold")
+	}
+	if config.World != nil {
+		if err := requireColumns(dataset, config.World.columns()...); err != nil {
+			return nil, stats, err
+		}
+	} else if err := requireColumns(dataset, config.X, config.Y); err != nil {
+		return nil, stats, err
+	}
+	withTarget := config.TargetX != "" || config.TargetY != ""
+	if withTarget && config.World != nil {
+		return nil, stats, fmt.Errorf("pursuit gain needs X/Y gaze velocity and can't be combined with world coordinates")
+	}
+	if withTarget {
+		if err := requireColumns(dataset, config.TargetX, config.TargetY); err != nil {
+			return nil, stats, err
+		}
+	}
+
+	points := make([]types.DataPoint, len(dataset.Points))
+	for i, p := range dataset.Points {
+		data := types.CloneData(p.Data, 5)
+		points[i] = p
+		points[i].Data = data
+	}
+
+	var vx, vy, speeds []float64
+	if config.World != nil {
+		dirs, ok := worldDirections(points, *config.World)
+		for i := range points {
+			if ok[i] {
+				points[i].Data[WorldXColumn] = dirs[i][0]
+				points[i].Data[WorldYColumn] = dirs[i][1]
+				points[i].Data[WorldZColumn] = dirs[i][2]
+			}
+		}
+		speeds = angularVelocities(points, dirs, ok)
+	} else {
+		vx, vy = velocities(points, config.X, config.Y)
+		speeds = make([]float64, len(points))
+		for i := range points {
+			speeds[i] = math.Hypot(vx[i], vy[i])
+		}
+	}
+	for i := range points {
+		speed := speeds[i]
+		if math.IsNaN(speed) {
+			continue
+		}
+		points[i].Data[VelocityColumn] = speed
+		switch {
+		case speed > config.SaccadeThreshold:
+			points[i].Data[MovementColumn] = Saccade
+		case speed < config.FixationThreshold:
+			points[i].Data[MovementColumn] = Fixation
+		default:
+			points[i].Data[MovementColumn] = Pursuit
+		}
+	}
+
+	if config.MinPursuitDuration > 0 {
+		relabelShortPursuit(points, config.MinPursuitDuration)
+	}
+
+	for _, p := range points {
+		code, ok := p.Data[MovementColumn]
+		if !ok {
+			continue
+		}
+		stats.Samples++
+		switch code {
+		case Fixation:
+			stats.Fixation++
+		case Saccade:
+			stats.Saccade++
+		case Pursuit:
+			stats.Pursuit++
+		}
+	}
+
+	if withTarget {
+		stats.Gains = pursuitGains(points, vx, vy, config)
+		for _, g := range stats.Gains {
+			stats.GainSamples += g.Samples
+		}
+	}
+
+	added := []string{VelocityColumn, MovementColumn}
+	if config.World != nil {
+		added = append([]string{WorldXColumn, WorldYColumn, WorldZColumn}, added...)
+	}
+	columns := appendColumns(dataset.Columns, added...)
+
+	metadata := make(map[string]interface{}, len(dataset.Metadata)+4)
+	for key, value := range dataset.Metadata {
+		metadata[key] = value
+	}
+	metadata["movement_classifier"] = "ivvt"
+	if config.World != nil {
+		metadata["velocity_frame"] = "world"
+	}
+	metadata["saccade_threshold"] = config.SaccadeThreshold
+	metadata["fixation_threshold"] = config.FixationThreshold
+	metadata["pursuit_samples"] = stats.Pursuit
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  columns,
+		Metadata: metadata,
+		Events:   dataset.Events,
+	}, stats, nil
+}
+
+// relabelShortPursuit turns pursuit runs shorter than minDuration into fixations; brief velocity
+// drift between fixation and saccade thresholds is usually noise rather than pursuit
+func relabelShortPursuit(points []types.DataPoint, minDuration float64) {
+	detector := events.Hysteresis{Enter: 1, Exit: 1}
+	for _, idx := range types.RecordingIndices(points) {
+		times := make([]float64, len(idx))
+		signal := make([]float64, len(idx))
+		for k, i := range idx {
+			times[k] = points[i].Timestamp
+			if points[i].Data[MovementColumn] == Pursuit {
+				signal[k] = 1
+			}
+		}
+		for _, iv := range detector.Detect(times, signal) {
+			// Measured between the first and last pursuit samples
+			if times[iv.End-1]-times[iv.Start] < minDuration {
+				for k := iv.Start; k < iv.End; k++ {
+					points[idx[k]].Data[MovementColumn] = Fixation
+				}
+			}
+		}
+	}
+}
+
+func requireColumns(dataset *types.Dataset, cols ...string) error {
+	for _, col := range cols {
+		if col == "" || !hasColumn(dataset.Columns, col) {
+			return fmt.Errorf("column %q not found", col)
+		}
+	}
+	return nil
+}
+
+func hasColumn(columns []string, col string) bool {
+	for _, c := range columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,366 @@
+package gaze
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"mbdvr/internal/events"
+	"mbdvr/internal/types"
+)
+
+// MicrosaccadeColumn is set to 1 during microsaccades and 0 on other analyzed fixation samples
+const MicrosaccadeColumn = "microsaccade"
+
+// MicrosaccadeConfig configures the Engbert-Kliegl (2003) velocity criterion
+type MicrosaccadeConfig struct {
+	X, Y         string  // Gaze columns, typically in degrees
+	Lambda       float64 // Velocity threshold in median-based standard deviations (default: 6)
+	MinSamples   int     // Minimum microsaccade length in samples (default: 3)
+	MaxAmplitude float64 // Larger movements are saccades, not microsaccades (0 = no limit)
+	MinFixation  float64 // Seconds; shorter fixation segments are skipped
+}
+
+// Microsaccade is one detected microsaccade
+type Microsaccade struct {
+	ParticipantID string
+	Condition     string
+	Start, End    float64
+	Amplitude     float64 // Distance spanned by the movement, in gaze units
+	PeakVelocity  float64 // In gaze units per second
+}
+
+// MicrosaccadeStats summarizes microsaccades per participant and condition
+type MicrosaccadeStats struct {
+	ParticipantID    string
+	Condition        string
+	FixationTime     float64 // Seconds of analyzed fixation segments
+	Count            int
+	Rate             float64 // Microsaccades per second of fixation
+	MeanAmplitude    float64
+	MedianAmplitude  float64
+	MeanPeakVelocity float64
+}
+
+// DetectMicrosaccades finds microsaccades inside fixation segments: runs of samples labelled as
+// fixations by Classify (or all valid samples if the MovementColumn is absent). Each segment is
+// detrended to remove slow drift, and samples whose 5-point velocity exceeds an elliptic threshold
+// of Lambda median-based standard deviations per axis (computed per recording) are candidates.
+// The result adds the MicrosaccadeColumn and a "MICROSACCADE" event per detection.
+func DetectMicrosaccades(dataset *types.Dataset, config MicrosaccadeConfig) (*types.Dataset, []Microsaccade, []MicrosaccadeStats, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, nil, nil, fmt.Errorf("dataset is empty")
+	}
+	if err := requireColumns(dataset, config.X, config.Y); err != nil {
+		return nil, nil, nil, err
+	}
+	if config.Lambda <= 0 {
+		config.Lambda = 6
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 3
+	}
+	labelled := hasColumn(dataset.Columns, MovementColumn)
+
+	points := make([]types.DataPoint, len(dataset.Points))
+	for i, p := range dataset.Points {
+		data := types.CloneData(p.Data, 1)
+		points[i] = p
+		points[i].Data = data
+	}
+
+	type key struct{ participant, condition string }
+	fixationTime := make(map[key]float64)
+	var detected []Microsaccade
+
+	for _, idx := range types.RecordingIndices(points) {
+		// Split the recording into fixation segments
+		var segments [][]int
+		var current []int
+		for _, i := range idx {
+			p := points[i]
+			x, okX := p.Data[config.X]
+			y, okY := p.Data[config.Y]
+			valid := okX && okY && !math.IsNaN(x) && !math.IsNaN(y)
+			if labelled {
+				valid = valid && p.Data[MovementColumn] == Fixation
+			}
+			if valid {
+				current = append(current, i)
+				continue
+			}
+			if len(current) > 0 {
+				segments = append(segments, current)
+				current = nil
+			}
+		}
+		if len(current) > 0 {
+			segments = append(segments, current)
+		}
+
+		// Velocities of detrended segments, pooled for the recording's thresholds
+		var kept [][]int
+		var vxs, vys [][]float64
+		var pooledX, pooledY []float64
+		for _, seg := range segments {
+			duration := points[seg[len(seg)-1]].Timestamp - points[seg[0]].Timestamp
+			if len(seg) < 5 || duration < config.MinFixation {
+				continue
+			}
+			times := make([]float64, len(seg))
+			xs := make([]float64, len(seg))
+			ys := make([]float64, len(seg))
+			for k, i := range seg {
+				times[k] = points[i].Timestamp
+				xs[k] = points[i].Data[config.X]
+				ys[k] = points[i].Data[config.Y]
+			}
+			detrend(times, xs)
+			detrend(times, ys)
+			vx, vy := ekVelocities(times, xs), ekVelocities(times, ys)
+			for k := range vx {
+				if !math.IsNaN(vx[k]) && !math.IsNaN(vy[k]) {
+					pooledX = append(pooledX, vx[k])
+					pooledY = append(pooledY, vy[k])
+				}
+			}
+			kept = append(kept, seg)
+			vxs = append(vxs, vx)
+			vys = append(vys, vy)
+			fixationTime[key{points[seg[0]].ParticipantID, points[seg[0]].Condition}] += duration
+		}
+
+		etaX := config.Lambda * medianSD(pooledX)
+		etaY := config.Lambda * medianSD(pooledY)
+		if len(pooledX) == 0 || etaX == 0 || etaY == 0 {
+			continue
+		}
+
+		for s, seg := range kept {
+			times := make([]float64, len(seg))
+			criterion := make([]float64, len(seg))
+			for k, i := range seg {
+				times[k] = points[i].Timestamp
+				points[i].Data[MicrosaccadeColumn] = 0
+				criterion[k] = math.Pow(vxs[s][k]/etaX, 2) + math.Pow(vys[s][k]/etaY, 2)
+			}
+
+			// The criterion is 1 on the threshold ellipse
+			for _, iv := range (events.Hysteresis{Enter: 1, Exit: 1}).Detect(times, criterion) {
+				// Movements still running at the segment end are cut off by a saccade or a gap
+				if iv.Open || iv.End-iv.Start < config.MinSamples {
+					continue
+				}
+				m := Microsaccade{
+					ParticipantID: points[seg[0]].ParticipantID,
+					Condition:     points[seg[0]].Condition,
+					Start:         times[iv.Start],
+					End:           times[iv.End-1],
+				}
+				minX, maxX := math.Inf(1), math.Inf(-1)
+				minY, maxY := math.Inf(1), math.Inf(-1)
+				for k := iv.Start; k < iv.End; k++ {
+					p := points[seg[k]]
+					minX, maxX = math.Min(minX, p.Data[config.X]), math.Max(maxX, p.Data[config.X])
+					minY, maxY = math.Min(minY, p.Data[config.Y]), math.Max(maxY, p.Data[config.Y])
+					m.PeakVelocity = math.Max(m.PeakVelocity, math.Hypot(vxs[s][k], vys[s][k]))
+				}
+				m.Amplitude = math.Hypot(maxX-minX, maxY-minY)
+				if config.MaxAmplitude > 0 && m.Amplitude > config.MaxAmplitude {
+					continue
+				}
+				for k := iv.Start; k < iv.End; k++ {
+					points[seg[k]].Data[MicrosaccadeColumn] = 1
+				}
+				detected = append(detected, m)
+			}
+		}
+	}
+
+	// Summaries cover every participant/condition with analyzed fixation time, including those without microsaccades
+	var order []key
+	for k := range fixationTime {
+		order = append(order, k)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].participant != order[j].participant {
+			return order[i].participant < order[j].participant
+		}
+		return order[i].condition < order[j].condition
+	})
+	amplitudes := make(map[key][]float64)
+	velocitySums := make(map[key]float64)
+	for _, m := range detected {
+		k := key{m.ParticipantID, m.Condition}
+		amplitudes[k] = append(amplitudes[k], m.Amplitude)
+		velocitySums[k] += m.PeakVelocity
+	}
+	var summaries []MicrosaccadeStats
+	for _, k := range order {
+		s := MicrosaccadeStats{
+			ParticipantID:    k.participant,
+			Condition:        k.condition,
+			FixationTime:     fixationTime[k],
+			Count:            len(amplitudes[k]),
+			MeanAmplitude:    math.NaN(),
+			MedianAmplitude:  math.NaN(),
+			MeanPeakVelocity: math.NaN(),
+		}
+		if s.FixationTime > 0 {
+			s.Rate = float64(s.Count) / s.FixationTime
+		}
+		if s.Count > 0 {
+			sum := 0.0
+			for _, a := range amplitudes[k] {
+				sum += a
+			}
+			s.MeanAmplitude = sum / float64(s.Count)
+			s.MedianAmplitude = median(amplitudes[k])
+			s.MeanPeakVelocity = velocitySums[k] / float64(s.Count)
+		}
+		summaries = append(summaries, s)
+	}
+
+	result := &types.Dataset{
+		Points:   points,
+		Columns:  appendColumns(dataset.Columns, MicrosaccadeColumn),
+		Metadata: make(map[string]interface{}, len(dataset.Metadata)+2),
+		Events:   append([]types.Event{}, dataset.Events...),
+	}
+	for key, value := range dataset.Metadata {
+		result.Metadata[key] = value
+	}
+	result.Metadata["microsaccades"] = len(detected)
+	result.Metadata["microsaccade_lambda"] = config.Lambda
+	for _, m := range detected {
+		result.Events = append(result.Events, types.Event{
+			Type:          "MICROSACCADE",
+			Start:         m.Start,
+			End:           m.End,
+			Data:          map[string]float64{"amplitude": m.Amplitude, "peak_velocity": m.PeakVelocity},
+			ParticipantID: m.ParticipantID,
+		})
+	}
+
+	return result, detected, summaries, nil
+}
+
+// detrend subtracts the least-squares line through (times, values) in place
+func detrend(times, values []float64) {
+	var mt, mv float64
+	for i := range times {
+		mt += times[i]
+		mv += values[i]
+	}
+	mt /= float64(len(times))
+	mv /= float64(len(values))
+	var stv, stt float64
+	for i := range times {
+		stv += (times[i] - mt) * (values[i] - mv)
+		stt += (times[i] - mt) * (times[i] - mt)
+	}
+	slope := 0.0
+	if stt > 0 {
+		slope = stv / stt
+	}
+	for i := range values {
+		values[i] -= mv + slope*(times[i]-mt)
+	}
+}
+
+// ekVelocities is the Engbert-Kliegl 5-point moving average velocity,
+// (x[n+2]+x[n+1]-x[n-1]-x[n-2]) / (t[n+2]+t[n+1]-t[n-1]-t[n-2]), which is 6*dt for uniform
+// sampling. The first and last two samples get NaN.
+func ekVelocities(times, values []float64) []float64 {
+	v := make([]float64, len(values))
+	for n := range v {
+		v[n] = math.NaN()
+		if n < 2 || n+2 >= len(values) {
+			continue
+		}
+		if dt := times[n+2] + times[n+1] - times[n-1] - times[n-2]; dt > 0 {
+			v[n] = (values[n+2] + values[n+1] - values[n-1] - values[n-2]) / dt
+		}
+	}
+	return v
+}
+
+// medianSD is the median-based velocity standard deviation sqrt(median(v^2) - median(v)^2)
+func medianSD(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	squares := make([]float64, len(values))
+	for i, v := range values {
+		squares[i] = v * v
+	}
+	m := median(values)
+	return math.Sqrt(math.Max(median(squares)-m*m, 0))
+}
+
+// median returns the median of values without reordering them
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func appendColumns(columns []string, cols ...string) []string {
+	result := append([]string{}, columns...)
+	for _, col := range cols {
+		if !hasColumn(result, col) {
+			result = append(result, col)
+		}
+	}
+	return result
+}
+
+// SaveMicrosaccades writes one row per microsaccade as CSV
+func SaveMicrosaccades(detected []Microsaccade, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	w := csv.NewWriter(f)
+	w.Write([]string{"participant_id", "condition", "start", "end", "amplitude", "peak_velocity"})
+	for _, m := range detected {
+		w.Write([]string{m.ParticipantID, m.Condition, format(m.Start), format(m.End), format(m.Amplitude), format(m.PeakVelocity)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
+
+// SaveMicrosaccadeStats writes the rate and amplitude statistics per participant and condition as CSV
+func SaveMicrosaccadeStats(summaries []MicrosaccadeStats, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	w := csv.NewWriter(f)
+	w.Write([]string{"participant_id", "condition", "fixation_time", "count", "rate", "mean_amplitude", "median_amplitude", "mean_peak_velocity"})
+	for _, s := range summaries {
+		w.Write([]string{s.ParticipantID, s.Condition, format(s.FixationTime), strconv.Itoa(s.Count), format(s.Rate),
+			format(s.MeanAmplitude), format(s.MedianAmplitude), format(s.MeanPeakVelocity)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
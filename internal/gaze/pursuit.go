@@ -0,0 +1,83 @@
+package gaze
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"mbdvr/internal/types"
+)
+
+// pursuitGains computes per-sample pursuit gain (gaze velocity projected on the target's direction of
+// motion, divided by target speed) and summarizes it per participant and condition
+func pursuitGains(points []types.DataPoint, vx, vy []float64, config ClassifyConfig) []PursuitGain {
+	tx, ty := velocities(points, config.TargetX, config.TargetY)
+
+	type key struct{ participant, condition string }
+	gains := make(map[key][]float64)
+	var order []key
+	for i, p := range points {
+		if p.Data[MovementColumn] != Pursuit {
+			continue
+		}
+		targetSpeed := math.Hypot(tx[i], ty[i])
+		if math.IsNaN(targetSpeed) || targetSpeed == 0 || targetSpeed < config.MinTargetSpeed {
+			continue
+		}
+		gain := (vx[i]*tx[i] + vy[i]*ty[i]) / (targetSpeed * targetSpeed)
+
+		k := key{p.ParticipantID, p.Condition}
+		if _, ok := gains[k]; !ok {
+			order = append(order, k)
+		}
+		gains[k] = append(gains[k], gain)
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].participant != order[j].participant {
+			return order[i].participant < order[j].participant
+		}
+		return order[i].condition < order[j].condition
+	})
+
+	var result []PursuitGain
+	for _, k := range order {
+		values := gains[k]
+		sum := 0.0
+		for _, g := range values {
+			sum += g
+		}
+		result = append(result, PursuitGain{
+			ParticipantID: k.participant,
+			Condition:     k.condition,
+			Samples:       len(values),
+			MeanGain:      sum / float64(len(values)),
+			MedianGain:    median(values),
+		})
+	}
+	return result
+}
+
+// SaveGains writes pursuit gain per participant and condition as CSV
+func SaveGains(gains []PursuitGain, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"participant_id", "condition", "pursuit_samples", "mean_gain", "median_gain"})
+	for _, g := range gains {
+		w.Write([]string{g.ParticipantID, g.Condition, strconv.Itoa(g.Samples),
+			strconv.FormatFloat(g.MeanGain, 'f', 6, 64), strconv.FormatFloat(g.MedianGain, 'f', 6, 64)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,45 @@
+package gaze
+
+import (
+	"math"
+
+	"mbdvr/internal/types"
+)
+
+// velocities estimates the (vx, vy) velocity of each point from its neighbouring valid samples
+// (central difference, one-sided at the ends) in column units per second. Points without a
+// valid position or neighbour get NaN.
+func velocities(points []types.DataPoint, xCol, yCol string) (vx, vy []float64) {
+	vx = make([]float64, len(points))
+	vy = make([]float64, len(points))
+	for i := range vx {
+		vx[i], vy[i] = math.NaN(), math.NaN()
+	}
+
+	for _, idx := range types.RecordingIndices(points) {
+		var valid []int
+		for _, i := range idx {
+			x, okX := points[i].Data[xCol]
+			y, okY := points[i].Data[yCol]
+			if okX && okY && !math.IsNaN(x) && !math.IsNaN(y) {
+				valid = append(valid, i)
+			}
+		}
+		for k, i := range valid {
+			prev, next := i, i
+			if k > 0 {
+				prev = valid[k-1]
+			}
+			if k+1 < len(valid) {
+				next = valid[k+1]
+			}
+			dt := points[next].Timestamp - points[prev].Timestamp
+			if dt <= 0 {
+				continue
+			}
+			vx[i] = (points[next].Data[xCol] - points[prev].Data[xCol]) / dt
+			vy[i] = (points[next].Data[yCol] - points[prev].Data[yCol]) / dt
+		}
+	}
+	return vx, vy
+}
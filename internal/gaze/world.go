@@ -0,0 +1,89 @@
+package gaze
+
+import (
+	"math"
+
+	"mbdvr/internal/spatial"
+	"mbdvr/internal/types"
+)
+
+// World gaze direction columns added when classifying in world coordinates
+const (
+	WorldXColumn = "world_gaze_x"
+	WorldYColumn = "world_gaze_y"
+	WorldZColumn = "world_gaze_z"
+)
+
+// WorldConfig composes eye-in-head gaze with head rotation into gaze-in-world, so eye movements
+// that compensate head motion (VOR) aren't mistaken for saccades or pursuit
+type WorldConfig struct {
+	Direction  [3]string // Eye-in-head gaze direction vector columns (x, y, z)
+	Quaternion [4]string // Head rotation quaternion columns, in w, x, y, z order
+}
+
+func (c WorldConfig) columns() []string {
+	return append(c.Direction[:], c.Quaternion[:]...)
+}
+
+// worldDirections rotates each eye-in-head gaze direction by the head quaternion into a unit
+// gaze-in-world vector. Points without a valid direction or rotation get ok = false.
+func worldDirections(points []types.DataPoint, config WorldConfig) (dirs []spatial.Vec3, ok []bool) {
+	dirs = make([]spatial.Vec3, len(points))
+	ok = make([]bool, len(points))
+	for i, p := range points {
+		var v [7]float64
+		valid := true
+		for k, col := range config.columns() {
+			val, exists := p.Data[col]
+			if !exists || math.IsNaN(val) {
+				valid = false
+				break
+			}
+			v[k] = val
+		}
+		if !valid {
+			continue
+		}
+		dir := spatial.Vec3{v[0], v[1], v[2]}.Normalize()
+		q, valid := spatial.Quat{v[3], v[4], v[5], v[6]}.Normalize()
+		if !valid || math.IsNaN(dir[0]) {
+			continue
+		}
+		dirs[i] = q.Rotate(dir)
+		ok[i] = true
+	}
+	return dirs, ok
+}
+
+// angularVelocities is the angle in degrees per second between the world gaze directions of each
+// point's neighbouring valid samples (central difference, one-sided at the ends), like velocities
+func angularVelocities(points []types.DataPoint, dirs []spatial.Vec3, ok []bool) []float64 {
+	speeds := make([]float64, len(points))
+	for i := range speeds {
+		speeds[i] = math.NaN()
+	}
+
+	for _, idx := range types.RecordingIndices(points) {
+		var valid []int
+		for _, i := range idx {
+			if ok[i] {
+				valid = append(valid, i)
+			}
+		}
+		for k, i := range valid {
+			prev, next := i, i
+			if k > 0 {
+				prev = valid[k-1]
+			}
+			if k+1 < len(valid) {
+				next = valid[k+1]
+			}
+			dt := points[next].Timestamp - points[prev].Timestamp
+			if dt <= 0 {
+				continue
+			}
+			speeds[i] = spatial.AngleBetween(dirs[prev], dirs[next]) / dt
+		}
+	}
+	return speeds
+}
@@ -0,0 +1,219 @@
+package loader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// SR Research EyeLink ASC exports (converted EDF files). Sample and event times
+// are recorded in milliseconds and converted to seconds.
+
+func isASCPath(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".asc"
+}
+
+type ascLayout struct {
+	left, right bool
+	velocity    bool
+	resolution  bool
+}
+
+func (a ascLayout) columns() []string {
+	var cols []string
+	binocular := a.left && a.right
+	if binocular {
+		cols = append(cols, "left_gaze_x", "left_gaze_y", "left_pupil_size", "right_gaze_x", "right_gaze_y", "right_pupil_size")
+	} else {
+		cols = append(cols, "gaze_x", "gaze_y", "pupil_size")
+	}
+	if a.velocity {
+		if binocular {
+			cols = append(cols, "left_vel_x", "left_vel_y", "right_vel_x", "right_vel_y")
+		} else {
+			cols = append(cols, "vel_x", "vel_y")
+		}
+	}
+	if a.resolution {
+		cols = append(cols, "res_x", "res_y")
+	}
+	return cols
+}
+
+func (l *Loader) loadASCFile(filePath string) (*types.Dataset, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	baseName := filepath.Base(filePath)
+	participantID := strings.SplitN(baseName, "_", 2)[0]
+
+	layout := ascLayout{left: true}
+	sampleCols := layout.columns()
+	columns := []string{"timestamp"}
+	seen := make(map[string]bool)
+	addColumns := func(cols []string) {
+		for _, col := range cols {
+			if !seen[col] {
+				seen[col] = true
+				columns = append(columns, col)
+			}
+		}
+	}
+
+	var points []types.DataPoint
+	var events []types.Event
+
+	scanner := bufio.NewScanner(newDecodingReader(f))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "**") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		// Sample lines start with the timestamp
+		if c := line[0]; c >= '0' && c <= '9' {
+			ms, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sample time on line %d of file %s: %v", lineNum, filePath, err)
+			}
+
+			addColumns(sampleCols)
+			point := types.DataPoint{
+				Timestamp:     ms / 1000,
+				Data:          make(map[string]float64, len(sampleCols)),
+				ParticipantID: participantID,
+				Condition:     l.Condition,
+			}
+			for j, col := range sampleCols {
+				if j+1 >= len(fields) {
+					break
+				}
+				// "." marks missing data (e.g. during blinks); trailing flags are not numeric
+				if val, err := strconv.ParseFloat(fields[j+1], 64); err == nil {
+					point.Data[col] = val
+				}
+			}
+			points = append(points, point)
+			continue
+		}
+
+		switch fields[0] {
+		case "SAMPLES":
+			layout = ascLayout{}
+			for _, token := range fields[1:] {
+				switch token {
+				case "LEFT":
+					layout.left = true
+				case "RIGHT":
+					layout.right = true
+				case "VEL":
+					layout.velocity = true
+				case "RES":
+					layout.resolution = true
+				}
+			}
+			sampleCols = layout.columns()
+
+		case "MSG":
+			if len(fields) < 2 {
+				continue
+			}
+			ms, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid message time on line %d of file %s: %v", lineNum, filePath, err)
+			}
+			events = append(events, types.Event{
+				Type:          "MSG",
+				Start:         ms / 1000,
+				End:           ms / 1000,
+				Message:       strings.Join(fields[2:], " "),
+				ParticipantID: participantID,
+			})
+
+		case "EFIX", "ESACC", "EBLINK":
+			event, err := parseASCEvent(fields)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s event on line %d of file %s: %v", fields[0], lineNum, filePath, err)
+			}
+			event.ParticipantID = participantID
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ASC data: %v", err)
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("file %s has no samples", filePath)
+	}
+
+	return &types.Dataset{
+		Points:  points,
+		Columns: columns,
+		Events:  events,
+		Metadata: map[string]interface{}{
+			"source_format": "eyelink_asc",
+		},
+	}, nil
+}
+
+// parseASCEvent handles end-of-event lines, e.g. "EFIX L 1000 1250 251 512.3 384.1 1020"
+func parseASCEvent(fields []string) (types.Event, error) {
+	if len(fields) < 5 {
+		return types.Event{}, fmt.Errorf("expected at least 5 fields, got %d", len(fields))
+	}
+
+	start, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return types.Event{}, err
+	}
+	end, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return types.Event{}, err
+	}
+
+	event := types.Event{
+		Type:  fields[0],
+		Eye:   fields[1],
+		Start: start / 1000,
+		End:   end / 1000,
+		Data:  make(map[string]float64),
+	}
+
+	var names []string
+	switch fields[0] {
+	case "EFIX":
+		names = []string{"duration", "x", "y", "pupil_size"}
+	case "ESACC":
+		names = []string{"duration", "start_x", "start_y", "end_x", "end_y", "amplitude", "peak_velocity"}
+	case "EBLINK":
+		names = []string{"duration"}
+	}
+	for j, name := range names {
+		if j+4 >= len(fields) {
+			break
+		}
+		if val, err := strconv.ParseFloat(fields[j+4], 64); err == nil {
+			event.Data[name] = val
+		}
+	}
+	// Durations are in milliseconds like the timestamps
+	if d, ok := event.Data["duration"]; ok {
+		event.Data["duration"] = d / 1000
+	}
+
+	return event, nil
+}
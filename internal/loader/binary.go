@@ -0,0 +1,258 @@
+package loader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Binary layout (little endian):
+//
+//	magic "MBD1", uint32 version
+//	columns, participants, conditions: uint32 count followed by length-prefixed strings
+//	metadata, events: length-prefixed JSON
+//	uint64 point count
+//	records: float64 timestamp, uint32 participant index, uint32 condition index,
+//	         one float64 per data column (NaN = missing)
+const (
+	binaryMagic   = "MBD1"
+	binaryVersion = 1
+)
+
+func isBinaryPath(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".mbd"
+}
+
+func SaveDatasetBinary(dataset *types.Dataset, outputPath string) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	var dataCols []string
+	if len(dataset.Columns) > 0 {
+		dataCols = dataset.Columns[1:]
+	}
+
+	participants, participantIdx := indexLabels(dataset.Points, func(p types.DataPoint) string { return p.ParticipantID })
+	conditions, conditionIdx := indexLabels(dataset.Points, func(p types.DataPoint) string { return p.Condition })
+
+	metadata, err := json.Marshal(dataset.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %v", err)
+	}
+	events, err := json.Marshal(dataset.Events)
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %v", err)
+	}
+
+	w.WriteString(binaryMagic)
+	binary.Write(w, binary.LittleEndian, uint32(binaryVersion))
+	writeStrings(w, dataset.Columns)
+	writeStrings(w, participants)
+	writeStrings(w, conditions)
+	writeBytes(w, metadata)
+	writeBytes(w, events)
+	binary.Write(w, binary.LittleEndian, uint64(len(dataset.Points)))
+
+	record := make([]byte, 16+8*len(dataCols))
+	for _, point := range dataset.Points {
+		binary.LittleEndian.PutUint64(record[0:], math.Float64bits(point.Timestamp))
+		binary.LittleEndian.PutUint32(record[8:], participantIdx[point.ParticipantID])
+		binary.LittleEndian.PutUint32(record[12:], conditionIdx[point.Condition])
+		for i, col := range dataCols {
+			val, ok := point.Data[col]
+			if !ok {
+				val = math.NaN()
+			}
+			binary.LittleEndian.PutUint64(record[16+8*i:], math.Float64bits(val))
+		}
+		if _, err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write %s: %v", outputPath, err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", outputPath, err)
+	}
+	return f.Close()
+}
+
+func LoadDatasetBinary(filePath string) (*types.Dataset, error) {
+	l := &Loader{}
+	return l.loadBinaryFile(filePath)
+}
+
+func (l *Loader) loadBinaryFile(filePath string) (*types.Dataset, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	// Counts and lengths in a damaged header must not size allocations beyond what the file can hold
+	size := info.Size()
+
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != binaryMagic {
+		return nil, fmt.Errorf("file %s is not an mbd dataset", filePath)
+	}
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("failed to read header of %s: %v", filePath, err)
+	}
+	if version != binaryVersion {
+		return nil, fmt.Errorf("file %s has unsupported mbd version %d", filePath, version)
+	}
+
+	columns, err := readStrings(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %v", filePath, err)
+	}
+	participants, err := readStrings(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read participants of %s: %v", filePath, err)
+	}
+	conditions, err := readStrings(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conditions of %s: %v", filePath, err)
+	}
+	rawMeta, err := readBytes(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %v", filePath, err)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(rawMeta, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata of %s: %v", filePath, err)
+	}
+	rawEvents, err := readBytes(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events of %s: %v", filePath, err)
+	}
+	var events []types.Event
+	if err := json.Unmarshal(rawEvents, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode events of %s: %v", filePath, err)
+	}
+
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("failed to read point count of %s: %v", filePath, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("file %s has no columns", filePath)
+	}
+
+	dataCols := columns[1:]
+	record := make([]byte, 16+8*len(dataCols))
+	if count > uint64(size)/uint64(len(record)) {
+		return nil, fmt.Errorf("file %s is truncated: its header lists %d points", filePath, count)
+	}
+	points := make([]types.DataPoint, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, fmt.Errorf("failed to read record %d of %s: %v", i+1, filePath, err)
+		}
+
+		pIdx := binary.LittleEndian.Uint32(record[8:])
+		cIdx := binary.LittleEndian.Uint32(record[12:])
+		if int(pIdx) >= len(participants) || int(cIdx) >= len(conditions) {
+			return nil, fmt.Errorf("record %d of %s has an invalid label index", i+1, filePath)
+		}
+
+		point := types.DataPoint{
+			Timestamp:     math.Float64frombits(binary.LittleEndian.Uint64(record[0:])),
+			Data:          make(map[string]float64, len(dataCols)),
+			ParticipantID: participants[pIdx],
+			Condition:     conditions[cIdx],
+		}
+		if l.Condition != "" {
+			point.Condition = l.Condition
+		}
+		for j, col := range dataCols {
+			if val := math.Float64frombits(binary.LittleEndian.Uint64(record[16+8*j:])); !math.IsNaN(val) {
+				point.Data[col] = val
+			}
+		}
+		points = append(points, point)
+	}
+
+	return &types.Dataset{Points: points, Columns: columns, Metadata: metadata, Events: events}, nil
+}
+
+func indexLabels(points []types.DataPoint, label func(types.DataPoint) string) ([]string, map[string]uint32) {
+	labels := []string{}
+	index := make(map[string]uint32)
+	for _, p := range points {
+		l := label(p)
+		if _, ok := index[l]; !ok {
+			index[l] = uint32(len(labels))
+			labels = append(labels, l)
+		}
+	}
+	return labels, index
+}
+
+func writeStrings(w *bufio.Writer, values []string) {
+	binary.Write(w, binary.LittleEndian, uint32(len(values)))
+	for _, v := range values {
+		writeBytes(w, []byte(v))
+	}
+}
+
+func writeBytes(w *bufio.Writer, b []byte) {
+	binary.Write(w, binary.LittleEndian, uint32(len(b)))
+	w.Write(b)
+}
+
+// readStrings reads a count-prefixed list of strings from a file of size bytes
+func readStrings(r io.Reader, size int64) ([]string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	// Each string takes at least its 4-byte length
+	if int64(n) > size/4 {
+		return nil, fmt.Errorf("count %d exceeds the file size", n)
+	}
+	values := make([]string, 0, n)
+	for i := uint32(0); i < n; i++ {
+		b, err := readBytes(r, size)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, string(b))
+	}
+	return values, nil
+}
+
+// readBytes reads a length-prefixed byte string from a file of size bytes
+func readBytes(r io.Reader, size int64) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if int64(n) > size {
+		return nil, fmt.Errorf("length %d exceeds the file size", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
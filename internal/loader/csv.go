@@ -1,20 +1,41 @@
 package loader
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"mbdvr/internal/diag"
 	"mbdvr/internal/types"
 )
 
 type Loader struct {
-	Condition string
+	Condition   string
+	ColumnNames []string // Column names for headerless files (first is timestamp)
+	NoHeader    bool     // Treat the first row as data and generate col_0, col_1, ...
+	Dedupe      bool     // Sort by (participant, timestamp) and drop exact duplicate points
+
+	SelectColumns []string // Data columns to keep (timestamp is always kept); empty keeps all
+
+	SkipRows int // Metadata rows before the header; 0 auto-detects the header row
+
+	MaxRows     int // Stop after this many points in total (0 = no limit)
+	SampleEvery int // Keep every Nth data row of each file (0 or 1 = all rows)
+
+	SplitRows int     // Maximum data rows per saved CSV file (0 = no limit)
+	SplitMB   float64 // Maximum size in MB per saved CSV file (0 = no limit)
+
+	Diagnostics *diag.Collector // Receives the warnings raised while loading (nil = metadata only)
 }
 
+// Header auto-detection gives up after this many non-numeric rows
+const maxPreambleRows = 100
+
 func (l *Loader) LoadFiles(pattern string) (*types.Dataset, error) {
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
@@ -27,63 +48,243 @@ func (l *Loader) LoadFiles(pattern string) (*types.Dataset, error) {
 	fmt.Printf("Found %d files matching pattern %s\n", len(matches), pattern)
 
 	var allPoints []types.DataPoint
+	var allEvents []types.Event
 	var columns []string
+	var stored []diag.Diagnostic
+	metadata := make(map[string]interface{})
 
 	// Load each file and aggregate points
 	for _, file := range matches {
-		points, cols, err := l.loadSingleFile(file)
+		if l.MaxRows > 0 && len(allPoints) >= l.MaxRows {
+			fmt.Printf("Reached --max-rows %d, skipping remaining files\n", l.MaxRows)
+			break
+		}
+
+		fileData, err := l.loadFile(file)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load file %s: %v", file, err)
+			return nil, fmt.Errorf("failed to load file %s: %w", file, err)
 		}
 
 		// Set columns only once from the first file
 		if len(columns) == 0 {
-			columns = cols
+			columns = fileData.Columns
 		}
 
-		allPoints = append(allPoints, points...)
+		// Keep stored metadata from formats that carry it
+		stored = append(stored, diag.FromMetadata(fileData.Metadata)...)
+		for key, value := range fileData.Metadata {
+			if _, exists := metadata[key]; !exists {
+				metadata[key] = value
+			}
+		}
+
+		if l.MaxRows > 0 && len(allPoints)+len(fileData.Points) > l.MaxRows {
+			fileData.Points = fileData.Points[:l.MaxRows-len(allPoints)]
+		}
+
+		allPoints = append(allPoints, fileData.Points...)
+		allEvents = append(allEvents, fileData.Events...)
 	}
 
 	dataset := &types.Dataset{
-		Points:  allPoints,
-		Columns: columns,
-		Metadata: map[string]interface{}{
-			"total_files":  len(matches),
-			"total_points": len(allPoints),
-		},
+		Points:   allPoints,
+		Columns:  columns,
+		Metadata: metadata,
+		Events:   allEvents,
+	}
+
+	if l.Dedupe {
+		metadata["duplicates_removed"] = SortAndDedupe(dataset)
+	}
+
+	if l.MaxRows > 0 || l.SampleEvery > 1 {
+		metadata["max_rows"] = l.MaxRows
+		metadata["sample_every"] = l.SampleEvery
+	}
+
+	metadata["total_files"] = len(matches)
+	metadata["total_points"] = len(dataset.Points)
+	l.diagnose(dataset, pattern, stored)
+
+	return dataset, nil
+}
+
+// loadFile dispatches to the importer for the file's format
+func (l *Loader) loadFile(filePath string) (*types.Dataset, error) {
+	format, err := detectFormat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataset *types.Dataset
+	switch format.name {
+	case formatASC:
+		dataset, err = l.loadASCFile(filePath)
+	case formatSQLite:
+		dataset, err = l.loadSQLiteFile(filePath)
+	case formatBinary:
+		dataset, err = l.loadBinaryFile(filePath)
+	case formatJSONLines:
+		dataset, err = l.loadJSONLinesFile(filePath)
+	default:
+		points, columns, err := l.loadSingleFile(filePath, format.delimiter)
+		if err != nil {
+			return nil, err
+		}
+		return &types.Dataset{Points: points, Columns: columns}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.selectDatasetColumns(dataset); err != nil {
+		return nil, err
 	}
+	dataset.Points = l.samplePoints(dataset.Points)
+	return dataset, nil
+}
+
+// samplePoints keeps every SampleEvery-th point for formats that are not decimated while parsing
+func (l *Loader) samplePoints(points []types.DataPoint) []types.DataPoint {
+	if l.SampleEvery <= 1 {
+		return points
+	}
+	sampled := make([]types.DataPoint, 0, len(points)/l.SampleEvery+1)
+	for i := 0; i < len(points); i += l.SampleEvery {
+		sampled = append(sampled, points[i])
+	}
+	return sampled
+}
+
+// LoadReader loads delimited text from in, for callers without a file system such as the WebAssembly
+// build. name stands in for the file name, which gives the participant ID.
+func (l *Loader) LoadReader(in io.Reader, name string) (*types.Dataset, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	head := data
+	if len(head) > sniffBytes {
+		head = head[:sniffBytes]
+	}
+	decoded, _ := io.ReadAll(newDecodingReader(bytes.NewReader(head)))
 
+	points, columns, err := l.readDelimited(bytes.NewReader(data), name, sniffDelimiter(strings.TrimSpace(string(decoded))))
+	if err != nil {
+		return nil, err
+	}
+	dataset := &types.Dataset{Points: points, Columns: columns, Metadata: make(map[string]interface{})}
+	if l.Dedupe {
+		dataset.Metadata["duplicates_removed"] = SortAndDedupe(dataset)
+	}
+	dataset.Metadata["total_points"] = len(dataset.Points)
+	l.diagnose(dataset, name, nil)
 	return dataset, nil
 }
 
-func (l *Loader) loadSingleFile(filePath string) ([]types.DataPoint, []string, error) {
+func (l *Loader) loadSingleFile(filePath string, delimiter rune) ([]types.DataPoint, []string, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open file: %v", err)
 	}
 	defer f.Close()
+	return l.readDelimited(f, filePath, delimiter)
+}
 
-	r := csv.NewReader(f)
-	records, err := r.ReadAll()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read CSV data: %v", err)
+// readDelimited parses delimited text; filePath names the source in errors and gives the participant ID
+func (l *Loader) readDelimited(in io.Reader, filePath string, delimiter rune) ([]types.DataPoint, []string, error) {
+	// Rows are parsed one at a time so only the selected values are kept in memory
+	r := csv.NewReader(newDecodingReader(in))
+	r.Comma = delimiter
+	r.ReuseRecord = true
+	// Metadata rows before the header have their own widths; data rows are checked against the header below
+	r.FieldsPerRecord = -1
+
+	headerless := l.NoHeader || len(l.ColumnNames) > 0
+	// Semicolon- and tab-separated exports from European locales use decimal commas; a comma in a
+	// number can only be a decimal comma when commas don't separate the fields
+	decimalComma := delimiter != ','
+
+	rowNum := 0
+	for ; rowNum < l.SkipRows; rowNum++ {
+		if _, err := r.Read(); err != nil {
+			return nil, nil, fmt.Errorf("file %s has fewer than %d rows to skip", filePath, l.SkipRows)
+		}
 	}
 
-	if len(records) < 2 {
+	first, err := r.Read()
+	if err == io.EOF {
 		return nil, nil, fmt.Errorf("file %s has insufficient data", filePath)
 	}
-
-	headerRowIdx := 0
-	dataStartIdx := 1
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV data: %v", err)
+	}
+	rowNum++
 
 	// Extract headers
-	headers := records[headerRowIdx]
+	var headers []string
+	var pending []string
+	if headerless {
+		headers = l.ColumnNames
+		if len(headers) == 0 {
+			headers = generateColumnNames(len(first))
+		}
+		pending = append([]string(nil), first...)
+	} else {
+		headers = append([]string(nil), first...)
+		if l.SkipRows == 0 {
+			// Auto-detect the header: the last row before the first numeric timestamp
+			for skipped := 0; ; skipped++ {
+				row, err := r.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to read CSV data: %v", err)
+				}
+				rowNum++
+				if _, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(row[0]), ",", ".", 1), 64); err == nil {
+					if skipped > 0 {
+						fmt.Printf("Skipped %d metadata rows before the header in %s\n", skipped, filePath)
+					}
+					pending = append([]string(nil), row...)
+					break
+				}
+				if skipped == maxPreambleRows {
+					return nil, nil, fmt.Errorf("no data rows found in the first %d rows of file %s (use --skip-rows)", maxPreambleRows, filePath)
+				}
+				headers = append(headers[:0], row...)
+			}
+		}
+	}
 	if len(headers) < 2 {
 		return nil, nil, fmt.Errorf("file %s has insufficient columns", filePath)
 	}
 
-	// Assume first column is timestamp, rest are data columns
-	dataCols := headers[1:]
+	selected, err := l.selectedColumns(headers[1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v in file %s", err, filePath)
+	}
+
+	// Assume first column is timestamp, rest are data columns.
+	// participant_id and condition columns written by SaveDatasetAsCSV are restored as labels.
+	participantIdx, conditionIdx := -1, -1
+	columns := []string{headers[0]}
+	var dataIdx []int
+	for j := 1; j < len(headers); j++ {
+		switch headers[j] {
+		case "participant_id":
+			participantIdx = j
+		case "condition":
+			conditionIdx = j
+		default:
+			if selected != nil && !selected[headers[j]] {
+				continue
+			}
+			columns = append(columns, headers[j])
+			dataIdx = append(dataIdx, j)
+		}
+	}
 
 	var points []types.DataPoint
 
@@ -92,77 +293,210 @@ func (l *Loader) loadSingleFile(filePath string) ([]types.DataPoint, []string, e
 	participantID := strings.SplitN(baseName, "_", 2)[0]
 
 	// Parse data rows
-	for i, row := range records[dataStartIdx:] {
+	dataRow := 0
+	for {
+		if l.MaxRows > 0 && len(points) >= l.MaxRows {
+			break
+		}
+
+		var row []string
+		if pending != nil {
+			row, pending = pending, nil
+		} else {
+			row, err = r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read CSV data: %v", err)
+			}
+			rowNum++
+		}
+
 		if len(row) != len(headers) {
-			return nil, nil, fmt.Errorf("row %d in file %s has incorrect number of columns", i+dataStartIdx+1, filePath)
+			return nil, nil, fmt.Errorf("row %d in file %s has incorrect number of columns", rowNum, filePath)
+		}
+
+		// Decimate while parsing so skipped rows are never converted
+		dataRow++
+		if l.SampleEvery > 1 && (dataRow-1)%l.SampleEvery != 0 {
+			continue
 		}
 
-		timestamp, err := strconv.ParseFloat(row[0], 64)
+		tsStr := row[0]
+		if decimalComma {
+			tsStr = strings.Replace(tsStr, ",", ".", 1)
+		}
+		timestamp, err := strconv.ParseFloat(tsStr, 64)
 		if err != nil {
-			return nil, nil, fmt.Errorf("invalid timestamp in row %d of file %s: %v", i+dataStartIdx+1, filePath, err)
+			return nil, nil, fmt.Errorf("invalid timestamp in row %d of file %s: %v", rowNum, filePath, err)
 		}
 
 		point := types.DataPoint{
 			Timestamp:     timestamp,
-			Data:          make(map[string]float64),
+			Data:          make(map[string]float64, len(dataIdx)),
 			ParticipantID: participantID,
 			Condition:     l.Condition,
 		}
 
+		if participantIdx >= 0 && row[participantIdx] != "" {
+			point.ParticipantID = row[participantIdx]
+		}
+		// An explicit --condition overrides the stored one
+		if conditionIdx >= 0 && l.Condition == "" {
+			point.Condition = row[conditionIdx]
+		}
+
 		//Convert all data columns to float64 if possible
-		for j, col := range dataCols {
-			if valStr := row[j+1]; valStr != "" {
+		for _, j := range dataIdx {
+			if valStr := row[j]; valStr != "" {
+				if decimalComma {
+					valStr = strings.Replace(valStr, ",", ".", 1)
+				}
 				val, err := strconv.ParseFloat(valStr, 64)
 				if err != nil {
-					return nil, nil, fmt.Errorf("invalid data value in row %d, column %s of file %s: %v", i+dataStartIdx+1, col, filePath, err)
+					return nil, nil, &ValueError{File: filePath, Row: rowNum, Column: headers[j], Value: valStr, Columns: columns[1:], Err: err}
 				}
-				point.Data[col] = val
+				point.Data[headers[j]] = val
 			}
 		}
 
 		points = append(points, point)
 	}
 
-	return points, headers, nil
+	if len(points) == 0 {
+		return nil, nil, fmt.Errorf("file %s has insufficient data", filePath)
+	}
+
+	return points, columns, nil
 }
 
-func (l *Loader) SaveDatasetAsCSV(dataset *types.Dataset, outputPath string) error {
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+// ValueError is a data value that isn't a number, such as a text label in a numeric export
+type ValueError struct {
+	File    string
+	Row     int
+	Column  string
+	Value   string
+	Columns []string // The data columns being loaded, to suggest a --select-columns without Column
+	Err     error
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid data value in row %d, column %s of file %s: %v", e.Row, e.Column, e.File, e.Err)
+}
+
+// selectedColumns returns the set of requested data columns, or nil to keep all of them
+func (l *Loader) selectedColumns(available []string) (map[string]bool, error) {
+	if len(l.SelectColumns) == 0 {
+		return nil, nil
 	}
-	defer f.Close()
 
-	w := csv.NewWriter(f)
-	defer w.Flush()
+	exists := make(map[string]bool, len(available))
+	for _, col := range available {
+		exists[col] = true
+	}
 
-	// Write header
-	header := append([]string{"timestamp", "participant_id", "condition"}, dataset.Columns...)
+	selected := make(map[string]bool, len(l.SelectColumns))
+	for _, col := range l.SelectColumns {
+		if !exists[col] {
+			return nil, fmt.Errorf("selected column %q not found", col)
+		}
+		selected[col] = true
+	}
+	return selected, nil
+}
 
-	//Skip first column from dataset.Columns if it's timestamp
-	if len(dataset.Columns) > 0 && dataset.Columns[0] == "timestamp" {
-		header = append([]string{"timestamp", "participant_id", "condition"}, dataset.Columns[1:]...)
+// selectDatasetColumns drops unselected data columns from formats that are not parsed column by column
+func (l *Loader) selectDatasetColumns(dataset *types.Dataset) error {
+	if len(l.SelectColumns) == 0 || len(dataset.Columns) == 0 {
+		return nil
 	}
 
-	w.Write(header)
+	selected, err := l.selectedColumns(dataset.Columns[1:])
+	if err != nil {
+		return err
+	}
 
-	// Write data points
+	columns := []string{dataset.Columns[0]}
+	for _, col := range dataset.Columns[1:] {
+		if selected[col] {
+			columns = append(columns, col)
+		}
+	}
 	for _, point := range dataset.Points {
-		row := make([]string, len(header))
-		row[0] = fmt.Sprintf("%f", point.Timestamp)
-		row[1] = point.ParticipantID
-		row[2] = point.Condition
-
-		for i, col := range dataset.Columns[1:] { // Skip timestamp column
-			if val, ok := point.Data[col]; ok {
-				row[i+3] = fmt.Sprintf("%f", val)
-			} else {
-				row[i+3] = ""
+		for col := range point.Data {
+			if !selected[col] {
+				delete(point.Data, col)
 			}
 		}
+	}
+	dataset.Columns = columns
+	return nil
+}
+
+func generateColumnNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("col_%d", i)
+	}
+	return names
+}
+
+// SaveDataset picks the output format from the file extension (.db/.sqlite for SQLite, .mbd for binary, CSV otherwise)
+func (l *Loader) SaveDataset(dataset *types.Dataset, outputPath string) error {
+	if (l.SplitRows > 0 || l.SplitMB > 0) && (isSQLitePath(outputPath) || isBinaryPath(outputPath)) {
+		return fmt.Errorf("splitting output into multiple files is only supported for CSV")
+	}
 
+	switch {
+	case isSQLitePath(outputPath):
+		return SaveDatasetSQLite(dataset, outputPath)
+	case isBinaryPath(outputPath):
+		return SaveDatasetBinary(dataset, outputPath)
+	default:
+		return l.SaveDatasetAsCSV(dataset, outputPath)
+	}
+}
+
+// WriteCSV writes the dataset to out in the layout of SaveDatasetAsCSV
+func WriteCSV(dataset *types.Dataset, out io.Writer) error {
+	var columns []string
+	if len(dataset.Columns) > 0 {
+		columns = dataset.Columns[1:]
+	}
+	w := csv.NewWriter(out)
+	w.Write(append([]string{"timestamp", "participant_id", "condition"}, columns...))
+	row := make([]string, len(columns)+3)
+	for _, point := range dataset.Points {
+		formatRow(row, columns, point)
 		w.Write(row)
 	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV data: %v", err)
+	}
+	return nil
+}
+
+func (l *Loader) SaveDatasetAsCSV(dataset *types.Dataset, outputPath string) error {
+	w, err := NewSplitWriter(outputPath, dataset.Columns, l.SplitRows, int64(l.SplitMB*1024*1024))
+	if err != nil {
+		return err
+	}
+
+	// Write data points
+	for _, point := range dataset.Points {
+		if err := w.WritePoint(point); err != nil {
+			w.Close()
+			return err
+		}
+	}
 
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if paths := w.Paths(); l.SplitRows > 0 || l.SplitMB > 0 {
+		fmt.Printf("Split output into %d files: %s ... %s\n", len(paths), paths[0], paths[len(paths)-1])
+	}
 	return nil
 }
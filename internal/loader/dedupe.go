@@ -0,0 +1,62 @@
+package loader
+
+import (
+	"math"
+	"sort"
+
+	"mbdvr/internal/types"
+)
+
+// SortAndDedupe orders points by participant and timestamp and drops exact duplicates
+// (same participant, condition, timestamp and values), as produced by overlapping
+// exports of the same session. It returns the number of points removed.
+func SortAndDedupe(dataset *types.Dataset) int {
+	points := dataset.Points
+	sort.SliceStable(points, func(i, j int) bool {
+		if points[i].ParticipantID != points[j].ParticipantID {
+			return points[i].ParticipantID < points[j].ParticipantID
+		}
+		return points[i].Timestamp < points[j].Timestamp
+	})
+
+	kept := points[:0]
+	removed := 0
+	for _, point := range points {
+		duplicate := false
+		// Duplicates share a timestamp, so only the run of equal timestamps needs checking
+		for j := len(kept) - 1; j >= 0; j-- {
+			prev := kept[j]
+			if prev.ParticipantID != point.ParticipantID || prev.Timestamp != point.Timestamp {
+				break
+			}
+			if prev.Condition == point.Condition && sameValues(prev.Data, point.Data) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			removed++
+			continue
+		}
+		kept = append(kept, point)
+	}
+
+	dataset.Points = kept
+	return removed
+}
+
+func sameValues(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, va := range a {
+		vb, ok := b[key]
+		if !ok {
+			return false
+		}
+		if va != vb && !(math.IsNaN(va) && math.IsNaN(vb)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// newDecodingReader strips a byte order mark and transcodes UTF-16 input to UTF-8
+func newDecodingReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(3)
+
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		br.Discard(len(bomUTF8))
+		return br
+	case bytes.HasPrefix(head, bomUTF16LE):
+		br.Discard(len(bomUTF16LE))
+		return &utf16Reader{r: br, order: binary.LittleEndian}
+	case bytes.HasPrefix(head, bomUTF16BE):
+		br.Discard(len(bomUTF16BE))
+		return &utf16Reader{r: br, order: binary.BigEndian}
+	}
+
+	return br
+}
+
+type utf16Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   []byte // Encoded UTF-8 not yet returned to the caller
+	err   error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.fill()
+	}
+
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+func (u *utf16Reader) fill() {
+	var unit [2]byte
+	for i := 0; i < 512; i++ {
+		if _, err := io.ReadFull(u.r, unit[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				u.buf = utf8.AppendRune(u.buf, utf8.RuneError)
+				err = io.EOF
+			}
+			u.err = err
+			return
+		}
+
+		r := rune(u.order.Uint16(unit[:]))
+		if utf16.IsSurrogate(r) {
+			var low [2]byte
+			if _, err := io.ReadFull(u.r, low[:]); err != nil {
+				u.buf = utf8.AppendRune(u.buf, utf8.RuneError)
+				u.err = io.EOF
+				return
+			}
+			r = utf16.DecodeRune(r, rune(u.order.Uint16(low[:])))
+		}
+		u.buf = utf8.AppendRune(u.buf, r)
+	}
+}
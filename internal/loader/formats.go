@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported input formats, picked by detectFormat
+const (
+	formatDelimited = "delimited" // CSV, TSV and semicolon-separated text
+	formatJSONLines = "jsonl"
+	formatASC       = "asc"
+	formatSQLite    = "sqlite"
+	formatBinary    = "mbd"
+)
+
+type fileFormat struct {
+	name      string
+	delimiter rune // Field separator for delimited text
+}
+
+const sniffBytes = 8192
+
+func isSQLitePath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".db" || ext == ".sqlite" || ext == ".sqlite3"
+}
+
+// detectFormat picks an importer from the extension, falling back to the file header for
+// unknown extensions. The delimiter of text files is always sniffed, so semicolon CSVs work too.
+func detectFormat(filePath string) (fileFormat, error) {
+	switch ext := strings.ToLower(filepath.Ext(filePath)); {
+	case isASCPath(filePath):
+		return fileFormat{name: formatASC}, nil
+	case isSQLitePath(filePath):
+		return fileFormat{name: formatSQLite}, nil
+	case isBinaryPath(filePath):
+		return fileFormat{name: formatBinary}, nil
+	case ext == ".jsonl" || ext == ".ndjson":
+		return fileFormat{name: formatJSONLines}, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fileFormat{}, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	raw := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, raw)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fileFormat{}, fmt.Errorf("failed to read file: %v", err)
+	}
+	raw = raw[:n]
+
+	switch {
+	case bytes.HasPrefix(raw, []byte("SQLite format 3\x00")):
+		return fileFormat{name: formatSQLite}, nil
+	case bytes.HasPrefix(raw, []byte(binaryMagic)):
+		return fileFormat{name: formatBinary}, nil
+	}
+
+	decoded, _ := io.ReadAll(newDecodingReader(bytes.NewReader(raw)))
+	text := strings.TrimSpace(string(decoded))
+	switch {
+	case strings.HasPrefix(text, "{"):
+		return fileFormat{name: formatJSONLines}, nil
+	case strings.HasPrefix(text, "** CONVERTED FROM") || strings.HasPrefix(text, "** SR RESEARCH"):
+		return fileFormat{name: formatASC}, nil
+	}
+
+	return fileFormat{name: formatDelimited, delimiter: sniffDelimiter(text)}, nil
+}
+
+// sniffDelimiter picks the separator that occurs most consistently in the first lines
+func sniffDelimiter(text string) rune {
+	lines := strings.Split(text, "\n")
+	// The last line may have been cut off by the sniff buffer
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+
+	// A separator found the same number of times on every line wins. Commas come last, since
+	// semicolon- and tab-separated files often use decimal commas, which can be just as regular.
+	for _, delim := range []rune{';', '\t', '|', ','} {
+		count := strings.Count(lines[0], string(delim))
+		consistent := count > 0
+		for _, line := range lines[1:] {
+			consistent = consistent && strings.Count(line, string(delim)) == count
+		}
+		if consistent {
+			return delim
+		}
+	}
+
+	best, bestScore := ',', 0
+	for _, delim := range []rune{',', '\t', ';', '|'} {
+		// Score by the smallest count on the last sniffed lines, which are past any metadata preamble
+		score := -1
+		for _, line := range lines {
+			c := strings.Count(line, string(delim))
+			if score < 0 || c < score {
+				score = c
+			}
+		}
+		if score > bestScore {
+			best, bestScore = delim, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,115 @@
+package loader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"mbdvr/internal/diag"
+	"mbdvr/internal/types"
+)
+
+func DetectUnitIssues(dataset *types.Dataset) []string {
+	var warnings []string
+	for _, d := range unitDiagnostics(dataset, "") {
+		warnings = append(warnings, d.Message)
+	}
+	return warnings
+}
+
+// diagnose records the unit and missingness warnings of a loaded dataset in its metadata and in
+// l.Diagnostics. Data saved with diagnostics by an earlier command was checked when it was first
+// loaded, so the stored diagnostics are passed on instead.
+func (l *Loader) diagnose(dataset *types.Dataset, source string, stored []diag.Diagnostic) {
+	found := &diag.Collector{}
+	found.Merge(stored)
+	var units []diag.Diagnostic
+	if len(stored) == 0 {
+		units = unitDiagnostics(dataset, source)
+		found.Merge(units)
+		if len(dataset.Columns) > 1 {
+			found.CheckMissing("load", source, dataset.Points, dataset.Columns[1:])
+		}
+	}
+
+	if len(units) > 0 {
+		warnings := make([]string, len(units))
+		for i, d := range units {
+			warnings[i] = d.Message
+		}
+		dataset.Metadata["unit_warnings"] = warnings
+	}
+	if found.Len() > 0 {
+		dataset.Metadata[diag.MetadataKey] = found.Diagnostics()
+	}
+	l.Diagnostics.Merge(found.Diagnostics())
+}
+
+func unitDiagnostics(dataset *types.Dataset, source string) []diag.Diagnostic {
+	if dataset == nil || len(dataset.Points) < 2 {
+		return nil
+	}
+
+	var warnings []diag.Diagnostic
+	warn := func(column, format string, args ...interface{}) {
+		warnings = append(warnings, diag.Diagnostic{Stage: "load", Kind: diag.Units, Source: source, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Median sample interval, computed per participant so merged files don't skew it
+	var deltas []float64
+	for i := 1; i < len(dataset.Points); i++ {
+		prev, cur := dataset.Points[i-1], dataset.Points[i]
+		if prev.ParticipantID != cur.ParticipantID {
+			continue
+		}
+		if d := cur.Timestamp - prev.Timestamp; d > 0 {
+			deltas = append(deltas, d)
+		}
+	}
+	if len(deltas) > 0 {
+		interval := median(deltas)
+		// Decimated loads space samples further apart
+		if n, ok := dataset.Metadata["sample_every"].(int); ok && n > 1 {
+			interval /= float64(n)
+		}
+		switch {
+		case interval >= 1000:
+			warn("", "median timestamp step is %.0f; timestamps look like microseconds, expected seconds", interval)
+		case interval >= 1:
+			warn("", "median timestamp step is %.3f; timestamps look like milliseconds, expected seconds", interval)
+		}
+	}
+
+	// Pupil diameter is expected in millimetres (roughly 1.5-9)
+	for _, col := range dataset.Columns {
+		if !strings.Contains(strings.ToLower(col), "pupil") {
+			continue
+		}
+		var values []float64
+		for _, p := range dataset.Points {
+			if val, ok := p.Data[col]; ok && !math.IsNaN(val) {
+				values = append(values, val)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if m := median(values); m >= 100 {
+			warn(col, "column %s has median %.1f; pupil values in the hundreds/thousands suggest micrometres or pixels, expected millimetres", col, m)
+		}
+	}
+
+	return warnings
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
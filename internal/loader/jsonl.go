@@ -0,0 +1,147 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// JSON lines: one object per line, e.g. {"timestamp": 0.016, "gaze_x": 512.3, "participant_id": "P001"}.
+// The timestamp is the "timestamp" key, or the first key of the first object.
+
+func (l *Loader) loadJSONLinesFile(filePath string) (*types.Dataset, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	baseName := filepath.Base(filePath)
+	participantID := strings.SplitN(baseName, "_", 2)[0]
+
+	var columns []string
+	seen := make(map[string]bool)
+	var points []types.DataPoint
+
+	scanner := bufio.NewScanner(newDecodingReader(f))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		keys, err := objectKeys(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON on line %d of file %s: %v", lineNum, filePath, err)
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("invalid JSON on line %d of file %s: %v", lineNum, filePath, err)
+		}
+
+		if columns == nil {
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("line %d of file %s has no fields", lineNum, filePath)
+			}
+			timestampKey := keys[0]
+			if _, ok := record["timestamp"]; ok {
+				timestampKey = "timestamp"
+			}
+			columns = []string{timestampKey}
+			seen[timestampKey] = true
+		}
+		for _, key := range keys {
+			if !seen[key] && key != "participant_id" && key != "condition" {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+
+		timestamp, ok := jsonNumber(record[columns[0]])
+		if !ok {
+			return nil, fmt.Errorf("invalid timestamp on line %d of file %s", lineNum, filePath)
+		}
+
+		point := types.DataPoint{
+			Timestamp:     timestamp,
+			Data:          make(map[string]float64, len(record)),
+			ParticipantID: participantID,
+			Condition:     l.Condition,
+		}
+		if id, ok := record["participant_id"].(string); ok && id != "" {
+			point.ParticipantID = id
+		}
+		if condition, ok := record["condition"].(string); ok && l.Condition == "" {
+			point.Condition = condition
+		}
+		for _, col := range columns[1:] {
+			if val, ok := jsonNumber(record[col]); ok {
+				point.Data[col] = val
+			}
+		}
+		points = append(points, point)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSON lines: %v", err)
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("file %s has insufficient data", filePath)
+	}
+
+	return &types.Dataset{Points: points, Columns: columns}, nil
+}
+
+// objectKeys returns the top-level keys of a JSON object in document order
+func objectKeys(data []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+		// Skip the value, whatever its type
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// jsonNumber converts numbers, booleans and numeric strings; null and other values are missing
+func jsonNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(val, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,132 @@
+package loader
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+
+	"mbdvr/internal/diag"
+	"mbdvr/internal/types"
+)
+
+// PreviewConfig draws a small random sample of a study for trying out analysis code before a full run
+type PreviewConfig struct {
+	Size        int   // Points kept per stratum
+	Seed        int64 // Random seed; the same seed and files give the same preview
+	ByCondition bool  // Stratify by participant and condition instead of by participant alone
+}
+
+// previewSample is a sampled point and its position in the full load, to restore the original order
+type previewSample struct {
+	seq   int
+	point types.DataPoint
+}
+
+// LoadPreview loads the files matching pattern one at a time and keeps a uniform random sample
+// (reservoir sampling) of up to Size points from each participant, or each participant and condition,
+// so every stratum is represented however unevenly the study is split. Memory is bounded by the
+// largest file plus the samples. The sampled points keep their original order, Dedupe applies to the
+// sample and the sampling is recorded in the metadata. Sampling breaks up the time series, so
+// sample-to-sample measures such as velocities are not meaningful on a preview.
+func (l *Loader) LoadPreview(pattern string, config PreviewConfig) (*types.Dataset, error) {
+	if config.Size <= 0 {
+		return nil, fmt.Errorf("preview size must be positive")
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find files matching pattern %s: %v", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files found matching pattern %s", pattern)
+	}
+
+	fmt.Printf("Found %d files matching pattern %s\n", len(matches), pattern)
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	reservoirs := make(map[string][]previewSample)
+	seen := make(map[string]int)
+	var strata []string
+	var columns []string
+	var events []types.Event
+	var stored []diag.Diagnostic
+	metadata := make(map[string]interface{})
+	total := 0
+
+	for _, file := range matches {
+		fileData, err := l.loadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load file %s: %v", file, err)
+		}
+		if len(columns) == 0 {
+			columns = fileData.Columns
+		}
+		stored = append(stored, diag.FromMetadata(fileData.Metadata)...)
+		for key, value := range fileData.Metadata {
+			if _, exists := metadata[key]; !exists {
+				metadata[key] = value
+			}
+		}
+		events = append(events, fileData.Events...)
+
+		for _, p := range fileData.Points {
+			key := p.ParticipantID
+			if config.ByCondition {
+				key += "\x00" + p.Condition
+			}
+			n, ok := seen[key]
+			if !ok {
+				strata = append(strata, key)
+			}
+			seen[key] = n + 1
+			sample := previewSample{seq: total, point: p}
+			total++
+
+			if n < config.Size {
+				reservoirs[key] = append(reservoirs[key], sample)
+			} else if j := rng.Intn(n + 1); j < config.Size {
+				reservoirs[key][j] = sample
+			}
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no data points found matching pattern %s", pattern)
+	}
+
+	var samples []previewSample
+	for _, key := range strata {
+		samples = append(samples, reservoirs[key]...)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].seq < samples[j].seq })
+	points := make([]types.DataPoint, len(samples))
+	for i, s := range samples {
+		points[i] = s.point
+	}
+
+	stratifiedBy := "participant"
+	if config.ByCondition {
+		stratifiedBy = "participant_condition"
+	}
+	dataset := &types.Dataset{
+		Points:   points,
+		Columns:  columns,
+		Metadata: metadata,
+		Events:   events,
+	}
+	if l.Dedupe {
+		metadata["duplicates_removed"] = SortAndDedupe(dataset)
+	}
+
+	metadata["preview"] = map[string]interface{}{
+		"size":          config.Size,
+		"seed":          config.Seed,
+		"stratified_by": stratifiedBy,
+		"strata":        len(strata),
+		"source_points": total,
+	}
+	metadata["total_files"] = len(matches)
+	metadata["total_points"] = len(dataset.Points)
+	l.diagnose(dataset, pattern, stored)
+
+	return dataset, nil
+}
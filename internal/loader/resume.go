@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProgressPath is where LoadFilesResumable records completed input files for outputPath
+func ProgressPath(outputPath string) string {
+	return outputPath + ".progress"
+}
+
+// LoadFilesResumable loads the files matching pattern one at a time, appending each to the CSV at
+// outputPath and recording it in the progress file. When resume is set and a progress file exists,
+// files completed by the previous run are skipped and their rows kept. The progress file is removed
+// once every file has been loaded. It returns the number of points written by this run.
+func (l *Loader) LoadFilesResumable(pattern, outputPath string, resume bool) (int, error) {
+	if isSQLitePath(outputPath) || isBinaryPath(outputPath) {
+		return 0, fmt.Errorf("resumable loading is only supported for CSV output")
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find files matching pattern %s: %v", pattern, err)
+	}
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no files found matching pattern %s", pattern)
+	}
+
+	fmt.Printf("Found %d files matching pattern %s\n", len(matches), pattern)
+
+	progressPath := ProgressPath(outputPath)
+	completed := make(map[string]bool)
+	if resume {
+		completed, err = readProgress(progressPath)
+		if err != nil {
+			return 0, err
+		}
+		if len(completed) > 0 {
+			fmt.Printf("Resuming: %d of %d files already loaded into %s\n", len(completed), len(matches), outputPath)
+		}
+	}
+
+	progress, err := os.OpenFile(progressPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open progress file: %v", err)
+	}
+	defer progress.Close()
+	if len(completed) == 0 {
+		if err := progress.Truncate(0); err != nil {
+			return 0, fmt.Errorf("failed to reset progress file: %v", err)
+		}
+	}
+
+	var w *Writer
+	written := 0
+	for i, file := range matches {
+		if completed[file] {
+			continue
+		}
+
+		fileData, err := l.loadFile(file)
+		if err != nil {
+			if w != nil {
+				w.Close()
+			}
+			return written, fmt.Errorf("failed to load file %s (%d of %d): %v; fix it and rerun with --resume to continue from this file", file, i+1, len(matches), err)
+		}
+
+		if w == nil {
+			if len(completed) > 0 {
+				w, err = NewAppendWriter(outputPath, fileData.Columns)
+			} else {
+				w, err = NewWriter(outputPath, fileData.Columns)
+			}
+			if err != nil {
+				return written, err
+			}
+		}
+
+		for _, point := range fileData.Points {
+			if err := w.WritePoint(point); err != nil {
+				w.Close()
+				return written, err
+			}
+		}
+		// Rows must be on disk before the file is marked as done
+		if err := w.Flush(); err != nil {
+			w.Close()
+			return written, err
+		}
+		if _, err := fmt.Fprintln(progress, file); err != nil {
+			w.Close()
+			return written, fmt.Errorf("failed to record progress: %v", err)
+		}
+		written += len(fileData.Points)
+	}
+
+	if w != nil {
+		if err := w.Close(); err != nil {
+			return written, err
+		}
+	}
+
+	progress.Close()
+	if err := os.Remove(progressPath); err != nil {
+		return written, fmt.Errorf("failed to remove progress file: %v", err)
+	}
+	return written, nil
+}
+
+func readProgress(progressPath string) (map[string]bool, error) {
+	completed := make(map[string]bool)
+
+	f, err := os.Open(progressPath)
+	if os.IsNotExist(err) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			completed[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read progress file: %v", err)
+	}
+	return completed, nil
+}
@@ -0,0 +1,237 @@
+//go:build !(js && wasm)
+
+package loader
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"mbdvr/internal/types"
+)
+
+// SaveDatasetSQLite stores the dataset in points, columns, metadata and events tables, replacing any existing file
+func SaveDatasetSQLite(dataset *types.Dataset, outputPath string) error {
+	if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %v", outputPath, err)
+	}
+
+	db, err := sql.Open("sqlite", outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	var dataCols []string
+	if len(dataset.Columns) > 0 {
+		dataCols = dataset.Columns[1:]
+	}
+
+	colDefs := []string{"id INTEGER PRIMARY KEY", "timestamp REAL NOT NULL", "participant_id TEXT", "condition TEXT"}
+	for _, col := range dataCols {
+		colDefs = append(colDefs, quoteIdent(col)+" REAL")
+	}
+
+	schema := []string{
+		"CREATE TABLE columns (position INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE metadata (key TEXT PRIMARY KEY, value TEXT)",
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, type TEXT, start REAL, end REAL, eye TEXT, message TEXT, data TEXT, participant_id TEXT)",
+		"CREATE TABLE points (" + strings.Join(colDefs, ", ") + ")",
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create schema: %v", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for i, col := range dataset.Columns {
+		if _, err := tx.Exec("INSERT INTO columns (position, name) VALUES (?, ?)", i, col); err != nil {
+			return fmt.Errorf("failed to write columns: %v", err)
+		}
+	}
+
+	for key, value := range dataset.Metadata {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata %s: %v", key, err)
+		}
+		if _, err := tx.Exec("INSERT INTO metadata (key, value) VALUES (?, ?)", key, string(encoded)); err != nil {
+			return fmt.Errorf("failed to write metadata: %v", err)
+		}
+	}
+
+	for _, event := range dataset.Events {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode event data: %v", err)
+		}
+		if _, err := tx.Exec("INSERT INTO events (type, start, end, eye, message, data, participant_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			event.Type, event.Start, event.End, event.Eye, event.Message, string(data), event.ParticipantID); err != nil {
+			return fmt.Errorf("failed to write events: %v", err)
+		}
+	}
+
+	insertCols := []string{"timestamp", "participant_id", "condition"}
+	for _, col := range dataCols {
+		insertCols = append(insertCols, quoteIdent(col))
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(insertCols)), ", ")
+	stmt, err := tx.Prepare("INSERT INTO points (" + strings.Join(insertCols, ", ") + ") VALUES (" + placeholders + ")")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(insertCols))
+	for _, point := range dataset.Points {
+		args[0] = point.Timestamp
+		args[1] = point.ParticipantID
+		args[2] = point.Condition
+		for i, col := range dataCols {
+			if val, ok := point.Data[col]; ok && !math.IsNaN(val) {
+				args[i+3] = val
+			} else {
+				args[i+3] = nil
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to write point: %v", err)
+		}
+	}
+
+	if _, err := tx.Exec("CREATE INDEX points_timestamp ON points (timestamp)"); err != nil {
+		return fmt.Errorf("failed to create timestamp index: %v", err)
+	}
+	if _, err := tx.Exec("CREATE INDEX points_participant ON points (participant_id, timestamp)"); err != nil {
+		return fmt.Errorf("failed to create participant index: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit database: %v", err)
+	}
+	return nil
+}
+
+func (l *Loader) loadSQLiteFile(filePath string) (*types.Dataset, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var columns []string
+	colRows, err := db.Query("SELECT name FROM columns ORDER BY position")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %v", err)
+	}
+	for colRows.Next() {
+		var name string
+		if err := colRows.Scan(&name); err != nil {
+			colRows.Close()
+			return nil, fmt.Errorf("failed to read columns: %v", err)
+		}
+		columns = append(columns, name)
+	}
+	colRows.Close()
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("database %s has no columns", filePath)
+	}
+
+	metadata := make(map[string]interface{})
+	metaRows, err := db.Query("SELECT key, value FROM metadata")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %v", err)
+	}
+	for metaRows.Next() {
+		var key, encoded string
+		if err := metaRows.Scan(&key, &encoded); err != nil {
+			metaRows.Close()
+			return nil, fmt.Errorf("failed to read metadata: %v", err)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(encoded), &value); err == nil {
+			metadata[key] = value
+		}
+	}
+	metaRows.Close()
+
+	var events []types.Event
+	eventRows, err := db.Query("SELECT type, start, end, eye, message, data, participant_id FROM events ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %v", err)
+	}
+	for eventRows.Next() {
+		var event types.Event
+		var data string
+		if err := eventRows.Scan(&event.Type, &event.Start, &event.End, &event.Eye, &event.Message, &data, &event.ParticipantID); err != nil {
+			eventRows.Close()
+			return nil, fmt.Errorf("failed to read events: %v", err)
+		}
+		json.Unmarshal([]byte(data), &event.Data)
+		events = append(events, event)
+	}
+	eventRows.Close()
+
+	dataCols := columns[1:]
+	selectCols := []string{"timestamp", "participant_id", "condition"}
+	for _, col := range dataCols {
+		selectCols = append(selectCols, quoteIdent(col))
+	}
+
+	rows, err := db.Query("SELECT " + strings.Join(selectCols, ", ") + " FROM points ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read points: %v", err)
+	}
+	defer rows.Close()
+
+	var points []types.DataPoint
+	var participantID, condition sql.NullString
+	values := make([]sql.NullFloat64, len(dataCols))
+	dest := make([]interface{}, len(selectCols))
+	for rows.Next() {
+		point := types.DataPoint{Data: make(map[string]float64)}
+		dest[0], dest[1], dest[2] = &point.Timestamp, &participantID, &condition
+		for i := range values {
+			dest[i+3] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read point: %v", err)
+		}
+
+		point.ParticipantID = participantID.String
+		point.Condition = condition.String
+		if l.Condition != "" {
+			point.Condition = l.Condition
+		}
+		for i, col := range dataCols {
+			if values[i].Valid {
+				point.Data[col] = values[i].Float64
+			}
+		}
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read points: %v", err)
+	}
+
+	return &types.Dataset{Points: points, Columns: columns, Metadata: metadata, Events: events}, nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,19 @@
+//go:build js && wasm
+
+package loader
+
+import (
+	"fmt"
+
+	"mbdvr/internal/types"
+)
+
+// The SQLite driver doesn't build for WebAssembly
+
+func SaveDatasetSQLite(dataset *types.Dataset, outputPath string) error {
+	return fmt.Errorf("SQLite output is not supported in the WebAssembly build")
+}
+
+func (l *Loader) loadSQLiteFile(filePath string) (*types.Dataset, error) {
+	return nil, fmt.Errorf("SQLite input is not supported in the WebAssembly build")
+}
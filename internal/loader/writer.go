@@ -0,0 +1,198 @@
+package loader
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Writer writes data points to a CSV file one at a time, so large datasets never have to be held in memory
+type Writer struct {
+	f       *os.File
+	w       *csv.Writer
+	columns []string // Data columns, without the timestamp column
+	row     []string
+	path    string
+
+	// Output partitioning: when a limit is set, rows go to numbered files (out_001.csv, out_002.csv, ...)
+	basePath string
+	maxRows  int
+	maxBytes int64
+	part     int
+	rows     int
+	bytes    int64
+	paths    []string
+}
+
+// NewWriter creates outputPath and writes the header. columns follows Dataset.Columns, where the first entry is the timestamp column.
+func NewWriter(outputPath string, columns []string) (*Writer, error) {
+	return NewSplitWriter(outputPath, columns, 0, 0)
+}
+
+// NewSplitWriter is like NewWriter but starts a new numbered file whenever maxRows or maxBytes (0 = no limit) would be exceeded
+func NewSplitWriter(outputPath string, columns []string, maxRows int, maxBytes int64) (*Writer, error) {
+	var dataCols []string
+	if len(columns) > 0 {
+		dataCols = columns[1:]
+	}
+
+	w := &Writer{
+		columns:  dataCols,
+		row:      make([]string, len(dataCols)+3),
+		basePath: outputPath,
+		maxRows:  maxRows,
+		maxBytes: maxBytes,
+	}
+	if err := w.openPart(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewAppendWriter appends rows to an existing CSV written by NewWriter with the same columns, e.g. to resume an interrupted load
+func NewAppendWriter(outputPath string, columns []string) (*Writer, error) {
+	var dataCols []string
+	if len(columns) > 0 {
+		dataCols = columns[1:]
+	}
+	header := append([]string{"timestamp", "participant_id", "condition"}, dataCols...)
+
+	existing, err := os.Open(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %v", err)
+	}
+	existingHeader, err := csv.NewReader(existing).Read()
+	existing.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of %s: %v", outputPath, err)
+	}
+	if strings.Join(existingHeader, ",") != strings.Join(header, ",") {
+		return nil, fmt.Errorf("columns of %s do not match the files being loaded", outputPath)
+	}
+
+	f, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %v", err)
+	}
+
+	return &Writer{
+		f:        f,
+		w:        csv.NewWriter(f),
+		columns:  dataCols,
+		row:      make([]string, len(dataCols)+3),
+		path:     outputPath,
+		basePath: outputPath,
+		paths:    []string{outputPath},
+	}, nil
+}
+
+// Flush writes buffered rows to the file
+func (w *Writer) Flush() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return fmt.Errorf("failed to flush %s: %v", w.path, err)
+	}
+	return nil
+}
+
+func (w *Writer) split() bool {
+	return w.maxRows > 0 || w.maxBytes > 0
+}
+
+func (w *Writer) openPart() error {
+	w.path = w.basePath
+	if w.split() {
+		w.part++
+		ext := filepath.Ext(w.basePath)
+		w.path = fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(w.basePath, ext), w.part, ext)
+	}
+
+	f, err := os.Create(w.path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	w.f = f
+	w.w = csv.NewWriter(f)
+	w.rows = 0
+	w.bytes = 0
+	w.paths = append(w.paths, w.path)
+
+	header := append([]string{"timestamp", "participant_id", "condition"}, w.columns...)
+	if err := w.w.Write(header); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write header to %s: %v", w.path, err)
+	}
+	w.bytes += rowSize(header)
+	return nil
+}
+
+// formatRow fills row with the timestamp, labels and data columns of point
+func formatRow(row, columns []string, point types.DataPoint) {
+	row[0] = fmt.Sprintf("%f", point.Timestamp)
+	row[1] = point.ParticipantID
+	row[2] = point.Condition
+
+	for i, col := range columns {
+		if val, ok := point.Data[col]; ok {
+			row[i+3] = fmt.Sprintf("%f", val)
+		} else {
+			row[i+3] = ""
+		}
+	}
+}
+
+func (w *Writer) WritePoint(point types.DataPoint) error {
+	formatRow(w.row, w.columns, point)
+
+	size := rowSize(w.row)
+	if w.rows > 0 && ((w.maxRows > 0 && w.rows >= w.maxRows) || (w.maxBytes > 0 && w.bytes+size > w.maxBytes)) {
+		if err := w.closePart(); err != nil {
+			return err
+		}
+		if err := w.openPart(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.w.Write(w.row); err != nil {
+		return fmt.Errorf("failed to write row to %s: %v", w.path, err)
+	}
+	w.rows++
+	w.bytes += size
+	return nil
+}
+
+// Paths lists the files written so far
+func (w *Writer) Paths() []string {
+	return w.paths
+}
+
+// Close flushes buffered rows and closes the file, reporting any write error
+func (w *Writer) Close() error {
+	return w.closePart()
+}
+
+func (w *Writer) closePart() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		w.f.Close()
+		return fmt.Errorf("failed to flush %s: %v", w.path, err)
+	}
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", w.path, err)
+	}
+	return nil
+}
+
+// rowSize approximates the encoded size of a CSV row (quoting is rare for numeric data)
+func rowSize(row []string) int64 {
+	size := int64(len(row)) // Separators and newline
+	for _, field := range row {
+		size += int64(len(field))
+	}
+	return size
+}
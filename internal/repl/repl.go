@@ -0,0 +1,301 @@
+// Package repl is an interactive prompt for exploring a loaded dataset without writing any files.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"mbdvr/internal/stats"
+	"mbdvr/internal/types"
+)
+
+const help = `Commands:
+  columns                  List the data columns and how many values each has
+  count                    Points, participants, conditions and time range in view
+  head [n]                 Print the first n points in view (default: 10)
+  tail [n]                 Print the last n points in view (default: 10)
+  describe <column>...     Count, missing, mean, SD, min, median and max
+  hist <column> [bins]     Text histogram (default: 10 bins)
+  between <start> <end>    Narrow the view to timestamps from start to end seconds
+  reset                    View the whole dataset again
+  help                     Show this help
+  quit                     Leave (or end of input)
+`
+
+// Session holds the dataset being explored and the part of it in view
+type Session struct {
+	dataset *types.Dataset
+	view    *types.Dataset
+	label   string // Description of the view, e.g. "10-20s"
+}
+
+func New(dataset *types.Dataset) *Session {
+	return &Session{dataset: dataset, view: dataset}
+}
+
+// Run reads commands from in until quit or the end of the input, writing a prompt and the results to
+// out. A failing command prints its error and the session goes on.
+func (s *Session) Run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		prompt := "mbdvr> "
+		if s.label != "" {
+			prompt = "mbdvr " + s.label + "> "
+		}
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		quit, err := s.Execute(scanner.Text(), out)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Execute runs one command line and reports whether it asked to quit
+func (s *Session) Execute(line string, out io.Writer) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	command, args := strings.ToLower(fields[0]), fields[1:]
+
+	switch command {
+	case "quit", "exit":
+		return true, nil
+	case "help", "?":
+		fmt.Fprint(out, help)
+	case "columns":
+		s.columns(out)
+	case "count":
+		s.count(out)
+	case "head", "tail":
+		n, err := optionalInt(args, 10)
+		if err != nil {
+			return false, err
+		}
+		return false, s.rows(out, n, command == "tail")
+	case "describe":
+		return false, s.describe(out, args)
+	case "hist":
+		return false, s.hist(out, args)
+	case "between":
+		return false, s.between(out, args)
+	case "reset":
+		s.view, s.label = s.dataset, ""
+		fmt.Fprintf(out, "%d points in view\n", len(s.view.Points))
+	default:
+		return false, fmt.Errorf("unknown command %q (type help for the commands)", command)
+	}
+	return false, nil
+}
+
+// optionalInt parses the first argument as a positive count, or returns def without arguments
+func optionalInt(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid count %q", args[0])
+	}
+	return n, nil
+}
+
+func (s *Session) dataColumns() []string {
+	if len(s.dataset.Columns) == 0 {
+		return nil
+	}
+	return s.dataset.Columns[1:]
+}
+
+func (s *Session) requireColumns(columns []string) error {
+	known := make(map[string]bool)
+	for _, col := range s.dataColumns() {
+		known[col] = true
+	}
+	for _, col := range columns {
+		if !known[col] {
+			return fmt.Errorf("column %s not found (type columns for the list)", col)
+		}
+	}
+	return nil
+}
+
+func (s *Session) columns(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, col := range s.dataColumns() {
+		values := 0
+		for _, p := range s.view.Points {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				values++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d values\n", col, values)
+	}
+	w.Flush()
+}
+
+func (s *Session) count(out io.Writer) {
+	participants := make(map[string]int)
+	conditions := make(map[string]int)
+	minTime, maxTime := math.Inf(1), math.Inf(-1)
+	for _, p := range s.view.Points {
+		participants[p.ParticipantID]++
+		conditions[p.Condition]++
+		minTime = math.Min(minTime, p.Timestamp)
+		maxTime = math.Max(maxTime, p.Timestamp)
+	}
+	fmt.Fprintf(out, "Points: %d\n", len(s.view.Points))
+	fmt.Fprintf(out, "Participants: %s\n", countList(participants))
+	fmt.Fprintf(out, "Conditions: %s\n", countList(conditions))
+	if len(s.view.Points) > 0 {
+		fmt.Fprintf(out, "Time range: %.3f to %.3f\n", minTime, maxTime)
+	}
+}
+
+// countList formats counts by name as "a (10), b (5)"
+func countList(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		label := name
+		if label == "" {
+			label = "(none)"
+		}
+		parts[i] = fmt.Sprintf("%s (%d)", label, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (s *Session) rows(out io.Writer, n int, last bool) error {
+	points := s.view.Points
+	if len(points) == 0 {
+		return fmt.Errorf("no points in view")
+	}
+	if n > len(points) {
+		n = len(points)
+	}
+	if last {
+		points = points[len(points)-n:]
+	} else {
+		points = points[:n]
+	}
+
+	columns := s.dataColumns()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "timestamp\tparticipant_id\tcondition\t%s\t\n", strings.Join(columns, "\t"))
+	for _, p := range points {
+		fmt.Fprintf(w, "%.3f\t%s\t%s", p.Timestamp, p.ParticipantID, p.Condition)
+		for _, col := range columns {
+			if v, ok := p.Data[col]; ok {
+				fmt.Fprintf(w, "\t%g", v)
+			} else {
+				fmt.Fprint(w, "\t")
+			}
+		}
+		fmt.Fprint(w, "\t\n")
+	}
+	return w.Flush()
+}
+
+func (s *Session) describe(out io.Writer, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("usage: describe <column>...")
+	}
+	if err := s.requireColumns(columns); err != nil {
+		return err
+	}
+	report, err := stats.ComputeStats(s.view, stats.StatsConfig{AnalyzeColumns: columns})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "column\tcount\tmissing\tmean\tsd\tmin\tmedian\tmax\t\n")
+	for _, c := range report.OverallStats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n",
+			c.Column, c.Count, c.MissingCount, c.Mean, c.StdDev, c.Min, c.Median, c.Max)
+	}
+	return w.Flush()
+}
+
+func (s *Session) hist(out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: hist <column> [bins]")
+	}
+	if err := s.requireColumns(args[:1]); err != nil {
+		return err
+	}
+	bins, err := optionalInt(args[1:], 10)
+	if err != nil {
+		return err
+	}
+	report, err := stats.ComputeStats(s.view, stats.StatsConfig{AnalyzeColumns: args[:1], HistogramBins: bins})
+	if err != nil {
+		return err
+	}
+	if len(report.Histograms) == 0 {
+		return fmt.Errorf("column %s has no values in view", args[0])
+	}
+
+	h := report.Histograms[0]
+	largest := 0
+	for _, b := range h.Bins {
+		if b.Count > largest {
+			largest = b.Count
+		}
+	}
+	const width = 40
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	for _, b := range h.Bins {
+		bar := 0
+		if largest > 0 {
+			bar = int(math.Round(float64(b.Count) / float64(largest) * width))
+		}
+		fmt.Fprintf(w, "%.3f\t- %.3f\t%d\t%s\n", b.Lower, b.Upper, b.Count, strings.Repeat("#", bar))
+	}
+	return w.Flush()
+}
+
+func (s *Session) between(out io.Writer, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: between <start> <end>")
+	}
+	start, err1 := strconv.ParseFloat(args[0], 64)
+	end, err2 := strconv.ParseFloat(args[1], 64)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("invalid time range %s to %s", args[0], args[1])
+	}
+	if end <= start {
+		return fmt.Errorf("end time %g must be greater than start time %g", end, start)
+	}
+
+	var points []types.DataPoint
+	for _, p := range s.dataset.Points {
+		if p.Timestamp >= start && p.Timestamp <= end {
+			points = append(points, p)
+		}
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("no data points between %gs and %gs", start, end)
+	}
+	s.view = &types.Dataset{Points: points, Columns: s.dataset.Columns, Metadata: s.dataset.Metadata, Events: s.dataset.Events}
+	s.label = fmt.Sprintf("%g-%gs", start, end)
+	fmt.Fprintf(out, "%d of %d points in view\n", len(points), len(s.dataset.Points))
+	return nil
+}
@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Bookmark marks a moment of a session with a note, for qualitative review across team members
+type Bookmark struct {
+	Time    float64   `json:"time"` // Seconds from the start of the replay
+	Label   string    `json:"label"`
+	Note    string    `json:"note,omitempty"`
+	Author  string    `json:"author,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// BookmarkPath is the sidecar file that stores the bookmarks of a dataset file
+func BookmarkPath(input string) string {
+	return input + ".bookmarks.json"
+}
+
+// LoadBookmarks reads a bookmark sidecar file; a missing file means no bookmarks yet
+func LoadBookmarks(path string) ([]Bookmark, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks: %v", err)
+	}
+
+	var bookmarks []Bookmark
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks %s: %v", path, err)
+	}
+	sortBookmarks(bookmarks)
+	return bookmarks, nil
+}
+
+// SaveBookmarks writes the bookmarks sorted by time, replacing the sidecar atomically
+func SaveBookmarks(path string, bookmarks []Bookmark) error {
+	sortBookmarks(bookmarks)
+	data, err := json.MarshalIndent(bookmarks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmarks: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write bookmarks: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write bookmarks: %v", err)
+	}
+	return nil
+}
+
+// AddBookmark re-reads the sidecar before appending, so bookmarks saved by others in the meantime are kept
+func AddBookmark(path string, bookmark Bookmark) ([]Bookmark, error) {
+	bookmarks, err := LoadBookmarks(path)
+	if err != nil {
+		return nil, err
+	}
+	if bookmark.Created.IsZero() {
+		bookmark.Created = time.Now()
+	}
+	bookmarks = append(bookmarks, bookmark)
+	if err := SaveBookmarks(path, bookmarks); err != nil {
+		return nil, err
+	}
+	return bookmarks, nil
+}
+
+func sortBookmarks(bookmarks []Bookmark) {
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].Time < bookmarks[j].Time
+	})
+}
+
+// FormatTime renders a replay offset as m:ss.ss
+func FormatTime(seconds float64) string {
+	minutes := int(seconds / 60)
+	return fmt.Sprintf("%d:%05.2f", minutes, seconds-float64(minutes*60))
+}
@@ -1,9 +1,13 @@
+//go:build !headless
+
 package replay
 
 // Use Fyne to create a simple UI for replaying eye gaze data
 
 import (
+	"math"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -14,7 +18,11 @@ import (
 	"mbdvr/internal/types"
 )
 
-func StartUI(dataset *types.Dataset, speed float64) {
+// UIAvailable reports whether this build includes the replay window
+const UIAvailable = true
+
+// StartUI opens the replay window. Bookmarks are read from and saved to bookmarkPath.
+func StartUI(dataset *types.Dataset, speed float64, bookmarkPath, author string) {
 	a := app.New()
 	w := a.NewWindow("Eye Gaze Data Replay")
 
@@ -34,18 +42,73 @@ func StartUI(dataset *types.Dataset, speed float64) {
 
 	//Canvas for displaying the eye gaze position.
 	canvas := widget.NewLabel("Eye Gaze Position")
+
+	// Replay position in seconds, shared with the replay goroutine
+	var position atomic.Uint64
+	startFrom := 0.0
+
+	bookmarks, err := LoadBookmarks(bookmarkPath)
+	if err != nil {
+		canvas.SetText(err.Error())
+	}
+	bookmarkList := widget.NewList(
+		func() int { return len(bookmarks) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			b := bookmarks[id]
+			item.(*widget.Label).SetText(FormatTime(b.Time) + "  " + b.Label)
+		},
+	)
+	bookmarkList.OnSelected = func(id widget.ListItemID) {
+		b := bookmarks[id]
+		startFrom = b.Time
+		text := "Bookmark at " + FormatTime(b.Time) + ": " + b.Label
+		if b.Author != "" {
+			text += " (" + b.Author + ")"
+		}
+		if b.Note != "" {
+			text += "\n" + b.Note
+		}
+		canvas.SetText(text + "\nStart replays from here.")
+	}
+
+	labelEntry := widget.NewEntry()
+	labelEntry.SetPlaceHolder("Bookmark label")
+	noteEntry := widget.NewMultiLineEntry()
+	noteEntry.SetPlaceHolder("Note (optional)")
+	bookmarkButton := widget.NewButton("Add Bookmark", func() {
+		if labelEntry.Text == "" {
+			canvas.SetText("Please enter a bookmark label.")
+			return
+		}
+		updated, err := AddBookmark(bookmarkPath, Bookmark{
+			Time:   math.Float64frombits(position.Load()),
+			Label:  labelEntry.Text,
+			Note:   noteEntry.Text,
+			Author: author,
+		})
+		if err != nil {
+			canvas.SetText(err.Error())
+			return
+		}
+		bookmarks = updated
+		bookmarkList.Refresh()
+		labelEntry.SetText("")
+		noteEntry.SetText("")
+	})
+
 	startButton := widget.NewButton("Start", func() {
 		if xGazeSelect.Selected == "" || yGazeSelect.Selected == "" {
 			canvas.SetText("Please select both X and Y gaze columns.")
 			return
 		}
-		go replayData(dataset, xGazeSelect.Selected, yGazeSelect.Selected, speedSlider.Value, canvas)
+		go replayData(dataset, xGazeSelect.Selected, yGazeSelect.Selected, speedSlider.Value, startFrom, &position, canvas)
 	})
 	stopButton := widget.NewButton("Stop", func() {
 		// Implement stop functionality if needed.
 	})
 
-	w.SetContent(container.NewVBox(
+	controls := container.NewVBox(
 		xGazeSelect,
 		yGazeSelect,
 		speedLabel,
@@ -53,30 +116,41 @@ func StartUI(dataset *types.Dataset, speed float64) {
 		startButton,
 		stopButton,
 		canvas,
-	))
+		labelEntry,
+		noteEntry,
+		bookmarkButton,
+	)
+	w.SetContent(container.NewBorder(controls, nil, nil, nil, bookmarkList))
 
-	w.Resize(fyne.NewSize(400, 300))
+	w.Resize(fyne.NewSize(400, 600))
 	w.ShowAndRun()
 }
 
-func replayData(dataset *types.Dataset, xCol, yCol string, speed float64, canvas *widget.Label) {
+func replayData(dataset *types.Dataset, xCol, yCol string, speed, startFrom float64, position *atomic.Uint64, canvas *widget.Label) {
 	if dataset == nil || len(dataset.Points) == 0 {
 		canvas.SetText("No data to replay.")
 		return
 	}
 
 	startTime := dataset.Points[0].Timestamp
+	first := true
 	for i, point := range dataset.Points {
+		if point.Timestamp-startTime < startFrom {
+			continue
+		}
+
 		// Calculate the time to wait before showing the next point
 		var waitTime float64
-		if i == 0 {
+		if first {
 			waitTime = 0
+			first = false
 		} else {
 			timeDiff := point.Timestamp - dataset.Points[i-1].Timestamp
 			waitTime = timeDiff / speed
 		}
 
 		time.Sleep(time.Duration(waitTime*1000) * time.Millisecond)
+		position.Store(math.Float64bits(point.Timestamp - startTime))
 
 		xGaze, xOk := point.Data[xCol]
 		yGaze, yOk := point.Data[yCol]
@@ -0,0 +1,12 @@
+//go:build headless
+
+package replay
+
+import "mbdvr/internal/types"
+
+// UIAvailable reports whether this build includes the replay window. Headless builds leave out Fyne and
+// its OpenGL dependencies, for containers that only run the processing commands.
+const UIAvailable = false
+
+// StartUI does nothing in headless builds; check UIAvailable first
+func StartUI(dataset *types.Dataset, speed float64, bookmarkPath, author string) {}
@@ -0,0 +1,388 @@
+// Package roles infers what each data column measures (gaze, pupil, head and controller tracking)
+// from its name and values, so commands can find their input columns without flags on well-named data.
+package roles
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"mbdvr/internal/types"
+)
+
+// Role is what a column measures
+type Role string
+
+const (
+	Gaze               Role = "gaze"           // Gaze point on a screen or in the scene (x, y[, z])
+	GazeDirection      Role = "gaze_direction" // Unit gaze direction vector (x, y, z)
+	Pupil              Role = "pupil"          // Pupil size
+	HeadPosition       Role = "head_position"
+	HeadRotation       Role = "head_rotation" // Quaternion (w, x, y, z) or Euler angles (x, y, z)
+	ControllerPosition Role = "controller_position"
+	ControllerRotation Role = "controller_rotation"
+	Target             Role = "target"     // Position of a stimulus or calibration target (x, y)
+	Confidence         Role = "confidence" // Per-sample tracking confidence or validity
+	None               Role = "none"       // No role; only set by overrides to stop a column being inferred
+)
+
+// All lists the roles other than None
+var All = []Role{Gaze, GazeDirection, Pupil, HeadPosition, HeadRotation, ControllerPosition, ControllerRotation, Target, Confidence}
+
+// Column is the role of one data column
+type Column struct {
+	Name   string `json:"column"`
+	Role   Role   `json:"role"`
+	Side   string `json:"side,omitempty"` // "left" or "right" eye or hand ("" = both or not sided)
+	Axis   string `json:"axis,omitempty"` // Component of a vector role: "x", "y", "z" or "w"
+	Source string `json:"source"`         // "name", "values" or "override"
+	stem   string // Name without the axis, grouping the components of one vector
+}
+
+// Spec is the role as written in overrides, e.g. "left_gaze_x" or "pupil"
+func (c Column) Spec() string {
+	spec := string(c.Role)
+	if c.Side != "" {
+		spec = c.Side + "_" + spec
+	}
+	if c.Axis != "" {
+		spec += "_" + c.Axis
+	}
+	return spec
+}
+
+// ParseSpec reads a role as written in overrides: an optional "left_" or "right_" side, the role and,
+// for vector roles, an "_x", "_y", "_z" or "_w" axis, e.g. "right_pupil" or "head_rotation_w"
+func ParseSpec(spec string) (Column, error) {
+	s := strings.ToLower(strings.TrimSpace(spec))
+	if s == string(None) {
+		return Column{Role: None}, nil
+	}
+	var c Column
+	for _, side := range []string{"left", "right"} {
+		if strings.HasPrefix(s, side+"_") {
+			c.Side, s = side, strings.TrimPrefix(s, side+"_")
+		}
+	}
+	for _, axis := range []string{"x", "y", "z", "w"} {
+		if strings.HasSuffix(s, "_"+axis) {
+			c.Axis, s = axis, strings.TrimSuffix(s, "_"+axis)
+		}
+	}
+	for _, role := range All {
+		if s == string(role) {
+			c.Role = role
+			return c, nil
+		}
+	}
+	names := make([]string, len(All))
+	for i, role := range All {
+		names[i] = string(role)
+	}
+	return Column{}, fmt.Errorf("unknown column role %q (roles: %s, or none)", spec, strings.Join(names, ", "))
+}
+
+// Roles holds the inferred roles of a dataset's columns, in column order
+type Roles struct {
+	Columns []Column
+}
+
+// Infer classifies the data columns by name, then checks the values of vector columns: gaze columns
+// forming a unit vector are gaze directions, and position columns with a w component are quaternion
+// rotations. overrides maps column names to roles (see ParseSpec) and replaces the inferred ones;
+// overrides of columns the dataset doesn't have are ignored, so one set can serve a whole study.
+func Infer(dataset *types.Dataset, overrides map[string]string) (*Roles, error) {
+	if dataset == nil {
+		return nil, fmt.Errorf("dataset is empty")
+	}
+	r := &Roles{}
+	for i, name := range dataset.Columns {
+		if i == 0 {
+			continue // Timestamp
+		}
+		if spec, ok := overrides[name]; ok {
+			c, err := ParseSpec(spec)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %v", name, err)
+			}
+			if c.Role != None {
+				c.Name, c.Source, c.stem = name, "override", "override"
+				r.Columns = append(r.Columns, c)
+			}
+			continue
+		}
+		if c, ok := fromName(name); ok {
+			r.Columns = append(r.Columns, c)
+		}
+	}
+	r.checkValues(dataset.Points)
+	return r, nil
+}
+
+var (
+	sideWords       = map[string]string{"left": "left", "l": "left", "right": "right", "r": "right"}
+	targetWords     = []string{"target", "stimulus", "stim"}
+	confidenceWords = []string{"confidence", "conf", "validity", "valid", "quality"}
+	pupilWords      = []string{"pupil", "diameter", "dilation"}
+	controllerWords = []string{"controller", "ctrl", "hand", "wand"}
+	headWords       = []string{"head", "hmd", "camera", "cam"}
+	gazeWords       = []string{"gaze", "eye", "por"}
+	directionWords  = []string{"dir", "direction", "vector", "vec", "ray", "forward"}
+	originWords     = []string{"origin"}
+	rotationWords   = []string{"rot", "rotation", "orientation", "quat", "quaternion", "q", "euler"}
+)
+
+// fromName infers a column's role from the words of its name, e.g. left_gaze_x, HeadRotW or pupil_diameter
+func fromName(name string) (Column, bool) {
+	words := splitWords(name)
+	c := Column{Name: name, Source: "name"}
+	if len(words) > 1 {
+		if last := words[len(words)-1]; last == "x" || last == "y" || last == "z" || last == "w" {
+			c.Axis = last
+		}
+	}
+	var stem []string
+	for i, w := range words {
+		if side, ok := sideWords[w]; ok && c.Side == "" {
+			c.Side = side
+		}
+		if c.Axis == "" || i < len(words)-1 {
+			stem = append(stem, w)
+		}
+	}
+	c.stem = strings.Join(stem, "_")
+
+	has := func(set []string) bool {
+		for _, w := range words {
+			for _, s := range set {
+				if w == s {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	tracked := func(position, rotation Role) Role {
+		if has(rotationWords) {
+			return rotation
+		}
+		return position
+	}
+	switch {
+	case has(targetWords) && c.Axis != "":
+		c.Role = Target
+	case has(confidenceWords):
+		c.Role = Confidence
+	case has(pupilWords):
+		c.Role, c.Axis = Pupil, ""
+	case has(controllerWords) && c.Axis != "":
+		c.Role = tracked(ControllerPosition, ControllerRotation)
+	case has(headWords) && c.Axis != "":
+		c.Role = tracked(HeadPosition, HeadRotation)
+	case has(gazeWords) && c.Axis != "" && !has(originWords):
+		c.Role = Gaze
+		if has(directionWords) {
+			c.Role = GazeDirection
+		}
+	default:
+		return Column{}, false
+	}
+	return c, true
+}
+
+// splitWords splits a column name into lower-case words at separators, camel case and digits
+func splitWords(name string) []string {
+	var words []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+	runes := []rune(name)
+	for i, ch := range runes {
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) {
+			flush()
+			continue
+		}
+		if i > 0 && len(current) > 0 {
+			prev := runes[i-1]
+			if (unicode.IsUpper(ch) && unicode.IsLower(prev)) || unicode.IsDigit(ch) != unicode.IsDigit(prev) {
+				flush()
+			} else if unicode.IsLower(ch) && unicode.IsUpper(prev) && len(current) > 1 {
+				// An acronym ends before a capitalized word, e.g. ET|Pupil
+				current = current[:len(current)-1]
+				flush()
+				current = append(current, prev)
+			}
+		}
+		current = append(current, ch)
+	}
+	flush()
+	return words
+}
+
+// checkValues refines vector roles from the data: gaze vectors of unit length are directions, and
+// position vectors with a w component are rotations
+func (r *Roles) checkValues(points []types.DataPoint) {
+	for _, group := range r.groups() {
+		first := r.Columns[group[0]]
+		if first.Source == "override" {
+			continue
+		}
+		axes := make(map[string]string)
+		for _, i := range group {
+			axes[r.Columns[i].Axis] = r.Columns[i].Name
+		}
+		role := first.Role
+		switch {
+		case first.Role == Gaze && axes["x"] != "" && axes["y"] != "" && axes["z"] != "":
+			if unitLength(points, []string{axes["x"], axes["y"], axes["z"]}) {
+				role = GazeDirection
+			}
+		case (first.Role == HeadPosition || first.Role == ControllerPosition) && axes["w"] != "":
+			role = HeadRotation
+			if first.Role == ControllerPosition {
+				role = ControllerRotation
+			}
+		}
+		if role != first.Role {
+			for _, i := range group {
+				r.Columns[i].Role, r.Columns[i].Source = role, "values"
+			}
+		}
+	}
+}
+
+// groups returns the column indices of each vector, keyed by role, side and stem, in column order
+func (r *Roles) groups() [][]int {
+	var order []string
+	byKey := make(map[string][]int)
+	for i, c := range r.Columns {
+		if c.Axis == "" {
+			continue
+		}
+		key := string(c.Role) + "\x00" + c.Side + "\x00" + c.stem
+		if _, ok := byKey[key]; !ok {
+			order = append(order, key)
+		}
+		byKey[key] = append(byKey[key], i)
+	}
+	groups := make([][]int, len(order))
+	for i, key := range order {
+		groups[i] = byKey[key]
+	}
+	return groups
+}
+
+// unitLength reports whether the median length of the vectors in columns is within 5% of 1
+func unitLength(points []types.DataPoint, columns []string) bool {
+	var lengths []float64
+	for _, p := range points {
+		sum := 0.0
+		complete := true
+		for _, col := range columns {
+			v, ok := p.Data[col]
+			if !ok || math.IsNaN(v) {
+				complete = false
+				break
+			}
+			sum += v * v
+		}
+		if complete {
+			lengths = append(lengths, math.Sqrt(sum))
+		}
+	}
+	if len(lengths) == 0 {
+		return false
+	}
+	sort.Float64s(lengths)
+	return math.Abs(lengths[len(lengths)/2]-1) < 0.05
+}
+
+// Find returns the columns with a role, with any side, in column order
+func (r *Roles) Find(role Role) []Column {
+	var found []Column
+	for _, c := range r.Columns {
+		if c.Role == role {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// First returns the first column with a role, preferring one without a side (e.g. a combined pupil
+// size over the left eye's)
+func (r *Roles) First(role Role) (string, bool) {
+	found := r.Find(role)
+	for _, c := range found {
+		if c.Side == "" {
+			return c.Name, true
+		}
+	}
+	if len(found) == 0 {
+		return "", false
+	}
+	return found[0].Name, true
+}
+
+// Vector returns the columns of the given axes of the first vector with a role and side, preferring
+// overridden columns, or nil if no vector has all of the axes
+func (r *Roles) Vector(role Role, side string, axes ...string) []string {
+	var candidates [][]string
+	for _, group := range r.groups() {
+		first := r.Columns[group[0]]
+		if first.Role != role || first.Side != side {
+			continue
+		}
+		names := make([]string, len(axes))
+		complete := true
+		for k, axis := range axes {
+			for _, i := range group {
+				if r.Columns[i].Axis == axis {
+					names[k] = r.Columns[i].Name
+				}
+			}
+			complete = complete && names[k] != ""
+		}
+		if !complete {
+			continue
+		}
+		if first.Source == "override" {
+			return names
+		}
+		candidates = append(candidates, names)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[0]
+}
+
+// Sides returns the sides that have a vector with a role, in column order ("" for one without a side)
+func (r *Roles) Sides(role Role) []string {
+	var sides []string
+	seen := make(map[string]bool)
+	for _, c := range r.Find(role) {
+		if !seen[c.Side] {
+			seen[c.Side] = true
+			sides = append(sides, c.Side)
+		}
+	}
+	return sides
+}
+
+// GazePoint returns the x and y columns of the gaze point, preferring combined gaze over either eye's
+func (r *Roles) GazePoint() (x, y string, ok bool) {
+	sides := r.Sides(Gaze)
+	sort.SliceStable(sides, func(i, j int) bool { return sides[i] == "" && sides[j] != "" })
+	for _, side := range sides {
+		if xy := r.Vector(Gaze, side, "x", "y"); xy != nil {
+			return xy[0], xy[1], true
+		}
+	}
+	return "", "", false
+}
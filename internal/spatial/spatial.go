@@ -0,0 +1,145 @@
+// Package spatial holds the 3D vector and quaternion math shared by the head-motion, gaze-in-world
+// and vergence computations.
+//
+// Quaternions are (w, x, y, z) with w the scalar part, matching the column order most VR exports use.
+// Angles are in degrees.
+package spatial
+
+import "math"
+
+// Vec3 is a 3D vector
+type Vec3 [3]float64
+
+// Quat is a rotation quaternion in (w, x, y, z) order
+type Quat [4]float64
+
+// Identity is the rotation that leaves vectors unchanged
+var Identity = Quat{1, 0, 0, 0}
+
+func Dot(a, b Vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func Cross(a, b Vec3) Vec3 {
+	return Vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (v Vec3) Norm() float64 {
+	return math.Sqrt(Dot(v, v))
+}
+
+// Normalize returns v scaled to unit length, or NaNs for the zero vector
+func (v Vec3) Normalize() Vec3 {
+	n := v.Norm()
+	if n == 0 {
+		return Vec3{math.NaN(), math.NaN(), math.NaN()}
+	}
+	return Vec3{v[0] / n, v[1] / n, v[2] / n}
+}
+
+// AngleBetween returns the angle between two vectors in degrees. It uses atan2 of the cross and dot
+// products, so it stays accurate for the small angles between consecutive samples where acos doesn't.
+func AngleBetween(a, b Vec3) float64 {
+	return math.Atan2(Cross(a, b).Norm(), Dot(a, b)) * 180 / math.Pi
+}
+
+// Normalize returns q scaled to unit length; ok is false for the zero quaternion or NaN components
+func (q Quat) Normalize() (Quat, bool) {
+	n := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])
+	if n == 0 || math.IsNaN(n) {
+		return Quat{}, false
+	}
+	return Quat{q[0] / n, q[1] / n, q[2] / n, q[3] / n}, true
+}
+
+// Conjugate is the inverse rotation of a unit quaternion
+func (q Quat) Conjugate() Quat {
+	return Quat{q[0], -q[1], -q[2], -q[3]}
+}
+
+// Mul composes rotations: q.Mul(r) applies r first, then q
+func (q Quat) Mul(r Quat) Quat {
+	return Quat{
+		q[0]*r[0] - q[1]*r[1] - q[2]*r[2] - q[3]*r[3],
+		q[0]*r[1] + q[1]*r[0] + q[2]*r[3] - q[3]*r[2],
+		q[0]*r[2] - q[1]*r[3] + q[2]*r[0] + q[3]*r[1],
+		q[0]*r[3] + q[1]*r[2] - q[2]*r[1] + q[3]*r[0],
+	}
+}
+
+// Rotate applies the unit quaternion q to v: q v q*
+func (q Quat) Rotate(v Vec3) Vec3 {
+	w, x, y, z := q[0], q[1], q[2], q[3]
+	// t = 2 (q.xyz × v); v' = v + w t + q.xyz × t
+	tx := 2 * (y*v[2] - z*v[1])
+	ty := 2 * (z*v[0] - x*v[2])
+	tz := 2 * (x*v[1] - y*v[0])
+	return Vec3{
+		v[0] + w*tx + (y*tz - z*ty),
+		v[1] + w*ty + (z*tx - x*tz),
+		v[2] + w*tz + (x*ty - y*tx),
+	}
+}
+
+// Angle returns the rotation angle between two unit quaternions in degrees (0-180)
+func Angle(a, b Quat) float64 {
+	d := math.Abs(a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3])
+	return 2 * math.Acos(math.Min(d, 1)) * 180 / math.Pi
+}
+
+// Slerp interpolates between unit quaternions a and b along the shorter arc, t in [0, 1]
+func Slerp(a, b Quat, t float64) Quat {
+	d := a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+	// q and -q are the same rotation; flip b so we don't take the long way round
+	if d < 0 {
+		b = Quat{-b[0], -b[1], -b[2], -b[3]}
+		d = -d
+	}
+
+	var wa, wb float64
+	if d > 0.9995 {
+		// Nearly identical rotations: linear interpolation avoids dividing by sin(theta) ~ 0
+		wa, wb = 1-t, t
+	} else {
+		theta := math.Acos(d)
+		sin := math.Sin(theta)
+		wa = math.Sin((1-t)*theta) / sin
+		wb = math.Sin(t*theta) / sin
+	}
+
+	q := Quat{wa*a[0] + wb*b[0], wa*a[1] + wb*b[1], wa*a[2] + wb*b[2], wa*a[3] + wb*b[3]}
+	if n, ok := q.Normalize(); ok {
+		return n
+	}
+	return q
+}
+
+// Euler returns the yaw, pitch and roll of a unit quaternion in degrees, as intrinsic Tait-Bryan
+// rotations about z (yaw), then y (pitch), then x (roll). Near ±90° pitch (gimbal lock) roll is
+// reported as 0 and the combined rotation as yaw.
+func (q Quat) Euler() (yaw, pitch, roll float64) {
+	w, x, y, z := q[0], q[1], q[2], q[3]
+	sinPitch := 2 * (w*y - z*x)
+	if math.Abs(sinPitch) >= 0.999999 {
+		pitch = math.Copysign(90, sinPitch)
+		yaw = -2 * math.Atan2(x, w) * math.Copysign(1, sinPitch) * 180 / math.Pi
+		return yaw, pitch, 0
+	}
+	yaw = math.Atan2(2*(w*z+x*y), 1-2*(y*y+z*z)) * 180 / math.Pi
+	pitch = math.Asin(sinPitch) * 180 / math.Pi
+	roll = math.Atan2(2*(w*x+y*z), 1-2*(x*x+y*y)) * 180 / math.Pi
+	return yaw, pitch, roll
+}
+
+// FromEuler builds a unit quaternion from yaw, pitch and roll in degrees, the inverse of Euler
+func FromEuler(yaw, pitch, roll float64) Quat {
+	sy, cy := math.Sincos(yaw * math.Pi / 360)
+	sp, cp := math.Sincos(pitch * math.Pi / 360)
+	sr, cr := math.Sincos(roll * math.Pi / 360)
+	return Quat{
+		cr*cp*cy + sr*sp*sy,
+		sr*cp*cy - cr*sp*sy,
+		cr*sp*cy + sr*cp*sy,
+		cr*cp*sy - sr*sp*cy,
+	}
+}
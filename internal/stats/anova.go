@@ -0,0 +1,155 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// ANOVA is a one-way between-conditions analysis of variance of one analyzed column. Like TTest,
+// it compares participant means: each participant's samples are averaged per condition first.
+type ANOVA struct {
+	Column     string
+	Groups     []ANOVAGroup // One per condition with data in the column, sorted by condition
+	DFBetween  int          // Conditions - 1
+	DFWithin   int          // Participant means - conditions
+	F          float64
+	P          float64 // Upper tail of the F distribution (NaN when F can't be computed)
+	EtaSquared float64 // Share of the variance of the participant means explained by the condition
+}
+
+// ANOVAGroup describes the participant means of one condition
+type ANOVAGroup struct {
+	Condition    string
+	Participants int
+	Mean         float64
+	SD           float64 // Sample SD of the participant means (NaN with one participant)
+}
+
+// computeANOVAs runs a one-way ANOVA across all conditions of the points for each column
+func computeANOVAs(points []types.DataPoint, columns []string) []ANOVA {
+	type key struct{ condition, participant, column string }
+	sums := make(map[key]float64)
+	counts := make(map[key]int)
+	for _, p := range points {
+		for _, col := range columns {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				k := key{p.Condition, p.ParticipantID, col}
+				sums[k] += v
+				counts[k]++
+			}
+		}
+	}
+
+	var anovas []ANOVA
+	for _, col := range columns {
+		byCondition := make(map[string][]float64)
+		var conditions []string
+		for k, n := range counts {
+			if k.column != col {
+				continue
+			}
+			if _, ok := byCondition[k.condition]; !ok {
+				conditions = append(conditions, k.condition)
+			}
+			byCondition[k.condition] = append(byCondition[k.condition], sums[k]/float64(n))
+		}
+		sort.Strings(conditions)
+
+		a := ANOVA{Column: col, F: math.NaN(), P: math.NaN(), EtaSquared: math.NaN()}
+		var all []float64
+		for _, condition := range conditions {
+			values := byCondition[condition]
+			sort.Float64s(values) // Map order would otherwise change the sums in the last digits
+			g := ANOVAGroup{Condition: condition, Participants: len(values), Mean: mean(values), SD: math.NaN()}
+			if len(values) > 1 {
+				g.SD = math.Sqrt(variance(values, g.Mean))
+			}
+			a.Groups = append(a.Groups, g)
+			all = append(all, values...)
+		}
+		a.DFBetween = len(a.Groups) - 1
+		a.DFWithin = len(all) - len(a.Groups)
+
+		grand := mean(all)
+		between, within := 0.0, 0.0
+		for _, g := range a.Groups {
+			between += float64(g.Participants) * (g.Mean - grand) * (g.Mean - grand)
+			for _, v := range byCondition[g.Condition] {
+				within += (v - g.Mean) * (v - g.Mean)
+			}
+		}
+		if a.DFBetween >= 1 && a.DFWithin >= 1 {
+			if total := between + within; total > 0 {
+				a.EtaSquared = between / total
+			}
+			if within > 0 {
+				a.F = (between / float64(a.DFBetween)) / (within / float64(a.DFWithin))
+				a.P = fSurvival(a.F, float64(a.DFBetween), float64(a.DFWithin))
+			}
+		}
+		anovas = append(anovas, a)
+	}
+	return anovas
+}
+
+// fSurvival is the probability of an F statistic of at least f with d1 and d2 degrees of freedom
+func fSurvival(f, d1, d2 float64) float64 {
+	if math.IsNaN(f) || d1 <= 0 || d2 <= 0 {
+		return math.NaN()
+	}
+	if f <= 0 {
+		return 1
+	}
+	return regularizedIncompleteBeta(d2/2, d1/2, d2/(d2+d1*f))
+}
+
+// Summary describes the test on one line, e.g. "F(2, 27) = 4.120, p = 0.02752, η² = 0.234"
+func (a ANOVA) Summary() string {
+	if math.IsNaN(a.F) {
+		return fmt.Sprintf("F not computed (%d conditions, %d participant means)", len(a.Groups), a.DFWithin+len(a.Groups))
+	}
+	return fmt.Sprintf("F(%d, %d) = %.3f, p = %.4g, η² = %.3f", a.DFBetween, a.DFWithin, a.F, a.P, a.EtaSquared)
+}
+
+func writeANOVASection(sb *strings.Builder, report *StatsReport) {
+	if len(report.ANOVAs) == 0 {
+		return
+	}
+	sb.WriteString("One-Way ANOVA (participant means):\n")
+	for _, a := range report.ANOVAs {
+		sb.WriteString(fmt.Sprintf("Column: %s | %s\n", a.Column, a.Summary()))
+		for _, g := range a.Groups {
+			sb.WriteString(fmt.Sprintf("  %s: Mean %.4f | SD %.4f | n = %d\n", conditionLabel(g.Condition), g.Mean, g.SD, g.Participants))
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// SaveANOVAs writes the report's ANOVAs as CSV, one row per column and condition; the test
+// results repeat on each condition's row
+func SaveANOVAs(report *StatsReport, filename string) error {
+	if len(report.ANOVAs) == 0 {
+		return fmt.Errorf("report has no ANOVA")
+	}
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	header := []string{"column", "condition", "participants", "mean", "sd", "df_between", "df_within", "f", "p", "eta_squared"}
+	return writeDistributionCSV(filename, header, func(w *csv.Writer) {
+		for _, a := range report.ANOVAs {
+			for _, g := range a.Groups {
+				w.Write([]string{a.Column, g.Condition, strconv.Itoa(g.Participants), format(g.Mean), format(g.SD),
+					strconv.Itoa(a.DFBetween), strconv.Itoa(a.DFWithin), format(a.F), formatP(a.P), format(a.EtaSquared)})
+			}
+		}
+	})
+}
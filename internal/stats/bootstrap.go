@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// bootstrapIntervals returns percentile bootstrap intervals at level for the mean and median of
+// sorted, from resamples of its values drawn with replacement. A resample is kept as counts per
+// index of sorted, so its median is found by a cumulative scan rather than another sort.
+func bootstrapIntervals(sorted []float64, resamples int, level float64, rng *rand.Rand) (meanLower, meanUpper, medianLower, medianUpper float64) {
+	n := len(sorted)
+	if n < 2 || resamples < 1 {
+		nan := math.NaN()
+		return nan, nan, nan, nan
+	}
+	means := make([]float64, resamples)
+	medians := make([]float64, resamples)
+	counts := make([]int, n)
+	for r := 0; r < resamples; r++ {
+		for i := range counts {
+			counts[i] = 0
+		}
+		sum := 0.0
+		for k := 0; k < n; k++ {
+			i := rng.Intn(n)
+			counts[i]++
+			sum += sorted[i]
+		}
+		means[r] = sum / float64(n)
+		medians[r] = (resampledOrder(sorted, counts, (n-1)/2) + resampledOrder(sorted, counts, n/2)) / 2
+	}
+	sort.Float64s(means)
+	sort.Float64s(medians)
+	tail := (1 - level) / 2
+	return quantile(means, tail), quantile(means, 1-tail), quantile(medians, tail), quantile(medians, 1-tail)
+}
+
+// participantMeans returns each participant's mean of col over its non-missing values, sorted
+func participantMeans(points []types.DataPoint, col string) []float64 {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, p := range points {
+		if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+			sums[p.ParticipantID] += v
+			counts[p.ParticipantID]++
+		}
+	}
+	means := make([]float64, 0, len(counts))
+	for id, n := range counts {
+		means = append(means, sums[id]/float64(n))
+	}
+	sort.Float64s(means)
+	return means
+}
+
+// resampledOrder is the k-th smallest value (from 0) of a resample given as counts per index of sorted
+func resampledOrder(sorted []float64, counts []int, k int) float64 {
+	seen := 0
+	for i, c := range counts {
+		seen += c
+		if seen > k {
+			return sorted[i]
+		}
+	}
+	return sorted[len(sorted)-1]
+}
+
+func writeBootstrap(sb *strings.Builder, indent string, s ColumnStats, level float64) {
+	if s.Bootstrap == 0 {
+		return
+	}
+	unit := "samples"
+	if s.BootstrapUnit == "participant" {
+		unit = "participant means"
+	}
+	sb.WriteString(fmt.Sprintf("%sMeanCI: [%.4f, %.4f] (%g%%, %d bootstrap resamples of %s)\n", indent, s.MeanLower, s.MeanUpper, level*100, s.Bootstrap, unit))
+	sb.WriteString(fmt.Sprintf("%sMedianCI: [%.4f, %.4f]\n", indent, s.MedianLower, s.MedianUpper))
+}
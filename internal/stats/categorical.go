@@ -0,0 +1,180 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Categorical columns hold discrete codes (scene IDs, object-hit IDs, ...) where
+// counts and proportions are meaningful and means are not.
+
+type CategoryCount struct {
+	Value      float64
+	Count      int
+	Proportion float64
+}
+
+type FrequencyTable struct {
+	Column     string
+	Total      int
+	Missing    int
+	Categories []CategoryCount // Sorted by value
+	Mode       float64
+	ModeCount  int
+}
+
+func computeFrequencies(points []types.DataPoint, columns []string) []FrequencyTable {
+	var tables []FrequencyTable
+
+	for _, col := range columns {
+		counts := make(map[float64]int)
+		table := FrequencyTable{Column: col}
+		for _, p := range points {
+			val, ok := p.Data[col]
+			if !ok || math.IsNaN(val) {
+				table.Missing++
+				continue
+			}
+			counts[val]++
+			table.Total++
+		}
+		if table.Total == 0 {
+			continue
+		}
+
+		for val, count := range counts {
+			table.Categories = append(table.Categories, CategoryCount{
+				Value:      val,
+				Count:      count,
+				Proportion: float64(count) / float64(table.Total),
+			})
+		}
+		sort.Slice(table.Categories, func(i, j int) bool {
+			return table.Categories[i].Value < table.Categories[j].Value
+		})
+
+		// Ties go to the smallest value so the mode is deterministic
+		for _, c := range table.Categories {
+			if c.Count > table.ModeCount {
+				table.Mode = c.Value
+				table.ModeCount = c.Count
+			}
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables
+}
+
+func formatCategory(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeFrequencySections(sb *strings.Builder, r *StatsReport) {
+	if len(r.OverallFrequencies) > 0 {
+		sb.WriteString("Overall Frequencies:\n")
+		writeFrequencyTables(sb, "", r.OverallFrequencies)
+		sb.WriteString("\n")
+	}
+
+	sections := []struct {
+		title, label string
+		groups       map[string][]FrequencyTable
+	}{
+		{"Frequencies by Condition", "Condition", r.ConditionFrequencies},
+		{"Frequencies by Participant", "Participant", r.ParticipantFrequencies},
+	}
+	for _, section := range sections {
+		if len(section.groups) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(section.groups))
+		for key := range section.groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		sb.WriteString(section.title + ":\n")
+		for _, key := range keys {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", section.label, key))
+			writeFrequencyTables(sb, "  ", section.groups[key])
+			sb.WriteString("\n")
+		}
+	}
+}
+
+func writeFrequencyTables(sb *strings.Builder, indent string, tables []FrequencyTable) {
+	for _, table := range tables {
+		sb.WriteString(fmt.Sprintf("%sColumn: %s (categorical)\n", indent, table.Column))
+		sb.WriteString(fmt.Sprintf("%s  Total: %d\n", indent, table.Total))
+		sb.WriteString(fmt.Sprintf("%s  MissingCount: %d\n", indent, table.Missing))
+		sb.WriteString(fmt.Sprintf("%s  Mode: %s (%d)\n", indent, formatCategory(table.Mode), table.ModeCount))
+		for _, c := range table.Categories {
+			sb.WriteString(fmt.Sprintf("%s  %s: %d (%.1f%%)\n", indent, formatCategory(c.Value), c.Count, c.Proportion*100))
+		}
+	}
+}
+
+func (r *StatsReport) formatFrequencies(markdown bool) string {
+	var sb strings.Builder
+
+	sections := []struct {
+		title, groupName string
+		groups           map[string][]FrequencyTable
+	}{
+		{"Overall Frequencies", "", map[string][]FrequencyTable{"": r.OverallFrequencies}},
+		{"Frequencies by Condition", "condition", r.ConditionFrequencies},
+		{"Frequencies by Participant", "participant", r.ParticipantFrequencies},
+	}
+
+	for _, section := range sections {
+		keys := make([]string, 0, len(section.groups))
+		for key, tables := range section.groups {
+			if len(tables) > 0 {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+
+		var headers []string
+		if section.groupName != "" {
+			headers = append(headers, section.groupName)
+		}
+		headers = append(headers, "column", "value", "count", "proportion")
+		var rows [][]string
+		for _, key := range keys {
+			for _, table := range section.groups[key] {
+				for _, c := range table.Categories {
+					value := formatCategory(c.Value)
+					if c.Value == table.Mode {
+						value += " (mode)"
+					}
+					var row []string
+					if section.groupName != "" {
+						row = append(row, key)
+					}
+					rows = append(rows, append(row, table.Column, value, strconv.Itoa(c.Count), fmt.Sprintf("%.3f", c.Proportion)))
+				}
+			}
+		}
+
+		if markdown {
+			sb.WriteString("## " + section.title + "\n\n")
+		} else {
+			sb.WriteString(section.title + ":\n")
+		}
+		writeTable(&sb, headers, rows, markdown)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,146 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/cleaner"
+	"mbdvr/internal/types"
+)
+
+// ComparisonValue is one statistic under cleanings A and B
+type ComparisonValue struct {
+	Section    string  `json:"section"` // "retention" or "distribution"
+	Group      string  `json:"group"`   // "overall" or a condition (distribution only)
+	Column     string  `json:"column,omitempty"`
+	Statistic  string  `json:"statistic"`
+	A          float64 `json:"a"`
+	B          float64 `json:"b"`
+	Difference float64 `json:"difference"` // B - A
+}
+
+// CleaningComparison sets two cleanings of the same input side by side, to justify the choice between them
+type CleaningComparison struct {
+	Labels [2]string         `json:"labels"`
+	Values []ComparisonValue `json:"values"`
+}
+
+// CompareCleanings compares how much data two cleanings keep and the statistics of the analyzed columns
+// they leave, overall and per condition. Columns are analyzed as in ComputeStats.
+func CompareCleanings(labels [2]string, cleaned [2]*types.Dataset, cleanStats [2]cleaner.CleanStats, config StatsConfig) (*CleaningComparison, error) {
+	c := &CleaningComparison{Labels: labels}
+	add := func(section, group, column, statistic string, a, b float64) {
+		c.Values = append(c.Values, ComparisonValue{Section: section, Group: group, Column: column, Statistic: statistic, A: a, B: b, Difference: b - a})
+	}
+
+	retention := func(s cleaner.CleanStats) []float64 {
+		retained := 0.0
+		if s.OriginalPoints > 0 {
+			retained = float64(s.FinalPoints) / float64(s.OriginalPoints) * 100
+		}
+		return []float64{float64(s.FinalPoints), retained, float64(s.RemovedMissing), float64(s.RemovedOutliers), float64(s.OutlierValues),
+			float64(s.RemovedFiltered), float64(s.ExactDuplicates + s.NearDuplicates), float64(s.LowConfidence), float64(s.BlinkSamples),
+			float64(s.FastSamples), float64(s.Interpolated), float64(s.Imputed)}
+	}
+	names := []string{"final_points", "retained_percent", "removed_missing", "removed_outliers", "outlier_values",
+		"removed_filtered", "duplicates", "low_confidence", "blink_samples", "implausible_velocity_samples", "values_interpolated",
+		"values_imputed"}
+	a, b := retention(cleanStats[0]), retention(cleanStats[1])
+	for i, name := range names {
+		add("retention", "overall", "", name, a[i], b[i])
+	}
+
+	config.ByParticipant = false
+	config.HistogramBins, config.QuantileSteps, config.GapThreshold = 0, 0, 0
+	config.Radial, config.Tracking, config.GrandAverage, config.Sensitivity = nil, nil, false, ""
+	// The overall statistics are only computed without grouping
+	var overall, reports [2]*StatsReport
+	for i, dataset := range cleaned {
+		if dataset == nil || len(dataset.Points) == 0 {
+			return nil, fmt.Errorf("cleaning %s leaves no data", labels[i])
+		}
+		for _, byCondition := range []bool{false, true} {
+			config.ByCondition = byCondition
+			report, err := ComputeStats(dataset, config)
+			if err != nil {
+				return nil, fmt.Errorf("cleaning %s: %v", labels[i], err)
+			}
+			if byCondition {
+				reports[i] = report
+			} else {
+				overall[i] = report
+			}
+		}
+	}
+
+	distribution := func(group string, statsA, statsB []ColumnStats) {
+		byColumn := make(map[string]ColumnStats, len(statsB))
+		for _, s := range statsB {
+			byColumn[s.Column] = s
+		}
+		for _, sa := range statsA {
+			sb, ok := byColumn[sa.Column]
+			if !ok {
+				continue
+			}
+			add("distribution", group, sa.Column, "count", float64(sa.Count), float64(sb.Count))
+			add("distribution", group, sa.Column, "missing", float64(sa.MissingCount), float64(sb.MissingCount))
+			add("distribution", group, sa.Column, "mean", sa.Mean, sb.Mean)
+			add("distribution", group, sa.Column, "sd", sa.StdDev, sb.StdDev)
+			add("distribution", group, sa.Column, "median", sa.Median, sb.Median)
+			add("distribution", group, sa.Column, "mad", sa.MAD, sb.MAD)
+			add("distribution", group, sa.Column, "skewness", sa.Skewness, sb.Skewness)
+			add("distribution", group, sa.Column, "kurtosis", sa.Kurtosis, sb.Kurtosis)
+			add("distribution", group, sa.Column, "min", sa.Min, sb.Min)
+			add("distribution", group, sa.Column, "max", sa.Max, sb.Max)
+		}
+	}
+	distribution("overall", overall[0].OverallStats, overall[1].OverallStats)
+	var conditions []string
+	for condition := range reports[0].ConditionStats {
+		if _, ok := reports[1].ConditionStats[condition]; ok {
+			conditions = append(conditions, condition)
+		}
+	}
+	sort.Strings(conditions)
+	for _, condition := range conditions {
+		distribution(condition, reports[0].ConditionStats[condition], reports[1].ConditionStats[condition])
+	}
+	return c, nil
+}
+
+// SaveComparison writes a cleaning comparison as JSON (.json) or long-format CSV
+func SaveComparison(c *CleaningComparison, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create comparison file: %v", err)
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c); err != nil {
+			return fmt.Errorf("failed to write comparison: %v", err)
+		}
+		return f.Close()
+	}
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	w := csv.NewWriter(f)
+	w.Write([]string{"section", "group", "column", "statistic", c.Labels[0], c.Labels[1], "difference"})
+	for _, v := range c.Values {
+		w.Write([]string{v.Section, v.Group, v.Column, v.Statistic, format(v.A), format(v.B), format(v.Difference)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write comparison: %v", err)
+	}
+	return f.Close()
+}
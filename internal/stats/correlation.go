@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"mbdvr/internal/types"
+)
+
+// CorrelationMethods are the correlations computed between the analyzed columns
+var CorrelationMethods = []string{"pearson", "spearman"}
+
+// CorrelationMatrix holds the correlations between every pair of analyzed columns within a group.
+// Each pair uses the samples where both columns have values.
+type CorrelationMatrix struct {
+	Grouping string // overall or condition
+	Group    string
+	Method   string // pearson or spearman
+	Columns  []string
+	R        [][]float64 // R[i][j] between Columns[i] and Columns[j] (NaN with under 3 pairs or a constant column)
+	N        [][]int     // Pairs of values behind R[i][j]
+}
+
+// computeCorrelations returns the Pearson and Spearman matrices of the columns over the points
+func computeCorrelations(points []types.DataPoint, columns []string, grouping, group string) []CorrelationMatrix {
+	var matrices []CorrelationMatrix
+	for _, method := range CorrelationMethods {
+		m := CorrelationMatrix{Grouping: grouping, Group: group, Method: method, Columns: columns}
+		m.R = make([][]float64, len(columns))
+		m.N = make([][]int, len(columns))
+		for i := range columns {
+			m.R[i] = make([]float64, len(columns))
+			m.N[i] = make([]int, len(columns))
+		}
+		for i := range columns {
+			for j := i; j < len(columns); j++ {
+				x, y := pairedValues(points, columns[i], columns[j])
+				if method == "spearman" {
+					x, y = ranks(x), ranks(y)
+				}
+				r, ok := pearson(x, y)
+				switch {
+				case !ok || len(x) < 3 || constant(x) || constant(y):
+					r = math.NaN()
+				case i == j:
+					r = 1 // Exactly, without rounding
+				}
+				m.R[i][j], m.R[j][i] = r, r
+				m.N[i][j], m.N[j][i] = len(x), len(x)
+			}
+		}
+		matrices = append(matrices, m)
+	}
+	return matrices
+}
+
+// pairedValues returns the values of two columns from the points where both have one
+func pairedValues(points []types.DataPoint, a, b string) ([]float64, []float64) {
+	var x, y []float64
+	for _, p := range points {
+		va, okA := p.Data[a]
+		vb, okB := p.Data[b]
+		if okA && okB && !math.IsNaN(va) && !math.IsNaN(vb) {
+			x = append(x, va)
+			y = append(y, vb)
+		}
+	}
+	return x, y
+}
+
+// constant reports whether all values are the same, which rounding can hide from the sums of squares
+func constant(values []float64) bool {
+	for _, v := range values {
+		if v != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// ranks replaces values by their ranks from 1, giving tied values their average rank
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+	ranked := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+		rank := float64(start+end+1) / 2 // Average of ranks start+1 .. end
+		for _, i := range order[start:end] {
+			ranked[i] = rank
+		}
+		start = end
+	}
+	return ranked
+}
+
+// SaveCorrelations writes the report's correlation matrices as CSV: one row per column of each
+// matrix, holding its correlations with every column
+func SaveCorrelations(report *StatsReport, filename string) error {
+	if len(report.Correlations) == 0 {
+		return fmt.Errorf("report has no correlations")
+	}
+	columns := report.Correlations[0].Columns
+	header := append([]string{"grouping", "group", "method", "column"}, columns...)
+	return writeDistributionCSV(filename, header, func(w *csv.Writer) {
+		for _, m := range report.Correlations {
+			for i, col := range m.Columns {
+				row := []string{m.Grouping, m.Group, m.Method, col}
+				for j := range m.Columns {
+					row = append(row, strconv.FormatFloat(m.R[i][j], 'f', 6, 64))
+				}
+				w.Write(row)
+			}
+		}
+	})
+}
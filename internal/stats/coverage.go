@@ -0,0 +1,216 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// CoverageCell counts the points of one participant and condition in one input file
+type CoverageCell struct {
+	Input         string
+	ParticipantID string
+	Condition     string
+	Points        int
+}
+
+// Coverage records which participants and conditions each pooled input contributes, so imbalanced
+// pooling is visible before pooled statistics are interpreted
+type Coverage struct {
+	Inputs []string
+	Cells  []CoverageCell // Sorted by input order, then participant and condition
+}
+
+// Add counts the points an input contributes per participant and condition
+func (c *Coverage) Add(input string, points []types.DataPoint) {
+	counts := make(map[[2]string]int)
+	for _, p := range points {
+		counts[[2]string{p.ParticipantID, p.Condition}]++
+	}
+	keys := make([][2]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return naturalLess(keys[i][0], keys[j][0])
+		}
+		return naturalLess(keys[i][1], keys[j][1])
+	})
+
+	c.Inputs = append(c.Inputs, input)
+	for _, key := range keys {
+		c.Cells = append(c.Cells, CoverageCell{Input: input, ParticipantID: key[0], Condition: key[1], Points: counts[key]})
+	}
+}
+
+// imbalanceRatio is how far a participant's point count in a condition may stray from the
+// condition's median before it is reported
+const imbalanceRatio = 2
+
+// Warnings lists participants missing from a condition, participant/condition pairs contributed by
+// more than one input (possibly the same data pooled twice) and point counts that differ from the
+// condition's median by more than imbalanceRatio
+func (c *Coverage) Warnings() []string {
+	participants, conditions := c.labels()
+	totals := make(map[[2]string]int)
+	inputs := make(map[[2]string][]string)
+	for _, cell := range c.Cells {
+		key := [2]string{cell.ParticipantID, cell.Condition}
+		totals[key] += cell.Points
+		inputs[key] = append(inputs[key], cell.Input)
+	}
+
+	var warnings []string
+	for _, condition := range conditions {
+		var missing []string
+		var counts []float64
+		for _, id := range participants {
+			if n, ok := totals[[2]string{id, condition}]; ok {
+				counts = append(counts, float64(n))
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s has no data for %s", conditionLabel(condition), strings.Join(missing, ", ")))
+		}
+
+		sort.Float64s(counts)
+		median := quantile(counts, 0.5)
+		for _, id := range participants {
+			n, ok := totals[[2]string{id, condition}]
+			if !ok || median == 0 {
+				continue
+			}
+			if ratio := float64(n) / median; ratio > imbalanceRatio || ratio < 1.0/imbalanceRatio {
+				warnings = append(warnings, fmt.Sprintf("%s in %s has %d points, %.1fx the condition median of %.0f",
+					id, conditionLabel(condition), n, ratio, median))
+			}
+		}
+	}
+
+	for _, id := range participants {
+		for _, condition := range conditions {
+			if in := inputs[[2]string{id, condition}]; len(in) > 1 {
+				warnings = append(warnings, fmt.Sprintf("%s in %s appears in %d inputs (%s)",
+					id, conditionLabel(condition), len(in), strings.Join(in, ", ")))
+			}
+		}
+	}
+	return warnings
+}
+
+// labels returns the participants and conditions across all inputs, in natural order
+func (c *Coverage) labels() (participants, conditions []string) {
+	seenParticipants := make(map[string]bool)
+	seenConditions := make(map[string]bool)
+	for _, cell := range c.Cells {
+		if !seenParticipants[cell.ParticipantID] {
+			seenParticipants[cell.ParticipantID] = true
+			participants = append(participants, cell.ParticipantID)
+		}
+		if !seenConditions[cell.Condition] {
+			seenConditions[cell.Condition] = true
+			conditions = append(conditions, cell.Condition)
+		}
+	}
+	sort.Slice(participants, func(i, j int) bool { return naturalLess(participants[i], participants[j]) })
+	sort.Slice(conditions, func(i, j int) bool { return naturalLess(conditions[i], conditions[j]) })
+	return participants, conditions
+}
+
+// String formats the coverage matrix: one row per participant, one column per input and
+// condition, with point counts ("-" where a participant has no data)
+func (c *Coverage) String() string {
+	participants, _ := c.labels()
+
+	type column struct{ input, condition string }
+	var columns []column
+	seen := make(map[column]bool)
+	counts := make(map[column]map[string]int)
+	for _, cell := range c.Cells {
+		col := column{cell.Input, cell.Condition}
+		if !seen[col] {
+			seen[col] = true
+			columns = append(columns, col)
+			counts[col] = make(map[string]int)
+		}
+		counts[col][cell.ParticipantID] += cell.Points
+	}
+	// Input order first, then conditions within an input
+	inputOrder := make(map[string]int)
+	for i, input := range c.Inputs {
+		if _, ok := inputOrder[input]; !ok {
+			inputOrder[input] = i
+		}
+	}
+	sort.SliceStable(columns, func(i, j int) bool {
+		if columns[i].input != columns[j].input {
+			return inputOrder[columns[i].input] < inputOrder[columns[j].input]
+		}
+		return naturalLess(columns[i].condition, columns[j].condition)
+	})
+
+	rows := [][]string{{"participant"}}
+	for _, col := range columns {
+		rows[0] = append(rows[0], fmt.Sprintf("%s [%s]", col.input, conditionLabel(col.condition)))
+	}
+	for _, id := range participants {
+		row := []string{id}
+		for _, col := range columns {
+			if n, ok := counts[col][id]; ok {
+				row = append(row, strconv.Itoa(n))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	var sb strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == 0 {
+				sb.WriteString(fmt.Sprintf("%-*s", widths[i], cell))
+			} else {
+				sb.WriteString(fmt.Sprintf("  %*s", widths[i], cell))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func writeCoverageSection(sb *strings.Builder, report *StatsReport) {
+	if report.Coverage == nil {
+		return
+	}
+	sb.WriteString("Coverage (points per participant, input and condition):\n")
+	sb.WriteString(report.Coverage.String())
+	for _, w := range report.Coverage.Warnings() {
+		sb.WriteString(fmt.Sprintf("  Warning: %s\n", w))
+	}
+	sb.WriteString("\n")
+}
+
+// SaveCoverage writes the coverage matrix as CSV in long format, one row per input, participant and condition
+func SaveCoverage(coverage *Coverage, filename string) error {
+	return writeDistributionCSV(filename, []string{"input", "participant_id", "condition", "points"}, func(w *csv.Writer) {
+		for _, cell := range coverage.Cells {
+			w.Write([]string{cell.Input, cell.ParticipantID, cell.Condition, strconv.Itoa(cell.Points)})
+		}
+	})
+}
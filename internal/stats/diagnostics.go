@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+
+	"mbdvr/internal/diag"
+	"mbdvr/internal/types"
+)
+
+// checkSkippedColumns warns about analyzed columns left out of the report: columns without any value,
+// and columns without values in some of the condition or participant groups
+func checkSkippedColumns(found *diag.Collector, dataset *types.Dataset, report *StatsReport, columns []string) {
+	empty := make(map[string]bool)
+	for _, col := range columns {
+		if len(extractColumnValues(dataset.Points, col)) == 0 {
+			empty[col] = true
+			found.Warnf("stats", diag.SkippedColumn, col, "column %s has no values and was left out of the statistics", col)
+		}
+	}
+
+	for _, grouping := range []struct {
+		name   string
+		groups map[string][]ColumnStats
+	}{{"condition", report.ConditionStats}, {"participant", report.ParticipantStats}} {
+		names := make([]string, 0, len(grouping.groups))
+		for name := range grouping.groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, col := range columns {
+			if empty[col] {
+				continue
+			}
+			var without []string
+			for _, name := range names {
+				present := false
+				for _, s := range grouping.groups[name] {
+					present = present || s.Column == col
+				}
+				if !present {
+					without = append(without, name)
+				}
+			}
+			if len(without) > 0 {
+				found.Warnf("stats", diag.SkippedColumn, col, "column %s has no values for %s %s", col, grouping.name, strings.Join(without, ", "))
+			}
+		}
+	}
+}
+
+// formatDiagnostics renders the report's diagnostics as a text or Markdown section
+func (r *StatsReport) formatDiagnostics(markdown bool) string {
+	if len(r.Diagnostics) == 0 {
+		return ""
+	}
+	if !markdown {
+		return diag.Format(r.Diagnostics) + "\n"
+	}
+
+	var sb strings.Builder
+	rows := make([][]string, len(r.Diagnostics))
+	for i, d := range r.Diagnostics {
+		rows[i] = []string{d.Stage, string(d.Kind), escapeMarkdown(d.Source), escapeMarkdown(d.Column), escapeMarkdown(d.Message)}
+	}
+	sb.WriteString("## Diagnostics\n\n")
+	writeTable(&sb, []string{"stage", "kind", "source", "column", "message"}, rows, true)
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"mbdvr/internal/types"
+)
+
+// Distribution holds the quantile function and empirical CDF of one column within a group,
+// for KS-style plots and shift functions downstream.
+type Distribution struct {
+	Grouping  string // overall, condition or participant
+	Group     string
+	Column    string
+	Count     int
+	Quantiles []QuantilePoint
+	ECDF      []ECDFPoint // One step per distinct value
+}
+
+type QuantilePoint struct {
+	Probability float64
+	Value       float64
+}
+
+type ECDFPoint struct {
+	Value      float64
+	Cumulative float64 // Proportion of values <= Value
+}
+
+func computeDistributions(points []types.DataPoint, columns []string, steps int, grouping, group string) []Distribution {
+	var distributions []Distribution
+
+	for _, col := range columns {
+		values := extractColumnValues(points, col)
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+
+		d := Distribution{Grouping: grouping, Group: group, Column: col, Count: len(values)}
+		for i := 0; i <= steps; i++ {
+			p := float64(i) / float64(steps)
+			d.Quantiles = append(d.Quantiles, QuantilePoint{Probability: p, Value: quantile(values, p)})
+		}
+		for i, v := range values {
+			if i+1 < len(values) && values[i+1] == v {
+				continue
+			}
+			d.ECDF = append(d.ECDF, ECDFPoint{Value: v, Cumulative: float64(i+1) / float64(len(values))})
+		}
+
+		distributions = append(distributions, d)
+	}
+
+	return distributions
+}
+
+// quantile interpolates linearly between order statistics (p in [0, 1])
+func quantile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	k := p * float64(len(sorted)-1)
+	lo := int(k)
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := k - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
+
+// shape returns the bias-adjusted sample skewness (G1) and excess kurtosis (G2), as reported by SPSS
+// and Excel; 0 for both when the values are normal. Skewness needs 3 values and kurtosis 4 (NaN
+// otherwise), and both are NaN for constant values.
+func shape(values []float64, mean float64) (skewness, kurtosis float64) {
+	n := float64(len(values))
+	var m2, m3, m4 float64
+	for _, v := range values {
+		d := v - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	skewness, kurtosis = math.NaN(), math.NaN()
+	if m2 == 0 {
+		return
+	}
+	if n >= 3 {
+		g1 := m3 / math.Pow(m2, 1.5)
+		skewness = g1 * math.Sqrt(n*(n-1)) / (n - 2)
+	}
+	if n >= 4 {
+		g2 := m4/(m2*m2) - 3
+		kurtosis = (n - 1) / ((n - 2) * (n - 3)) * ((n+1)*g2 + 6)
+	}
+	return
+}
+
+func sortedDistributions(report *StatsReport) []Distribution {
+	distributions := make([]Distribution, len(report.Distributions))
+	copy(distributions, report.Distributions)
+	sort.SliceStable(distributions, func(i, j int) bool {
+		if distributions[i].Grouping != distributions[j].Grouping {
+			return distributions[i].Grouping < distributions[j].Grouping
+		}
+		return distributions[i].Group < distributions[j].Group
+	})
+	return distributions
+}
+
+// SaveQuantiles writes the quantile function of every column and group as long-format CSV
+func SaveQuantiles(report *StatsReport, filename string) error {
+	if len(report.Distributions) == 0 {
+		return fmt.Errorf("report has no distributions")
+	}
+
+	return writeDistributionCSV(filename, []string{"grouping", "group", "column", "probability", "quantile"}, func(w *csv.Writer) {
+		for _, d := range sortedDistributions(report) {
+			for _, q := range d.Quantiles {
+				w.Write([]string{d.Grouping, d.Group, d.Column, strconv.FormatFloat(q.Probability, 'f', -1, 64), strconv.FormatFloat(q.Value, 'g', -1, 64)})
+			}
+		}
+	})
+}
+
+// SaveECDF writes the empirical CDF of every column and group as long-format CSV
+func SaveECDF(report *StatsReport, filename string) error {
+	if len(report.Distributions) == 0 {
+		return fmt.Errorf("report has no distributions")
+	}
+
+	return writeDistributionCSV(filename, []string{"grouping", "group", "column", "value", "cumulative"}, func(w *csv.Writer) {
+		for _, d := range sortedDistributions(report) {
+			for _, e := range d.ECDF {
+				w.Write([]string{d.Grouping, d.Group, d.Column, strconv.FormatFloat(e.Value, 'g', -1, 64), strconv.FormatFloat(e.Cumulative, 'f', 6, 64)})
+			}
+		}
+	})
+}
+
+func writeDistributionCSV(filename string, header []string, writeRows func(w *csv.Writer)) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(header)
+	writeRows(w)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return f.Close()
+}
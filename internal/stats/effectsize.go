@@ -0,0 +1,133 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// EffectSize is the standardized mean difference of one analyzed column between two conditions,
+// over participant means like TTest and ANOVA
+type EffectSize struct {
+	Column     string
+	ConditionA string
+	ConditionB string
+	NA, NB     int // Participants in each condition
+	MeanA      float64
+	MeanB      float64
+	PooledSD   float64 // SD of the participant means pooled over both conditions
+	CohensD    float64 // (MeanA - MeanB) / PooledSD (NaN under 2 participants in either condition or with no spread)
+	DLower     float64 // Confidence interval of d from its large-sample standard error
+	DUpper     float64
+	HedgesG    float64 // d corrected for its small-sample bias
+	GLower     float64 // The d interval with the same correction
+	GUpper     float64
+}
+
+// computeEffectSizes compares every pair of conditions, in sorted order, for each column
+func computeEffectSizes(points []types.DataPoint, columns []string, level float64) []EffectSize {
+	type key struct{ condition, participant, column string }
+	sums := make(map[key]float64)
+	counts := make(map[key]int)
+	for _, p := range points {
+		for _, col := range columns {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				k := key{p.Condition, p.ParticipantID, col}
+				sums[k] += v
+				counts[k]++
+			}
+		}
+	}
+	var conditions []string
+	seen := make(map[string]bool)
+	for k := range counts {
+		if !seen[k.condition] {
+			seen[k.condition] = true
+			conditions = append(conditions, k.condition)
+		}
+	}
+	sort.Strings(conditions)
+	z := math.Sqrt2 * math.Erfinv(level)
+
+	var sizes []EffectSize
+	for _, col := range columns {
+		means := make(map[string][]float64)
+		for k, n := range counts {
+			if k.column == col {
+				means[k.condition] = append(means[k.condition], sums[k]/float64(n))
+			}
+		}
+		for i, conditionA := range conditions {
+			for _, conditionB := range conditions[i+1:] {
+				a, b := means[conditionA], means[conditionB]
+				sort.Float64s(a) // Map order would otherwise change the sums in the last digits
+				sort.Float64s(b)
+				sizes = append(sizes, effectSize(col, conditionA, conditionB, a, b, z))
+			}
+		}
+	}
+	return sizes
+}
+
+// effectSize computes d and g between the participant means a and b, with intervals of ±z standard errors
+func effectSize(col, conditionA, conditionB string, a, b []float64, z float64) EffectSize {
+	nan := math.NaN()
+	e := EffectSize{Column: col, ConditionA: conditionA, ConditionB: conditionB, NA: len(a), NB: len(b),
+		MeanA: mean(a), MeanB: mean(b), PooledSD: nan, CohensD: nan, DLower: nan, DUpper: nan, HedgesG: nan, GLower: nan, GUpper: nan}
+	if len(a) < 2 || len(b) < 2 {
+		return e
+	}
+	na, nb := float64(len(a)), float64(len(b))
+	e.PooledSD = math.Sqrt(((na-1)*variance(a, e.MeanA) + (nb-1)*variance(b, e.MeanB)) / (na + nb - 2))
+	if e.PooledSD == 0 {
+		return e
+	}
+	e.CohensD = (e.MeanA - e.MeanB) / e.PooledSD
+	se := math.Sqrt((na+nb)/(na*nb) + e.CohensD*e.CohensD/(2*(na+nb)))
+	e.DLower, e.DUpper = e.CohensD-z*se, e.CohensD+z*se
+
+	correction := 1 - 3/(4*(na+nb)-9)
+	e.HedgesG = e.CohensD * correction
+	e.GLower, e.GUpper = e.DLower*correction, e.DUpper*correction
+	return e
+}
+
+func writeEffectSizeSection(sb *strings.Builder, report *StatsReport) {
+	if len(report.EffectSizes) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("Effect Sizes (participant means, %g%% CI):\n", report.ConfidenceLevel*100))
+	for _, e := range report.EffectSizes {
+		sb.WriteString(fmt.Sprintf("Column: %s | %s vs %s (n = %d, %d)\n", e.Column, conditionLabel(e.ConditionA), conditionLabel(e.ConditionB), e.NA, e.NB))
+		sb.WriteString(fmt.Sprintf("  Cohen's d: %.3f [%.3f, %.3f]\n", e.CohensD, e.DLower, e.DUpper))
+		sb.WriteString(fmt.Sprintf("  Hedges' g: %.3f [%.3f, %.3f]\n", e.HedgesG, e.GLower, e.GUpper))
+	}
+	sb.WriteString("\n")
+}
+
+// SaveEffectSizes writes the report's effect sizes as CSV, one row per column and pair of conditions
+func SaveEffectSizes(report *StatsReport, filename string) error {
+	if len(report.EffectSizes) == 0 {
+		return fmt.Errorf("report has no effect sizes")
+	}
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	header := []string{"column", "condition_a", "condition_b", "n_a", "n_b", "mean_a", "mean_b", "pooled_sd",
+		"cohens_d", "d_lower", "d_upper", "hedges_g", "g_lower", "g_upper", "confidence"}
+	return writeDistributionCSV(filename, header, func(w *csv.Writer) {
+		for _, e := range report.EffectSizes {
+			w.Write([]string{e.Column, e.ConditionA, e.ConditionB, strconv.Itoa(e.NA), strconv.Itoa(e.NB),
+				format(e.MeanA), format(e.MeanB), format(e.PooledSD), format(e.CohensD), format(e.DLower), format(e.DUpper),
+				format(e.HedgesG), format(e.GLower), format(e.GUpper), format(report.ConfidenceLevel)})
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type FormatOptions struct {
+	Digits   int      // Significant digits, 0 = fixed 4 decimal places
+	Fields   []string // Statistics to include (see FieldNames), empty = all
+	Layout   string   // "wide" (one row per column) or "long" (one row per statistic)
+	Markdown bool     // Render tables as Markdown instead of aligned text
+}
+
+type reportField struct {
+	name     string
+	integer  bool
+	value    func(ColumnStats) float64
+	optional bool // Only shown when requested with Fields
+}
+
+var reportFields = []reportField{
+	{"count", true, func(s ColumnStats) float64 { return float64(s.Count) }, false},
+	{"missing", true, func(s ColumnStats) float64 { return float64(s.MissingCount) }, false},
+	{"mean", false, func(s ColumnStats) float64 { return s.Mean }, false},
+	{"median", false, func(s ColumnStats) float64 { return s.Median }, false},
+	{"sd", false, func(s ColumnStats) float64 { return s.StdDev }, false},
+	{"min", false, func(s ColumnStats) float64 { return s.Min }, false},
+	{"max", false, func(s ColumnStats) float64 { return s.Max }, false},
+	{"outliers", true, func(s ColumnStats) float64 { return float64(s.OutlierCount) }, false},
+	{"outlier_lower", false, func(s ColumnStats) float64 { return s.OutlierLower }, true},
+	{"outlier_upper", false, func(s ColumnStats) float64 { return s.OutlierUpper }, true},
+	{"trimmed_mean", false, func(s ColumnStats) float64 { return s.TrimmedMean }, true},
+	{"winsorized_mean", false, func(s ColumnStats) float64 { return s.WinsorizedMean }, true},
+	{"mad", false, func(s ColumnStats) float64 { return s.MAD }, true},
+	{"skewness", false, func(s ColumnStats) float64 { return s.Skewness }, true},
+	{"kurtosis", false, func(s ColumnStats) float64 { return s.Kurtosis }, true},
+	{"mean_lower", false, func(s ColumnStats) float64 { return s.MeanLower }, true},
+	{"mean_upper", false, func(s ColumnStats) float64 { return s.MeanUpper }, true},
+	{"median_lower", false, func(s ColumnStats) float64 { return s.MedianLower }, true},
+	{"median_upper", false, func(s ColumnStats) float64 { return s.MedianUpper }, true},
+}
+
+func FieldNames() []string {
+	names := make([]string, len(reportFields))
+	for i, f := range reportFields {
+		names[i] = f.name
+	}
+	return names
+}
+
+func selectFields(names []string) ([]reportField, error) {
+	if len(names) == 0 {
+		var defaults []reportField
+		for _, f := range reportFields {
+			if !f.optional {
+				defaults = append(defaults, f)
+			}
+		}
+		return defaults, nil
+	}
+
+	var selected []reportField
+	for _, name := range names {
+		if f, ok := percentileField(name); ok {
+			selected = append(selected, f)
+			continue
+		}
+		found := false
+		for _, f := range reportFields {
+			if f.name == strings.ToLower(name) {
+				selected = append(selected, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown statistic %q (available: %s, or a percentile such as p95)", name, strings.Join(FieldNames(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+// percentileField reads a percentile statistic such as "p95" or "p2.5"; columns without that
+// percentile (see StatsConfig.Percentiles) show NaN
+func percentileField(name string) (reportField, bool) {
+	name = strings.ToLower(name)
+	if !strings.HasPrefix(name, "p") {
+		return reportField{}, false
+	}
+	p, err := strconv.ParseFloat(name[1:], 64)
+	if err != nil || p < 0 || p > 100 {
+		return reportField{}, false
+	}
+	return reportField{name: name, value: func(s ColumnStats) float64 {
+		for _, pc := range s.Percentiles {
+			if pc.P == p {
+				return pc.Value
+			}
+		}
+		return math.NaN()
+	}, optional: true}, true
+}
+
+func (r *StatsReport) Format(opts FormatOptions) (string, error) {
+	fields, err := selectFields(opts.Fields)
+	if err != nil {
+		return "", err
+	}
+	if opts.Layout != "" && opts.Layout != "wide" && opts.Layout != "long" {
+		return "", fmt.Errorf("unknown table layout %q (use 'wide' or 'long')", opts.Layout)
+	}
+
+	var sb strings.Builder
+
+	if len(r.OverallStats) > 0 {
+		writeSection(&sb, "Overall Statistics", "", map[string][]ColumnStats{"": r.OverallStats}, fields, opts)
+	}
+	if len(r.ConditionStats) > 0 {
+		writeSection(&sb, "Statistics by Condition", "condition", r.ConditionStats, fields, opts)
+	}
+	if len(r.ParticipantStats) > 0 {
+		writeSection(&sb, "Statistics by Participant", "participant", r.ParticipantStats, fields, opts)
+	}
+	sb.WriteString(r.formatFrequencies(opts.Markdown))
+	sb.WriteString(r.formatDiagnostics(opts.Markdown))
+
+	return sb.String(), nil
+}
+
+func writeSection(sb *strings.Builder, title, groupName string, groups map[string][]ColumnStats, fields []reportField, opts FormatOptions) {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var headers []string
+	if groupName != "" {
+		headers = append(headers, groupName)
+	}
+	headers = append(headers, "column")
+	if opts.Layout == "long" {
+		headers = append(headers, "statistic", "value")
+	} else {
+		for _, f := range fields {
+			headers = append(headers, f.name)
+		}
+	}
+
+	var rows [][]string
+	for _, key := range keys {
+		for _, colStats := range groups[key] {
+			prefix := []string{colStats.Column}
+			if groupName != "" {
+				prefix = []string{key, colStats.Column}
+			}
+
+			if opts.Layout == "long" {
+				for _, f := range fields {
+					row := append(append([]string{}, prefix...), f.name, formatField(f, colStats, opts.Digits))
+					rows = append(rows, row)
+				}
+				continue
+			}
+
+			row := append([]string{}, prefix...)
+			for _, f := range fields {
+				row = append(row, formatField(f, colStats, opts.Digits))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if opts.Markdown {
+		sb.WriteString("## " + title + "\n\n")
+	} else {
+		sb.WriteString(title + ":\n")
+	}
+	writeTable(sb, headers, rows, opts.Markdown)
+	sb.WriteString("\n")
+}
+
+func writeTable(sb *strings.Builder, headers []string, rows [][]string, markdown bool) {
+	if markdown {
+		sb.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+		sep := make([]string, len(headers))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		sb.WriteString("|" + strings.Join(sep, "|") + "|\n")
+		for _, row := range rows {
+			sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		return
+	}
+
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	writeRow := func(cells []string) {
+		var line strings.Builder
+		for i, cell := range cells {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(fmt.Sprintf("%-*s", widths[i], cell))
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	writeRow(headers)
+	for _, row := range rows {
+		writeRow(row)
+	}
+}
+
+func formatField(f reportField, s ColumnStats, digits int) string {
+	v := f.value(s)
+	if f.integer {
+		return strconv.Itoa(int(v))
+	}
+	return formatValue(v, digits)
+}
+
+func formatValue(v float64, digits int) string {
+	if digits <= 0 {
+		return fmt.Sprintf("%.4f", v)
+	}
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	decimals := digits - 1 - int(math.Floor(math.Log10(math.Abs(v))))
+	if decimals >= 0 {
+		return strconv.FormatFloat(v, 'f', decimals, 64)
+	}
+	scale := math.Pow(10, float64(-decimals))
+	return strconv.FormatFloat(math.Round(v/scale)*scale, 'f', 0, 64)
+}
+
+func SaveFormattedReport(report *StatsReport, outputPath string, opts FormatOptions) error {
+	var content string
+	var err error
+	switch {
+	case isMarkdownPath(outputPath):
+		content, err = report.Markdown(opts)
+	case isLaTeXPath(outputPath):
+		content, err = report.LaTeX(opts)
+	default:
+		content, err = report.Format(opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeReportFile(outputPath, content)
+}
@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Gap is a stretch without samples longer than the gap threshold
+type Gap struct {
+	ParticipantID string
+	Condition     string // Condition of the sample before the gap
+	Start         float64
+	End           float64
+	Duration      float64
+}
+
+// GapSummary describes the data loss of one participant's recording
+type GapSummary struct {
+	ParticipantID string
+	Samples       int
+	Duration      float64 // Seconds from first to last sample
+	Gaps          int
+	LongestGap    float64
+	LostTime      float64 // Gap time beyond the typical sampling interval
+	LossPercent   float64 // LostTime as a percentage of Duration
+}
+
+// computeGaps scans each participant's timestamps for intervals longer than threshold seconds.
+// A gap loses its length minus one typical (median) sampling interval, since that much time
+// would pass between samples anyway.
+func computeGaps(points []types.DataPoint, threshold float64) ([]Gap, []GapSummary) {
+	byParticipant := make(map[string][]types.DataPoint)
+	var participants []string
+	for _, p := range points {
+		if _, ok := byParticipant[p.ParticipantID]; !ok {
+			participants = append(participants, p.ParticipantID)
+		}
+		byParticipant[p.ParticipantID] = append(byParticipant[p.ParticipantID], p)
+	}
+	sort.Strings(participants)
+
+	var gaps []Gap
+	var summaries []GapSummary
+	for _, participant := range participants {
+		recording := byParticipant[participant]
+		sort.SliceStable(recording, func(i, j int) bool { return recording[i].Timestamp < recording[j].Timestamp })
+
+		summary := GapSummary{ParticipantID: participant, Samples: len(recording)}
+		summary.Duration = recording[len(recording)-1].Timestamp - recording[0].Timestamp
+
+		intervals := make([]float64, 0, len(recording))
+		for i := 1; i < len(recording); i++ {
+			intervals = append(intervals, recording[i].Timestamp-recording[i-1].Timestamp)
+		}
+		typical := 0.0
+		if len(intervals) > 0 {
+			sorted := append([]float64(nil), intervals...)
+			sort.Float64s(sorted)
+			typical = quantile(sorted, 0.5)
+		}
+
+		for i, d := range intervals {
+			if d <= threshold {
+				continue
+			}
+			prev := recording[i]
+			gaps = append(gaps, Gap{
+				ParticipantID: participant,
+				Condition:     prev.Condition,
+				Start:         prev.Timestamp,
+				End:           recording[i+1].Timestamp,
+				Duration:      d,
+			})
+			summary.Gaps++
+			summary.LostTime += d - typical
+			if d > summary.LongestGap {
+				summary.LongestGap = d
+			}
+		}
+		if summary.Duration > 0 {
+			summary.LossPercent = summary.LostTime / summary.Duration * 100
+		}
+		summaries = append(summaries, summary)
+	}
+	return gaps, summaries
+}
+
+func writeGapSection(sb *strings.Builder, report *StatsReport) {
+	if len(report.GapSummaries) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("Gaps (longer than %gs):\n", report.GapThreshold))
+	for _, s := range report.GapSummaries {
+		sb.WriteString(fmt.Sprintf("Participant: %s\n", s.ParticipantID))
+		sb.WriteString(fmt.Sprintf("  Duration: %.3f s\n", s.Duration))
+		sb.WriteString(fmt.Sprintf("  Gaps: %d\n", s.Gaps))
+		sb.WriteString(fmt.Sprintf("  LongestGap: %.3f s\n", s.LongestGap))
+		sb.WriteString(fmt.Sprintf("  DataLoss: %.2f%%\n", s.LossPercent))
+	}
+	sb.WriteString(fmt.Sprintf("TotalDataLoss: %.2f%%\n", report.TotalLossPercent()))
+	for _, g := range report.Gaps {
+		sb.WriteString(fmt.Sprintf("  %s: %.3f - %.3f (%.3f s)\n", g.ParticipantID, g.Start, g.End, g.Duration))
+	}
+	sb.WriteString("\n")
+}
+
+// TotalLossPercent is the data loss over all recordings
+func (r *StatsReport) TotalLossPercent() float64 {
+	var lost, duration float64
+	for _, s := range r.GapSummaries {
+		lost += s.LostTime
+		duration += s.Duration
+	}
+	if duration == 0 {
+		return 0
+	}
+	return lost / duration * 100
+}
+
+// SaveGaps writes the gaps report as CSV, one row per gap
+func SaveGaps(report *StatsReport, filename string) error {
+	if report.GapSummaries == nil {
+		return fmt.Errorf("report has no gap analysis")
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	return writeDistributionCSV(filename, []string{"participant_id", "condition", "start", "end", "duration"}, func(w *csv.Writer) {
+		for _, g := range report.Gaps {
+			w.Write([]string{g.ParticipantID, g.Condition, format(g.Start), format(g.End), format(g.Duration)})
+		}
+	})
+}
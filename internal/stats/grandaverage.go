@@ -0,0 +1,153 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// GrandAverage is the across-participant mean of a column at one time point of a condition
+type GrandAverage struct {
+	Condition string
+	Time      float64
+	Column    string
+	N         int // Participants with a value at this time
+	Mean      float64
+	SD        float64 // Between participants (NaN with fewer than two)
+	SE        float64
+	CILower   float64 // Student t interval at the configured confidence level
+	CIUpper   float64
+}
+
+// timeKey rounds timestamps to microseconds, so grid times that went through a CSV round trip still match
+func timeKey(t float64) float64 {
+	return math.Round(t*1e6) / 1e6
+}
+
+// computeGrandAverages averages time courses pointwise across participants. Each participant's samples
+// at a time point are averaged first, so participants count once however many samples they have there.
+// Timestamps must be aligned across participants, e.g. with resample --align.
+func computeGrandAverages(points []types.DataPoint, cols []string, level float64) []GrandAverage {
+	type cell struct {
+		condition string
+		time      float64
+	}
+	// cell -> column -> participant -> (sum, count)
+	sums := make(map[cell]map[string]map[string][2]float64)
+	for _, p := range points {
+		c := cell{p.Condition, timeKey(p.Timestamp)}
+		byCol, ok := sums[c]
+		if !ok {
+			byCol = make(map[string]map[string][2]float64)
+			sums[c] = byCol
+		}
+		for _, col := range cols {
+			v, ok := p.Data[col]
+			if !ok || math.IsNaN(v) {
+				continue
+			}
+			if byCol[col] == nil {
+				byCol[col] = make(map[string][2]float64)
+			}
+			acc := byCol[col][p.ParticipantID]
+			byCol[col][p.ParticipantID] = [2]float64{acc[0] + v, acc[1] + 1}
+		}
+	}
+
+	cells := make([]cell, 0, len(sums))
+	for c := range sums {
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].condition != cells[j].condition {
+			return naturalLess(cells[i].condition, cells[j].condition)
+		}
+		return cells[i].time < cells[j].time
+	})
+
+	var averages []GrandAverage
+	for _, c := range cells {
+		for _, col := range cols {
+			byParticipant := sums[c][col]
+			if len(byParticipant) == 0 {
+				continue
+			}
+			values := make([]float64, 0, len(byParticipant))
+			for _, acc := range byParticipant {
+				values = append(values, acc[0]/acc[1])
+			}
+
+			avg := GrandAverage{Condition: c.condition, Time: c.time, Column: col, N: len(values), Mean: mean(values),
+				SD: math.NaN(), SE: math.NaN(), CILower: math.NaN(), CIUpper: math.NaN()}
+			if avg.N > 1 {
+				avg.SD = sampleSD(values, avg.Mean)
+				avg.SE = avg.SD / math.Sqrt(float64(avg.N))
+				margin := studentTQuantile((1+level)/2, float64(avg.N-1)) * avg.SE
+				avg.CILower, avg.CIUpper = avg.Mean-margin, avg.Mean+margin
+			}
+			averages = append(averages, avg)
+		}
+	}
+	return averages
+}
+
+func writeGrandAverageSection(sb *strings.Builder, report *StatsReport) {
+	if len(report.GrandAverages) == 0 {
+		return
+	}
+	type summary struct {
+		times        map[float64]bool
+		minN, maxN   int
+		participants int
+	}
+	var conditions []string
+	byCondition := make(map[string]*summary)
+	for _, a := range report.GrandAverages {
+		s, ok := byCondition[a.Condition]
+		if !ok {
+			s = &summary{times: make(map[float64]bool), minN: a.N}
+			byCondition[a.Condition] = s
+			conditions = append(conditions, a.Condition)
+		}
+		s.times[a.Time] = true
+		if a.N < s.minN {
+			s.minN = a.N
+		}
+		if a.N > s.maxN {
+			s.maxN = a.N
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("Grand Averages (%g%% CI):\n", report.ConfidenceLevel*100))
+	for _, condition := range conditions {
+		s := byCondition[condition]
+		sb.WriteString(fmt.Sprintf("Condition: %s\n", conditionLabel(condition)))
+		sb.WriteString(fmt.Sprintf("  TimePoints: %d\n", len(s.times)))
+		sb.WriteString(fmt.Sprintf("  Participants per point: %d-%d\n", s.minN, s.maxN))
+	}
+	sb.WriteString("\n")
+}
+
+// SaveGrandAverages writes the grand average time courses as CSV, one row per condition, time and column
+func SaveGrandAverages(report *StatsReport, filename string) error {
+	if report.GrandAverages == nil {
+		return fmt.Errorf("report has no grand averages")
+	}
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	return writeDistributionCSV(filename, []string{"condition", "time", "column", "n", "mean", "sd", "se", "ci_lower", "ci_upper"}, func(w *csv.Writer) {
+		for _, a := range report.GrandAverages {
+			w.Write([]string{a.Condition, format(a.Time), a.Column, strconv.Itoa(a.N),
+				format(a.Mean), format(a.SD), format(a.SE), format(a.CILower), format(a.CIUpper)})
+		}
+	})
+}
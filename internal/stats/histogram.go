@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Histograms share bin edges per column across all groups so distributions from
+// different conditions/participants can be overlaid directly.
+
+type HistogramBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+type Histogram struct {
+	Grouping string         `json:"grouping"` // overall, condition or participant
+	Group    string         `json:"group,omitempty"`
+	Column   string         `json:"column"`
+	Total    int            `json:"total"`
+	Bins     []HistogramBin `json:"bins"`
+}
+
+type histogramRange struct {
+	min, max float64
+}
+
+func computeHistogramRanges(points []types.DataPoint, columns []string) map[string]histogramRange {
+	ranges := make(map[string]histogramRange)
+	for _, col := range columns {
+		values := extractColumnValues(points, col)
+		if len(values) == 0 {
+			continue
+		}
+		r := histogramRange{min: math.Inf(1), max: math.Inf(-1)}
+		for _, v := range values {
+			r.min = math.Min(r.min, v)
+			r.max = math.Max(r.max, v)
+		}
+		ranges[col] = r
+	}
+	return ranges
+}
+
+func computeHistograms(points []types.DataPoint, columns []string, ranges map[string]histogramRange, bins int, grouping, group string) []Histogram {
+	var histograms []Histogram
+
+	for _, col := range columns {
+		r, ok := ranges[col]
+		if !ok {
+			continue
+		}
+		width := (r.max - r.min) / float64(bins)
+
+		h := Histogram{Grouping: grouping, Group: group, Column: col, Bins: make([]HistogramBin, bins)}
+		for i := range h.Bins {
+			h.Bins[i].Lower = r.min + float64(i)*width
+			h.Bins[i].Upper = r.min + float64(i+1)*width
+		}
+		h.Bins[bins-1].Upper = r.max
+
+		for _, v := range extractColumnValues(points, col) {
+			idx := 0
+			if width > 0 {
+				idx = int((v - r.min) / width)
+			}
+			// The last bin includes the maximum
+			if idx >= bins {
+				idx = bins - 1
+			}
+			h.Bins[idx].Count++
+			h.Total++
+		}
+
+		histograms = append(histograms, h)
+	}
+
+	return histograms
+}
+
+// SaveHistograms writes the report's histograms as JSON (.json) or long-format CSV
+func SaveHistograms(report *StatsReport, filename string) error {
+	if len(report.Histograms) == 0 {
+		return fmt.Errorf("report has no histograms")
+	}
+
+	histograms := make([]Histogram, len(report.Histograms))
+	copy(histograms, report.Histograms)
+	sort.SliceStable(histograms, func(i, j int) bool {
+		if histograms[i].Grouping != histograms[j].Grouping {
+			return histograms[i].Grouping < histograms[j].Grouping
+		}
+		return histograms[i].Group < histograms[j].Group
+	})
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create histogram file: %v", err)
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(histograms); err != nil {
+			return fmt.Errorf("failed to write histograms: %v", err)
+		}
+		return f.Close()
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"grouping", "group", "column", "bin", "lower", "upper", "count", "proportion"})
+	for _, h := range histograms {
+		for i, bin := range h.Bins {
+			w.Write([]string{
+				h.Grouping,
+				h.Group,
+				h.Column,
+				strconv.Itoa(i),
+				strconv.FormatFloat(bin.Lower, 'g', -1, 64),
+				strconv.FormatFloat(bin.Upper, 'g', -1, 64),
+				strconv.Itoa(bin.Count),
+				strconv.FormatFloat(float64(bin.Count)/float64(h.Total), 'f', 6, 64),
+			})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write histograms: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,169 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// SensitivityStatistics lists the condition-level statistics the leave-one-out analysis can recompute
+var SensitivityStatistics = []string{"mean", "median"}
+
+// Influence is how much leaving one participant out shifts a condition-level statistic
+type Influence struct {
+	Condition     string
+	Column        string
+	ParticipantID string
+	Without       float64 // Statistic over the other participants' samples
+	Shift         float64 // Without minus the statistic with everyone
+	Standardized  float64 // Shift in jackknife standard errors (NaN when the standard error is 0)
+}
+
+// Sensitivity summarizes the leave-one-out analysis of one condition and column
+type Sensitivity struct {
+	Condition       string
+	Column          string
+	Participants    int
+	Full            float64 // Statistic with every participant
+	JackknifeSE     float64 // Jackknife standard error of the statistic
+	MostInfluential string
+	MaxShift        float64     // Largest absolute shift, signed
+	Influences      []Influence // One per participant, in natural order
+}
+
+func statistic(name string, values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	if name == "median" {
+		sorted := make([]float64, len(values))
+		copy(sorted, values)
+		sort.Float64s(sorted)
+		return quantile(sorted, 0.5)
+	}
+	return mean(values)
+}
+
+// computeSensitivity recomputes each condition's statistic of each column without each participant in
+// turn, pooling samples the same way as the condition statistics. Conditions need two participants.
+func computeSensitivity(points []types.DataPoint, cols []string, name string) []Sensitivity {
+	// condition -> column -> participant -> values
+	values := make(map[string]map[string]map[string][]float64)
+	for _, p := range points {
+		byCol, ok := values[p.Condition]
+		if !ok {
+			byCol = make(map[string]map[string][]float64)
+			values[p.Condition] = byCol
+		}
+		for _, col := range cols {
+			v, ok := p.Data[col]
+			if !ok || math.IsNaN(v) {
+				continue
+			}
+			if byCol[col] == nil {
+				byCol[col] = make(map[string][]float64)
+			}
+			byCol[col][p.ParticipantID] = append(byCol[col][p.ParticipantID], v)
+		}
+	}
+
+	conditions := make([]string, 0, len(values))
+	for condition := range values {
+		conditions = append(conditions, condition)
+	}
+	sort.Slice(conditions, func(i, j int) bool { return naturalLess(conditions[i], conditions[j]) })
+
+	var results []Sensitivity
+	for _, condition := range conditions {
+		for _, col := range cols {
+			byParticipant := values[condition][col]
+			if len(byParticipant) < 2 {
+				continue
+			}
+			ids := make([]string, 0, len(byParticipant))
+			var all []float64
+			for id, v := range byParticipant {
+				ids = append(ids, id)
+				all = append(all, v...)
+			}
+			sort.Slice(ids, func(i, j int) bool { return naturalLess(ids[i], ids[j]) })
+
+			s := Sensitivity{Condition: condition, Column: col, Participants: len(ids), Full: statistic(name, all)}
+			without := make([]float64, len(ids))
+			for k, id := range ids {
+				var rest []float64
+				for _, other := range ids {
+					if other != id {
+						rest = append(rest, byParticipant[other]...)
+					}
+				}
+				without[k] = statistic(name, rest)
+			}
+
+			// SE = sqrt((n-1)/n * sum (theta_i - mean theta)^2)
+			n := float64(len(ids))
+			m := mean(without)
+			ss := 0.0
+			for _, w := range without {
+				ss += (w - m) * (w - m)
+			}
+			s.JackknifeSE = math.Sqrt((n - 1) / n * ss)
+
+			for k, id := range ids {
+				inf := Influence{Condition: condition, Column: col, ParticipantID: id, Without: without[k],
+					Shift: without[k] - s.Full, Standardized: math.NaN()}
+				if s.JackknifeSE > 0 {
+					inf.Standardized = inf.Shift / s.JackknifeSE
+				}
+				if s.MostInfluential == "" || math.Abs(inf.Shift) > math.Abs(s.MaxShift) {
+					s.MostInfluential, s.MaxShift = id, inf.Shift
+				}
+				s.Influences = append(s.Influences, inf)
+			}
+			results = append(results, s)
+		}
+	}
+	return results
+}
+
+func writeSensitivitySection(sb *strings.Builder, report *StatsReport) {
+	if len(report.Sensitivity) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("Leave-One-Participant-Out Sensitivity (%s):\n", report.SensitivityStatistic))
+	for _, s := range report.Sensitivity {
+		sb.WriteString(fmt.Sprintf("Condition: %s | Column: %s\n", conditionLabel(s.Condition), s.Column))
+		sb.WriteString(fmt.Sprintf("  Full: %.4f (n=%d, jackknife SE %.4f)\n", s.Full, s.Participants, s.JackknifeSE))
+		sb.WriteString(fmt.Sprintf("  Most influential: %s (shift %+.4f)\n", s.MostInfluential, s.MaxShift))
+		for _, inf := range s.Influences {
+			sb.WriteString(fmt.Sprintf("  Without %s: %.4f (shift %+.4f)\n", inf.ParticipantID, inf.Without, inf.Shift))
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// SaveSensitivity writes the leave-one-out results as CSV, one row per condition, column and left-out participant
+func SaveSensitivity(report *StatsReport, filename string) error {
+	if report.Sensitivity == nil {
+		return fmt.Errorf("report has no sensitivity analysis")
+	}
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	return writeDistributionCSV(filename, []string{"condition", "column", "statistic", "left_out", "full", "without", "shift", "standardized_shift"}, func(w *csv.Writer) {
+		for _, s := range report.Sensitivity {
+			for _, inf := range s.Influences {
+				w.Write([]string{s.Condition, s.Column, report.SensitivityStatistic, inf.ParticipantID,
+					format(s.Full), format(inf.Without), format(inf.Shift), format(inf.Standardized)})
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LaTeX renders the report as booktabs tables, one per grouping
+func (r *StatsReport) LaTeX(opts FormatOptions) (string, error) {
+	fields, err := selectFields(opts.Fields)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("% Requires \\usepackage{booktabs}\n")
+
+	if len(r.OverallStats) > 0 {
+		writeLaTeXTable(&sb, "Descriptive statistics", "", map[string][]ColumnStats{"": r.OverallStats}, fields, opts.Digits)
+	}
+	if len(r.ConditionStats) > 0 {
+		writeLaTeXTable(&sb, "Descriptive statistics by condition", "Condition", r.ConditionStats, fields, opts.Digits)
+	}
+	if len(r.ParticipantStats) > 0 {
+		writeLaTeXTable(&sb, "Descriptive statistics by participant", "Participant", r.ParticipantStats, fields, opts.Digits)
+	}
+
+	return sb.String(), nil
+}
+
+func writeLaTeXTable(sb *strings.Builder, caption, groupName string, groups map[string][]ColumnStats, fields []reportField, digits int) {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var headers []string
+	align := ""
+	if groupName != "" {
+		headers = append(headers, groupName)
+		align += "l"
+	}
+	headers = append(headers, "Column")
+	align += "l"
+	for _, f := range fields {
+		headers = append(headers, escapeLaTeX(f.name))
+		align += "r"
+	}
+
+	sb.WriteString("\n\\begin{table}[ht]\n\\centering\n")
+	sb.WriteString("\\begin{tabular}{" + align + "}\n\\toprule\n")
+	sb.WriteString(strings.Join(headers, " & ") + " \\\\\n\\midrule\n")
+
+	for i, key := range keys {
+		if i > 0 {
+			sb.WriteString("\\addlinespace\n")
+		}
+		for j, colStats := range groups[key] {
+			var cells []string
+			if groupName != "" {
+				label := ""
+				if j == 0 {
+					label = escapeLaTeX(key)
+				}
+				cells = append(cells, label)
+			}
+			cells = append(cells, escapeLaTeX(colStats.Column))
+			for _, f := range fields {
+				cells = append(cells, formatField(f, colStats, digits))
+			}
+			sb.WriteString(strings.Join(cells, " & ") + " \\\\\n")
+		}
+	}
+
+	sb.WriteString("\\bottomrule\n\\end{tabular}\n")
+	sb.WriteString("\\caption{" + caption + "}\n\\end{table}\n")
+}
+
+var latexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+func escapeLaTeX(s string) string {
+	return latexReplacer.Replace(s)
+}
+
+func isLaTeXPath(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".tex"
+}
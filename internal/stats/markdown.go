@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+)
+
+// Markdown renders the report as GitHub-flavored Markdown with one table per condition/participant
+func (r *StatsReport) Markdown(opts FormatOptions) (string, error) {
+	fields, err := selectFields(opts.Fields)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Statistics Report\n\n")
+
+	if len(r.OverallStats) > 0 {
+		sb.WriteString("## Overall\n\n")
+		writeMarkdownGroup(&sb, r.OverallStats, fields, opts.Digits)
+	}
+
+	writeMarkdownGroups(&sb, "By Condition", r.ConditionStats, fields, opts.Digits)
+	writeMarkdownGroups(&sb, "By Participant", r.ParticipantStats, fields, opts.Digits)
+	sb.WriteString(r.formatFrequencies(true))
+	sb.WriteString(r.formatDiagnostics(true))
+
+	return sb.String(), nil
+}
+
+func writeMarkdownGroups(sb *strings.Builder, title string, groups map[string][]ColumnStats, fields []reportField, digits int) {
+	if len(groups) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sb.WriteString("## " + title + "\n\n")
+	for _, key := range keys {
+		sb.WriteString("### " + escapeMarkdown(key) + "\n\n")
+		writeMarkdownGroup(sb, groups[key], fields, digits)
+	}
+}
+
+func writeMarkdownGroup(sb *strings.Builder, columnStats []ColumnStats, fields []reportField, digits int) {
+	headers := []string{"column"}
+	for _, f := range fields {
+		headers = append(headers, f.name)
+	}
+
+	var rows [][]string
+	for _, colStats := range columnStats {
+		row := []string{escapeMarkdown(colStats.Column)}
+		for _, f := range fields {
+			row = append(row, formatField(f, colStats, digits))
+		}
+		rows = append(rows, row)
+	}
+
+	writeTable(sb, headers, rows, true)
+	sb.WriteString("\n")
+}
+
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,179 @@
+package stats
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Figure layout of SaveGrandAveragePlot, in SVG user units
+const (
+	plotWidth       = 720
+	plotPanelHeight = 300
+	plotMarginLeft  = 70
+	plotMarginRight = 150 // Room for the legend
+	plotMarginTop   = 36
+	plotMarginBelow = 50
+)
+
+// plotColors is the Okabe-Ito palette, distinguishable with the common forms of color blindness
+var plotColors = []string{"#0072B2", "#E69F00", "#009E73", "#D55E00", "#CC79A7", "#56B4E9", "#F0E442", "#000000"}
+
+// niceTicks returns about n evenly spaced round tick values covering [lo, hi]
+func niceTicks(lo, hi float64, n int) []float64 {
+	if hi <= lo {
+		return []float64{lo}
+	}
+	raw := (hi - lo) / float64(n)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		step = m * magnitude
+		if step >= raw {
+			break
+		}
+	}
+	var ticks []float64
+	for t := math.Ceil(lo/step) * step; t <= hi+step*1e-9; t += step {
+		// Snap values like 0.30000000000000004 and -0 for the labels
+		ticks = append(ticks, math.Round(t/step)*step+0)
+	}
+	return ticks
+}
+
+// SaveGrandAveragePlot renders the grand average time courses as an SVG figure: one panel per
+// column with a line per condition and a shaded band of ±1 standard error around it. Time points
+// with fewer than two participants have no band.
+func SaveGrandAveragePlot(report *StatsReport, filename string) error {
+	if report.GrandAverages == nil {
+		return fmt.Errorf("report has no grand averages")
+	}
+
+	var columns, conditions []string
+	seenColumns := make(map[string]bool)
+	seenConditions := make(map[string]bool)
+	byColumn := make(map[string]map[string][]GrandAverage)
+	for _, a := range report.GrandAverages {
+		if !seenColumns[a.Column] {
+			seenColumns[a.Column] = true
+			columns = append(columns, a.Column)
+			byColumn[a.Column] = make(map[string][]GrandAverage)
+		}
+		if !seenConditions[a.Condition] {
+			seenConditions[a.Condition] = true
+			conditions = append(conditions, a.Condition)
+		}
+		// GrandAverages are sorted by condition and time, so each series is in time order
+		byColumn[a.Column][a.Condition] = append(byColumn[a.Column][a.Condition], a)
+	}
+
+	coord := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	label := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	}
+
+	height := len(columns) * plotPanelHeight
+	innerWidth := float64(plotWidth - plotMarginLeft - plotMarginRight)
+	innerHeight := float64(plotPanelHeight - plotMarginTop - plotMarginBelow)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"sans-serif\" font-size=\"12\">\n",
+		plotWidth, height, plotWidth, height))
+	sb.WriteString(fmt.Sprintf("<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", plotWidth, height))
+
+	for panel, column := range columns {
+		series := byColumn[column]
+		minT, maxT := math.Inf(1), math.Inf(-1)
+		minY, maxY := math.Inf(1), math.Inf(-1)
+		for _, averages := range series {
+			for _, a := range averages {
+				minT, maxT = math.Min(minT, a.Time), math.Max(maxT, a.Time)
+				lo, hi := a.Mean, a.Mean
+				if !math.IsNaN(a.SE) {
+					lo, hi = a.Mean-a.SE, a.Mean+a.SE
+				}
+				minY, maxY = math.Min(minY, lo), math.Max(maxY, hi)
+			}
+		}
+		if maxT == minT {
+			minT, maxT = minT-0.5, maxT+0.5
+		}
+		if maxY == minY {
+			minY, maxY = minY-0.5, maxY+0.5
+		}
+		pad := (maxY - minY) * 0.05
+		minY, maxY = minY-pad, maxY+pad
+
+		top := float64(panel*plotPanelHeight + plotMarginTop)
+		x := func(t float64) float64 { return plotMarginLeft + (t-minT)/(maxT-minT)*innerWidth }
+		y := func(v float64) float64 { return top + (maxY-v)/(maxY-minY)*innerHeight }
+		bottom := top + innerHeight
+
+		sb.WriteString(fmt.Sprintf("<g id=\"%s\">\n", html.EscapeString(column)))
+		sb.WriteString(fmt.Sprintf("<text x=\"%s\" y=\"%s\" font-size=\"14\" font-weight=\"bold\">%s</text>\n",
+			coord(plotMarginLeft), coord(top-12), html.EscapeString(column)))
+
+		// Axes and ticks
+		for _, t := range niceTicks(minT, maxT, 8) {
+			sb.WriteString(fmt.Sprintf("<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"#ddd\"/>\n", coord(x(t)), coord(top), coord(x(t)), coord(bottom)))
+			sb.WriteString(fmt.Sprintf("<text x=\"%s\" y=\"%s\" text-anchor=\"middle\">%s</text>\n", coord(x(t)), coord(bottom+16), label(t)))
+		}
+		for _, v := range niceTicks(minY, maxY, 6) {
+			sb.WriteString(fmt.Sprintf("<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"#ddd\"/>\n", coord(plotMarginLeft), coord(y(v)), coord(plotMarginLeft+innerWidth), coord(y(v))))
+			sb.WriteString(fmt.Sprintf("<text x=\"%s\" y=\"%s\" text-anchor=\"end\">%s</text>\n", coord(plotMarginLeft-6), coord(y(v)+4), label(v)))
+		}
+		sb.WriteString(fmt.Sprintf("<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"none\" stroke=\"#333\"/>\n",
+			coord(plotMarginLeft), coord(top), coord(innerWidth), coord(innerHeight)))
+		sb.WriteString(fmt.Sprintf("<text x=\"%s\" y=\"%s\" text-anchor=\"middle\">Time (s)</text>\n", coord(plotMarginLeft+innerWidth/2), coord(bottom+36)))
+
+		for i, condition := range conditions {
+			averages, ok := series[condition]
+			if !ok {
+				continue
+			}
+			color := plotColors[i%len(plotColors)]
+
+			// One band polygon per run of time points with a standard error
+			for start := 0; start < len(averages); {
+				if math.IsNaN(averages[start].SE) {
+					start++
+					continue
+				}
+				end := start
+				for end < len(averages) && !math.IsNaN(averages[end].SE) {
+					end++
+				}
+				var band []string
+				for _, a := range averages[start:end] {
+					band = append(band, coord(x(a.Time))+","+coord(y(a.Mean+a.SE)))
+				}
+				for k := end - 1; k >= start; k-- {
+					a := averages[k]
+					band = append(band, coord(x(a.Time))+","+coord(y(a.Mean-a.SE)))
+				}
+				sb.WriteString(fmt.Sprintf("<polygon points=\"%s\" fill=\"%s\" fill-opacity=\"0.25\" stroke=\"none\"/>\n", strings.Join(band, " "), color))
+				start = end
+			}
+
+			line := make([]string, len(averages))
+			for k, a := range averages {
+				line[k] = coord(x(a.Time)) + "," + coord(y(a.Mean))
+			}
+			sb.WriteString(fmt.Sprintf("<polyline points=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\"/>\n", strings.Join(line, " "), color))
+
+			legendY := top + 10 + float64(i)*18
+			legendX := plotMarginLeft + innerWidth + 12
+			sb.WriteString(fmt.Sprintf("<rect x=\"%s\" y=\"%s\" width=\"14\" height=\"10\" fill=\"%s\" fill-opacity=\"0.25\" stroke=\"%s\"/>\n",
+				coord(legendX), coord(legendY-9), color, color))
+			sb.WriteString(fmt.Sprintf("<text x=\"%s\" y=\"%s\">%s</text>\n", coord(legendX+20), coord(legendY), html.EscapeString(conditionLabel(condition))))
+		}
+		sb.WriteString("</g>\n")
+	}
+	sb.WriteString("</svg>\n")
+
+	return writeReportFile(filename, sb.String())
+}
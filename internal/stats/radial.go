@@ -0,0 +1,176 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// RadialConfig describes a bullseye analysis of gaze around a center point (e.g. screen center or a target)
+type RadialConfig struct {
+	X, Y    string  // Gaze coordinate columns
+	CenterX float64 // Center in the same units as the gaze columns
+	CenterY float64
+	Bands   []float64 // Radii; proportions are reported for gaze within each radius
+	TimeBin float64   // Seconds per timeline bin, relative to each participant's first sample (0 = no timeline)
+}
+
+// RadialStats summarizes gaze distance from the center within one group
+type RadialStats struct {
+	Grouping        string // overall, condition or participant
+	Group           string
+	Count           int
+	MeanDistance    float64
+	MedianDistance  float64
+	SDDistance      float64
+	P90Distance     float64
+	BandProportions []float64 // Proportion of samples within each band radius
+	Timeline        []RadialBin
+}
+
+type RadialBin struct {
+	Start           float64 // Seconds from the participant's first sample
+	Count           int
+	BandProportions []float64
+}
+
+func computeRadial(points []types.DataPoint, config RadialConfig, grouping, group string) (RadialStats, bool) {
+	r := RadialStats{Grouping: grouping, Group: group}
+
+	// Timeline bins are relative to each participant's first sample so sessions line up
+	starts := make(map[string]float64)
+	for _, p := range points {
+		if start, ok := starts[p.ParticipantID]; !ok || p.Timestamp < start {
+			starts[p.ParticipantID] = p.Timestamp
+		}
+	}
+
+	var distances []float64
+	withinBand := make([]int, len(config.Bands))
+	bins := make(map[int]*RadialBin)
+	binWithin := make(map[int][]int)
+	for _, p := range points {
+		x, okX := p.Data[config.X]
+		y, okY := p.Data[config.Y]
+		if !okX || !okY || math.IsNaN(x) || math.IsNaN(y) {
+			continue
+		}
+		d := math.Hypot(x-config.CenterX, y-config.CenterY)
+		distances = append(distances, d)
+
+		var bin *RadialBin
+		if config.TimeBin > 0 {
+			idx := int(math.Floor((p.Timestamp - starts[p.ParticipantID]) / config.TimeBin))
+			if bins[idx] == nil {
+				bins[idx] = &RadialBin{Start: float64(idx) * config.TimeBin}
+				binWithin[idx] = make([]int, len(config.Bands))
+			}
+			bin = bins[idx]
+			bin.Count++
+			for b, radius := range config.Bands {
+				if d <= radius {
+					binWithin[idx][b]++
+				}
+			}
+		}
+		for b, radius := range config.Bands {
+			if d <= radius {
+				withinBand[b]++
+			}
+		}
+	}
+	if len(distances) == 0 {
+		return r, false
+	}
+
+	sort.Float64s(distances)
+	r.Count = len(distances)
+	var sum, sumSq float64
+	for _, d := range distances {
+		sum += d
+		sumSq += d * d
+	}
+	r.MeanDistance = sum / float64(r.Count)
+	r.SDDistance = math.Sqrt(math.Max(0, sumSq/float64(r.Count)-r.MeanDistance*r.MeanDistance))
+	r.MedianDistance = quantile(distances, 0.5)
+	r.P90Distance = quantile(distances, 0.9)
+	for _, n := range withinBand {
+		r.BandProportions = append(r.BandProportions, float64(n)/float64(r.Count))
+	}
+
+	indices := make([]int, 0, len(bins))
+	for idx := range bins {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+	for _, idx := range indices {
+		bin := bins[idx]
+		for _, n := range binWithin[idx] {
+			bin.BandProportions = append(bin.BandProportions, float64(n)/float64(bin.Count))
+		}
+		r.Timeline = append(r.Timeline, *bin)
+	}
+
+	return r, true
+}
+
+func writeRadialSection(sb *strings.Builder, report *StatsReport) {
+	if len(report.Radial) == 0 {
+		return
+	}
+	cfg := report.RadialConfig
+	sb.WriteString(fmt.Sprintf("Radial Distribution (%s/%s around %g,%g):\n", cfg.X, cfg.Y, cfg.CenterX, cfg.CenterY))
+	for _, r := range report.Radial {
+		label := r.Grouping
+		if r.Group != "" {
+			label += " " + r.Group
+		}
+		sb.WriteString(fmt.Sprintf("%s\n", label))
+		sb.WriteString(fmt.Sprintf("  Count: %d\n", r.Count))
+		sb.WriteString(fmt.Sprintf("  MeanDistance: %.4f\n", r.MeanDistance))
+		sb.WriteString(fmt.Sprintf("  MedianDistance: %.4f\n", r.MedianDistance))
+		sb.WriteString(fmt.Sprintf("  SDDistance: %.4f\n", r.SDDistance))
+		sb.WriteString(fmt.Sprintf("  P90Distance: %.4f\n", r.P90Distance))
+		for b, radius := range cfg.Bands {
+			sb.WriteString(fmt.Sprintf("  Within %g: %.1f%%\n", radius, r.BandProportions[b]*100))
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// sortRadial orders the radial stats by grouping and group for stable output
+func sortRadial(radial []RadialStats) {
+	sort.SliceStable(radial, func(i, j int) bool {
+		if radial[i].Grouping != radial[j].Grouping {
+			return radial[i].Grouping < radial[j].Grouping
+		}
+		return radial[i].Group < radial[j].Group
+	})
+}
+
+// SaveRadial writes the proportion of gaze within each band radius as long-format CSV: one row per group
+// and band for the whole recording (bin_start empty), plus one per timeline bin
+func SaveRadial(report *StatsReport, filename string) error {
+	if len(report.Radial) == 0 {
+		return fmt.Errorf("report has no radial analysis")
+	}
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	return writeDistributionCSV(filename, []string{"grouping", "group", "bin_start", "count", "radius", "proportion", "mean_distance", "median_distance"}, func(w *csv.Writer) {
+		for _, r := range report.Radial {
+			for b, radius := range report.RadialConfig.Bands {
+				w.Write([]string{r.Grouping, r.Group, "", strconv.Itoa(r.Count), format(radius), strconv.FormatFloat(r.BandProportions[b], 'f', 6, 64), format(r.MeanDistance), format(r.MedianDistance)})
+			}
+			for _, bin := range r.Timeline {
+				for b, radius := range report.RadialConfig.Bands {
+					w.Write([]string{r.Grouping, r.Group, format(bin.Start), strconv.Itoa(bin.Count), format(radius), strconv.FormatFloat(bin.BandProportions[b], 'f', 6, 64), "", ""})
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// trimmedMean drops the given proportion of values from each tail (sorted input)
+func trimmedMean(sorted []float64, proportion float64) float64 {
+	k := int(math.Floor(proportion * float64(len(sorted))))
+	kept := sorted[k : len(sorted)-k]
+	if len(kept) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range kept {
+		sum += v
+	}
+	return sum / float64(len(kept))
+}
+
+// winsorizedMean clamps the given proportion of values in each tail to the nearest kept value (sorted input)
+func winsorizedMean(sorted []float64, proportion float64) float64 {
+	n := len(sorted)
+	k := int(math.Floor(proportion * float64(n)))
+	if 2*k >= n {
+		return math.NaN()
+	}
+	lo, hi := sorted[k], sorted[n-1-k]
+	sum := 0.0
+	for _, v := range sorted {
+		sum += math.Max(lo, math.Min(hi, v))
+	}
+	return sum / float64(n)
+}
+
+// medianAbsoluteDeviation is the raw (unscaled) MAD; multiply by 1.4826 for a normal-consistent SD estimate
+func medianAbsoluteDeviation(sorted []float64, median float64) float64 {
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return quantile(deviations, 0.5)
+}
@@ -5,19 +5,56 @@ package stats
 import (
 	"fmt"
 	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"mbdvr/internal/cleaner"
+	"mbdvr/internal/diag"
 	"mbdvr/internal/types"
 )
 
 const MAX_DATASETS = 10
 
 type StatsConfig struct {
-	AnalyzeColumns []string
-	ByCondition    bool
-	ByParticipant  bool
+	AnalyzeColumns  []string
+	ByCondition     bool
+	ByParticipant   bool
+	OutlierMethod   string  // "zscore" (default) or "iqr", same methods as the cleaner
+	ZScoreThreshold float64 // for zscore outlier counting (default: 3.0)
+
+	CategoricalColumns []string // Columns summarized as frequency tables instead of numeric statistics
+
+	HistogramBins int // Number of histogram bins per analyzed column (0 = no histograms)
+	QuantileSteps int // Quantile function resolution, e.g. 100 for percentiles (0 = no quantiles/ECDF)
+
+	Percentiles         []float64 // Percentiles (0-100) reported for every column (nil = DefaultPercentiles)
+	TrimProportion      float64   // Proportion trimmed from each tail for TrimmedMean (default: 0.2)
+	WinsorizeProportion float64   // Proportion clamped in each tail for WinsorizedMean (default: 0.2)
+
+	Bootstrap     int   // Resamples for percentile bootstrap intervals of each column's mean and median (0 = none)
+	BootstrapSeed int64 // Random seed of the resamples; the same seed and data give the same intervals
+
+	Radial   *RadialConfig   // Bullseye analysis of gaze around a center point (nil = none)
+	Tracking *TrackingConfig // Gaze-vs-target tracking error per trial and condition (nil = none)
+
+	GapThreshold float64 // Report intervals between samples longer than this many seconds (0 = no gap report)
+
+	GrandAverage    bool    // Pointwise across-participant averages of the analyzed columns per condition and time
+	ConfidenceLevel float64 // Confidence level of the grand average, effect size and bootstrap intervals (default: 0.95)
+
+	Sensitivity string // "" (off), "mean" or "median": leave each participant out of the condition statistics in turn
+
+	Correlations bool // Pearson and Spearman correlation matrices of the analyzed columns, overall and per condition
+
+	TTestConditions []string // Two conditions compared by Welch's and paired t-tests of the analyzed columns (nil = none)
+	ANOVA           bool     // One-way ANOVA of the analyzed columns across all conditions
+	EffectSizes     bool     // Cohen's d and Hedges' g of the analyzed columns for every pair of conditions
+
+	Diagnostics *diag.Collector // Receives the warnings raised by the analysis; the report lists all it holds
 }
 
 type ColumnStats struct {
@@ -32,12 +69,76 @@ type ColumnStats struct {
 	OutlierCount    int
 	OutlierMethod   string
 	ZScoreThreshold float64
+	OutlierLower    float64 // Values below this bound are counted as outliers
+	OutlierUpper    float64 // Values above this bound are counted as outliers
+	TrimmedMean     float64
+	WinsorizedMean  float64
+	MAD             float64      // Median absolute deviation (unscaled)
+	Skewness        float64      // Sample skewness (G1): > 0 for a long right tail (NaN under 3 values)
+	Kurtosis        float64      // Sample excess kurtosis (G2): > 0 for heavier tails than normal (NaN under 4 values)
+	Percentiles     []Percentile // At StatsConfig.Percentiles, in order
+	Bootstrap       int          // Resamples behind the intervals below (0 = no intervals)
+	BootstrapUnit   string       // What was resampled: "participant" means when the group spans several participants, else "sample"
+	MeanLower       float64      // Bootstrap interval of the mean at the report's ConfidenceLevel (NaN under 2 units)
+	MeanUpper       float64
+	MedianLower     float64 // Bootstrap interval of the median
+	MedianUpper     float64
+}
+
+// Percentile is the value below which P percent of a column's values fall (linear interpolation)
+type Percentile struct {
+	P     float64
+	Value float64
+}
+
+// DefaultPercentiles are reported when StatsConfig.Percentiles is nil
+var DefaultPercentiles = []float64{5, 25, 75, 95}
+
+// Label names a percentile, e.g. P5 or P97.5
+func (p Percentile) Label() string {
+	return "P" + strconv.FormatFloat(p.P, 'f', -1, 64)
 }
 
 type StatsReport struct {
 	OverallStats     []ColumnStats
 	ConditionStats   map[string][]ColumnStats
 	ParticipantStats map[string][]ColumnStats
+
+	OverallFrequencies     []FrequencyTable
+	ConditionFrequencies   map[string][]FrequencyTable
+	ParticipantFrequencies map[string][]FrequencyTable
+
+	Histograms    []Histogram
+	Distributions []Distribution
+
+	RadialConfig RadialConfig
+	Radial       []RadialStats
+
+	TrackingConfig    TrackingConfig
+	TrackingTrials    []TrackingTrial
+	TrackingSummaries []TrackingSummary // One per condition
+	TrackingSeries    []TrackingSample
+
+	GapThreshold float64
+	Gaps         []Gap
+	GapSummaries []GapSummary // One per participant
+
+	ConfidenceLevel float64
+	GrandAverages   []GrandAverage // Sorted by condition, time and column
+
+	SensitivityStatistic string
+	Sensitivity          []Sensitivity // Sorted by condition, then in analyzed column order
+
+	Correlations []CorrelationMatrix // Overall, then by condition; Pearson before Spearman
+
+	TTests []TTest // Per analyzed column: Welch's, then paired if participants were in both conditions
+	ANOVAs []ANOVA // One per analyzed column
+
+	EffectSizes []EffectSize // Per analyzed column, every pair of conditions in sorted order
+
+	Coverage *Coverage // Set by the caller when pooling several inputs (nil = none)
+
+	Diagnostics []diag.Diagnostic // Warnings from the input's loading and cleaning and from the analysis
 }
 
 func ComputeStats(dataset *types.Dataset, config StatsConfig) (*StatsReport, error) {
@@ -46,12 +147,86 @@ func ComputeStats(dataset *types.Dataset, config StatsConfig) (*StatsReport, err
 	}
 
 	report := &StatsReport{
-		ConditionStats:   make(map[string][]ColumnStats),
-		ParticipantStats: make(map[string][]ColumnStats),
+		ConditionStats:         make(map[string][]ColumnStats),
+		ParticipantStats:       make(map[string][]ColumnStats),
+		ConditionFrequencies:   make(map[string][]FrequencyTable),
+		ParticipantFrequencies: make(map[string][]FrequencyTable),
 	}
 
-	if len(config.AnalyzeColumns) == 0 {
-		config.AnalyzeColumns = dataset.Columns
+	requested := config.AnalyzeColumns
+	// nil analyzes every column; an empty slice analyzes none (frequency tables only)
+	if config.AnalyzeColumns == nil {
+		// Categorical columns get frequency tables instead of means
+		categorical := make(map[string]bool)
+		for _, col := range config.CategoricalColumns {
+			categorical[col] = true
+		}
+		for _, col := range dataset.Columns {
+			if !categorical[col] {
+				config.AnalyzeColumns = append(config.AnalyzeColumns, col)
+			}
+		}
+	}
+	if config.OutlierMethod == "" {
+		config.OutlierMethod = "zscore"
+	}
+	if config.OutlierMethod != "zscore" && config.OutlierMethod != "iqr" {
+		return nil, fmt.Errorf("unknown outlier method %q (use 'zscore' or 'iqr')", config.OutlierMethod)
+	}
+	if config.ZScoreThreshold <= 0 {
+		config.ZScoreThreshold = 3.0
+	}
+	if config.TrimProportion == 0 {
+		config.TrimProportion = 0.2
+	}
+	if config.WinsorizeProportion == 0 {
+		config.WinsorizeProportion = 0.2
+	}
+	if config.TrimProportion < 0 || config.TrimProportion >= 0.5 || config.WinsorizeProportion < 0 || config.WinsorizeProportion >= 0.5 {
+		return nil, fmt.Errorf("trim and winsorize proportions must be in [0, 0.5)")
+	}
+	if config.Percentiles == nil {
+		config.Percentiles = DefaultPercentiles
+	}
+	for _, p := range config.Percentiles {
+		if p < 0 || p > 100 || math.IsNaN(p) {
+			return nil, fmt.Errorf("percentile %g must be between 0 and 100", p)
+		}
+	}
+
+	if config.Bootstrap < 0 {
+		return nil, fmt.Errorf("bootstrap resamples must not be negative, got %d", config.Bootstrap)
+	}
+	if config.GrandAverage || config.EffectSizes || config.Bootstrap > 0 {
+		if config.ConfidenceLevel == 0 {
+			config.ConfidenceLevel = 0.95
+		}
+		if config.ConfidenceLevel <= 0 || config.ConfidenceLevel >= 1 {
+			return nil, fmt.Errorf("confidence level must be between 0 and 1, got %g", config.ConfidenceLevel)
+		}
+		report.ConfidenceLevel = config.ConfidenceLevel
+	}
+
+	if config.Radial != nil {
+		for i := 1; i < len(config.Radial.Bands); i++ {
+			if config.Radial.Bands[i] <= config.Radial.Bands[i-1] {
+				return nil, fmt.Errorf("radial bands must be increasing")
+			}
+		}
+		report.RadialConfig = *config.Radial
+	}
+	addRadial := func(points []types.DataPoint, grouping, group string) {
+		if config.Radial == nil {
+			return
+		}
+		if r, ok := computeRadial(points, *config.Radial, grouping, group); ok {
+			report.Radial = append(report.Radial, r)
+		}
+	}
+
+	var histRanges map[string]histogramRange
+	if config.HistogramBins > 0 {
+		histRanges = computeHistogramRanges(dataset.Points, config.AnalyzeColumns)
 	}
 
 	if config.ByCondition {
@@ -73,7 +248,19 @@ func ComputeStats(dataset *types.Dataset, config StatsConfig) (*StatsReport, err
 			if err != nil {
 				return nil, fmt.Errorf("failed to compute stats for condition %s: %v", condition, err)
 			}
-			report.ConditionStats[condition] = stats
+			if len(config.AnalyzeColumns) > 0 {
+				report.ConditionStats[condition] = stats
+			}
+			if len(config.CategoricalColumns) > 0 {
+				report.ConditionFrequencies[condition] = computeFrequencies(points, config.CategoricalColumns)
+			}
+			if config.HistogramBins > 0 {
+				report.Histograms = append(report.Histograms, computeHistograms(points, config.AnalyzeColumns, histRanges, config.HistogramBins, "condition", condition)...)
+			}
+			if config.QuantileSteps > 0 {
+				report.Distributions = append(report.Distributions, computeDistributions(points, config.AnalyzeColumns, config.QuantileSteps, "condition", condition)...)
+			}
+			addRadial(points, "condition", condition)
 		}
 	}
 
@@ -96,7 +283,19 @@ func ComputeStats(dataset *types.Dataset, config StatsConfig) (*StatsReport, err
 			if err != nil {
 				return nil, fmt.Errorf("failed to compute stats for participant %s: %v", participant, err)
 			}
-			report.ParticipantStats[participant] = stats
+			if len(config.AnalyzeColumns) > 0 {
+				report.ParticipantStats[participant] = stats
+			}
+			if len(config.CategoricalColumns) > 0 {
+				report.ParticipantFrequencies[participant] = computeFrequencies(points, config.CategoricalColumns)
+			}
+			if config.HistogramBins > 0 {
+				report.Histograms = append(report.Histograms, computeHistograms(points, config.AnalyzeColumns, histRanges, config.HistogramBins, "participant", participant)...)
+			}
+			if config.QuantileSteps > 0 {
+				report.Distributions = append(report.Distributions, computeDistributions(points, config.AnalyzeColumns, config.QuantileSteps, "participant", participant)...)
+			}
+			addRadial(points, "participant", participant)
 		}
 	}
 
@@ -106,8 +305,131 @@ func ComputeStats(dataset *types.Dataset, config StatsConfig) (*StatsReport, err
 			return nil, fmt.Errorf("failed to compute overall stats: %v", err)
 		}
 		report.OverallStats = stats
+		if len(config.CategoricalColumns) > 0 {
+			report.OverallFrequencies = computeFrequencies(dataset.Points, config.CategoricalColumns)
+		}
+		if config.HistogramBins > 0 {
+			report.Histograms = computeHistograms(dataset.Points, config.AnalyzeColumns, histRanges, config.HistogramBins, "overall", "")
+		}
+		if config.QuantileSteps > 0 {
+			report.Distributions = computeDistributions(dataset.Points, config.AnalyzeColumns, config.QuantileSteps, "overall", "")
+		}
+		addRadial(dataset.Points, "overall", "")
+	}
+	sortRadial(report.Radial)
+
+	if config.Tracking != nil {
+		known := make(map[string]bool)
+		for _, col := range dataset.Columns {
+			known[col] = true
+		}
+		for _, col := range []string{config.Tracking.X, config.Tracking.Y, config.Tracking.TargetX, config.Tracking.TargetY} {
+			if !known[col] {
+				return nil, fmt.Errorf("tracking column %q not found", col)
+			}
+		}
+		report.TrackingConfig = *config.Tracking
+		report.TrackingTrials, report.TrackingSummaries, report.TrackingSeries = computeTracking(dataset.Points, *config.Tracking)
+	}
+
+	if config.GapThreshold > 0 {
+		report.GapThreshold = config.GapThreshold
+		report.Gaps, report.GapSummaries = computeGaps(dataset.Points, config.GapThreshold)
+	}
+
+	if config.GrandAverage {
+		report.GrandAverages = computeGrandAverages(dataset.Points, config.AnalyzeColumns, config.ConfidenceLevel)
+	}
+
+	if config.Sensitivity != "" {
+		known := false
+		for _, name := range SensitivityStatistics {
+			known = known || name == config.Sensitivity
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown sensitivity statistic %q (use %s)", config.Sensitivity, strings.Join(SensitivityStatistics, " or "))
+		}
+		report.SensitivityStatistic = config.Sensitivity
+		report.Sensitivity = computeSensitivity(dataset.Points, config.AnalyzeColumns, config.Sensitivity)
+	}
+
+	if config.Correlations {
+		if len(config.AnalyzeColumns) < 2 {
+			return nil, fmt.Errorf("correlations need at least two analyzed columns")
+		}
+		report.Correlations = computeCorrelations(dataset.Points, config.AnalyzeColumns, "overall", "")
+		if config.ByCondition {
+			byCondition := make(map[string][]types.DataPoint)
+			var conditions []string
+			for _, p := range dataset.Points {
+				condition := p.Condition
+				if condition == "" {
+					condition = "unknown"
+				}
+				if _, ok := byCondition[condition]; !ok {
+					conditions = append(conditions, condition)
+				}
+				byCondition[condition] = append(byCondition[condition], p)
+			}
+			sort.Strings(conditions)
+			for _, condition := range conditions {
+				report.Correlations = append(report.Correlations, computeCorrelations(byCondition[condition], config.AnalyzeColumns, "condition", condition)...)
+			}
+		}
+	}
+
+	if config.TTestConditions != nil {
+		if len(config.TTestConditions) != 2 || config.TTestConditions[0] == config.TTestConditions[1] {
+			return nil, fmt.Errorf("t-tests compare two different conditions")
+		}
+		if len(config.AnalyzeColumns) == 0 {
+			return nil, fmt.Errorf("t-tests need analyzed columns")
+		}
+		present := make(map[string]bool)
+		for _, p := range dataset.Points {
+			present[p.Condition] = true
+		}
+		for _, condition := range config.TTestConditions {
+			if !present[condition] {
+				return nil, fmt.Errorf("no data for condition %s", condition)
+			}
+		}
+		report.TTests = computeTTests(dataset.Points, config.AnalyzeColumns, config.TTestConditions[0], config.TTestConditions[1])
+	}
+
+	if config.ANOVA {
+		if len(config.AnalyzeColumns) == 0 {
+			return nil, fmt.Errorf("ANOVA needs analyzed columns")
+		}
+		conditions := make(map[string]bool)
+		for _, p := range dataset.Points {
+			conditions[p.Condition] = true
+		}
+		if len(conditions) < 2 {
+			return nil, fmt.Errorf("ANOVA needs at least two conditions, found %d", len(conditions))
+		}
+		report.ANOVAs = computeANOVAs(dataset.Points, config.AnalyzeColumns)
 	}
 
+	if config.EffectSizes {
+		if len(config.AnalyzeColumns) == 0 {
+			return nil, fmt.Errorf("effect sizes need analyzed columns")
+		}
+		report.EffectSizes = computeEffectSizes(dataset.Points, config.AnalyzeColumns, config.ConfidenceLevel)
+		if len(report.EffectSizes) == 0 {
+			return nil, fmt.Errorf("effect sizes need at least two conditions")
+		}
+	}
+
+	// Without a collector, the report lists the warnings stored with the input
+	found := config.Diagnostics
+	if found == nil {
+		found = &diag.Collector{}
+		found.Merge(diag.FromMetadata(dataset.Metadata))
+	}
+	checkSkippedColumns(found, dataset, report, requested)
+	report.Diagnostics = found.Diagnostics()
+
 	return report, nil
 }
 
@@ -162,19 +484,39 @@ func computeColumnStats(dataset *types.Dataset, columns []string, config StatsCo
 		variance := (sumSq / float64(stats.Count-stats.MissingCount)) - (stats.Mean * stats.Mean)
 		stats.StdDev = math.Sqrt(variance)
 
-		// Outlier detection using Z-score method
-		if stats.StdDev > 0 {
-			zThreshold := 3.0 // Common threshold
-			stats.OutlierMethod = "z-score"
-			stats.ZScoreThreshold = zThreshold
-
-			for _, v := range values {
-				if !math.IsNaN(v) {
-					zScore := math.Abs((v - stats.Mean) / stats.StdDev)
-					if zScore > zThreshold {
-						stats.OutlierCount++
-					}
-				}
+		// Robust alternatives for heavy-tailed distributions
+		stats.TrimmedMean = trimmedMean(sortedValues, config.TrimProportion)
+		stats.WinsorizedMean = winsorizedMean(sortedValues, config.WinsorizeProportion)
+		stats.MAD = medianAbsoluteDeviation(sortedValues, stats.Median)
+		stats.Skewness, stats.Kurtosis = shape(sortedValues, stats.Mean)
+		for _, p := range config.Percentiles {
+			stats.Percentiles = append(stats.Percentiles, Percentile{P: p, Value: quantile(sortedValues, p/100)})
+		}
+
+		if config.Bootstrap > 0 {
+			// Samples of one recording are not independent, so like TTest and ANOVA a group spanning
+			// several participants resamples participant means; only a single participant's samples are
+			// resampled directly
+			units, unit := participantMeans(dataset.Points, col), "participant"
+			if len(units) < 2 {
+				units, unit = sortedValues, "sample"
+			}
+			// A fresh source per column keeps the intervals independent of the order groups are computed in
+			rng := rand.New(rand.NewSource(config.BootstrapSeed))
+			stats.Bootstrap = config.Bootstrap
+			stats.BootstrapUnit = unit
+			stats.MeanLower, stats.MeanUpper, stats.MedianLower, stats.MedianUpper = bootstrapIntervals(units, config.Bootstrap, config.ConfidenceLevel, rng)
+		}
+
+		// Outlier counting uses the same bounds as the cleaner
+		stats.OutlierMethod = config.OutlierMethod
+		if config.OutlierMethod == "zscore" {
+			stats.ZScoreThreshold = config.ZScoreThreshold
+		}
+		stats.OutlierLower, stats.OutlierUpper = cleaner.OutlierBounds(sortedValues, config.OutlierMethod, config.ZScoreThreshold)
+		for _, v := range sortedValues {
+			if v < stats.OutlierLower || v > stats.OutlierUpper {
+				stats.OutlierCount++
 			}
 		}
 
@@ -204,6 +546,13 @@ func (r *StatsReport) String() string {
 			sb.WriteString(fmt.Sprintf("  Mean: %.4f\n", stats.Mean))
 			sb.WriteString(fmt.Sprintf("  Median: %.4f\n", stats.Median))
 			sb.WriteString(fmt.Sprintf("  StdDev: %.4f\n", stats.StdDev))
+			sb.WriteString(fmt.Sprintf("  TrimmedMean: %.4f\n", stats.TrimmedMean))
+			sb.WriteString(fmt.Sprintf("  WinsorizedMean: %.4f\n", stats.WinsorizedMean))
+			sb.WriteString(fmt.Sprintf("  MAD: %.4f\n", stats.MAD))
+			sb.WriteString(fmt.Sprintf("  Skewness: %.4f\n", stats.Skewness))
+			sb.WriteString(fmt.Sprintf("  Kurtosis: %.4f\n", stats.Kurtosis))
+			writePercentiles(&sb, "  ", stats.Percentiles)
+			writeBootstrap(&sb, "  ", stats, r.ConfidenceLevel)
 			sb.WriteString(fmt.Sprintf("  Min: %.4f\n", stats.Min))
 			sb.WriteString(fmt.Sprintf("  Max: %.4f\n", stats.Max))
 			sb.WriteString(fmt.Sprintf("  Count: %d\n", stats.Count))
@@ -211,6 +560,7 @@ func (r *StatsReport) String() string {
 			sb.WriteString(fmt.Sprintf("  OutlierCount: %d\n", stats.OutlierCount))
 			sb.WriteString(fmt.Sprintf("  OutlierMethod: %s\n", stats.OutlierMethod))
 			sb.WriteString(fmt.Sprintf("  ZScoreThreshold: %.2f\n", stats.ZScoreThreshold))
+			sb.WriteString(fmt.Sprintf("  OutlierBounds: [%.4f, %.4f]\n", stats.OutlierLower, stats.OutlierUpper))
 		}
 		sb.WriteString("\n")
 	}
@@ -232,6 +582,13 @@ func (r *StatsReport) String() string {
 				sb.WriteString(fmt.Sprintf("    Mean: %.4f\n", colStats.Mean))
 				sb.WriteString(fmt.Sprintf("    Median: %.4f\n", colStats.Median))
 				sb.WriteString(fmt.Sprintf("    StdDev: %.4f\n", colStats.StdDev))
+				sb.WriteString(fmt.Sprintf("    TrimmedMean: %.4f\n", colStats.TrimmedMean))
+				sb.WriteString(fmt.Sprintf("    WinsorizedMean: %.4f\n", colStats.WinsorizedMean))
+				sb.WriteString(fmt.Sprintf("    MAD: %.4f\n", colStats.MAD))
+				sb.WriteString(fmt.Sprintf("    Skewness: %.4f\n", colStats.Skewness))
+				sb.WriteString(fmt.Sprintf("    Kurtosis: %.4f\n", colStats.Kurtosis))
+				writePercentiles(&sb, "    ", colStats.Percentiles)
+				writeBootstrap(&sb, "    ", colStats, r.ConfidenceLevel)
 				sb.WriteString(fmt.Sprintf("    Min: %.4f\n", colStats.Min))
 				sb.WriteString(fmt.Sprintf("    Max: %.4f\n", colStats.Max))
 				sb.WriteString(fmt.Sprintf("    Count: %d\n", colStats.Count))
@@ -239,6 +596,7 @@ func (r *StatsReport) String() string {
 				sb.WriteString(fmt.Sprintf("    OutlierCount: %d\n", colStats.OutlierCount))
 				sb.WriteString(fmt.Sprintf("    OutlierMethod: %s\n", colStats.OutlierMethod))
 				sb.WriteString(fmt.Sprintf("    ZScoreThreshold: %.2f\n", colStats.ZScoreThreshold))
+				sb.WriteString(fmt.Sprintf("    OutlierBounds: [%.4f, %.4f]\n", colStats.OutlierLower, colStats.OutlierUpper))
 			}
 			sb.WriteString("\n")
 		}
@@ -261,6 +619,13 @@ func (r *StatsReport) String() string {
 				sb.WriteString(fmt.Sprintf("    Mean: %.4f\n", colStats.Mean))
 				sb.WriteString(fmt.Sprintf("    Median: %.4f\n", colStats.Median))
 				sb.WriteString(fmt.Sprintf("    StdDev: %.4f\n", colStats.StdDev))
+				sb.WriteString(fmt.Sprintf("    TrimmedMean: %.4f\n", colStats.TrimmedMean))
+				sb.WriteString(fmt.Sprintf("    WinsorizedMean: %.4f\n", colStats.WinsorizedMean))
+				sb.WriteString(fmt.Sprintf("    MAD: %.4f\n", colStats.MAD))
+				sb.WriteString(fmt.Sprintf("    Skewness: %.4f\n", colStats.Skewness))
+				sb.WriteString(fmt.Sprintf("    Kurtosis: %.4f\n", colStats.Kurtosis))
+				writePercentiles(&sb, "    ", colStats.Percentiles)
+				writeBootstrap(&sb, "    ", colStats, r.ConfidenceLevel)
 				sb.WriteString(fmt.Sprintf("    Min: %.4f\n", colStats.Min))
 				sb.WriteString(fmt.Sprintf("    Max: %.4f\n", colStats.Max))
 				sb.WriteString(fmt.Sprintf("    Count: %d\n", colStats.Count))
@@ -268,22 +633,54 @@ func (r *StatsReport) String() string {
 				sb.WriteString(fmt.Sprintf("    OutlierCount: %d\n", colStats.OutlierCount))
 				sb.WriteString(fmt.Sprintf("    OutlierMethod: %s\n", colStats.OutlierMethod))
 				sb.WriteString(fmt.Sprintf("    ZScoreThreshold: %.2f\n", colStats.ZScoreThreshold))
+				sb.WriteString(fmt.Sprintf("    OutlierBounds: [%.4f, %.4f]\n", colStats.OutlierLower, colStats.OutlierUpper))
 			}
 			sb.WriteString("\n")
 		}
 	}
 
+	writeFrequencySections(&sb, r)
+	writeRadialSection(&sb, r)
+	writeTrackingSection(&sb, r)
+	writeGapSection(&sb, r)
+	writeGrandAverageSection(&sb, r)
+	writeSensitivitySection(&sb, r)
+	writeTTestSection(&sb, r)
+	writeANOVASection(&sb, r)
+	writeEffectSizeSection(&sb, r)
+	writeCoverageSection(&sb, r)
+	sb.WriteString(r.formatDiagnostics(false))
+
 	return sb.String()
 }
 
+func writePercentiles(sb *strings.Builder, indent string, percentiles []Percentile) {
+	for _, p := range percentiles {
+		sb.WriteString(fmt.Sprintf("%s%s: %.4f\n", indent, p.Label(), p.Value))
+	}
+}
+
 func SaveReport(report *StatsReport, outputPath string) error {
+	if isMarkdownPath(outputPath) || isLaTeXPath(outputPath) {
+		return SaveFormattedReport(report, outputPath, FormatOptions{})
+	}
+
+	return writeReportFile(outputPath, report.String())
+}
+
+func isMarkdownPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func writeReportFile(outputPath, content string) error {
 	f, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
 	}
 	defer f.Close()
 
-	_, err = f.WriteString(report.String())
+	_, err = f.WriteString(content)
 	if err != nil {
 		return fmt.Errorf("failed to write report to file: %v", err)
 	}
@@ -0,0 +1,308 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// TrackingConfig compares gaze with a target trajectory, e.g. for moving-target tasks
+type TrackingConfig struct {
+	X, Y             string
+	TargetX, TargetY string
+	TrialColumn      string  // Optional trial ID column; otherwise each participant/condition recording is one trial
+	MaxLag           float64 // Largest lag in seconds searched by the cross-correlation (default: 1)
+}
+
+// TrackingTrial holds the tracking error of one trial
+type TrackingTrial struct {
+	ParticipantID  string
+	Condition      string
+	Trial          string
+	Samples        int
+	MeanError      float64 // Mean Euclidean distance between gaze and target
+	RMSE           float64
+	Lag            float64 // Seconds gaze trails the target at maximum cross-correlation (negative = leads)
+	LagCorrelation float64
+}
+
+// TrackingSummary aggregates trials of one condition
+type TrackingSummary struct {
+	Condition string
+	Trials    int
+	MeanError float64 // Mean over trials
+	MeanRMSE  float64
+	SDRMSE    float64
+	MeanLag   float64
+}
+
+// TrackingSample is one point of the tracking error time series
+type TrackingSample struct {
+	Timestamp     float64
+	ParticipantID string
+	Condition     string
+	Trial         string
+	Error         float64
+}
+
+func computeTracking(points []types.DataPoint, config TrackingConfig) ([]TrackingTrial, []TrackingSummary, []TrackingSample) {
+	type trialKey struct{ participant, condition, trial string }
+	trials := make(map[trialKey][]types.DataPoint)
+	var order []trialKey
+	for _, p := range points {
+		k := trialKey{p.ParticipantID, p.Condition, ""}
+		if config.TrialColumn != "" {
+			id, ok := p.Data[config.TrialColumn]
+			if !ok || math.IsNaN(id) {
+				continue
+			}
+			k.trial = strconv.FormatFloat(id, 'f', -1, 64)
+		}
+		if _, ok := trials[k]; !ok {
+			order = append(order, k)
+		}
+		trials[k] = append(trials[k], p)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if a.condition != b.condition {
+			return a.condition < b.condition
+		}
+		if a.participant != b.participant {
+			return a.participant < b.participant
+		}
+		return naturalLess(a.trial, b.trial)
+	})
+
+	var results []TrackingTrial
+	var series []TrackingSample
+	for _, k := range order {
+		trialPoints := trials[k]
+		sort.SliceStable(trialPoints, func(i, j int) bool { return trialPoints[i].Timestamp < trialPoints[j].Timestamp })
+
+		var gx, gy, tx, ty, times []float64
+		var sum, sumSq float64
+		for _, p := range trialPoints {
+			x, ok1 := p.Data[config.X]
+			y, ok2 := p.Data[config.Y]
+			a, ok3 := p.Data[config.TargetX]
+			b, ok4 := p.Data[config.TargetY]
+			if !ok1 || !ok2 || !ok3 || !ok4 || math.IsNaN(x) || math.IsNaN(y) || math.IsNaN(a) || math.IsNaN(b) {
+				continue
+			}
+			e := math.Hypot(x-a, y-b)
+			sum += e
+			sumSq += e * e
+			gx, gy, tx, ty = append(gx, x), append(gy, y), append(tx, a), append(ty, b)
+			times = append(times, p.Timestamp)
+			series = append(series, TrackingSample{p.Timestamp, k.participant, k.condition, k.trial, e})
+		}
+		if len(gx) == 0 {
+			continue
+		}
+
+		t := TrackingTrial{
+			ParticipantID: k.participant,
+			Condition:     k.condition,
+			Trial:         k.trial,
+			Samples:       len(gx),
+			MeanError:     sum / float64(len(gx)),
+			RMSE:          math.Sqrt(sumSq / float64(len(gx))),
+		}
+		t.Lag, t.LagCorrelation = trackingLag(times, [][2][]float64{{gx, tx}, {gy, ty}}, config.MaxLag)
+		results = append(results, t)
+	}
+
+	byCondition := make(map[string][]TrackingTrial)
+	var conditions []string
+	for _, t := range results {
+		if _, ok := byCondition[t.Condition]; !ok {
+			conditions = append(conditions, t.Condition)
+		}
+		byCondition[t.Condition] = append(byCondition[t.Condition], t)
+	}
+	var summaries []TrackingSummary
+	for _, condition := range conditions {
+		s := TrackingSummary{Condition: condition, Trials: len(byCondition[condition])}
+		var rmse []float64
+		lags := 0
+		for _, t := range byCondition[condition] {
+			s.MeanError += t.MeanError
+			rmse = append(rmse, t.RMSE)
+			if !math.IsNaN(t.Lag) {
+				s.MeanLag += t.Lag
+				lags++
+			}
+		}
+		s.MeanError /= float64(s.Trials)
+		s.MeanRMSE = mean(rmse)
+		s.SDRMSE = sampleSD(rmse, s.MeanRMSE)
+		if lags > 0 {
+			s.MeanLag /= float64(lags)
+		} else {
+			s.MeanLag = math.NaN()
+		}
+		summaries = append(summaries, s)
+	}
+
+	return results, summaries, series
+}
+
+// trackingLag finds the lag (in seconds) maximizing the mean Pearson correlation between gaze and target
+// over the given (gaze, target) dimension pairs. Samples are assumed roughly evenly spaced; the lag step
+// is the median sampling interval. Dimensions where either signal is constant are ignored.
+func trackingLag(times []float64, dims [][2][]float64, maxLag float64) (float64, float64) {
+	if len(times) < 3 {
+		return math.NaN(), math.NaN()
+	}
+	intervals := make([]float64, 0, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		intervals = append(intervals, times[i]-times[i-1])
+	}
+	sort.Float64s(intervals)
+	dt := intervals[len(intervals)/2]
+	if dt <= 0 {
+		return math.NaN(), math.NaN()
+	}
+	if maxLag <= 0 {
+		maxLag = 1
+	}
+	maxShift := int(maxLag / dt)
+	if maxShift > len(times)/2 {
+		maxShift = len(times) / 2
+	}
+
+	bestLag, bestCorr := math.NaN(), math.Inf(-1)
+	for shift := -maxShift; shift <= maxShift; shift++ {
+		total, used := 0.0, 0
+		for _, d := range dims {
+			// gaze[i] is compared with target[i-shift]: positive shifts mean gaze trails the target
+			var g, tg []float64
+			for i := range d[0] {
+				j := i - shift
+				if j >= 0 && j < len(d[1]) {
+					g = append(g, d[0][i])
+					tg = append(tg, d[1][j])
+				}
+			}
+			if r, ok := pearson(g, tg); ok {
+				total += r
+				used++
+			}
+		}
+		if used == 0 {
+			continue
+		}
+		if corr := total / float64(used); corr > bestCorr {
+			bestLag, bestCorr = float64(shift)*dt, corr
+		}
+	}
+	if math.IsInf(bestCorr, -1) {
+		return math.NaN(), math.NaN()
+	}
+	return bestLag, bestCorr
+}
+
+func pearson(a, b []float64) (float64, bool) {
+	if len(a) < 2 {
+		return 0, false
+	}
+	ma, mb := mean(a), mean(b)
+	var sab, saa, sbb float64
+	for i := range a {
+		da, db := a[i]-ma, b[i]-mb
+		sab += da * db
+		saa += da * da
+		sbb += db * db
+	}
+	if saa == 0 || sbb == 0 {
+		return 0, false
+	}
+	return sab / math.Sqrt(saa*sbb), true
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func sampleSD(values []float64, m float64) float64 {
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	ss := 0.0
+	for _, v := range values {
+		ss += (v - m) * (v - m)
+	}
+	return math.Sqrt(ss / float64(len(values)-1))
+}
+
+// naturalLess orders numeric trial IDs numerically and others lexically
+func naturalLess(a, b string) bool {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
+
+func writeTrackingSection(sb *strings.Builder, report *StatsReport) {
+	if len(report.TrackingSummaries) == 0 {
+		return
+	}
+	cfg := report.TrackingConfig
+	sb.WriteString(fmt.Sprintf("Target Tracking (%s/%s vs %s/%s):\n", cfg.X, cfg.Y, cfg.TargetX, cfg.TargetY))
+	for _, s := range report.TrackingSummaries {
+		sb.WriteString(fmt.Sprintf("Condition: %s\n", conditionLabel(s.Condition)))
+		sb.WriteString(fmt.Sprintf("  Trials: %d\n", s.Trials))
+		sb.WriteString(fmt.Sprintf("  MeanError: %.4f\n", s.MeanError))
+		sb.WriteString(fmt.Sprintf("  MeanRMSE: %.4f\n", s.MeanRMSE))
+		sb.WriteString(fmt.Sprintf("  SDRMSE: %.4f\n", s.SDRMSE))
+		sb.WriteString(fmt.Sprintf("  MeanLag: %.4f s\n", s.MeanLag))
+	}
+	sb.WriteString("\n")
+}
+
+func conditionLabel(condition string) string {
+	if condition == "" {
+		return "unknown"
+	}
+	return condition
+}
+
+// SaveTrackingTrials writes the per-trial tracking error metrics as CSV
+func SaveTrackingTrials(report *StatsReport, filename string) error {
+	if len(report.TrackingTrials) == 0 {
+		return fmt.Errorf("report has no tracking analysis")
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', 6, 64) }
+	return writeDistributionCSV(filename, []string{"participant_id", "condition", "trial", "samples", "mean_error", "rmse", "lag", "lag_correlation"}, func(w *csv.Writer) {
+		for _, t := range report.TrackingTrials {
+			w.Write([]string{t.ParticipantID, t.Condition, t.Trial, strconv.Itoa(t.Samples), format(t.MeanError), format(t.RMSE), format(t.Lag), format(t.LagCorrelation)})
+		}
+	})
+}
+
+// SaveTrackingSeries writes the tracking error time series as CSV
+func SaveTrackingSeries(report *StatsReport, filename string) error {
+	if len(report.TrackingSeries) == 0 {
+		return fmt.Errorf("report has no tracking analysis")
+	}
+	return writeDistributionCSV(filename, []string{"timestamp", "participant_id", "condition", "trial", "error"}, func(w *csv.Writer) {
+		for _, s := range report.TrackingSeries {
+			w.Write([]string{strconv.FormatFloat(s.Timestamp, 'f', 6, 64), s.ParticipantID, s.Condition, s.Trial, strconv.FormatFloat(s.Error, 'f', 6, 64)})
+		}
+	})
+}
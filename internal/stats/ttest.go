@@ -0,0 +1,175 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// TTest compares one analyzed column between two conditions. The unit of analysis is the
+// participant: each participant's samples are averaged per condition first, since samples of one
+// recording are not independent observations.
+type TTest struct {
+	Column     string
+	Test       string // "welch" (independent groups) or "paired" (participants with data in both conditions)
+	ConditionA string
+	ConditionB string
+	NA, NB     int     // Participants in each condition (paired: both are the number of pairs)
+	MeanA      float64 // Mean of the participant means in ConditionA
+	MeanB      float64
+	MeanDiff   float64 // MeanA - MeanB
+	T          float64
+	DF         float64
+	P          float64 // Two-sided (NaN when the test can't be computed)
+}
+
+// computeTTests runs Welch's t-test between the two conditions for each column, and a paired t-test
+// when at least two participants have data in both
+func computeTTests(points []types.DataPoint, columns []string, conditionA, conditionB string) []TTest {
+	// Each participant's mean of each column per condition
+	type key struct{ participant, condition, column string }
+	sums := make(map[key]float64)
+	counts := make(map[key]int)
+	var participants []string
+	seen := make(map[string]bool)
+	for _, p := range points {
+		if p.Condition != conditionA && p.Condition != conditionB {
+			continue
+		}
+		if !seen[p.ParticipantID] {
+			seen[p.ParticipantID] = true
+			participants = append(participants, p.ParticipantID)
+		}
+		for _, col := range columns {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				k := key{p.ParticipantID, p.Condition, col}
+				sums[k] += v
+				counts[k]++
+			}
+		}
+	}
+	sort.Strings(participants)
+
+	var tests []TTest
+	for _, col := range columns {
+		var a, b, diffs []float64
+		for _, id := range participants {
+			ka, kb := key{id, conditionA, col}, key{id, conditionB, col}
+			if counts[ka] > 0 {
+				a = append(a, sums[ka]/float64(counts[ka]))
+			}
+			if counts[kb] > 0 {
+				b = append(b, sums[kb]/float64(counts[kb]))
+			}
+			if counts[ka] > 0 && counts[kb] > 0 {
+				diffs = append(diffs, sums[ka]/float64(counts[ka])-sums[kb]/float64(counts[kb]))
+			}
+		}
+
+		welch := TTest{Column: col, Test: "welch", ConditionA: conditionA, ConditionB: conditionB, NA: len(a), NB: len(b),
+			MeanA: mean(a), MeanB: mean(b), T: math.NaN(), DF: math.NaN(), P: math.NaN()}
+		welch.MeanDiff = welch.MeanA - welch.MeanB
+		if len(a) >= 2 && len(b) >= 2 {
+			va, vb := variance(a, welch.MeanA)/float64(len(a)), variance(b, welch.MeanB)/float64(len(b))
+			if se := math.Sqrt(va + vb); se > 0 {
+				welch.T = welch.MeanDiff / se
+				welch.DF = (va + vb) * (va + vb) / (va*va/float64(len(a)-1) + vb*vb/float64(len(b)-1))
+				welch.P = twoSidedP(welch.T, welch.DF)
+			}
+		}
+		tests = append(tests, welch)
+
+		if len(diffs) >= 2 {
+			paired := TTest{Column: col, Test: "paired", ConditionA: conditionA, ConditionB: conditionB, NA: len(diffs), NB: len(diffs),
+				T: math.NaN(), DF: float64(len(diffs) - 1), P: math.NaN()}
+			var pa, pb []float64
+			for _, id := range participants {
+				ka, kb := key{id, conditionA, col}, key{id, conditionB, col}
+				if counts[ka] > 0 && counts[kb] > 0 {
+					pa = append(pa, sums[ka]/float64(counts[ka]))
+					pb = append(pb, sums[kb]/float64(counts[kb]))
+				}
+			}
+			paired.MeanA, paired.MeanB = mean(pa), mean(pb)
+			paired.MeanDiff = mean(diffs)
+			if se := math.Sqrt(variance(diffs, paired.MeanDiff) / float64(len(diffs))); se > 0 {
+				paired.T = paired.MeanDiff / se
+				paired.P = twoSidedP(paired.T, paired.DF)
+			}
+			tests = append(tests, paired)
+		}
+	}
+	return tests
+}
+
+// variance is the sample variance (n - 1) of values around their mean m
+func variance(values []float64, m float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return sum / float64(len(values)-1)
+}
+
+// twoSidedP is the probability of a t statistic at least as extreme as t under the null hypothesis
+func twoSidedP(t, df float64) float64 {
+	return 2 * studentTCDF(-math.Abs(t), df)
+}
+
+// formatP writes a p-value for CSV with 6 significant digits, since small ones would round to 0 as decimals
+func formatP(p float64) string {
+	if math.IsNaN(p) {
+		return ""
+	}
+	return strconv.FormatFloat(p, 'g', 6, 64)
+}
+
+// Summary describes the test on one line, e.g. "Welch t(17.3) = 2.410, p = 0.02742"
+func (t TTest) Summary() string {
+	name := "Welch"
+	if t.Test == "paired" {
+		name = "Paired"
+	}
+	if math.IsNaN(t.T) {
+		return fmt.Sprintf("%s: not computed (n = %d, %d)", name, t.NA, t.NB)
+	}
+	return fmt.Sprintf("%s t(%.1f) = %.3f, p = %.4g", name, t.DF, t.T, t.P)
+}
+
+func writeTTestSection(sb *strings.Builder, report *StatsReport) {
+	if len(report.TTests) == 0 {
+		return
+	}
+	first := report.TTests[0]
+	sb.WriteString(fmt.Sprintf("T-Tests (%s vs %s, participant means):\n", first.ConditionA, first.ConditionB))
+	for _, t := range report.TTests {
+		sb.WriteString(fmt.Sprintf("Column: %s | %s | Means: %.4f vs %.4f (n = %d, %d) | Difference: %+.4f\n",
+			t.Column, t.Summary(), t.MeanA, t.MeanB, t.NA, t.NB, t.MeanDiff))
+	}
+	sb.WriteString("\n")
+}
+
+// SaveTTests writes the report's t-tests as CSV, one row per column and test
+func SaveTTests(report *StatsReport, filename string) error {
+	if len(report.TTests) == 0 {
+		return fmt.Errorf("report has no t-tests")
+	}
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	header := []string{"column", "test", "condition_a", "condition_b", "n_a", "n_b", "mean_a", "mean_b", "mean_diff", "t", "df", "p"}
+	return writeDistributionCSV(filename, header, func(w *csv.Writer) {
+		for _, t := range report.TTests {
+			w.Write([]string{t.Column, t.Test, t.ConditionA, t.ConditionB, strconv.Itoa(t.NA), strconv.Itoa(t.NB),
+				format(t.MeanA), format(t.MeanB), format(t.MeanDiff), format(t.T), format(t.DF), formatP(t.P)})
+		}
+	})
+}
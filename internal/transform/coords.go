@@ -0,0 +1,207 @@
+package transform
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Frame describes a device's 2D gaze coordinate convention.
+// In normalized units coordinates are fractions of the screen size, so a center-origin
+// normalized frame spans -0.5 to 0.5.
+type Frame struct {
+	Units  string // "normalized" or "pixels"
+	Origin string // "top-left", "bottom-left" or "center"
+	YUp    bool   // y grows upwards
+	Width  float64
+	Height float64 // Screen size in pixels, needed for pixel units
+}
+
+// CanonicalFrame is the frame datasets are normalized to: normalized units, origin top-left, y down
+var CanonicalFrame = Frame{Units: "normalized", Origin: "top-left"}
+
+// FrameMetadataKey records the coordinate frame of a dataset's gaze columns
+const FrameMetadataKey = "coordinate_frame"
+
+// ParseFrame reads a comma-separated frame spec such as "pixels,bottom-left" or "normalized,center,y-up".
+// Omitted parts default to normalized and top-left; y points down unless the origin is bottom-left.
+func ParseFrame(spec string) (Frame, error) {
+	frame := Frame{Units: "normalized", Origin: "top-left"}
+	yDirection := ""
+	for _, part := range strings.Split(spec, ",") {
+		switch part = strings.ToLower(strings.TrimSpace(part)); part {
+		case "":
+		case "normalized", "pixels":
+			frame.Units = part
+		case "top-left", "bottom-left", "center":
+			frame.Origin = part
+		case "y-up", "y-down":
+			yDirection = part
+		default:
+			return Frame{}, fmt.Errorf("unknown coordinate frame part %q (use normalized/pixels, top-left/bottom-left/center, y-up/y-down)", part)
+		}
+	}
+	frame.YUp = yDirection == "y-up" || (yDirection == "" && frame.Origin == "bottom-left")
+	return frame, nil
+}
+
+func (f Frame) String() string {
+	y := "y-down"
+	if f.YUp {
+		y = "y-up"
+	}
+	return f.Units + "," + f.Origin + "," + y
+}
+
+// origin returns the frame's origin in canonical coordinates
+func (f Frame) origin() (float64, float64) {
+	switch f.Origin {
+	case "center":
+		return 0.5, 0.5
+	case "bottom-left":
+		return 0, 1
+	default:
+		return 0, 0
+	}
+}
+
+func (f Frame) toCanonical(x, y float64) (float64, float64) {
+	if f.Units == "pixels" {
+		x, y = x/f.Width, y/f.Height
+	}
+	if f.YUp {
+		y = -y
+	}
+	ox, oy := f.origin()
+	return x + ox, y + oy
+}
+
+func (f Frame) fromCanonical(x, y float64) (float64, float64) {
+	ox, oy := f.origin()
+	x, y = x-ox, y-oy
+	if f.YUp {
+		y = -y
+	}
+	if f.Units == "pixels" {
+		x, y = x*f.Width, y*f.Height
+	}
+	return x, y
+}
+
+type CoordinateConfig struct {
+	Pairs [][2]string // (x, y) column pairs to convert
+	From  Frame       // Source frame; zero value = the frame recorded in the dataset metadata
+	To    Frame       // Target frame; zero value = CanonicalFrame
+}
+
+// NormalizeCoordinates converts gaze column pairs between coordinate frames and records the
+// resulting frame in the dataset metadata
+func NormalizeCoordinates(dataset *types.Dataset, config CoordinateConfig) (*types.Dataset, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, fmt.Errorf("dataset is empty")
+	}
+	if len(config.Pairs) == 0 {
+		return nil, fmt.Errorf("no coordinate columns to convert")
+	}
+
+	from := config.From
+	if from.Units == "" {
+		recorded, ok, err := RecordedFrame(dataset)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("source coordinate frame is not recorded in the dataset; specify it")
+		}
+		from = recorded
+	}
+	to := config.To
+	if to.Units == "" {
+		to = CanonicalFrame
+	}
+	for _, f := range []Frame{from, to} {
+		if f.Units == "pixels" && (f.Width <= 0 || f.Height <= 0) {
+			return nil, fmt.Errorf("pixel coordinates need the screen size")
+		}
+	}
+
+	points, err := mapPairs(dataset, config.Pairs, func(x, y float64) (float64, float64) {
+		return to.fromCanonical(from.toCanonical(x, y))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := copyMetadata(dataset.Metadata)
+	metadata[FrameMetadataKey] = to.String()
+	if to.Units == "pixels" {
+		metadata["screen_width"] = to.Width
+		metadata["screen_height"] = to.Height
+	}
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   dataset.Events,
+	}, nil
+}
+
+// RecordedFrame returns the coordinate frame stored in the dataset metadata, if any
+func RecordedFrame(dataset *types.Dataset) (Frame, bool, error) {
+	recorded, ok := dataset.Metadata[FrameMetadataKey].(string)
+	if !ok {
+		return Frame{}, false, nil
+	}
+	frame, err := ParseFrame(recorded)
+	if err != nil {
+		return Frame{}, false, err
+	}
+	frame.Width, _ = dataset.Metadata["screen_width"].(float64)
+	frame.Height, _ = dataset.Metadata["screen_height"].(float64)
+	return frame, true, nil
+}
+
+// Center returns the screen center in the frame's own coordinates
+func (f Frame) Center() (float64, float64) {
+	return f.fromCanonical(0.5, 0.5)
+}
+
+// mapPairs applies fn to every (x, y) column pair, copying points so the input dataset is unchanged
+func mapPairs(dataset *types.Dataset, pairs [][2]string, fn func(x, y float64) (float64, float64)) ([]types.DataPoint, error) {
+	columns := make(map[string]bool)
+	for _, col := range dataset.Columns {
+		columns[col] = true
+	}
+	for _, pair := range pairs {
+		if !columns[pair[0]] || !columns[pair[1]] {
+			return nil, fmt.Errorf("coordinate columns %s/%s not found", pair[0], pair[1])
+		}
+	}
+
+	points := make([]types.DataPoint, len(dataset.Points))
+	for i, point := range dataset.Points {
+		data := maps.Clone(point.Data)
+		for _, pair := range pairs {
+			x, okX := data[pair[0]]
+			y, okY := data[pair[1]]
+			if !okX || !okY {
+				continue
+			}
+			data[pair[0]], data[pair[1]] = fn(x, y)
+		}
+		points[i] = point
+		points[i].Data = data
+	}
+	return points, nil
+}
+
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(metadata)+1)
+	for key, value := range metadata {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package transform
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"mbdvr/internal/expr"
+)
+
+// ParseDerivation reads derived columns and filters from YAML: a list of steps, on its own or under a
+// "derive" key. Each step is a "column: expression" map, a "column = expression" string or a
+// "filter: expression" map; steps run in order, so a step sees the columns computed before it.
+func ParseDerivation(data []byte) ([]ScriptStep, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid derive config: %v", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("derive config is empty")
+	}
+	list := root.Content[0]
+	if list.Kind == yaml.MappingNode {
+		var found *yaml.Node
+		for i := 0; i+1 < len(list.Content); i += 2 {
+			if key := list.Content[i].Value; key != "derive" {
+				return nil, fmt.Errorf("line %d: unknown derive config key %q", list.Content[i].Line, key)
+			}
+			found = list.Content[i+1]
+		}
+		if found == nil {
+			return nil, fmt.Errorf("derive config is empty")
+		}
+		list = found
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: the derive config must be a list of steps", list.Line)
+	}
+
+	var steps []ScriptStep
+	for _, item := range list.Content {
+		step, err := parseDeriveStep(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", item.Line, err)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("derive config is empty")
+	}
+	return steps, nil
+}
+
+func parseDeriveStep(node *yaml.Node) (ScriptStep, error) {
+	switch {
+	case node.Kind == yaml.ScalarNode:
+		assignments, err := expr.ParseAssignments(node.Value)
+		if err != nil {
+			return ScriptStep{}, err
+		}
+		if len(assignments) != 1 {
+			return ScriptStep{}, fmt.Errorf("expected one 'column = expression' in %q", node.Value)
+		}
+		return ScriptStep{Assignment: &assignments[0]}, nil
+	case node.Kind == yaml.MappingNode && len(node.Content) == 2 && node.Content[1].Kind == yaml.ScalarNode:
+		key, value := node.Content[0].Value, node.Content[1].Value
+		e, err := expr.Compile(value)
+		if err != nil {
+			return ScriptStep{}, fmt.Errorf("%s: %v", key, err)
+		}
+		if key == "filter" {
+			return ScriptStep{Filter: e}, nil
+		}
+		return ScriptStep{Assignment: &expr.Assignment{Column: key, Expr: e}}, nil
+	}
+	return ScriptStep{}, fmt.Errorf("a step must be 'column: expression', 'column = expression' or 'filter: expression'")
+}
+
+// LoadDerivation reads a YAML derive config file
+func LoadDerivation(filename string) ([]ScriptStep, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read derive config: %v", err)
+	}
+	steps, err := ParseDerivation(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return steps, nil
+}
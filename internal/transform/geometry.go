@@ -0,0 +1,147 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"mbdvr/internal/types"
+)
+
+// Operation is one geometric transform of gaze coordinates, e.g. to fix a device mounted upside-down
+type Operation struct {
+	Kind string    // "flip-x", "flip-y", "swap", "rotate", "scale" or "offset"
+	Args []float64 // rotate: degrees counter-clockwise; scale: sx, sy; offset: dx, dy
+}
+
+func (op Operation) String() string {
+	parts := []string{op.Kind}
+	for _, arg := range op.Args {
+		parts = append(parts, strconv.FormatFloat(arg, 'g', -1, 64))
+	}
+	return strings.Join(parts, ":")
+}
+
+// ParseOperations reads a comma-separated list such as "flip-y,rotate:180,scale:2:2,offset:0.1:0"
+func ParseOperations(spec string) ([]Operation, error) {
+	var ops []Operation
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		op := Operation{Kind: strings.ToLower(fields[0])}
+		for _, field := range fields[1:] {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument %q in %s", field, part)
+			}
+			op.Args = append(op.Args, v)
+		}
+
+		var wantArgs []int
+		switch op.Kind {
+		case "flip-x", "flip-y", "swap":
+			wantArgs = []int{0}
+		case "rotate":
+			wantArgs = []int{1}
+		case "scale":
+			wantArgs = []int{1, 2}
+		case "offset":
+			wantArgs = []int{2}
+		default:
+			return nil, fmt.Errorf("unknown transform %q (use flip-x, flip-y, swap, rotate:DEG, scale:S[:SY], offset:DX:DY)", op.Kind)
+		}
+		valid := false
+		for _, n := range wantArgs {
+			valid = valid || len(op.Args) == n
+		}
+		if !valid {
+			return nil, fmt.Errorf("wrong number of arguments in %s", part)
+		}
+		if op.Kind == "scale" && len(op.Args) == 1 {
+			op.Args = append(op.Args, op.Args[0])
+		}
+
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// NeedsCenter reports whether any operation flips or rotates about a center point
+func NeedsCenter(ops []Operation) bool {
+	for _, op := range ops {
+		if op.Kind == "flip-x" || op.Kind == "flip-y" || op.Kind == "rotate" {
+			return true
+		}
+	}
+	return false
+}
+
+type GeometryConfig struct {
+	Pairs      [][2]string // (x, y) column pairs to transform
+	Operations []Operation // Applied in order
+	CenterX    float64     // Flips and rotations are about this point
+	CenterY    float64
+}
+
+// ApplyGeometry transforms gaze column pairs and records the operations in the dataset metadata
+func ApplyGeometry(dataset *types.Dataset, config GeometryConfig) (*types.Dataset, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, fmt.Errorf("dataset is empty")
+	}
+	if len(config.Pairs) == 0 {
+		return nil, fmt.Errorf("no coordinate columns to transform")
+	}
+
+	cx, cy := config.CenterX, config.CenterY
+	points, err := mapPairs(dataset, config.Pairs, func(x, y float64) (float64, float64) {
+		for _, op := range config.Operations {
+			switch op.Kind {
+			case "flip-x":
+				x = 2*cx - x
+			case "flip-y":
+				y = 2*cy - y
+			case "swap":
+				x, y = y, x
+			case "rotate":
+				// Counter-clockwise in a y-up frame
+				rad := op.Args[0] * math.Pi / 180
+				sin, cos := math.Sincos(rad)
+				dx, dy := x-cx, y-cy
+				x, y = cx+dx*cos-dy*sin, cy+dx*sin+dy*cos
+			case "scale":
+				x, y = x*op.Args[0], y*op.Args[1]
+			case "offset":
+				x, y = x+op.Args[0], y+op.Args[1]
+			}
+		}
+		return x, y
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := copyMetadata(dataset.Metadata)
+	var applied []string
+	if previous, ok := metadata["geometry_transforms"].([]interface{}); ok {
+		for _, p := range previous {
+			if s, ok := p.(string); ok {
+				applied = append(applied, s)
+			}
+		}
+	}
+	for _, op := range config.Operations {
+		applied = append(applied, op.String())
+	}
+	metadata["geometry_transforms"] = applied
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   dataset.Events,
+	}, nil
+}
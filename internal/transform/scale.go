@@ -0,0 +1,215 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+
+	"mbdvr/internal/types"
+)
+
+// ScaleMetadataKey lists the Scaling steps applied to the dataset, in order
+const ScaleMetadataKey = "scaling"
+
+// Scaling records how one column was scaled, overall or in one participant's data, as
+// scaled = (value - Center) / Scale. A column without spread scales to 0 and records Scale 0.
+type Scaling struct {
+	Column        string  `json:"column"`
+	ParticipantID string  `json:"participant_id,omitempty"` // Set when scaled per participant
+	Method        string  `json:"method"`
+	Center        float64 `json:"center"` // Mean (zscore) or minimum (minmax)
+	Scale         float64 `json:"scale"`  // Standard deviation (zscore) or range (minmax)
+}
+
+// Invert returns the original value of a scaled value
+func (s Scaling) Invert(v float64) float64 {
+	return v*s.Scale + s.Center
+}
+
+type ScaleConfig struct {
+	Columns        []string // Data columns to scale
+	Method         string   // "zscore" (mean 0, SD 1) or "minmax" (0 to 1)
+	PerParticipant bool     // Scale each participant's values separately, e.g. for pupil sizes
+}
+
+// ScaleColumns z-scores or min-max normalizes columns and appends the parameters used to the
+// dataset metadata, so UnscaleColumns can restore the original values
+func ScaleColumns(dataset *types.Dataset, config ScaleConfig) (*types.Dataset, []Scaling, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, nil, fmt.Errorf("dataset is empty")
+	}
+	if config.Method != "zscore" && config.Method != "minmax" {
+		return nil, nil, fmt.Errorf("unknown scaling method %q (use 'zscore' or 'minmax')", config.Method)
+	}
+	if len(config.Columns) == 0 {
+		return nil, nil, fmt.Errorf("no columns to scale")
+	}
+	known := make(map[string]bool, len(dataset.Columns))
+	for _, col := range dataset.Columns[1:] {
+		known[col] = true
+	}
+	for _, col := range config.Columns {
+		if !known[col] {
+			return nil, nil, fmt.Errorf("column %s not found", col)
+		}
+	}
+
+	group := func(p types.DataPoint) string { return "" }
+	if config.PerParticipant {
+		group = func(p types.DataPoint) string { return p.ParticipantID }
+	}
+	values := make(map[string]map[string][]float64) // group -> column -> values
+	for _, p := range dataset.Points {
+		key := group(p)
+		if values[key] == nil {
+			values[key] = make(map[string][]float64)
+		}
+		for _, col := range config.Columns {
+			if v, ok := p.Data[col]; ok && !math.IsNaN(v) {
+				values[key][col] = append(values[key][col], v)
+			}
+		}
+	}
+	groups := make([]string, 0, len(values))
+	for key := range values {
+		groups = append(groups, key)
+	}
+	sort.Strings(groups)
+
+	var scalings []Scaling
+	params := make(map[[2]string]Scaling)
+	for _, key := range groups {
+		for _, col := range config.Columns {
+			v := values[key][col]
+			if len(v) == 0 {
+				continue
+			}
+			s := Scaling{Column: col, ParticipantID: key, Method: config.Method}
+			if config.Method == "zscore" {
+				s.Center, s.Scale = meanSD(v)
+			} else {
+				lo, hi := v[0], v[0]
+				for _, x := range v {
+					lo, hi = math.Min(lo, x), math.Max(hi, x)
+				}
+				s.Center, s.Scale = lo, hi-lo
+			}
+			params[[2]string{key, col}] = s
+			scalings = append(scalings, s)
+		}
+	}
+
+	points := make([]types.DataPoint, len(dataset.Points))
+	for i, p := range dataset.Points {
+		data := maps.Clone(p.Data)
+		for _, col := range config.Columns {
+			v, ok := data[col]
+			if !ok || math.IsNaN(v) {
+				continue
+			}
+			s := params[[2]string{group(p), col}]
+			if s.Scale == 0 {
+				data[col] = 0
+			} else {
+				data[col] = (v - s.Center) / s.Scale
+			}
+		}
+		points[i] = p
+		points[i].Data = data
+	}
+
+	previous, err := RecordedScaling(dataset)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := copyMetadata(dataset.Metadata)
+	metadata[ScaleMetadataKey] = append(previous, scalings...)
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   dataset.Events,
+	}, scalings, nil
+}
+
+// UnscaleColumns restores the original values of every scaling recorded in the dataset metadata, most
+// recent first, and removes the record
+func UnscaleColumns(dataset *types.Dataset) (*types.Dataset, int, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, 0, fmt.Errorf("dataset is empty")
+	}
+	scalings, err := RecordedScaling(dataset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(scalings) == 0 {
+		return nil, 0, fmt.Errorf("no scaling is recorded in the dataset metadata")
+	}
+
+	points := make([]types.DataPoint, len(dataset.Points))
+	for i, p := range dataset.Points {
+		data := maps.Clone(p.Data)
+		for k := len(scalings) - 1; k >= 0; k-- {
+			s := scalings[k]
+			if s.ParticipantID != "" && s.ParticipantID != p.ParticipantID {
+				continue
+			}
+			if v, ok := data[s.Column]; ok && !math.IsNaN(v) {
+				data[s.Column] = s.Invert(v)
+			}
+		}
+		points[i] = p
+		points[i].Data = data
+	}
+
+	metadata := copyMetadata(dataset.Metadata)
+	delete(metadata, ScaleMetadataKey)
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  dataset.Columns,
+		Metadata: metadata,
+		Events:   dataset.Events,
+	}, len(scalings), nil
+}
+
+// RecordedScaling returns the scaling steps stored in the dataset metadata. Metadata loaded from a file
+// holds them as decoded JSON, so they are converted back to Scaling values.
+func RecordedScaling(dataset *types.Dataset) ([]Scaling, error) {
+	recorded, ok := dataset.Metadata[ScaleMetadataKey]
+	if !ok {
+		return nil, nil
+	}
+	if scalings, ok := recorded.([]Scaling); ok {
+		return append([]Scaling{}, scalings...), nil
+	}
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s metadata: %v", ScaleMetadataKey, err)
+	}
+	var scalings []Scaling
+	if err := json.Unmarshal(data, &scalings); err != nil {
+		return nil, fmt.Errorf("invalid %s metadata: %v", ScaleMetadataKey, err)
+	}
+	return scalings, nil
+}
+
+// meanSD returns the mean and sample standard deviation (0 for a single value)
+func meanSD(values []float64) (float64, float64) {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	m := sum / float64(len(values))
+	if len(values) < 2 {
+		return m, 0
+	}
+	ss := 0.0
+	for _, v := range values {
+		ss += (v - m) * (v - m)
+	}
+	return m, math.Sqrt(ss / float64(len(values)-1))
+}
@@ -0,0 +1,178 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"mbdvr/internal/expr"
+	"mbdvr/internal/types"
+)
+
+// pointEnv exposes a data point to expressions: its columns, plus timestamp, participant_id and condition.
+// The dataset's timestamp column (e.g. time_s) reads the point's Timestamp like the "timestamp" alias.
+// Columns that exist in the dataset but are missing in this point evaluate to NaN.
+type pointEnv struct {
+	point           *types.DataPoint
+	columns         map[string]bool
+	timestampColumn string
+}
+
+func (e pointEnv) Lookup(name string) (expr.Value, bool) {
+	if name == e.timestampColumn {
+		return expr.Number(e.point.Timestamp), true
+	}
+	switch name {
+	case "timestamp":
+		return expr.Number(e.point.Timestamp), true
+	case "participant_id":
+		return expr.String(e.point.ParticipantID), true
+	case "condition":
+		return expr.String(e.point.Condition), true
+	}
+	if v, ok := e.point.Data[name]; ok {
+		return expr.Number(v), true
+	}
+	if e.columns[name] {
+		return expr.Number(math.NaN()), true
+	}
+	return expr.Value{}, false
+}
+
+type ScriptConfig struct {
+	Assignments []expr.Assignment // Evaluated in order; later assignments see earlier results
+	Steps       []ScriptStep      // Columns and filters in any order, evaluated after Assignments
+	Filter      *expr.Expr        // Points are kept where this is true (nil = keep all)
+}
+
+// ScriptStep is a computed column or a filter; later steps only see the points a filter keeps
+type ScriptStep struct {
+	Assignment *expr.Assignment
+	Filter     *expr.Expr
+}
+
+// ApplyExpressions computes columns and filters points with user expressions, in one pass over the
+// points. A NaN result leaves the value missing. Assigning to an existing column replaces it; new columns
+// are appended.
+func ApplyExpressions(dataset *types.Dataset, config ScriptConfig) (*types.Dataset, int, error) {
+	if dataset == nil || len(dataset.Points) == 0 {
+		return nil, 0, fmt.Errorf("dataset is empty")
+	}
+	var steps []ScriptStep
+	for i := range config.Assignments {
+		steps = append(steps, ScriptStep{Assignment: &config.Assignments[i]})
+	}
+	steps = append(steps, config.Steps...)
+	if config.Filter != nil {
+		steps = append(steps, ScriptStep{Filter: config.Filter})
+	}
+
+	columns := append([]string{}, dataset.Columns...)
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+	for _, step := range steps {
+		if a := step.Assignment; a != nil && (a.Column == columns[0] || a.Column == "timestamp" || a.Column == "participant_id" || a.Column == "condition") {
+			return nil, 0, fmt.Errorf("cannot assign to %s", a.Column)
+		}
+	}
+
+	// Check every variable up front so typos fail before any point is processed
+	defined := make(map[string]bool, len(known))
+	for col := range known {
+		defined[col] = true
+	}
+	for _, name := range []string{"timestamp", "participant_id", "condition"} {
+		defined[name] = true
+	}
+	check := func(e *expr.Expr) error {
+		for _, name := range e.Variables() {
+			if !defined[name] {
+				return fmt.Errorf("unknown column %q in %q", name, e.String())
+			}
+		}
+		return nil
+	}
+	for _, step := range steps {
+		if step.Filter != nil {
+			if err := check(step.Filter); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if err := check(step.Assignment.Expr); err != nil {
+			return nil, 0, err
+		}
+		defined[step.Assignment.Column] = true
+	}
+
+	for _, step := range steps {
+		if a := step.Assignment; a != nil && !known[a.Column] {
+			columns = append(columns, a.Column)
+			known[a.Column] = true
+		}
+	}
+
+	var points []types.DataPoint
+	removed := 0
+points:
+	for _, point := range dataset.Points {
+		data := types.CloneData(point.Data, len(steps))
+		point.Data = data
+		env := pointEnv{point: &point, columns: known, timestampColumn: columns[0]}
+
+		for _, step := range steps {
+			if step.Filter != nil {
+				keep, err := step.Filter.Eval(env)
+				if err != nil {
+					return nil, 0, fmt.Errorf("filter at timestamp %v: %v", point.Timestamp, err)
+				}
+				if !keep.Truthy() {
+					removed++
+					continue points
+				}
+				continue
+			}
+
+			a := step.Assignment
+			v, err := a.Expr.Eval(env)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s at timestamp %v: %v", a.Column, point.Timestamp, err)
+			}
+			if v.IsStr {
+				return nil, 0, fmt.Errorf("%s at timestamp %v: expression returned string %q; columns must be numeric", a.Column, point.Timestamp, v.Str)
+			}
+			if math.IsNaN(v.Num) || math.IsInf(v.Num, 0) {
+				delete(data, a.Column)
+			} else {
+				data[a.Column] = v.Num
+			}
+		}
+		points = append(points, point)
+	}
+
+	metadata := copyMetadata(dataset.Metadata)
+	var applied, filters []string
+	for _, step := range steps {
+		if step.Filter != nil {
+			filters = append(filters, step.Filter.String())
+		} else {
+			applied = append(applied, step.Assignment.Column+" = "+step.Assignment.Expr.String())
+		}
+	}
+	if len(applied) > 0 {
+		metadata["expressions"] = applied
+	}
+	if len(filters) > 0 {
+		metadata["filter"] = strings.Join(filters, "; ")
+		metadata["filtered_points"] = removed
+	}
+
+	return &types.Dataset{
+		Points:   points,
+		Columns:  columns,
+		Metadata: metadata,
+		Events:   dataset.Events,
+	}, removed, nil
+}
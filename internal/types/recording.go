@@ -0,0 +1,29 @@
+package types
+
+import "sort"
+
+// RecordingIndices groups point indices by recording, i.e. by participant and condition, in order of
+// first appearance, each group in timestamp order. A participant's recordings of different
+// conditions usually each start at t=0, so grouping by participant alone would interleave them.
+func RecordingIndices(points []DataPoint) [][]int {
+	type recording struct{ participant, condition string }
+	groups := make(map[recording][]int)
+	var order []recording
+	for i, p := range points {
+		key := recording{p.ParticipantID, p.Condition}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	result := make([][]int, 0, len(order))
+	for _, key := range order {
+		idx := groups[key]
+		sort.SliceStable(idx, func(a, b int) bool {
+			return points[idx[a]].Timestamp < points[idx[b]].Timestamp
+		})
+		result = append(result, idx)
+	}
+	return result
+}
@@ -1,5 +1,7 @@
 package types
 
+import "maps"
+
 type DataPoint struct {
 	Timestamp     float64            `json:"timestamp"`
 	Data          map[string]float64 `json:"data"` // All columns as key-value pairs
@@ -11,4 +13,22 @@ type Dataset struct {
 	Points   []DataPoint            `json:"points"`
 	Columns  []string               `json:"columns"`
 	Metadata map[string]interface{} `json:"metadata"`
+	Events   []Event                `json:"events,omitempty"`
+}
+
+type Event struct {
+	Type          string             `json:"type"` // e.g. "MSG", "EFIX", "ESACC", "EBLINK"
+	Start         float64            `json:"start"`
+	End           float64            `json:"end"` // Same as Start for instantaneous events
+	Eye           string             `json:"eye,omitempty"`
+	Message       string             `json:"message,omitempty"`
+	Data          map[string]float64 `json:"data,omitempty"`
+	ParticipantID string             `json:"participant_id"`
+}
+
+// CloneData copies a point's Data with room for extra more columns, for stages that add columns to the copy
+func CloneData(data map[string]float64, extra int) map[string]float64 {
+	clone := make(map[string]float64, len(data)+extra)
+	maps.Copy(clone, data)
+	return clone
 }
@@ -0,0 +1,117 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// FileName is the metrics log written next to the study manifest when usage metrics are enabled
+const FileName = "mbdvr-usage.jsonl"
+
+// Record describes one successful command run. Records are only ever written to a local file.
+type Record struct {
+	Time       time.Time `json:"time"`
+	Study      string    `json:"study,omitempty"`
+	Command    string    `json:"command"`
+	Duration   float64   `json:"duration_seconds"`
+	InputBytes int64     `json:"input_bytes"`
+	Points     int       `json:"points"`
+}
+
+// Append adds a record to the metrics log, creating it if needed
+func Append(path string, record Record) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage metrics: %v", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage metrics: %v", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage metrics: %v", err)
+	}
+	return nil
+}
+
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage metrics: %v", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d of %s: %v", lineNum, path, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage metrics: %v", err)
+	}
+	return records, nil
+}
+
+// Summary aggregates the runs of one command within one study
+type Summary struct {
+	Study      string
+	Command    string
+	Runs       int
+	Duration   float64 // Total seconds
+	InputBytes int64
+	Points     int
+	First      time.Time
+	Last       time.Time
+}
+
+// Summarize groups records by study and command, keeping only runs at or after since
+func Summarize(records []Record, since time.Time) []Summary {
+	index := make(map[[2]string]int)
+	var summaries []Summary
+	for _, r := range records {
+		if r.Time.Before(since) {
+			continue
+		}
+		key := [2]string{r.Study, r.Command}
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, Summary{Study: r.Study, Command: r.Command, First: r.Time})
+		}
+		s := &summaries[i]
+		s.Runs++
+		s.Duration += r.Duration
+		s.InputBytes += r.InputBytes
+		s.Points += r.Points
+		if r.Time.Before(s.First) {
+			s.First = r.Time
+		}
+		if r.Time.After(s.Last) {
+			s.Last = r.Time
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Study != summaries[j].Study {
+			return summaries[i].Study < summaries[j].Study
+		}
+		return summaries[i].Command < summaries[j].Command
+	})
+	return summaries
+}
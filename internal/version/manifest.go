@@ -0,0 +1,83 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ManifestName is the study manifest file, looked up in the working directory and its parents
+const ManifestName = "mbdvr.json"
+
+// Manifest declares study-wide settings shared by everyone processing the study's data
+type Manifest struct {
+	Study         string `json:"study,omitempty"`
+	PinnedVersion string `json:"mbdvr_version,omitempty"` // Tool version the study was started with
+	UsageMetrics  bool   `json:"usage_metrics,omitempty"` // Log local-only run metrics next to the manifest
+	// Column name -> role overrides for column role inference, e.g. {"ET_PupilL": "left_pupil"}
+	ColumnRoles map[string]string `json:"column_roles,omitempty"`
+}
+
+// FindManifest returns the path of the nearest study manifest at or above dir, or "" if there is none
+func FindManifest(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, ManifestName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read study manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse study manifest %s: %v", path, err)
+	}
+	return &manifest, nil
+}
+
+func SaveManifest(path string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode study manifest: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write study manifest: %v", err)
+	}
+	return nil
+}
+
+// CheckPin returns a warning when the nearest study manifest pins a different tool version
+func CheckPin(dir string) (string, error) {
+	path, err := FindManifest(dir)
+	if err != nil || path == "" {
+		return "", err
+	}
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		return "", err
+	}
+	if manifest.PinnedVersion == "" || Same(manifest.PinnedVersion, Current()) {
+		return "", nil
+	}
+	study := path
+	if manifest.Study != "" {
+		study = manifest.Study + " (" + path + ")"
+	}
+	return fmt.Sprintf("study %s was started with mbdvr %s, but this is mbdvr %s; results may not be comparable",
+		study, manifest.PinnedVersion, Current()), nil
+}
@@ -0,0 +1,102 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is set at build time with -ldflags "-X mbdvr/internal/version.Version=v1.2.3"
+var Version = ""
+
+const releasesURL = "https://api.github.com/repos/mbd888/mbdvr/releases/latest"
+
+// Current returns the version of the running binary ("dev" for untagged builds)
+func Current() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// Latest asks GitHub for the most recent release tag
+func Latest() (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(releasesURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for updates: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to check for updates: %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to parse release information: %v", err)
+	}
+	return release.TagName, nil
+}
+
+// Compare orders two versions like "v1.2.3" and "1.10"; missing parts count as 0.
+// It returns -1, 0 or 1, and an error when either version is not numeric (e.g. "dev").
+func Compare(a, b string) (int, error) {
+	pa, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// Same reports whether two versions are equal, ignoring a "v" prefix and comparing text for non-numeric versions
+func Same(a, b string) bool {
+	if c, err := Compare(a, b); err == nil {
+		return c == 0
+	}
+	return strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
+
+func parse(v string) ([]int, error) {
+	core := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	// Pre-release and build suffixes are ignored
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	var parts []int
+	for _, field := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}